@@ -0,0 +1,229 @@
+package bins
+
+import (
+	"io"
+	"fmt"
+	"strings"
+	"strconv"
+	"encoding/csv"
+	"github.com/gin-gonic/gin"
+)
+
+// Card brands
+const (
+	VISA       = "visa"
+	MASTERCARD = "mastercard"
+	MIR        = "mir"
+	AMEX       = "amex"
+	UNIONPAY   = "unionpay"
+	JCB        = "jcb"
+)
+
+// Card types
+const (
+	DEBIT   = "debit"
+	CREDIT  = "credit"
+	PREPAID = "prepaid"
+)
+
+// Prefixes are lengths of BINs looked up, the longest first
+var Prefixes = []int{8, 7, 6}
+
+// Bin is the card metadata of the BIN. Country is ISO 3166-1 alpha-2 code
+type Bin struct {
+	Prefix  *string `json:"bin"`
+	Brand   *string `json:"brand"`
+	Type    *string `json:"type"`
+	Level   *string `json:"level"`
+	Issuer  *string `json:"issuer"`
+	Country *string `json:"country"`
+}
+
+// Brand will detect the brand of the PAN by IIN ranges. It is used for
+// cards which BIN is unknown
+func Brand(pan string) string {
+	prefix := func(n int) int {
+		if len(pan) < n {
+			return -1
+		}
+		v, err := strconv.Atoi(pan[:n])
+		if err != nil {
+			return -1
+		}
+		return v
+	}
+
+	switch p2, p4 := prefix(2), prefix(4); {
+	case p4 >= 2200 && p4 <= 2204:
+		return MIR
+	case p2 == 34 || p2 == 37:
+		return AMEX
+	case p4 >= 3528 && p4 <= 3589:
+		return JCB
+	case p2 == 62:
+		return UNIONPAY
+	case p2 >= 51 && p2 <= 55, p4 >= 2221 && p4 <= 2720:
+		return MASTERCARD
+	case strings.HasPrefix(pan, "4"):
+		return VISA
+	}
+
+	return ""
+}
+
+// Unknown will return metadata of the PAN which BIN is not in the database
+func Unknown(pan string) *Bin {
+	bin := pan
+	if len(bin) > 6 {
+		bin = bin[:6]
+	}
+
+	brand := Brand(pan)
+	return &Bin{
+		Prefix: &bin,
+		Brand:  &brand,
+	}
+}
+
+var columns = []string{"bin", "brand", "type", "level", "issuer", "country"}
+
+func optional(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// ParseCSV will parse BINs from CSV with the header. Columns are bin, brand,
+// type, level, issuer and country, bin and brand are required
+func ParseCSV(r io.Reader) (error, []*Bin) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("can not read header: %v", err), nil
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range columns[:2] {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("column %s is required", name), nil
+		}
+	}
+
+	var list []*Bin
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err), nil
+		}
+
+		values := map[string]*string{}
+		for _, name := range columns {
+			if i, ok := index[name]; ok && i < len(record) {
+				values[name] = optional(record[i])
+			}
+		}
+
+		bin := &Bin{
+			Prefix:  values["bin"],
+			Brand:   values["brand"],
+			Type:    values["type"],
+			Level:   values["level"],
+			Issuer:  values["issuer"],
+			Country: values["country"],
+		}
+
+		if err := bin.normalize(); err != nil {
+			return fmt.Errorf("line %d: %v", line, err), nil
+		}
+
+		list = append(list, bin)
+	}
+
+	return nil, list
+}
+
+func lower(value *string) *string {
+	if value == nil {
+		return nil
+	}
+
+	v := strings.ToLower(*value)
+	return &v
+}
+
+// normalize will validate the BIN and bring its values to the stored form
+func (b *Bin) normalize() error {
+	if b.Prefix == nil {
+		return fmt.Errorf("bin is required")
+	}
+
+	valid := false
+	for _, n := range Prefixes {
+		valid = valid || len(*b.Prefix) == n
+	}
+	if _, err := strconv.Atoi(*b.Prefix); err != nil || !valid {
+		return fmt.Errorf("bin %s must be of 6 to 8 digits", *b.Prefix)
+	}
+
+	if b.Brand == nil {
+		return fmt.Errorf("brand of bin %s is required", *b.Prefix)
+	}
+
+	b.Brand = lower(b.Brand)
+	b.Type = lower(b.Type)
+	b.Level = lower(b.Level)
+
+	if b.Type != nil && *b.Type != DEBIT && *b.Type != CREDIT && *b.Type != PREPAID {
+		return fmt.Errorf("type %s of bin %s is unknown", *b.Type, *b.Prefix)
+	}
+
+	if b.Country != nil {
+		country := strings.ToUpper(*b.Country)
+		if len(country) != 2 {
+			return fmt.Errorf("country %s of bin %s must be alpha-2 code", country, *b.Prefix)
+		}
+		b.Country = &country
+	}
+
+	return nil
+}
+
+// Matches will tell whether the metadata matches the brand, type and
+// country. Nil conditions match anything
+func (b *Bin) Matches(brand *string, cardType *string, country *string) bool {
+	equal := func(condition *string, value *string) bool {
+		return condition == nil || (value != nil && strings.EqualFold(*condition, *value))
+	}
+
+	return b != nil && equal(brand, b.Brand) && equal(cardType, b.Type) && equal(country, b.Country)
+}
+
+const contextKey = "bin"
+
+// Set will keep card metadata of the request for routers
+func Set(c *gin.Context, bin *Bin) {
+	c.Set(contextKey, bin)
+}
+
+// Get will return card metadata of the request or nil
+func Get(c *gin.Context) *Bin {
+	if value, ok := c.Get(contextKey); ok {
+		if bin, ok := value.(*Bin); ok {
+			return bin
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package bins
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func value(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return *s
+}
+
+// memoryBinStore keeps BINs by prefix, only lookup by PAN is supported
+type memoryBinStore struct {
+	bins map[string]*Bin
+	err  error
+}
+
+func (ms *memoryBinStore) Import(ctx interface{}, list []*Bin) (error, int) {
+	return fmt.Errorf("not implemented"), 0
+}
+
+func (ms *memoryBinStore) Delete(ctx interface{}, bin *Bin) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryBinStore) Query(ctx interface{}, specification BinSpecification) (error, int, []*Bin) {
+	if ms.err != nil {
+		return ms.err, 0, nil
+	}
+
+	_, args := specification.ToSqlClauses()
+	for _, prefix := range args[0].([]string) {
+		if bin, ok := ms.bins[prefix]; ok {
+			return nil, 1, []*Bin{bin}
+		}
+	}
+
+	return nil, 0, nil
+}
+
+func (ms *memoryBinStore) Attach(ctx interface{}, transactionId int, bin *Bin) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryBinStore) OfTransaction(ctx interface{}, transactionId int) (error, *Bin) {
+	return fmt.Errorf("not implemented"), nil
+}
+
+func TestBrand(t *testing.T) {
+	tests := []struct {
+		pan  string
+		want string
+	}{
+		{pan: "4111111111111111", want: VISA},
+		{pan: "5555555555554444", want: MASTERCARD},
+		{pan: "2221000000000009", want: MASTERCARD},
+		{pan: "2200000000000004", want: MIR},
+		{pan: "378282246310005", want: AMEX},
+		{pan: "3530111333300000", want: JCB},
+		{pan: "6200000000000005", want: UNIONPAY},
+		{pan: "9000000000000000", want: ""},
+		{pan: "4", want: VISA},
+		{pan: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pan, func(t *testing.T) {
+			if got := Brand(tt.pan); got != tt.want {
+				t.Errorf("Brand(%q) = %q, want %q", tt.pan, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	store := &memoryBinStore{bins: map[string]*Bin{
+		"411111":   {Prefix: str("411111"), Brand: str(VISA), Type: str(DEBIT)},
+		"41111122": {Prefix: str("41111122"), Brand: str(VISA), Type: str(CREDIT)},
+		"555555":   {Prefix: str("555555"), Brand: str(MASTERCARD)},
+	}}
+
+	tests := []struct {
+		name   string
+		pan    string
+		prefix string
+		brand  string
+		typ    string
+	}{
+		{name: "longest prefix first", pan: "4111112233334444", prefix: "41111122", brand: VISA, typ: CREDIT},
+		{name: "six digits prefix", pan: "4111111111111111", prefix: "411111", brand: VISA, typ: DEBIT},
+		{name: "unknown bin by number", pan: "2200000000000004", prefix: "220000", brand: MIR, typ: "<nil>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, bin := Lookup(nil, store, tt.pan)
+			if err != nil {
+				t.Fatalf("Lookup(%q) failed: %v", tt.pan, err)
+			}
+
+			if value(bin.Prefix) != tt.prefix || value(bin.Brand) != tt.brand || value(bin.Type) != tt.typ {
+				t.Errorf(
+					"Lookup(%q) = %s/%s/%s, want %s/%s/%s",
+					tt.pan,
+					value(bin.Prefix),
+					value(bin.Brand),
+					value(bin.Type),
+					tt.prefix,
+					tt.brand,
+					tt.typ,
+				)
+			}
+		})
+	}
+
+	if err, _ := Lookup(nil, &memoryBinStore{err: fmt.Errorf("db is down")}, "4111111111111111"); err == nil {
+		t.Errorf("store error is not returned")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		csv   string
+		err   string
+		count int
+		first Bin
+	}{
+		{
+			name:  "all columns normalized",
+			csv:   "BIN,Brand,Type,Level,Issuer,Country\n411111, VISA ,Debit,Classic,Bank,ru\n",
+			count: 1,
+			first: Bin{
+				Prefix:  str("411111"),
+				Brand:   str("visa"),
+				Type:    str(DEBIT),
+				Level:   str("classic"),
+				Issuer:  str("Bank"),
+				Country: str("RU"),
+			},
+		},
+		{
+			name:  "optional columns missing",
+			csv:   "bin,brand\n55555555,mastercard\n2200000,mir\n",
+			count: 2,
+			first: Bin{Prefix: str("55555555"), Brand: str(MASTERCARD)},
+		},
+		{
+			name:  "empty optional values",
+			csv:   "bin,brand,type,country\n411111,visa,,\n",
+			count: 1,
+			first: Bin{Prefix: str("411111"), Brand: str(VISA)},
+		},
+		{
+			name: "required column missing",
+			csv:  "bin,type\n411111,debit\n",
+			err:  "column brand is required",
+		},
+		{
+			name: "short bin",
+			csv:  "bin,brand\n41111,visa\n",
+			err:  "line 2: bin 41111 must be of 6 to 8 digits",
+		},
+		{
+			name: "not a number",
+			csv:  "bin,brand\n41111a,visa\n",
+			err:  "line 2: bin 41111a must be of 6 to 8 digits",
+		},
+		{
+			name: "unknown type",
+			csv:  "bin,brand,type\n411111,visa,debit\n411112,visa,gold\n",
+			err:  "line 3: type gold of bin 411112 is unknown",
+		},
+		{
+			name: "bad country",
+			csv:  "bin,brand,country\n411111,visa,RUS\n",
+			err:  "line 2: country RUS of bin 411111 must be alpha-2 code",
+		},
+		{
+			name: "brand missing",
+			csv:  "bin,brand\n411111,\n",
+			err:  "line 2: brand of bin 411111 is required",
+		},
+		{
+			name: "no header",
+			csv:  "",
+			err:  "can not read header: EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, list := ParseCSV(strings.NewReader(tt.csv))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseCSV failed: %v", err)
+			}
+
+			if len(list) != tt.count {
+				t.Fatalf("parsed %d bins, want %d", len(list), tt.count)
+			}
+
+			got := list[0]
+			for _, field := range []struct {
+				name      string
+				got, want *string
+			}{
+				{"bin", got.Prefix, tt.first.Prefix},
+				{"brand", got.Brand, tt.first.Brand},
+				{"type", got.Type, tt.first.Type},
+				{"level", got.Level, tt.first.Level},
+				{"issuer", got.Issuer, tt.first.Issuer},
+				{"country", got.Country, tt.first.Country},
+			} {
+				if value(field.got) != value(field.want) {
+					t.Errorf("%s = %s, want %s", field.name, value(field.got), value(field.want))
+				}
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	bin := &Bin{Prefix: str("411111"), Brand: str(VISA), Type: str(DEBIT), Country: str("RU")}
+
+	tests := []struct {
+		name     string
+		bin      *Bin
+		brand    *string
+		cardType *string
+		country  *string
+		want     bool
+	}{
+		{name: "no conditions", bin: bin, want: true},
+		{name: "all match", bin: bin, brand: str("VISA"), cardType: str(DEBIT), country: str("ru"), want: true},
+		{name: "brand differs", bin: bin, brand: str(MASTERCARD), want: false},
+		{name: "value unknown", bin: &Bin{Brand: str(VISA)}, cardType: str(DEBIT), want: false},
+		{name: "no bin", bin: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.bin.Matches(tt.brand, tt.cardType, tt.country); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package bins
+
+import (
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+type BinSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type binSpecificationByPrefix struct {
+	prefix string
+}
+
+func (bs *binSpecificationByPrefix) ToSqlClauses() (string, []interface{}) {
+	return "where prefix=$1", []interface{}{bs.prefix}
+}
+
+func NewBinSpecificationByPrefix(prefix string) BinSpecification {
+	return &binSpecificationByPrefix{prefix: prefix}
+}
+
+type binSpecificationByPAN struct {
+	pan string
+}
+
+func (bs *binSpecificationByPAN) ToSqlClauses() (string, []interface{}) {
+	var prefixes []string
+	for _, n := range Prefixes {
+		if len(bs.pan) >= n {
+			prefixes = append(prefixes, bs.pan[:n])
+		}
+	}
+
+	return "where prefix=any($1) order by length(prefix) desc limit 1", []interface{}{prefixes}
+}
+
+// NewBinSpecificationByPAN will select the longest BIN of the PAN
+func NewBinSpecificationByPAN(pan string) BinSpecification {
+	return &binSpecificationByPAN{pan: pan}
+}
+
+type binSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (bs *binSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by prefix limit $1 offset $2", []interface{}{bs.limit, bs.offset}
+}
+
+func NewBinSpecificationWithLimitAndOffset(limit int, offset int) BinSpecification {
+	return &binSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type binSpecificationByBrandWithLimitAndOffset struct {
+	brand  string
+	limit  int
+	offset int
+}
+
+func (bs *binSpecificationByBrandWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "where brand=$1 order by prefix limit $2 offset $3", []interface{}{bs.brand, bs.limit, bs.offset}
+}
+
+func NewBinSpecificationByBrandWithLimitAndOffset(brand string, limit int, offset int) BinSpecification {
+	return &binSpecificationByBrandWithLimitAndOffset{brand: brand, limit: limit, offset: offset}
+}
+
+type BinRepository interface {
+	// Import will add or replace BINs at once and return their number
+	Import(ctx interface{}, list []*Bin) (error, int)
+	Delete(ctx interface{}, bin *Bin) (error, bool)
+	Query(ctx interface{}, specification BinSpecification) (error, int, []*Bin)
+	// Attach will keep card metadata of the transaction as it was at
+	// the payment time
+	Attach(ctx interface{}, transactionId int, bin *Bin) error
+	// OfTransaction will return card metadata of the transaction or nil
+	OfTransaction(ctx interface{}, transactionId int) (error, *Bin)
+}
+
+type PGPoolBinStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (bs *PGPoolBinStore) Import(ctx interface{}, list []*Bin) (error, int) {
+	tx, err := bs.pool.Begin(tracing.ContextOf(ctx))
+	if err != nil {
+		return err, 0
+	}
+	defer tx.Rollback(tracing.ContextOf(ctx))
+
+	batch := &pgx.Batch{}
+	for _, bin := range list {
+		batch.Queue(
+			`insert into bins (prefix, brand, type, level, issuer, country) values ($1, $2, $3, $4, $5, $6)
+			on conflict (prefix) do update set
+				brand=excluded.brand,
+				type=excluded.type,
+				level=excluded.level,
+				issuer=excluded.issuer,
+				country=excluded.country`,
+			bin.Prefix,
+			bin.Brand,
+			bin.Type,
+			bin.Level,
+			bin.Issuer,
+			bin.Country,
+		)
+	}
+
+	results := tx.SendBatch(tracing.ContextOf(ctx), batch)
+	for i := range list {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return fmt.Errorf("bin %s: %v", *list[i].Prefix, err), 0
+		}
+	}
+
+	if err := results.Close(); err != nil {
+		return err, 0
+	}
+
+	return tx.Commit(tracing.ContextOf(ctx)), len(list)
+}
+
+func (bs *PGPoolBinStore) Delete(ctx interface{}, bin *Bin) (error, bool) {
+	ct, err := bs.pool.Exec(tracing.ContextOf(ctx), "delete from bins where prefix=$1", bin.Prefix)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("bin %s not found", *bin.Prefix), true
+	}
+
+	return nil, false
+}
+
+func (bs *PGPoolBinStore) Query(ctx interface{}, specification BinSpecification) (error, int, []*Bin) {
+	var list []*Bin
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := bs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf("select prefix, brand, type, level, issuer, country, count(*) over() from bins %s", where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bin := &Bin{}
+		if err := rows.Scan(
+			&bin.Prefix,
+			&bin.Brand,
+			&bin.Type,
+			&bin.Level,
+			&bin.Issuer,
+			&bin.Country,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		list = append(list, bin)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, list
+}
+
+func (bs *PGPoolBinStore) Attach(ctx interface{}, transactionId int, bin *Bin) error {
+	_, err := bs.pool.Exec(
+		tracing.ContextOf(ctx),
+		`insert into transaction_bins (transaction_id, prefix, brand, type, level, issuer, country)
+		values ($1, $2, $3, $4, $5, $6, $7) on conflict (transaction_id) do nothing`,
+		transactionId,
+		bin.Prefix,
+		bin.Brand,
+		bin.Type,
+		bin.Level,
+		bin.Issuer,
+		bin.Country,
+	)
+
+	return err
+}
+
+func (bs *PGPoolBinStore) OfTransaction(ctx interface{}, transactionId int) (error, *Bin) {
+	bin := &Bin{}
+
+	err := bs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`select prefix, brand, type, level, issuer, country from transaction_bins
+		where transaction_id=$1`,
+		transactionId,
+	).Scan(
+		&bin.Prefix,
+		&bin.Brand,
+		&bin.Type,
+		&bin.Level,
+		&bin.Issuer,
+		&bin.Country,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return err, nil
+	}
+
+	return nil, bin
+}
+
+// Lookup will return card metadata of the PAN. Brand of the PAN which BIN is
+// not in the database is detected by its number
+func Lookup(ctx interface{}, store BinRepository, pan string) (error, *Bin) {
+	err, _, list := store.Query(ctx, NewBinSpecificationByPAN(pan))
+	if err != nil {
+		return err, nil
+	}
+
+	if len(list) == 0 {
+		return nil, Unknown(pan)
+	}
+
+	return nil, list[0]
+}
+
+func NewPGPoolBinStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) BinRepository {
+	return &PGPoolBinStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
@@ -0,0 +1,265 @@
+package breaker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+const (
+	CLOSED   = "closed"
+	OPEN     = "open"
+	HALFOPEN = "halfopen"
+)
+
+// Defaults used until the breaker is configured and for settings left unset
+const (
+	DefaultFailures = 5
+	DefaultTimeout  = 30 * time.Second
+)
+
+var (
+	// @note: settings are changed on config reload, they are accessed
+	// atomically
+	failuresThreshold int64 = DefaultFailures
+	openTimeout             = int64(DefaultTimeout)
+
+	mu       sync.Mutex
+	breakers = make(map[int]*Breaker)
+)
+
+// Configure sets the number of consecutive failures which opens a circuit
+// and the time to wait before probing it again. Zero means the default, so
+// settings removed from config are reset on reload
+func Configure(failures int, timeout time.Duration) {
+	if failures <= 0 {
+		failures = DefaultFailures
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	atomic.StoreInt64(&failuresThreshold, int64(failures))
+	atomic.StoreInt64(&openTimeout, int64(timeout))
+}
+
+// probeAfter is the time the circuit stays open before the probe
+func probeAfter() time.Duration {
+	return time.Duration(atomic.LoadInt64(&openTimeout))
+}
+
+// Breaker is the circuit breaker of the account
+type Breaker struct {
+	mu        sync.Mutex
+	accountId int
+	state     string
+	failures  int
+	opened    time.Time
+	probing   bool
+	lastError string
+}
+
+// Status is the snapshot of the breaker state
+type Status struct {
+	AccountId int        `json:"account_id"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	Opened    *time.Time `json:"opened"`
+	LastError string     `json:"last_error"`
+}
+
+func (b *Breaker) String() string {
+	return fmt.Sprintf("circuit of account <%d>", b.accountId)
+}
+
+// Check will return an error if circuit is open and it is not time to probe.
+// It does not change breaker state
+func (b *Breaker) Check() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == OPEN && time.Since(b.opened) < probeAfter() {
+		return fmt.Errorf("%s is open: %s", b, b.lastError)
+	}
+
+	return nil
+}
+
+// Allow will return an error if request should not be made. After open
+// timeout one probe request is allowed while circuit is half open
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case OPEN:
+		if time.Since(b.opened) < probeAfter() {
+			return fmt.Errorf("%s is open: %s", b, b.lastError)
+		}
+		b.state = HALFOPEN
+		b.probing = true
+	case HALFOPEN:
+		if b.probing {
+			return fmt.Errorf("%s is half open, probe in progress", b)
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CLOSED
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	b.lastError = err.Error()
+
+	if b.state == HALFOPEN || int64(b.failures) >= atomic.LoadInt64(&failuresThreshold) {
+		b.state = OPEN
+		b.opened = time.Now()
+	}
+}
+
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CLOSED
+	b.failures = 0
+	b.probing = false
+	b.lastError = ""
+}
+
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := Status{
+		AccountId: b.accountId,
+		State:     b.state,
+		Failures:  b.failures,
+		LastError: b.lastError,
+	}
+
+	if b.state != CLOSED {
+		opened := b.opened
+		status.Opened = &opened
+	}
+
+	return status
+}
+
+// ForAccount will return the breaker of the account
+func ForAccount(accountId int) *Breaker {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if b, ok := breakers[accountId]; ok {
+		return b
+	}
+
+	b := &Breaker{
+		accountId: accountId,
+		state:     CLOSED,
+	}
+	breakers[accountId] = b
+
+	return b
+}
+
+// Available will return false if the circuit of the account is open
+func Available(accountId int) bool {
+	return ForAccount(accountId).Check() == nil
+}
+
+// Statuses will return states of all known breakers ordered by account id
+func Statuses() []Status {
+	mu.Lock()
+	list := make([]*Breaker, 0, len(breakers))
+	for _, b := range breakers {
+		list = append(list, b)
+	}
+	mu.Unlock()
+
+	statuses := make([]Status, 0, len(list))
+	for _, b := range list {
+		statuses = append(statuses, b.Status())
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].AccountId < statuses[j].AccountId
+	})
+
+	return statuses
+}
+
+type guardedKey struct{}
+
+// Guard will return the context of the new payment. Its requests fail fast
+// while the circuit is open. Operations on payments already made, like 3DS
+// completion, reversal and refund, are always sent to the bank
+func Guard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, guardedKey{}, true)
+}
+
+// Guarded tells whether requests of the context fail fast
+func Guarded(ctx context.Context) bool {
+	guarded, _ := ctx.Value(guardedKey{}).(bool)
+	return guarded
+}
+
+// Transport counts transport errors and 5xx responses as failures. Requests
+// of guarded contexts are not sent while the circuit is open
+type Transport struct {
+	Base    http.RoundTripper
+	Breaker *Breaker
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if Guarded(r.Context()) {
+		if err := t.Breaker.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	res, err := base.RoundTrip(r)
+	if err != nil {
+		t.Breaker.Failure(err)
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		t.Breaker.Failure(fmt.Errorf("%s %s responded %d", r.Method, r.URL.Host, res.StatusCode))
+	} else {
+		t.Breaker.Success()
+	}
+
+	return res, nil
+}
+
+func (t *Transport) CloseIdleConnections() {
+	if ci, ok := t.Base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
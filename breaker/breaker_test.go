@@ -0,0 +1,232 @@
+package breaker
+
+import (
+	"fmt"
+	"time"
+	"context"
+	"testing"
+	"net/http"
+	"sync/atomic"
+)
+
+// step is the action on the breaker: "fail", "success", "allow", "deny"
+// (allow expected to fail) or "expire" to let the open timeout pass
+type step string
+
+func run(t *testing.T, b *Breaker, steps []step) {
+	t.Helper()
+
+	for i, s := range steps {
+		switch s {
+		case "fail":
+			b.Failure(fmt.Errorf("bank is down"))
+		case "success":
+			b.Success()
+		case "allow":
+			if err := b.Allow(); err != nil {
+				t.Fatalf("step %d: request not allowed: %v", i, err)
+			}
+		case "deny":
+			if err := b.Allow(); err == nil {
+				t.Fatalf("step %d: request allowed", i)
+			}
+		case "expire":
+			b.opened = b.opened.Add(-probeAfter())
+		default:
+			t.Fatalf("step %d: unknown step %s", i, s)
+		}
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	Configure(3, 30 * time.Second)
+
+	tests := []struct {
+		name     string
+		steps    []step
+		state    string
+		failures int
+	}{
+		{
+			name:     "closed stays closed below threshold",
+			steps:    []step{"allow", "fail", "allow", "fail"},
+			state:    CLOSED,
+			failures: 2,
+		},
+		{
+			name:     "success resets failures",
+			steps:    []step{"fail", "fail", "success", "fail"},
+			state:    CLOSED,
+			failures: 1,
+		},
+		{
+			name:     "opens on threshold",
+			steps:    []step{"fail", "fail", "fail", "deny"},
+			state:    OPEN,
+			failures: 3,
+		},
+		{
+			name:     "half open after timeout allows one probe",
+			steps:    []step{"fail", "fail", "fail", "expire", "allow", "deny"},
+			state:    HALFOPEN,
+			failures: 3,
+		},
+		{
+			name:     "successful probe closes",
+			steps:    []step{"fail", "fail", "fail", "expire", "allow", "success", "allow"},
+			state:    CLOSED,
+			failures: 0,
+		},
+		{
+			name:     "failed probe opens again",
+			steps:    []step{"fail", "fail", "fail", "expire", "allow", "fail", "deny"},
+			state:    OPEN,
+			failures: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Breaker{accountId: 1, state: CLOSED}
+			run(t, b, tt.steps)
+
+			status := b.Status()
+			if status.State != tt.state {
+				t.Errorf("state = %s, want %s", status.State, tt.state)
+			}
+			if status.Failures != tt.failures {
+				t.Errorf("failures = %d, want %d", status.Failures, tt.failures)
+			}
+		})
+	}
+}
+
+func TestBreakerCheck(t *testing.T) {
+	Configure(1, 30 * time.Second)
+	defer Configure(0, 0)
+
+	b := &Breaker{accountId: 1, state: CLOSED}
+	if err := b.Check(); err != nil {
+		t.Fatalf("closed circuit checked: %v", err)
+	}
+
+	b.Failure(fmt.Errorf("bank is down"))
+	if err := b.Check(); err == nil {
+		t.Fatalf("open circuit passed the check")
+	}
+
+	// @note: check must not take the probe
+	b.opened = b.opened.Add(-probeAfter())
+	if err := b.Check(); err != nil {
+		t.Fatalf("expired circuit checked: %v", err)
+	}
+	if b.Status().State != OPEN {
+		t.Fatalf("check changed the state to %s", b.Status().State)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(0, 0)
+
+	tests := []struct {
+		name     string
+		failures int
+		timeout  time.Duration
+		want     int64
+		probe    time.Duration
+	}{
+		{name: "set", failures: 3, timeout: time.Minute, want: 3, probe: time.Minute},
+		{name: "zero is default", want: DefaultFailures, probe: DefaultTimeout},
+		{name: "failures only", failures: 10, want: 10, probe: DefaultTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Configure(7, time.Hour)
+			Configure(tt.failures, tt.timeout)
+
+			if got := atomic.LoadInt64(&failuresThreshold); got != tt.want {
+				t.Errorf("failures = %d, want %d", got, tt.want)
+			}
+
+			if got := probeAfter(); got != tt.probe {
+				t.Errorf("timeout = %v, want %v", got, tt.probe)
+			}
+		})
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransport(t *testing.T) {
+	Configure(5, 30 * time.Second)
+
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		failures int
+	}{
+		{name: "ok", status: http.StatusOK, failures: 0},
+		{name: "client error", status: http.StatusBadRequest, failures: 0},
+		{name: "server error", status: http.StatusBadGateway, failures: 1},
+		{name: "transport error", err: fmt.Errorf("connection refused"), failures: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Breaker{accountId: 1, state: CLOSED}
+			transport := &Transport{
+				Base: roundTripper(func(r *http.Request) (*http.Response, error) {
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &http.Response{StatusCode: tt.status}, nil
+				}),
+				Breaker: b,
+			}
+
+			r, _ := http.NewRequest("GET", "http://bank.test/", nil)
+			transport.RoundTrip(r)
+
+			if failures := b.Status().Failures; failures != tt.failures {
+				t.Errorf("failures = %d, want %d", failures, tt.failures)
+			}
+		})
+	}
+}
+
+func TestTransportGuarded(t *testing.T) {
+	Configure(1, 30 * time.Second)
+	defer Configure(0, 0)
+
+	sent := 0
+	b := &Breaker{accountId: 1, state: CLOSED}
+	transport := &Transport{
+		Base: roundTripper(func(r *http.Request) (*http.Response, error) {
+			sent++
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		}),
+		Breaker: b,
+	}
+	b.Failure(fmt.Errorf("bank is down"))
+
+	guarded, _ := http.NewRequestWithContext(Guard(context.Background()), "POST", "http://bank.test/", nil)
+	if _, err := transport.RoundTrip(guarded); err == nil || sent != 0 {
+		t.Fatalf("new payment is sent through open circuit")
+	}
+
+	// @note: reversal of the payment already made reaches the bank
+	r, _ := http.NewRequest("POST", "http://bank.test/", nil)
+	if _, err := transport.RoundTrip(r); err != nil || sent != 1 {
+		t.Fatalf("operation is not sent through open circuit: %v", err)
+	}
+
+	if b.Status().State != CLOSED {
+		t.Errorf("circuit is %s after successful response", b.Status().State)
+	}
+}
@@ -1,7 +1,283 @@
 package client
 
 import (
+	"io"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+	"context"
+	"sync/atomic"
+	"net/url"
+	"io/ioutil"
 	"net/http"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/sha256"
+	"encoding/json"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/metrics"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
 )
 
-var Client *http.Client
+// Client is the default HTTP client shared by accounts without own settings.
+// Requests are sent by the client given to Set, so holders of Client use
+// the one rebuilt on reload
+var Client = &http.Client{Transport: defaultTransport{}}
+
+var current atomic.Value
+
+// Default will return the client given to Set
+func Default() *http.Client {
+	if c, ok := current.Load().(*http.Client); ok {
+		return c
+	}
+
+	return http.DefaultClient
+}
+
+// Set will replace the default client at once. Cached clients of accounts
+// are dropped, so they are rebuilt with the config reloaded
+func Set(c *http.Client) {
+	old := Default()
+	current.Store(c)
+	old.CloseIdleConnections()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for id, cached := range cache {
+		if cached.hash != "default" {
+			cached.client.CloseIdleConnections()
+		}
+		delete(cache, id)
+	}
+}
+
+// cancelBody cancels the request timeout once the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (cb *cancelBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.cancel()
+	return err
+}
+
+// defaultTransport sends requests by the default client. Its timeout is
+// applied to every request including reading of the body
+type defaultTransport struct{}
+
+func (defaultTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c := Default()
+
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if c.Timeout <= 0 {
+		return transport.RoundTrip(r)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.Timeout)
+	res, err := transport.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+func (defaultTransport) CloseIdleConnections() {
+	Default().CloseIdleConnections()
+}
+
+// Settings of the account outbound HTTP client. It is read from
+// "http_client" key of account settings
+type Settings struct {
+	// Cert and Key are paths to PEM encoded client certificate and
+	// private key. Key material is never kept in account settings,
+	// since they are returned by the accounts API
+	Cert string `json:"cert_file"`
+	Key  string `json:"key_file"`
+
+	// Ca is the path to PEM encoded CA bundle. If set, only servers
+	// signed by these CAs are trusted
+	Ca string `json:"ca_file"`
+
+	// Proxy is the egress proxy URL. Environment proxy is used if empty
+	Proxy string `json:"proxy"`
+
+	// Timeouts in seconds. Config client timeouts are used if zero
+	Timeout struct {
+		Read    int `json:"read"`
+		Connect int `json:"connect"`
+	} `json:"timeout"`
+}
+
+type cachedClient struct {
+	hash   string
+	client *http.Client
+}
+
+var (
+	mu    sync.Mutex
+	cache = make(map[int]*cachedClient)
+)
+
+func settingsFromAccount(account *repository.Account) (error, *Settings) {
+	if account.Settings == nil {
+		return nil, nil
+	}
+
+	raw, ok := (*account.Settings)["http_client"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	jsonbody, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("can not marshal http client settings: %v", err), nil
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(jsonbody, &settings); err != nil {
+		return fmt.Errorf("can not decode http client settings: %v", err), nil
+	}
+
+	return nil, &settings
+}
+
+// hash will tell settings apart. Files are not looked at, certificates
+// rotated in place are picked up on config reload, since Set drops cached
+// clients
+func (s *Settings) hash() string {
+	jsonbody, _ := json.Marshal(s)
+	return fmt.Sprintf("%x", sha256.Sum256(jsonbody))
+}
+
+// New will return HTTP client built from config and account settings
+func New(cfg *config.Config, settings *Settings) (error, *http.Client) {
+	cfg.RLock()
+	readTimeout := cfg.Client.Timeout.Read * time.Second
+	connectTimeout := cfg.Client.Timeout.Connect * time.Second
+	cfg.RUnlock()
+
+	if settings.Timeout.Read > 0 {
+		readTimeout = time.Duration(settings.Timeout.Read) * time.Second
+	}
+
+	if settings.Timeout.Connect > 0 {
+		connectTimeout = time.Duration(settings.Timeout.Connect) * time.Second
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if settings.Cert != "" || settings.Key != "" {
+		cert, err := tls.LoadX509KeyPair(settings.Cert, settings.Key)
+		if err != nil {
+			return fmt.Errorf("can not load client certificate: %v", err), nil
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if settings.Ca != "" {
+		ca, err := ioutil.ReadFile(settings.Ca)
+		if err != nil {
+			return fmt.Errorf("can not read ca certificates: %v", err), nil
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("can not load ca certificates"), nil
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if settings.Proxy != "" {
+		proxyUrl, err := url.Parse(settings.Proxy)
+		if err != nil {
+			return fmt.Errorf("can not parse proxy url: %v", err), nil
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+
+	return nil, tracing.Instrument(metrics.Instrument(&http.Client{
+		Timeout: readTimeout,
+		Transport: &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout: connectTimeout,
+			}).DialContext,
+			TLSClientConfig: tlsConfig,
+			ForceAttemptHTTP2: true,
+			IdleConnTimeout: 90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}))
+}
+
+// ForAccount will return HTTP client of the account. Clients are cached per
+// account and rebuilt when account http client settings change. Every
+// client is guarded by the circuit breaker of the account
+func ForAccount(cfg *config.Config, account *repository.Account) (error, *http.Client) {
+	err, settings := settingsFromAccount(account)
+	if err != nil {
+		return err, nil
+	}
+
+	if account.Id == nil {
+		return nil, Client
+	}
+
+	// @note: accounts without own settings share default client transport
+	hash := "default"
+	if settings != nil {
+		hash = settings.hash()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if cached, ok := cache[*account.Id]; ok {
+		if cached.hash == hash {
+			return nil, cached.client
+		}
+		// @note: the default client is shared, only own clients are closed
+		if cached.hash != "default" {
+			cached.client.CloseIdleConnections()
+		}
+	}
+
+	httpClient := Client
+	if settings != nil {
+		err, httpClient = New(cfg, settings)
+		if err != nil {
+			return fmt.Errorf("can not build http client of account <%d>: %v", *account.Id, err), nil
+		}
+	}
+
+	accountClient := &http.Client{
+		Timeout: httpClient.Timeout,
+		Transport: &breaker.Transport{
+			Base:    httpClient.Transport,
+			Breaker: breaker.ForAccount(*account.Id),
+		},
+	}
+
+	cache[*account.Id] = &cachedClient{
+		hash:   hash,
+		client: accountClient,
+	}
+
+	return nil, accountClient
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"testing"
+	"net/http"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/metrics"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+// closingTransport counts closing of its idle connections
+type closingTransport struct {
+	closed int
+}
+
+func (ct *closingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (ct *closingTransport) CloseIdleConnections() {
+	ct.closed++
+}
+
+func account(id int, settings repository.AccountSettings) *repository.Account {
+	a := &repository.Account{Id: &id}
+	if settings != nil {
+		a.Settings = &settings
+	}
+
+	return a
+}
+
+func withProxy(proxy string) repository.AccountSettings {
+	return repository.AccountSettings{"http_client": map[string]interface{}{"proxy": proxy}}
+}
+
+func TestForAccount(t *testing.T) {
+	shared := &closingTransport{}
+	Set(&http.Client{Transport: shared})
+	cfg := &config.Config{}
+
+	get := func(a *repository.Account) *http.Client {
+		t.Helper()
+		err, c := ForAccount(cfg, a)
+		if err != nil {
+			t.Fatalf("ForAccount failed: %v", err)
+		}
+		return c
+	}
+
+	first := get(account(1, nil))
+	if get(account(1, nil)) != first {
+		t.Errorf("client of the account is not cached")
+	}
+
+	own := get(account(1, withProxy("http://proxy:3128")))
+	if own == first {
+		t.Errorf("client is not rebuilt when settings change")
+	}
+	if shared.closed != 0 {
+		t.Errorf("shared default client is closed when the account gets own settings")
+	}
+
+	if get(account(1, withProxy("http://proxy:3128"))) != own {
+		t.Errorf("client with own settings is not cached")
+	}
+
+	// @note: own client is replaced by the counting one to see it closed
+	owned := &closingTransport{}
+	cache[1].client = &http.Client{Transport: owned}
+	get(account(1, nil))
+	if owned.closed != 1 {
+		t.Errorf("own client is closed %d times when settings are removed, want 1", owned.closed)
+	}
+
+	cached := get(account(2, nil))
+	Set(&http.Client{Transport: &closingTransport{}})
+	if shared.closed != 1 {
+		t.Errorf("replaced default client is closed %d times, want 1", shared.closed)
+	}
+	if get(account(2, nil)) == cached {
+		t.Errorf("cached client is kept after the default client is set")
+	}
+}
+
+func TestCloseIdleConnections(t *testing.T) {
+	base := &closingTransport{}
+	tracing.Instrument(metrics.Instrument(&http.Client{Transport: base})).CloseIdleConnections()
+
+	if base.closed != 1 {
+		t.Errorf("base transport is closed %d times through instrumented client, want 1", base.closed)
+	}
+}
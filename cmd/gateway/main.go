@@ -1,12 +1,34 @@
 package main
 
 import (
+	"os"
+	"fmt"
 	"log"
-	"github.com/sirupsen/logrus"
+	"flag"
+	"time"
+	"context"
 	//"github.com/wk8/go-ordered-map"
 	"github.com/serg666/repository"
 	"github.com/serg666/gateway/client"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/gateway/journal"
+	"github.com/serg666/gateway/reconciliation"
+	"github.com/serg666/gateway/reports"
+	"github.com/serg666/gateway/rates"
+	"github.com/serg666/gateway/fees"
+	"github.com/serg666/gateway/ledger"
+	"github.com/serg666/gateway/disputes"
+	"github.com/serg666/gateway/webhooks"
+	"github.com/serg666/gateway/limits"
+	"github.com/serg666/gateway/risk"
+	"github.com/serg666/gateway/reviews"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/gateway/metrics"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/gateway/health"
+	"github.com/serg666/gateway/events"
 	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/logging"
 
 	"github.com/serg666/gateway/plugins"
 
@@ -16,9 +38,13 @@ import (
 
 	"github.com/serg666/gateway/plugins/channels/kvellbank"
 	"github.com/serg666/gateway/plugins/channels/alfabank"
+	"github.com/serg666/gateway/plugins/channels/remote"
 )
 
 func main() {
+	// @note: flags are parsed by config.ParseFlags
+	checkConfig := flag.Bool("check-config", false, "validate config and exit")
+
 	cfgPath, err := config.ParseFlags()
 	if err != nil {
 		log.Fatalf("can not parse flags due to: %v", err)
@@ -29,25 +55,46 @@ func main() {
 		log.Fatalf("can not get new config due to: %v", err)
 	}
 
+	if *checkConfig {
+		fmt.Printf("config %s is valid\n", *cfgPath)
+		os.Exit(0)
+	}
+
 	pgPool, err := repository.MakePgPoolFromDSN(cfg.Databases.Default.Dsn)
 	if err != nil {
 		log.Fatalf("Can not make pg pool: %v", err)
 	}
 
-	loggerFunc := func (c interface{}) logrus.FieldLogger {
-		return cfg.LogRusLogger(c)
+	if err := metrics.RegisterPool("default", pgPool); err != nil {
+		log.Fatalf("Can not register pg pool metrics: %v", err)
+	}
+
+	err, stopTracing := tracing.Setup(cfg)
+	if err != nil {
+		log.Fatalf("Can not setup tracing: %v", err)
+	}
+
+	err, logger := logging.New(cfg)
+	if err != nil {
+		log.Fatalf("Can not make logger: %v", err)
 	}
+	defer logger.Close()
 
-	client.Client = cfg.HttpClient()
+	loggerFunc := logger.Func
+
+	// @note: accounts without own http client settings share its
+	// instrumented transport
+	client.Set(tracing.Instrument(metrics.Instrument(cfg.HttpClient())))
+	breaker.Configure(cfg.Breaker.Failures, cfg.Breaker.Timeout * time.Second)
 
 	//currencyStore := repository.NewOrderedMapCurrencyStore(orderedmap.New(), loggerFunc)
 	currencyStore := repository.NewPGPoolCurrencyStore(pgPool, loggerFunc)
 	//profileStore := repository.NewOrderedMapProfileStore(orderedmap.New(), currencyStore, loggerFunc)
 	profileStore := repository.NewPGPoolProfileStore(pgPool, currencyStore, loggerFunc)
 	//sessionStore := repository.NewOrderedMapSessionStore(orderedmap.New(), loggerFunc)
-	sessionStore := repository.NewHttpClientSessionStore(cfg.CardStore.Url, client.Client, loggerFunc)
+	sessionStore := tracing.SessionStore(repository.NewHttpClientSessionStore(cfg.CardStore.Url, client.Client, loggerFunc))
 	//cardStore := repository.NewOrderedMapCardStore(orderedmap.New(), loggerFunc)
-	cardStore := repository.NewHttpClientCardStore(cfg.CardStore.Url, client.Client, loggerFunc)
+	cardStore := tracing.CardStore(repository.NewHttpClientCardStore(cfg.CardStore.Url, client.Client, loggerFunc))
 	channelStore := repository.NewPGPoolChannelStore(pgPool, loggerFunc)
 	accountStore := repository.NewPGPoolAccountStore(pgPool, currencyStore, channelStore, loggerFunc)
 	instrumentStore := repository.NewPGPoolInstrumentStore(pgPool, loggerFunc)
@@ -68,6 +115,27 @@ func main() {
 		currencyStore,
 		loggerFunc,
 	)
+	stateStore := plugins.NewPGPoolPluginStateStore(pgPool, loggerFunc)
+	journalStore := journal.NewPGPoolJournalStore(pgPool, loggerFunc)
+	reconciliationStore := reconciliation.NewPGPoolReconciliationStore(pgPool, loggerFunc)
+	reportStore := reports.NewPGPoolReportStore(pgPool, loggerFunc)
+	rateStore := rates.NewPGPoolRateStore(pgPool, currencyStore, loggerFunc)
+	feeStore := fees.NewPGPoolFeeStore(pgPool, loggerFunc)
+	ledgerStore := ledger.NewPGPoolLedgerStore(pgPool, loggerFunc)
+	webhookStore := webhooks.NewPGPoolWebhookStore(pgPool, loggerFunc)
+	limitStore := limits.NewPGPoolLimitStore(pgPool, loggerFunc)
+	riskStore := risk.NewPGPoolRiskStore(pgPool, loggerFunc)
+	reviewStore := reviews.NewPGPoolReviewStore(pgPool, loggerFunc)
+	binStore := bins.NewPGPoolBinStore(pgPool, loggerFunc)
+	disputeLifecycle := disputes.NewLifecycle(
+		disputes.NewPGPoolDisputeStore(pgPool, loggerFunc),
+		ledgerStore,
+		webhookStore,
+		cfg.Disputes.Deadlines,
+		loggerFunc,
+	)
+
+	journal.Store = journalStore
 
 	if visamaster.Registered != nil {
 		log.Fatalf("Can not register visamaster router: %v", visamaster.Registered)
@@ -85,11 +153,23 @@ func main() {
 		log.Fatalf("Can not register alfabank channel: %v", alfabank.Registered)
 	}
 
+	if alfabank.ReconciliationRegistered != nil {
+		log.Fatalf("Can not register alfabank settlement parser: %v", alfabank.ReconciliationRegistered)
+	}
+
+	if risk.Registered != nil {
+		log.Fatalf("Can not register risk rules: %v", risk.Registered)
+	}
+
+	if err := remote.RegisterBankChannels(cfg, loggerFunc); err != nil {
+		log.Fatalf("Can not register remote channels: %v", err)
+	}
+
 	if err := plugins.RegisterBankChannels(channelStore); err != nil {
 		log.Fatalf("Failed to register bank channels: %v", err)
 	}
 
-	if err := plugins.CheckBankChannels(channelStore); err != nil {
+	if err := plugins.CheckBankChannels(channelStore, stateStore); err != nil {
 		log.Fatalf("Failed to check bank channels: %v", err)
 	}
 
@@ -97,7 +177,7 @@ func main() {
 		log.Fatalf("Failed to register payment instruments: %v", err)
 	}
 
-	if err := plugins.CheckPaymentInstruments(instrumentStore); err != nil {
+	if err := plugins.CheckPaymentInstruments(instrumentStore, stateStore); err != nil {
 		log.Fatalf("Failed to check payment instruments: %v", err)
 	}
 
@@ -105,10 +185,22 @@ func main() {
 		log.Fatalf("Failed to register routers: %v", err)
 	}
 
-	if err := plugins.CheckRouters(routerStore); err != nil {
+	if err := plugins.CheckRouters(routerStore, stateStore); err != nil {
 		log.Fatalf("Failed to check routers: %v", err)
 	}
 
+	rates.Schedule(cfg.Rates.Feeds, rateStore, currencyStore, loggerFunc)
+	disputeLifecycle.Schedule(cfg.Disputes.Interval, transactionStore)
+
+	broker := events.NewBroker(pgPool, loggerFunc)
+	broker.Listen()
+
+	// @note: sessions are kept by the card store service as well
+	checker := health.NewChecker(cfg.Health.Timeout * time.Second)
+	checker.Add("postgres", health.Postgres(pgPool))
+	checker.Add("cardstore", health.HTTP(client.Client, cfg.CardStore.Url))
+	checker.Add("plugins", health.Plugins(routerStore, instrumentStore, channelStore, stateStore))
+
     handler := MakeHandler(
 		routeStore,
 		routerStore,
@@ -120,10 +212,38 @@ func main() {
 		cardStore,
 		transactionStore,
 		sessionStore,
+		stateStore,
+		journalStore,
+		reconciliationStore,
+		reportStore,
+		rateStore,
+		feeStore,
+		ledgerStore,
+		webhookStore,
+		disputeLifecycle,
+		limitStore,
+		riskStore,
+		reviewStore,
+		binStore,
+		broker,
+		checker,
 		cfg,
 		loggerFunc,
     )
 
+	config.Watch(cfgPath, cfg.Reload.Interval * time.Second, func() {
+		reload(cfgPath, cfg, logger)
+	})
+
 	// Run the server
-	cfg.RunServer(handler, loggerFunc)
+	cfg.RunServer(handler, loggerFunc, checker.Drain, broker.Close)
+
+	remote.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.Timeout.Server * time.Second)
+	defer cancel()
+
+	if err := stopTracing(ctx); err != nil {
+		log.Printf("Can not flush traces: %v", err)
+	}
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+	"github.com/serg666/gateway/client"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/gateway/metrics"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/logging"
+)
+
+// reload will apply the changed config file to the running gateway. The
+// config is refused as a whole if it is invalid or changes sections which
+// need the restart, so the gateway is never left half reloaded
+func reload(cfgPath *string, cfg *config.Config, logger *logging.Logger) {
+	log := logger.Func(nil)
+
+	next, err := config.NewConfig(cfgPath)
+	if err != nil {
+		log.Errorf("Config is not reloaded: %v", err)
+		return
+	}
+
+	err, changes := cfg.Changes(next)
+	if err != nil {
+		log.Errorf("Config is not reloaded: %v", err)
+		return
+	}
+
+	if len(changes) == 0 {
+		log.Printf("Config is reloaded, nothing is changed")
+		return
+	}
+
+	// @note: loggers are the only part which may fail to be made, so they
+	// are replaced first
+	if err := logger.Reload(next); err != nil {
+		log.Errorf("Config is not reloaded: can not make logger: %v", err)
+		return
+	}
+
+	// @note: config is applied first, so account clients rebuilt once the
+	// cache is dropped get the new settings
+	cfg.Apply(next)
+	client.Set(tracing.Instrument(metrics.Instrument(next.HttpClient())))
+	breaker.Configure(next.Breaker.Failures, next.Breaker.Timeout * time.Second)
+
+	log = logger.Func(nil)
+	for _, change := range changes {
+		log.Printf("Config is reloaded, %s", change)
+	}
+}
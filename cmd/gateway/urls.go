@@ -1,6 +1,7 @@
 package main
 
 import (
+	"time"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin/binding"
@@ -9,6 +10,22 @@ import (
 	"github.com/serg666/gateway/config"
 	"github.com/serg666/gateway/middlewares"
 	"github.com/serg666/gateway/handlers"
+	"github.com/serg666/gateway/journal"
+	"github.com/serg666/gateway/plugins"
+	"github.com/serg666/gateway/reconciliation"
+	"github.com/serg666/gateway/reports"
+	"github.com/serg666/gateway/rates"
+	"github.com/serg666/gateway/fees"
+	"github.com/serg666/gateway/ledger"
+	"github.com/serg666/gateway/disputes"
+	"github.com/serg666/gateway/webhooks"
+	"github.com/serg666/gateway/limits"
+	"github.com/serg666/gateway/risk"
+	"github.com/serg666/gateway/reviews"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/gateway/metrics"
+	"github.com/serg666/gateway/health"
+	"github.com/serg666/gateway/events"
 	"github.com/serg666/repository"
 )
 
@@ -23,6 +40,21 @@ func MakeHandler(
 	cardStore repository.CardRepository,
 	transactionStore repository.TransactionRepository,
 	sessionStore repository.SessionRepository,
+	stateStore plugins.PluginStateRepository,
+	journalStore journal.JournalRepository,
+	reconciliationStore reconciliation.ReconciliationRepository,
+	reportStore reports.ReportRepository,
+	rateStore rates.RateRepository,
+	feeStore fees.FeeRepository,
+	ledgerStore ledger.LedgerRepository,
+	webhookStore webhooks.WebhookRepository,
+	disputeLifecycle *disputes.Lifecycle,
+	limitStore limits.LimitRepository,
+	riskStore risk.RiskRepository,
+	reviewStore reviews.ReviewRepository,
+	binStore bins.BinRepository,
+	broker *events.Broker,
+	checker *health.Checker,
 	cfg *config.Config,
 	loggerFunc repository.LoggerFunc,
 ) *gin.Engine {
@@ -34,11 +66,38 @@ func MakeHandler(
 		routerStore,
 		currencyStore,
 		channelStore,
+		stateStore,
 		loggerFunc,
 	)
-	accountHandler := handlers.NewAccountHandler(accountStore, currencyStore, channelStore, loggerFunc)
+	accountHandler := handlers.NewAccountHandler(accountStore, currencyStore, channelStore, stateStore, loggerFunc)
 	profileHandler := handlers.NewProfileHandler(profileStore, currencyStore, loggerFunc)
 	currencyHandler := handlers.NewCurrencyHandler(currencyStore, loggerFunc)
+	pluginHandler := handlers.NewPluginHandler(routerStore, instrumentStore, channelStore, stateStore, loggerFunc)
+	breakerHandler := handlers.NewBreakerHandler(accountStore, loggerFunc)
+	healthHandler := handlers.NewHealthHandler(checker, loggerFunc)
+	eventHandler := handlers.NewEventHandler(
+		profileStore,
+		transactionStore,
+		broker,
+		cfg.Events.Heartbeat,
+		loggerFunc,
+	)
+	journalHandler := handlers.NewJournalHandler(transactionStore, journalStore, loggerFunc)
+	reconciliationHandler := handlers.NewReconciliationHandler(channelStore, reconciliationStore, loggerFunc)
+	reportHandler := handlers.NewReportHandler(reportStore, loggerFunc)
+	rateHandler := handlers.NewRateHandler(rateStore, currencyStore, loggerFunc)
+	feeHandler := handlers.NewFeeHandler(feeStore, profileStore, accountStore, loggerFunc)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerStore, profileStore, currencyStore, loggerFunc)
+	webhookHandler := handlers.NewWebhookHandler(webhookStore, profileStore, loggerFunc)
+	limitHandler := handlers.NewLimitHandler(limitStore, profileStore, accountStore, routeStore, loggerFunc)
+	riskHandler := handlers.NewRiskHandler(riskStore, profileStore, loggerFunc)
+	binHandler := handlers.NewBinHandler(binStore, loggerFunc)
+	disputeHandler := handlers.NewDisputeHandler(
+		disputeLifecycle,
+		transactionStore,
+		cfg.Disputes.EvidenceDir,
+		loggerFunc,
+	)
 	transactionHandler := handlers.NewTransactionHandler(
 		routeStore,
 		routerStore,
@@ -50,9 +109,22 @@ func MakeHandler(
 		cardStore,
 		transactionStore,
 		sessionStore,
+		stateStore,
+		rateStore,
+		feeStore,
+		ledgerStore,
+		limitStore,
+		riskStore,
+		reviewStore,
+		binStore,
 		cfg,
 		loggerFunc,
 	)
+	reviewHandler := handlers.NewReviewHandler(reviewStore, transactionHandler, loggerFunc)
+
+	// @note: overdue reviews are declined with the bank channels of
+	// the transaction handler
+	reviewHandler.Schedule(cfg.Reviews.Interval * time.Second)
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("iscvv", func(fl validator.FieldLevel) bool {
@@ -75,10 +147,16 @@ func MakeHandler(
 
 	handler.Use(
 		requestid.New(),
-		middlewares.Logger(loggerFunc),
+		middlewares.Logger(loggerFunc, "/healthz", "/readyz"),
+		middlewares.Metrics(),
+		middlewares.Tracing(cfg.Tracing.Service),
 		gin.Recovery(),
 	)
 
+	handler.GET("/metrics", metrics.Handler())
+	handler.GET("/healthz", healthHandler.HealthzHandler)
+	handler.GET("/readyz", healthHandler.ReadyzHandler)
+
 	// @note: payment interface
 	handler.POST("/profiles/:pid/transactions/authorize/card", transactionHandler.CardAuthorizeHandler)
 	handler.POST("/profiles/:pid/transactions/preauthorize/card", transactionHandler.CardPreAuthorizeHandler)
@@ -90,6 +168,8 @@ func MakeHandler(
 	handler.POST("/profiles/:pid/transactions/:tid/processcres", transactionHandler.ProcessCresHandler)
 	handler.POST("/profiles/:pid/transactions/:tid/processpares", transactionHandler.ProcessParesHandler)
 	handler.GET("/profiles/:pid/transactions/:tid", transactionHandler.GetTransactionHandler)
+	handler.GET("/profiles/:pid/transactions/:tid/events", eventHandler.TransactionEventsHandler)
+	handler.GET("/profiles/:pid/events", eventHandler.ProfileEventsHandler)
 
 	// @note: admin interface (should be moved to another web service)
 	handler.POST("/routes", routeHandler.CreateRouteHandler)
@@ -116,5 +196,69 @@ func MakeHandler(
 	handler.GET("/currencies/:id", currencyHandler.GetCurrencyHandler)
 	handler.PATCH("/currencies/:id", currencyHandler.PatchCurrencyHandler)
 
+	handler.POST("/rates", rateHandler.CreateRateHandler)
+	handler.POST("/rates/import", rateHandler.ImportRatesHandler)
+	handler.GET("/rates", rateHandler.GetRatesHandler)
+	handler.GET("/rates/:id", rateHandler.GetRateHandler)
+	handler.DELETE("/rates/:id", rateHandler.DeleteRateHandler)
+	handler.GET("/effectiverate", rateHandler.GetEffectiveRateHandler)
+	handler.POST("/feeplans", feeHandler.CreateFeePlanHandler)
+	handler.GET("/feeplans", feeHandler.GetFeePlansHandler)
+	handler.GET("/feeplans/:id", feeHandler.GetFeePlanHandler)
+	handler.DELETE("/feeplans/:id", feeHandler.DeleteFeePlanHandler)
+	handler.POST("/feeplans/:id/rules", feeHandler.CreateFeeRuleHandler)
+	handler.DELETE("/feeplans/:id/rules/:rid", feeHandler.DeleteFeeRuleHandler)
+	handler.GET("/profiles/:pid/feeplan", feeHandler.GetProfileFeePlanHandler)
+	handler.PUT("/profiles/:pid/feeplan", feeHandler.AssignFeePlanHandler)
+	handler.DELETE("/profiles/:pid/feeplan", feeHandler.UnassignFeePlanHandler)
+	handler.GET("/profiles/:pid/balance", ledgerHandler.GetBalanceHandler)
+	handler.GET("/profiles/:pid/statement", ledgerHandler.GetStatementHandler)
+	handler.GET("/profiles/:pid/webhook", webhookHandler.GetWebhookHandler)
+	handler.PUT("/profiles/:pid/webhook", webhookHandler.SetWebhookHandler)
+	handler.DELETE("/profiles/:pid/webhook", webhookHandler.DeleteWebhookHandler)
+	handler.POST("/transactions/:tid/disputes", disputeHandler.OpenDisputeHandler)
+	handler.GET("/transactions/:tid/disputes", disputeHandler.GetTransactionDisputesHandler)
+	handler.GET("/disputes", disputeHandler.GetDisputesHandler)
+	handler.GET("/disputes/:id", disputeHandler.GetDisputeHandler)
+	handler.POST("/disputes/:id/transition", disputeHandler.TransitDisputeHandler)
+	handler.POST("/disputes/:id/evidence", disputeHandler.AddEvidenceHandler)
+	handler.GET("/disputes/:id/evidence/:eid", disputeHandler.GetEvidenceHandler)
+	handler.POST("/limits", limitHandler.CreateLimitHandler)
+	handler.GET("/limits", limitHandler.GetLimitsHandler)
+	handler.GET("/limits/:id", limitHandler.GetLimitHandler)
+	handler.GET("/limits/:id/usage", limitHandler.GetLimitUsageHandler)
+	handler.DELETE("/limits/:id", limitHandler.DeleteLimitHandler)
+	handler.POST("/risk/rules", riskHandler.CreateRuleHandler)
+	handler.GET("/risk/rules", riskHandler.GetRulesHandler)
+	handler.DELETE("/risk/rules/:id", riskHandler.DeleteRuleHandler)
+	handler.POST("/risk/blocklist", riskHandler.BlockHandler)
+	handler.GET("/risk/blocklist", riskHandler.GetBlocklistHandler)
+	handler.DELETE("/risk/blocklist/:id", riskHandler.UnblockHandler)
+	handler.GET("/risk/assessments", riskHandler.GetAssessmentsHandler)
+	handler.GET("/reviews", reviewHandler.GetReviewsHandler)
+	handler.GET("/reviews/:id", reviewHandler.GetReviewHandler)
+	handler.POST("/reviews/:id/approve", reviewHandler.ApproveReviewHandler)
+	handler.POST("/reviews/:id/reject", reviewHandler.RejectReviewHandler)
+	handler.POST("/bins/import", binHandler.ImportBinsHandler)
+	handler.GET("/bins", binHandler.GetBinsHandler)
+	handler.GET("/bins/:bin", binHandler.GetBinHandler)
+	handler.DELETE("/bins/:bin", binHandler.DeleteBinHandler)
+
+	handler.GET("/plugins", pluginHandler.GetPluginsHandler)
+	handler.GET("/plugins/states", pluginHandler.GetPluginStatesHandler)
+	handler.POST("/plugins/:kind/:id/retire", pluginHandler.RetirePluginHandler)
+	handler.POST("/plugins/:kind/:id/activate", pluginHandler.ActivatePluginHandler)
+
+	handler.GET("/transactions/:tid/journal", journalHandler.GetTransactionJournalHandler)
+
+	handler.POST("/channels/:id/reconciliations", reconciliationHandler.CreateReconciliationHandler)
+	handler.GET("/reconciliations", reconciliationHandler.GetReconciliationsHandler)
+	handler.GET("/reconciliations/:id", reconciliationHandler.GetReconciliationHandler)
+
+	handler.GET("/reports/turnover", reportHandler.GetTurnOverReportHandler)
+
+	handler.GET("/breakers", breakerHandler.GetBreakersHandler)
+	handler.POST("/breakers/:id/reset", breakerHandler.ResetBreakerHandler)
+
 	return handler
 }
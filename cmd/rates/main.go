@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"flag"
+	"github.com/serg666/repository"
+	"github.com/serg666/gateway/client"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/logging"
+	"github.com/serg666/gateway/rates"
+)
+
+func main() {
+	configPath := flag.String("config", "./config.yml", "path to config file")
+	format := flag.String("format", rates.ECB, "feed format: ecb or csv")
+	location := flag.String("location", "", "feed URL or file (configured feeds if empty)")
+	flag.Parse()
+
+	if err := config.ValidateConfigPath(configPath); err != nil {
+		log.Fatalf("can not parse flags due to: %v", err)
+	}
+
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		log.Fatalf("can not get new config due to: %v", err)
+	}
+
+	pgPool, err := repository.MakePgPoolFromDSN(cfg.Databases.Default.Dsn)
+	if err != nil {
+		log.Fatalf("Can not make pg pool: %v", err)
+	}
+	defer pgPool.Close()
+
+	err, logger := logging.New(cfg)
+	if err != nil {
+		log.Fatalf("Can not make logger: %v", err)
+	}
+	defer logger.Close()
+
+	loggerFunc := logger.Func
+
+	client.Set(cfg.HttpClient())
+
+	currencyStore := repository.NewPGPoolCurrencyStore(pgPool, loggerFunc)
+	rateStore := rates.NewPGPoolRateStore(pgPool, currencyStore, loggerFunc)
+
+	feeds := cfg.Rates.Feeds
+	if *location != "" {
+		feeds = []config.RateFeed{{Format: *format, Location: *location}}
+	}
+
+	if len(feeds) == 0 {
+		log.Fatalf("No rate feeds to import")
+	}
+
+	for _, feed := range feeds {
+		err, imported := rates.Import(nil, rateStore, currencyStore, feed.Format, feed.Location)
+		if err != nil {
+			log.Fatalf("Can not import rates from %s: %v", feed.Location, err)
+		}
+
+		log.Printf("%d rates imported from %s", imported, feed.Location)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"log"
+	"flag"
+	"time"
+	"github.com/serg666/repository"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/logging"
+	"github.com/serg666/gateway/reports"
+)
+
+func main() {
+	configPath := flag.String("config", "./config.yml", "path to config file")
+	from := flag.String("from", time.Now().AddDate(0, 0, -1).Format("2006-01-02"), "first day of the report")
+	to := flag.String("to", "", "last day of the report (defaults to from)")
+	profileId := flag.Int("profile", 0, "profile id (all profiles if zero)")
+	accountId := flag.Int("account", 0, "account id (all accounts if zero)")
+	format := flag.String("format", reports.CSV, "report format: csv or json")
+	output := flag.String("output", "", "report file (stdout if empty)")
+	flag.Parse()
+
+	if err := config.ValidateConfigPath(configPath); err != nil {
+		log.Fatalf("can not parse flags due to: %v", err)
+	}
+
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		log.Fatalf("can not get new config due to: %v", err)
+	}
+
+	if *to == "" {
+		to = from
+	}
+
+	fromDay, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		log.Fatalf("invalid from day: %v", err)
+	}
+
+	toDay, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		log.Fatalf("invalid to day: %v", err)
+	}
+
+	filter := reports.TurnOverFilter{
+		From: fromDay,
+		To:   toDay.AddDate(0, 0, 1),
+	}
+
+	if *profileId != 0 {
+		filter.ProfileId = profileId
+	}
+
+	if *accountId != 0 {
+		filter.AccountId = accountId
+	}
+
+	os.Exit(run(cfg, filter, *format, *output))
+}
+
+// run will write the report. Exit code is returned, so deferred closing is
+// done before exit
+func run(cfg *config.Config, filter reports.TurnOverFilter, format string, output string) int {
+	pgPool, err := repository.MakePgPoolFromDSN(cfg.Databases.Default.Dsn)
+	if err != nil {
+		log.Printf("Can not make pg pool: %v", err)
+		return 1
+	}
+	defer pgPool.Close()
+
+	err, logger := logging.New(cfg)
+	if err != nil {
+		log.Printf("Can not make logger: %v", err)
+		return 1
+	}
+	defer logger.Close()
+
+	loggerFunc := logger.Func
+
+	err, rows := reports.NewPGPoolReportStore(pgPool, loggerFunc).TurnOver(nil, filter)
+	if err != nil {
+		log.Printf("Can not make turnover report: %v", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			log.Printf("Can not create report file: %v", err)
+			return 1
+		}
+		defer out.Close()
+	}
+
+	if err := reports.WriteTurnOver(out, format, rows); err != nil {
+		log.Printf("Can not write turnover report: %v", err)
+		return 1
+	}
+
+	return 0
+}
@@ -5,20 +5,59 @@ import (
 	"fmt"
 	"time"
 	"flag"
+	"sync"
 	"syscall"
 	"context"
 	"net"
 	"net/http"
+	"regexp"
+	"reflect"
+	"io/ioutil"
 	"os/signal"
 	"gopkg.in/yaml.v2"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
-	"github.com/gin-contrib/requestid"
 	"github.com/serg666/repository"
 )
 
+// RemoteChannel describes out-of-process bank channel plugin
+type RemoteChannel struct {
+	// Id and Key are used to register channel in the channels table
+	Id  int    `yaml:"id"`
+	Key string `yaml:"key"`
+
+	// Address is the host:port of the plugin gRPC server
+	Address string `yaml:"address"`
+
+	// Ca is the path to the CA certificate to verify plugin server with.
+	// Plain text connection is used if empty
+	Ca string `yaml:"ca"`
+
+	// Timeout is the time limit for every call to the plugin, 10 seconds
+	// if zero
+	Timeout time.Duration `yaml:"timeout"`
+
+	// HealthInterval is the interval between plugin health checks
+	HealthInterval time.Duration `yaml:"health_interval"`
+}
+
+// RateFeed describes exchange rate feed to import periodically
+type RateFeed struct {
+	// Format is the feed format: ecb or csv
+	Format string `yaml:"format"`
+
+	// Location is the feed URL or local file path
+	Location string `yaml:"location"`
+
+	// Interval is the time between imports
+	Interval time.Duration `yaml:"interval"`
+}
+
 // Config struct for webapp config
 type Config struct {
+	// mu guards Reloadable sections
+	mu sync.RWMutex
+
 	Client struct {
 		Timeout struct {
 			// Read specifies a time limit for requests made by this
@@ -57,6 +96,37 @@ type Config struct {
 	} `yaml:"server"`
 	LogRus struct {
 		Level logrus.Level `yaml:"level"`
+
+		// Format is text or json
+		Format string `yaml:"format"`
+
+		// Output is stderr, stdout, file or syslog
+		Output string `yaml:"output"`
+
+		// File is the log file rotated by size
+		File struct {
+			Path string `yaml:"path"`
+
+			// MaxSize is megabytes the file is rotated at
+			MaxSize int `yaml:"max_size"`
+
+			// MaxBackups and MaxAge in days limit rotated files kept
+			MaxBackups int `yaml:"max_backups"`
+			MaxAge     int `yaml:"max_age"`
+
+			Compress bool `yaml:"compress"`
+		} `yaml:"file"`
+
+		// Syslog is the local syslog if network and address are empty
+		Syslog struct {
+			Network string `yaml:"network"`
+			Address string `yaml:"address"`
+			Tag     string `yaml:"tag"`
+		} `yaml:"syslog"`
+
+		// Packages are levels by the package name or import path
+		// overriding Level
+		Packages map[string]logrus.Level `yaml:"packages"`
 	} `yaml:"logrus"`
 	Alfabank struct {
 		Ecom struct {
@@ -71,26 +141,87 @@ type Config struct {
 	CardStore struct {
 		Url string `yaml:"url"`
 	} `yaml:"cardstore"`
-}
-
-func (cfg *Config) LogRusLogger(c interface{}) logrus.FieldLogger {
-	logger := logrus.New()
-	// @todo: somehow to configure logger from config
-	logger.SetLevel(cfg.LogRus.Level)
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-		DisableLevelTruncation: true,
-		ForceColors: true,
-	})
-
-	var rid string
-	if c != nil {
-		rid = requestid.Get(c.(*gin.Context))
-	}
-
-	return logger.WithFields(logrus.Fields{
-		"request_id": rid,
-	})
+	Breaker struct {
+		// Failures is the number of consecutive failed bank calls
+		// which opens the circuit of the account
+		Failures int `yaml:"failures"`
+
+		// Timeout is the time the circuit stays open before
+		// the probe call is allowed
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"breaker"`
+	Rates struct {
+		Feeds []RateFeed `yaml:"feeds"`
+	} `yaml:"rates"`
+	Disputes struct {
+		// EvidenceDir is the local directory evidence files are stored in
+		EvidenceDir string `yaml:"evidence_dir"`
+
+		// Deadlines are days given to respond in the dispute state
+		Deadlines map[string]int `yaml:"deadlines"`
+
+		// Interval is the time between checks of overdue disputes
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"disputes"`
+	Risk struct {
+		// Review and Deny are total scores of triggered rules escalating
+		// the decision. Zero is off
+		Review int `yaml:"review"`
+		Deny   int `yaml:"deny"`
+	} `yaml:"risk"`
+	Reviews struct {
+		// SLA is the time given to the operator to decide on the held
+		// transaction before it is declined
+		SLA time.Duration `yaml:"sla"`
+
+		// Interval is the time between checks of overdue reviews
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"reviews"`
+	Events struct {
+		// Heartbeat is the time between heartbeats of idle status
+		// streams
+		Heartbeat time.Duration `yaml:"heartbeat"`
+	} `yaml:"events"`
+	Health struct {
+		// Timeout is the time limit for all readiness checks
+		Timeout time.Duration `yaml:"timeout"`
+
+		// Drain is the time readiness fails on shutdown before
+		// the server stops accepting connections
+		Drain time.Duration `yaml:"drain"`
+	} `yaml:"health"`
+	Tracing struct {
+		// Exporter is where spans are sent: otlp collector or file.
+		// Tracing is off if empty
+		Exporter string `yaml:"exporter"`
+
+		// Endpoint is the host:port of the OTLP/HTTP collector
+		Endpoint string `yaml:"endpoint"`
+
+		// Insecure is to send spans to the collector over plain HTTP
+		Insecure bool `yaml:"insecure"`
+
+		// File is the path spans are appended to by the file exporter
+		File string `yaml:"file"`
+
+		// Service is the service name of spans
+		Service string `yaml:"service"`
+
+		// Ratio is the fraction of traces sampled from 0 to 1. Traces
+		// sampled by the caller are always sampled
+		Ratio float64 `yaml:"ratio"`
+	} `yaml:"tracing"`
+	Reload struct {
+		// Interval is the time between checks of the config file for
+		// changes. The file is not watched if zero, SIGHUP reloads it
+		// anyway
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"reload"`
+	Plugins struct {
+		Remote struct {
+			Channels []RemoteChannel `yaml:"channels"`
+		} `yaml:"remote"`
+	} `yaml:"plugins"`
 }
 
 // Will return the HTTP Client
@@ -110,21 +241,29 @@ func (cfg *Config) HttpClient() *http.Client {
 	}
 }
 
-// Run will run the HTTP Server
-func (cfg *Config) RunServer(handler *gin.Engine, loggerFunc repository.LoggerFunc) {
+type connKey struct{}
+
+// ClearWriteDeadline will lift the write timeout of the server for the
+// request, so long-lived responses like event streams are not cut
+func ClearWriteDeadline(r *http.Request) error {
+	conn, ok := r.Context().Value(connKey{}).(net.Conn)
+	if !ok {
+		return fmt.Errorf("no connection in request context")
+	}
+
+	return conn.SetWriteDeadline(time.Time{})
+}
+
+// Run will run the HTTP Server. The drain func is called once the shutdown
+// is started, before the server stops accepting connections. Funcs onClose
+// are called when it stops, they must end long-lived requests since the
+// server does not cancel them and waits for them to finish
+func (cfg *Config) RunServer(handler *gin.Engine, loggerFunc repository.LoggerFunc, drain func(), onClose ...func()) {
 	log := loggerFunc(nil)
 
 	// Set up a channel to listen to for interrupt signals
 	runChan := make(chan os.Signal, 1)
 
-	// Set up a context to allow for graceful server shutdowns in the event
-	// of an OS interrupt (defers the cancel just in case)
-	ctx, cancel := context.WithTimeout(
-		context.Background(),
-		cfg.Server.Timeout.Server * time.Second,
-	)
-	defer cancel()
-
 	// Define server options
 	server := &http.Server{
 		Addr:           cfg.Server.Host + ":" + cfg.Server.Port,
@@ -133,10 +272,17 @@ func (cfg *Config) RunServer(handler *gin.Engine, loggerFunc repository.LoggerFu
 		WriteTimeout:   cfg.Server.Timeout.Write * time.Second,
 		IdleTimeout:    cfg.Server.Timeout.Idle * time.Second,
 		MaxHeaderBytes: 1 << 20,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c)
+		},
+	}
+
+	for _, f := range onClose {
+		server.RegisterOnShutdown(f)
 	}
 
 	// Handle ctrl+c/ctrl+x interrupt and some other signals
-	signal.Notify(runChan, os.Interrupt, os.Kill, syscall.SIGTSTP, syscall.SIGSTOP)
+	signal.Notify(runChan, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGTSTP, syscall.SIGSTOP)
 
 	// Alert the user that the server is starting
 	log.Printf("Server is starting on %s", server.Addr)
@@ -159,32 +305,81 @@ func (cfg *Config) RunServer(handler *gin.Engine, loggerFunc repository.LoggerFu
 	// If we get one of the pre-prescribed syscalls, gracefully terminate the server
 	// while alerting the user
 	log.Printf("Server is shutting down due to %+v", interrupt)
-	// @todo: check shutdown here. it does not work after some time while runing server
+
+	if drain != nil {
+		drain()
+	}
+
+	// @note: requests are still served while the orchestrator notices the
+	// gateway is not ready
+	if cfg.Health.Drain > 0 {
+		log.Printf("Draining for %v", cfg.Health.Drain * time.Second)
+		time.Sleep(cfg.Health.Drain * time.Second)
+	}
+
+	// Set up a context to allow for graceful server shutdowns. It is made
+	// here, so the timeout is not spent while the server is running
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		cfg.Server.Timeout.Server * time.Second,
+	)
+	defer cancel()
+
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server was unable to gracefully shutdown due to err: %+v", err)
 	}
 }
 
-// NewConfig returns a new decoded Config struct
+var unknownField = regexp.MustCompile(`field (\S+) not found in type .*`)
+
+// unknownFields will drop the anonymous struct types from errors of unknown
+// keys, they tell nothing but make the error unreadable
+func unknownFields(err error) error {
+	if te, ok := err.(*yaml.TypeError); ok {
+		for i := range te.Errors {
+			te.Errors[i] = unknownField.ReplaceAllString(te.Errors[i], "unknown key $1")
+		}
+	}
+
+	return err
+}
+
+// NewConfig returns a new decoded Config struct. Unknown keys of the file
+// are errors, values are overridden by environment variables and the result
+// is validated
 func NewConfig(configPath *string) (*Config, error) {
 	// Create config structure
 	config := &Config{}
 
-	// Open config file
-	file, err := os.Open(*configPath)
+	// Read config file
+	data, err := ioutil.ReadFile(*configPath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	// Init new YAML decode
-	d := yaml.NewDecoder(file)
 
 	// Start YAML decoding from file
-	if err := d.Decode(&config); err != nil {
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("can not decode yaml config: %v", unknownFields(err))
+	}
+
+	// @note: raw values are needed to tell durations like 1m from seconds
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("can not decode yaml config: %v", err)
 	}
 
+	if err := durations(reflect.ValueOf(config).Elem(), raw, ""); err != nil {
+		return nil, fmt.Errorf("can not decode yaml config: %v", err)
+	}
+
+	if err := config.Override(); err != nil {
+		return nil, fmt.Errorf("can not override config from environment: %v", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
@@ -0,0 +1,295 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// load will return the config shipped with the gateway, so it is checked
+// to be valid too
+func load(t *testing.T) *Config {
+	path := "config.yml"
+	cfg, err := NewConfig(&path)
+	if err != nil {
+		t.Fatalf("shipped config is invalid: %v", err)
+	}
+
+	return cfg
+}
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		err   bool
+	}{
+		{value: "30", want: 30},
+		{value: "0", want: 0},
+		{value: "1m30s", want: 90},
+		{value: "2h", want: 7200},
+		{value: "1.5s", err: true},
+		{value: "1500ms", err: true},
+		{value: "abc", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := seconds(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("seconds = %d, want error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("seconds failed: %v", err)
+			}
+
+			if int64(got) != tt.want {
+				t.Errorf("seconds = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "dsn")
+	missing := filepath.Join(dir, "missing")
+	if err := ioutil.WriteFile(secret, []byte("dbname=secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(cfg *Config) interface{}
+		want  interface{}
+		err   string
+	}{
+		{
+			name:  "string",
+			env:   map[string]string{"GATEWAY_CARDSTORE_URL": "http://cardstore:8090"},
+			check: func(cfg *Config) interface{} { return cfg.CardStore.Url },
+			want:  "http://cardstore:8090",
+		},
+		{
+			name:  "duration in seconds",
+			env:   map[string]string{"GATEWAY_SERVER_TIMEOUT_WRITE": "2m"},
+			check: func(cfg *Config) interface{} { return int64(cfg.Server.Timeout.Write) },
+			want:  int64(120),
+		},
+		{
+			name:  "float",
+			env:   map[string]string{"GATEWAY_TRACING_RATIO": "0.25"},
+			check: func(cfg *Config) interface{} { return cfg.Tracing.Ratio },
+			want:  0.25,
+		},
+		{
+			name:  "slice grown",
+			env:   map[string]string{"GATEWAY_RATES_FEEDS_1_LOCATION": "feed.csv"},
+			check: func(cfg *Config) interface{} { return []string{cfg.Rates.Feeds[0].Location, cfg.Rates.Feeds[1].Location} },
+			want:  []string{"", "feed.csv"},
+		},
+		{
+			name:  "map key in lower case",
+			env:   map[string]string{"GATEWAY_DISPUTES_DEADLINES_CHARGEBACK": "60"},
+			check: func(cfg *Config) interface{} { return cfg.Disputes.Deadlines["chargeback"] },
+			want:  60,
+		},
+		{
+			name:  "secret from file",
+			env:   map[string]string{"GATEWAY_DATABASES_DEFAULT_DSN_FILE": secret},
+			check: func(cfg *Config) interface{} { return cfg.Databases.Default.Dsn },
+			want:  "dbname=secret",
+		},
+		{
+			name:  "other variables ignored",
+			env:   map[string]string{"CARDSTORE_URL": "http://cardstore:8090"},
+			check: func(cfg *Config) interface{} { return cfg.CardStore.Url },
+			want:  "http://127.0.0.1:8090",
+		},
+		{
+			name: "value and file both set",
+			env:  map[string]string{"GATEWAY_DATABASES_DEFAULT_DSN": "dbname=kvell", "GATEWAY_DATABASES_DEFAULT_DSN_FILE": secret},
+			err:  "GATEWAY_DATABASES_DEFAULT_DSN and GATEWAY_DATABASES_DEFAULT_DSN_FILE are both set",
+		},
+		{
+			name: "file missing",
+			env:  map[string]string{"GATEWAY_CARDSTORE_URL_FILE": missing},
+			err:  "GATEWAY_CARDSTORE_URL_FILE: open " + missing + ": no such file or directory",
+		},
+		{
+			name: "not a number",
+			env:  map[string]string{"GATEWAY_BREAKER_FAILURES": "five"},
+			err:  `GATEWAY_BREAKER_FAILURES: strconv.ParseInt: parsing "five": invalid syntax`,
+		},
+		{
+			name: "bad duration",
+			env:  map[string]string{"GATEWAY_HEALTH_DRAIN": "1.5s"},
+			err:  "GATEWAY_HEALTH_DRAIN: duration 1.5s is not whole seconds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := load(t)
+
+			err := override(reflect.ValueOf(cfg).Elem(), EnvPrefix, tt.env)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("override failed: %v", err)
+			}
+
+			if got := tt.check(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   []string
+	}{
+		{
+			name:   "shipped config",
+			change: func(cfg *Config) {},
+		},
+		{
+			name: "timeouts",
+			change: func(cfg *Config) {
+				cfg.Client.Timeout.Read = 0
+				cfg.Server.Timeout.Idle = -1
+			},
+			want: []string{"client.timeout.read: must be positive, got 0", "server.timeout.idle: must not be negative, got -1"},
+		},
+		{
+			name:   "port missing",
+			change: func(cfg *Config) { cfg.Server.Port = "" },
+			want:   []string{"server.port: is required"},
+		},
+		{
+			name:   "port out of range",
+			change: func(cfg *Config) { cfg.Server.Port = "70000" },
+			want:   []string{`server: port "70000" is not from 1 to 65535`},
+		},
+		{
+			name: "logging",
+			change: func(cfg *Config) {
+				cfg.LogRus.Format = "xml"
+				cfg.LogRus.Output = "file"
+				cfg.LogRus.File.Path = ""
+			},
+			want: []string{`logrus.format: "xml" is not one of "", "text", "json"`, "logrus.file.path: is required"},
+		},
+		{
+			name: "urls",
+			change: func(cfg *Config) {
+				cfg.Alfabank.Ecom.Url = "web.rbsuat.com"
+				cfg.CardStore.Url = ""
+			},
+			want: []string{`alfabank.ecom.url: "web.rbsuat.com" is not http(s) URL`, "cardstore.url: is required"},
+		},
+		{
+			name:   "dsn missing",
+			change: func(cfg *Config) { cfg.Databases.Default.Dsn = "" },
+			want:   []string{"databases.default.dsn: is required"},
+		},
+		{
+			name: "rate feed",
+			change: func(cfg *Config) {
+				cfg.Rates.Feeds = append(cfg.Rates.Feeds, RateFeed{Format: "json"})
+			},
+			want: []string{`rates.feeds[0].format: "json" is not one of "ecb", "csv"`, "rates.feeds[0].location: is required"},
+		},
+		{
+			name: "disputes",
+			change: func(cfg *Config) {
+				cfg.Disputes.EvidenceDir = ""
+				cfg.Disputes.Deadlines = map[string]int{"chargeback": 0}
+			},
+			want: []string{"disputes.evidence_dir: is required", "disputes.deadlines.chargeback: must be positive, got 0"},
+		},
+		{
+			name: "review above deny",
+			change: func(cfg *Config) {
+				cfg.Risk.Review = 150
+			},
+			want: []string{"risk.review: must not be greater than risk.deny"},
+		},
+		{
+			name: "deny off",
+			change: func(cfg *Config) {
+				cfg.Risk.Review = 150
+				cfg.Risk.Deny = 0
+			},
+		},
+		{
+			name:   "review sla",
+			change: func(cfg *Config) { cfg.Reviews.SLA = 0 },
+			want:   []string{"reviews.sla: must be positive, got 0"},
+		},
+		{
+			name: "tracing",
+			change: func(cfg *Config) {
+				cfg.Tracing.Exporter = "otlp"
+				cfg.Tracing.Endpoint = "collector"
+				cfg.Tracing.Ratio = 1.5
+			},
+			want: []string{`tracing.endpoint: "collector" is not host:port`, "tracing.ratio: must be from 0 to 1, got 1.5"},
+		},
+		{
+			name: "remote channels",
+			change: func(cfg *Config) {
+				cfg.Plugins.Remote.Channels = []RemoteChannel{
+					{Id: 3, Key: "somebank", Address: "127.0.0.1:9001"},
+					{Id: 3, Key: "somebank", Address: "127.0.0.1:9002", Timeout: -1},
+					{Key: "otherbank"},
+				}
+			},
+			want: []string{
+				"plugins.remote.channels[1].id: 3 is used twice",
+				"plugins.remote.channels[1].key: somebank is used twice",
+				"plugins.remote.channels[1].timeout: must not be negative, got -1",
+				"plugins.remote.channels[2].id: must be positive, got 0",
+				"plugins.remote.channels[2].address: is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := load(t)
+			tt.change(cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate failed: %v", err)
+				}
+				return
+			}
+
+			problems, ok := err.(ValidationError)
+			if !ok {
+				t.Fatalf("error = %v, want validation error", err)
+			}
+
+			if !reflect.DeepEqual([]string(problems), tt.want) {
+				t.Errorf("problems =\n%v\nwant\n%v", problems, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"os"
+	"fmt"
+	"time"
+	"reflect"
+	"strconv"
+	"strings"
+	"encoding"
+	"io/ioutil"
+)
+
+// EnvPrefix is the prefix of environment variables overriding the config.
+// Names are made of yaml keys, e.g. GATEWAY_CARDSTORE_URL for cardstore.url,
+// GATEWAY_RATES_FEEDS_0_LOCATION for location of the first feed and
+// GATEWAY_DISPUTES_DEADLINES_CHARGEBACK for the key of the map. The value
+// is read from the file named by the variable with _FILE suffix, so
+// secrets are not kept in the environment
+const EnvPrefix = "GATEWAY"
+
+const fileSuffix = "_FILE"
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	unmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// seconds will parse the duration given as number of seconds or as Go
+// duration like 1m30s. Durations of the config are kept in seconds
+func seconds(value string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is neither seconds nor duration", value)
+	}
+
+	if d%time.Second != 0 {
+		return 0, fmt.Errorf("duration %s is not whole seconds", value)
+	}
+
+	return d / time.Second, nil
+}
+
+// yamlName will return the yaml key of the field or empty string if the
+// field is not configured
+func yamlName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+// durations will bring durations given in yaml as Go durations to seconds.
+// yaml decodes them to nanoseconds otherwise
+func durations(v reflect.Value, raw interface{}, path string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		m, _ := raw.(map[interface{}]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			name := yamlName(v.Type().Field(i))
+			if name == "" {
+				continue
+			}
+
+			if err := durations(v.Field(i), m[name], strings.TrimPrefix(path+"."+name, ".")); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		items, _ := raw.([]interface{})
+		for i := 0; i < v.Len() && i < len(items); i++ {
+			if err := durations(v.Index(i), items[i], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	default:
+		if s, ok := raw.(string); ok && v.Type() == durationType {
+			d, err := seconds(s)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			v.SetInt(int64(d))
+		}
+	}
+
+	return nil
+}
+
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	return env
+}
+
+// lookup will return the value of the variable or the content of the file
+// named by the variable with _FILE suffix
+func lookup(env map[string]string, name string) (string, bool, error) {
+	value, ok := env[name]
+	path, fromFile := env[name+fileSuffix]
+
+	if ok && fromFile {
+		return "", false, fmt.Errorf("%s and %s%s are both set", name, name, fileSuffix)
+	}
+
+	if fromFile {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s%s: %v", name, fileSuffix, err)
+		}
+
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+	}
+
+	return value, ok, nil
+}
+
+// set will set the scalar value parsed from the text
+func set(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := seconds(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("%s can not be set from environment", v.Type())
+	}
+
+	return nil
+}
+
+// suffixes will return rest of names of variables with the prefix
+func suffixes(env map[string]string, prefix string) []string {
+	var result []string
+	for name := range env {
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, strings.TrimSuffix(name[len(prefix):], fileSuffix))
+		}
+	}
+
+	return result
+}
+
+// override will set fields of the value from variables named after the
+// name given
+func override(v reflect.Value, name string, env map[string]string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			key := yamlName(v.Type().Field(i))
+			if key == "" {
+				continue
+			}
+
+			if err := override(v.Field(i), name+"_"+strings.ToUpper(key), env); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		// @note: the slice grows up to the greatest index given
+		size := v.Len()
+		for _, suffix := range suffixes(env, name+"_") {
+			index, err := strconv.Atoi(strings.SplitN(suffix, "_", 2)[0])
+			if err == nil && index >= size {
+				size = index + 1
+			}
+		}
+
+		if size > v.Len() {
+			grown := reflect.MakeSlice(v.Type(), size, size)
+			reflect.Copy(grown, v)
+			v.Set(grown)
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if err := override(v.Index(i), fmt.Sprintf("%s_%d", name, i), env); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, suffix := range suffixes(env, name+"_") {
+			value, _, err := lookup(env, name+"_"+suffix)
+			if err != nil {
+				return err
+			}
+
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := set(elem, value); err != nil {
+				return fmt.Errorf("%s_%s: %v", name, suffix, err)
+			}
+
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			v.SetMapIndex(reflect.ValueOf(strings.ToLower(suffix)).Convert(v.Type().Key()), elem)
+		}
+	default:
+		value, ok, err := lookup(env, name)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if err := set(v, value); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Override will override the config by environment variables
+func (cfg *Config) Override() error {
+	return override(reflect.ValueOf(cfg).Elem(), EnvPrefix, environ())
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"os"
+	"fmt"
+	"sort"
+	"time"
+	"reflect"
+	"strings"
+	"syscall"
+	"os/signal"
+)
+
+// Reloadable are sections of the config applied by Apply without restart.
+// Changes of other sections are refused
+var Reloadable = []string{"client", "logrus", "alfabank", "breaker"}
+
+func reloadable(section string) bool {
+	for _, r := range Reloadable {
+		if r == section {
+			return true
+		}
+	}
+
+	return false
+}
+
+func show(v reflect.Value) string {
+	if v.Type() == durationType {
+		return fmt.Sprintf("%ds", v.Int())
+	}
+
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// diff will return keys of values which differ with old and new values
+func diff(a reflect.Value, b reflect.Value, path string) map[string][2]string {
+	changes := make(map[string][2]string)
+
+	switch a.Kind() {
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			name := yamlName(a.Type().Field(i))
+			if name == "" {
+				continue
+			}
+
+			for key, change := range diff(a.Field(i), b.Field(i), strings.TrimPrefix(path+"."+name, ".")) {
+				changes[key] = change
+			}
+		}
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			changes[path] = [2]string{fmt.Sprintf("%d items", a.Len()), fmt.Sprintf("%d items", b.Len())}
+			break
+		}
+
+		for i := 0; i < a.Len(); i++ {
+			for key, change := range diff(a.Index(i), b.Index(i), fmt.Sprintf("%s[%d]", path, i)) {
+				changes[key] = change
+			}
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, k := range append(a.MapKeys(), b.MapKeys()...) {
+			keys[fmt.Sprintf("%v", k.Interface())] = k
+		}
+
+		for name, k := range keys {
+			av, bv := a.MapIndex(k), b.MapIndex(k)
+			switch {
+			case !av.IsValid():
+				changes[path+"."+name] = [2]string{"none", show(bv)}
+			case !bv.IsValid():
+				changes[path+"."+name] = [2]string{show(av), "none"}
+			case !reflect.DeepEqual(av.Interface(), bv.Interface()):
+				changes[path+"."+name] = [2]string{show(av), show(bv)}
+			}
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			changes[path] = [2]string{show(a), show(b)}
+		}
+	}
+
+	return changes
+}
+
+// Changes will describe keys changed by the next config. Values are shown
+// for reloadable sections only, other ones may keep secrets. The error lists
+// keys which need the restart
+func (cfg *Config) Changes(next *Config) (error, []string) {
+	cfg.mu.RLock()
+	changes := diff(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(next).Elem(), "")
+	cfg.mu.RUnlock()
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var described, unsafe []string
+	for _, key := range keys {
+		if !reloadable(strings.FieldsFunc(key, func(r rune) bool { return r == '.' || r == '[' })[0]) {
+			unsafe = append(unsafe, key)
+			continue
+		}
+
+		described = append(described, fmt.Sprintf("%s: %s -> %s", key, changes[key][0], changes[key][1]))
+	}
+
+	if len(unsafe) > 0 {
+		return fmt.Errorf("restart is required to change %s", strings.Join(unsafe, ", ")), nil
+	}
+
+	return nil, described
+}
+
+// Apply will replace reloadable sections by ones of the next config
+func (cfg *Config) Apply(next *Config) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	cfg.Client = next.Client
+	cfg.LogRus = next.LogRus
+	cfg.Alfabank = next.Alfabank
+	cfg.Breaker = next.Breaker
+}
+
+// RLock and RUnlock guard reloadable sections read while the gateway runs
+func (cfg *Config) RLock() {
+	cfg.mu.RLock()
+}
+
+func (cfg *Config) RUnlock() {
+	cfg.mu.RUnlock()
+}
+
+func modified(path string) time.Time {
+	s, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return s.ModTime()
+}
+
+// Watch will call reload on SIGHUP and once the config file is modified.
+// The file is checked every interval, it is not watched if zero. Calls are
+// made one by one
+func Watch(path *string, interval time.Duration, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		tick = time.NewTicker(interval).C
+	}
+
+	last := modified(*path)
+	go func() {
+		for {
+			select {
+			case <-hup:
+			case <-tick:
+				if modified(*path).Equal(last) {
+					continue
+				}
+			}
+
+			last = modified(*path)
+			reload()
+		}
+	}()
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"time"
+	"reflect"
+	"syscall"
+	"testing"
+	"io/ioutil"
+	"path/filepath"
+	"github.com/sirupsen/logrus"
+)
+
+func TestChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(next *Config)
+		want   []string
+		err    string
+	}{
+		{
+			name:   "nothing changed",
+			change: func(next *Config) {},
+		},
+		{
+			name: "reloadable sections",
+			change: func(next *Config) {
+				next.Client.Timeout.Read = 30
+				next.Breaker.Failures = 10
+				next.LogRus.Packages = map[string]logrus.Level{"alfabank": logrus.InfoLevel}
+			},
+			want: []string{
+				"breaker.failures: 5 -> 10",
+				"client.timeout.read: 60s -> 30s",
+				"logrus.packages.alfabank: none -> info",
+			},
+		},
+		{
+			name: "restart required",
+			change: func(next *Config) {
+				next.Client.Timeout.Read = 30
+				next.Server.Port = "8081"
+				next.Databases.Default.Dsn = "dbname=secret"
+			},
+			err: "restart is required to change databases.default.dsn, server.port",
+		},
+		{
+			name: "items added",
+			change: func(next *Config) {
+				next.Rates.Feeds = append(next.Rates.Feeds, RateFeed{Format: "ecb"})
+			},
+			err: "restart is required to change rates.feeds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, next := load(t), load(t)
+			tt.change(next)
+
+			err, changes := cfg.Changes(next)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Changes failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("changes =\n%v\nwant\n%v", changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	cfg, next := load(t), load(t)
+	next.Client.Timeout.Read = 30
+	next.Breaker.Timeout = 60
+	next.Server.Port = "8081"
+
+	cfg.Apply(next)
+
+	if cfg.Client.Timeout.Read != 30 || cfg.Breaker.Timeout != 60 {
+		t.Errorf("reloadable sections are not applied: %+v %+v", cfg.Client, cfg.Breaker)
+	}
+
+	if cfg.Server.Port != "8080" {
+		t.Errorf("server section is applied: port %s", cfg.Server.Port)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := ioutil.WriteFile(path, []byte("server: {}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	Watch(&path, 10 * time.Millisecond, func() {
+		reloaded <- struct{}{}
+	})
+
+	wait := func(cause string) {
+		t.Helper()
+		select {
+		case <-reloaded:
+		case <-time.After(time.Second):
+			t.Fatalf("config is not reloaded on %s", cause)
+		}
+	}
+
+	select {
+	case <-reloaded:
+		t.Fatalf("config is reloaded while the file is not modified")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	modified := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	wait("file modification")
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	wait("SIGHUP")
+}
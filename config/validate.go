@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"strconv"
+	"net/url"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ValidationError lists every problem of the config at once
+type ValidationError []string
+
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("invalid config:\n  %s", strings.Join(ve, "\n  "))
+}
+
+type validator struct {
+	problems ValidationError
+}
+
+func (v *validator) fail(key string, format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) required(key string, value string) bool {
+	if value == "" {
+		v.fail(key, "is required")
+		return false
+	}
+
+	return true
+}
+
+func (v *validator) url(key string, value string) {
+	if !v.required(key, value) {
+		return
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.fail(key, "%q is not http(s) URL", value)
+	}
+}
+
+func (v *validator) address(key string, value string) {
+	if !v.required(key, value) {
+		return
+	}
+
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		v.fail(key, "%q is not host:port", value)
+		return
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		v.fail(key, "port %q is not from 1 to 65535", port)
+	}
+}
+
+func (v *validator) positive(key string, value int64) {
+	if value <= 0 {
+		v.fail(key, "must be positive, got %d", value)
+	}
+}
+
+func (v *validator) nonNegative(key string, value int64) {
+	if value < 0 {
+		v.fail(key, "must not be negative, got %d", value)
+	}
+}
+
+func (v *validator) oneOf(key string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	quoted := make([]string, len(allowed))
+	for i, a := range allowed {
+		quoted[i] = strconv.Quote(a)
+	}
+
+	v.fail(key, "%q is not one of %s", value, strings.Join(quoted, ", "))
+}
+
+// Validate will check the config and return all of its problems. Values
+// used only at payment time are checked too, so they fail at start
+func (cfg *Config) Validate() error {
+	v := &validator{}
+
+	v.positive("client.timeout.read", int64(cfg.Client.Timeout.Read))
+	v.positive("client.timeout.connect", int64(cfg.Client.Timeout.Connect))
+
+	if v.required("server.port", cfg.Server.Port) {
+		v.address("server", net.JoinHostPort(cfg.Server.Host, cfg.Server.Port))
+	}
+	v.positive("server.timeout.server", int64(cfg.Server.Timeout.Server))
+	v.nonNegative("server.timeout.write", int64(cfg.Server.Timeout.Write))
+	v.nonNegative("server.timeout.read", int64(cfg.Server.Timeout.Read))
+	v.nonNegative("server.timeout.idle", int64(cfg.Server.Timeout.Idle))
+
+	// @note: formats and outputs are of the logging package
+	v.oneOf("logrus.format", cfg.LogRus.Format, "", "text", "json")
+	v.oneOf("logrus.output", cfg.LogRus.Output, "", "stderr", "stdout", "file", "syslog")
+	if cfg.LogRus.Output == "file" {
+		v.required("logrus.file.path", cfg.LogRus.File.Path)
+		v.nonNegative("logrus.file.max_size", int64(cfg.LogRus.File.MaxSize))
+		v.nonNegative("logrus.file.max_backups", int64(cfg.LogRus.File.MaxBackups))
+		v.nonNegative("logrus.file.max_age", int64(cfg.LogRus.File.MaxAge))
+	}
+
+	v.url("alfabank.ecom.url", cfg.Alfabank.Ecom.Url)
+
+	if v.required("databases.default.dsn", cfg.Databases.Default.Dsn) {
+		if _, err := pgxpool.ParseConfig(cfg.Databases.Default.Dsn); err != nil {
+			v.fail("databases.default.dsn", "%v", err)
+		}
+	}
+
+	v.url("cardstore.url", cfg.CardStore.Url)
+
+	v.nonNegative("breaker.failures", int64(cfg.Breaker.Failures))
+	v.nonNegative("breaker.timeout", int64(cfg.Breaker.Timeout))
+
+	for i, feed := range cfg.Rates.Feeds {
+		key := fmt.Sprintf("rates.feeds[%d]", i)
+		v.oneOf(key+".format", feed.Format, "ecb", "csv")
+		v.required(key+".location", feed.Location)
+		v.nonNegative(key+".interval", int64(feed.Interval))
+	}
+
+	v.required("disputes.evidence_dir", cfg.Disputes.EvidenceDir)
+	for state, days := range cfg.Disputes.Deadlines {
+		v.positive("disputes.deadlines."+state, int64(days))
+	}
+	v.nonNegative("disputes.interval", int64(cfg.Disputes.Interval))
+
+	v.nonNegative("risk.review", int64(cfg.Risk.Review))
+	v.nonNegative("risk.deny", int64(cfg.Risk.Deny))
+	if cfg.Risk.Review > 0 && cfg.Risk.Deny > 0 && cfg.Risk.Review > cfg.Risk.Deny {
+		v.fail("risk.review", "must not be greater than risk.deny")
+	}
+
+	v.positive("reviews.sla", int64(cfg.Reviews.SLA))
+	v.nonNegative("reviews.interval", int64(cfg.Reviews.Interval))
+
+	v.nonNegative("events.heartbeat", int64(cfg.Events.Heartbeat))
+	v.nonNegative("health.timeout", int64(cfg.Health.Timeout))
+	v.nonNegative("health.drain", int64(cfg.Health.Drain))
+
+	v.nonNegative("reload.interval", int64(cfg.Reload.Interval))
+
+	// @note: exporters are of the tracing package
+	v.oneOf("tracing.exporter", cfg.Tracing.Exporter, "", "otlp", "file")
+	switch cfg.Tracing.Exporter {
+	case "otlp":
+		v.address("tracing.endpoint", cfg.Tracing.Endpoint)
+	case "file":
+		v.required("tracing.file", cfg.Tracing.File)
+	}
+	if cfg.Tracing.Ratio < 0 || cfg.Tracing.Ratio > 1 {
+		v.fail("tracing.ratio", "must be from 0 to 1, got %v", cfg.Tracing.Ratio)
+	}
+
+	ids := map[int]bool{}
+	keys := map[string]bool{}
+	for i, rc := range cfg.Plugins.Remote.Channels {
+		key := fmt.Sprintf("plugins.remote.channels[%d]", i)
+		v.positive(key+".id", int64(rc.Id))
+		if ids[rc.Id] {
+			v.fail(key+".id", "%d is used twice", rc.Id)
+		}
+		ids[rc.Id] = true
+
+		if v.required(key+".key", rc.Key) && keys[rc.Key] {
+			v.fail(key+".key", "%s is used twice", rc.Key)
+		}
+		keys[rc.Key] = true
+
+		v.address(key+".address", rc.Address)
+		v.nonNegative(key+".timeout", int64(rc.Timeout))
+		v.nonNegative(key+".health_interval", int64(rc.HealthInterval))
+	}
+
+	if len(v.problems) > 0 {
+		return v.problems
+	}
+
+	return nil
+}
@@ -16,6 +16,35 @@ SET xmloption = content;
 SET client_min_messages = warning;
 SET row_security = off;
 
+--
+-- Name: notify_transaction_status(); Type: FUNCTION; Schema: public; Owner: kvell
+--
+
+CREATE FUNCTION public.notify_transaction_status() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.status IS NOT DISTINCT FROM NEW.status
+        AND OLD.response_code IS NOT DISTINCT FROM NEW.response_code THEN
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('transaction_status', json_build_object(
+        'id', NEW.id,
+        'profile_id', NEW.profile_id,
+        'type', NEW.type,
+        'status', NEW.status,
+        'response_code', NEW.response_code,
+        'reference_id', NEW.reference_id
+    )::text);
+
+    RETURN NULL;
+END;
+$$;
+
+
+ALTER FUNCTION public.notify_transaction_status() OWNER TO kvell;
+
 SET default_tablespace = '';
 
 SET default_table_access_method = heap;
@@ -271,252 +300,1679 @@ ALTER SEQUENCE public.transactions_id_seq OWNED BY public.transactions.id;
 
 
 --
--- Name: accounts id; Type: DEFAULT; Schema: public; Owner: kvell
+-- Name: plugin_states; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.accounts ALTER COLUMN id SET DEFAULT nextval('public.accounts_id_seq'::regclass);
+CREATE TABLE public.plugin_states (
+    kind character varying(255) NOT NULL,
+    plugin_id integer NOT NULL,
+    state character varying(255) NOT NULL,
+    reason text,
+    updated timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
 
+ALTER TABLE public.plugin_states OWNER TO kvell;
 
 --
--- Name: currencies id; Type: DEFAULT; Schema: public; Owner: kvell
+-- Name: bank_journal; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.currencies ALTER COLUMN id SET DEFAULT nextval('public.currencies_id_seq'::regclass);
+CREATE TABLE public.bank_journal (
+    id integer NOT NULL,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    transaction_id integer,
+    account_id integer NOT NULL,
+    channel character varying(255) NOT NULL,
+    method character varying(16) NOT NULL,
+    url text NOT NULL,
+    request text,
+    status_code integer,
+    response text,
+    error text,
+    latency integer NOT NULL
+);
 
 
+ALTER TABLE public.bank_journal OWNER TO kvell;
+
 --
--- Name: profiles id; Type: DEFAULT; Schema: public; Owner: kvell
+-- Name: bank_journal_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.profiles ALTER COLUMN id SET DEFAULT nextval('public.profiles_id_seq'::regclass);
+CREATE SEQUENCE public.bank_journal_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
 
+ALTER TABLE public.bank_journal_id_seq OWNER TO kvell;
 
 --
--- Name: routes id; Type: DEFAULT; Schema: public; Owner: kvell
+-- Name: bank_journal_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routes ALTER COLUMN id SET DEFAULT nextval('public.routes_id_seq'::regclass);
+ALTER SEQUENCE public.bank_journal_id_seq OWNED BY public.bank_journal.id;
 
 
 --
--- Name: transactions id; Type: DEFAULT; Schema: public; Owner: kvell
+-- Name: reconciliations; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions ALTER COLUMN id SET DEFAULT nextval('public.transactions_id_seq'::regclass);
+CREATE TABLE public.reconciliations (
+    id integer NOT NULL,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    channel_id integer NOT NULL,
+    format character varying(255) NOT NULL,
+    filename text NOT NULL,
+    date date NOT NULL,
+    matched integer NOT NULL,
+    missing integer NOT NULL,
+    extra integer NOT NULL,
+    mismatched integer NOT NULL
+);
+
 
+ALTER TABLE public.reconciliations OWNER TO kvell;
 
 --
--- Name: accounts accounts_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: reconciliations_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.accounts
-    ADD CONSTRAINT accounts_pkey PRIMARY KEY (id);
+CREATE SEQUENCE public.reconciliations_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
 
+ALTER TABLE public.reconciliations_id_seq OWNER TO kvell;
 
 --
--- Name: channels channel_key_uix; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: reconciliations_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.channels
-    ADD CONSTRAINT channel_key_uix UNIQUE (key);
+ALTER SEQUENCE public.reconciliations_id_seq OWNED BY public.reconciliations.id;
 
 
 --
--- Name: channels channels_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: reconciliation_items; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.channels
-    ADD CONSTRAINT channels_pkey PRIMARY KEY (id);
+CREATE TABLE public.reconciliation_items (
+    id integer NOT NULL,
+    reconciliation_id integer NOT NULL,
+    status character varying(255) NOT NULL,
+    transaction_id integer,
+    type character varying(255),
+    remote_id character varying(255),
+    rrn character varying(255),
+    authcode character varying(8),
+    amount integer,
+    expected_amount integer,
+    currency integer
+);
 
 
+ALTER TABLE public.reconciliation_items OWNER TO kvell;
+
 --
--- Name: currencies currencies_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: reconciliation_items_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.currencies
-    ADD CONSTRAINT currencies_pkey PRIMARY KEY (id);
+CREATE SEQUENCE public.reconciliation_items_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.reconciliation_items_id_seq OWNER TO kvell;
+
 --
--- Name: currencies currency_numeric_code_uix; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: reconciliation_items_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.currencies
-    ADD CONSTRAINT currency_numeric_code_uix UNIQUE (numeric_code);
+ALTER SEQUENCE public.reconciliation_items_id_seq OWNED BY public.reconciliation_items.id;
 
 
 --
--- Name: instruments instruments_key_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: rates; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.instruments
-    ADD CONSTRAINT instruments_key_key UNIQUE (key);
+CREATE TABLE public.rates (
+    id integer NOT NULL,
+    from_currency_id integer NOT NULL,
+    to_currency_id integer NOT NULL,
+    rate numeric(24,12) NOT NULL,
+    valid_from timestamp with time zone NOT NULL,
+    valid_to timestamp with time zone,
+    source character varying(255),
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
 
+ALTER TABLE public.rates OWNER TO kvell;
 
 --
--- Name: instruments instruments_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: rates_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.instruments
-    ADD CONSTRAINT instruments_pkey PRIMARY KEY (id);
+CREATE SEQUENCE public.rates_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
 
+ALTER TABLE public.rates_id_seq OWNER TO kvell;
 
 --
--- Name: profiles profiles_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: rates_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.profiles
-    ADD CONSTRAINT profiles_pkey PRIMARY KEY (id);
+ALTER SEQUENCE public.rates_id_seq OWNED BY public.rates.id;
 
 
 --
--- Name: routers routers_key_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: fee_plans; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routers
-    ADD CONSTRAINT routers_key_key UNIQUE (key);
+CREATE TABLE public.fee_plans (
+    id integer NOT NULL,
+    name character varying(255) NOT NULL
+);
 
 
+ALTER TABLE public.fee_plans OWNER TO kvell;
+
 --
--- Name: routers routers_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: fee_plans_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routers
-    ADD CONSTRAINT routers_pkey PRIMARY KEY (id);
+CREATE SEQUENCE public.fee_plans_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.fee_plans_id_seq OWNER TO kvell;
+
 --
--- Name: routes routes_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: fee_plans_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routes
-    ADD CONSTRAINT routes_pkey PRIMARY KEY (id);
+ALTER SEQUENCE public.fee_plans_id_seq OWNED BY public.fee_plans.id;
 
 
 --
--- Name: routes routes_profile_id_instrument_id_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: fee_rules; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routes
-    ADD CONSTRAINT routes_profile_id_instrument_id_key UNIQUE (profile_id, instrument_id);
+CREATE TABLE public.fee_rules (
+    id integer NOT NULL,
+    plan_id integer NOT NULL,
+    account_id integer,
+    brand character varying(255),
+    type character varying(255),
+    fixed integer,
+    percent numeric(8,4),
+    min integer,
+    max integer
+);
 
 
+ALTER TABLE public.fee_rules OWNER TO kvell;
+
 --
--- Name: transactions transactions_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+-- Name: fee_rules_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions
-    ADD CONSTRAINT transactions_pkey PRIMARY KEY (id);
+CREATE SEQUENCE public.fee_rules_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.fee_rules_id_seq OWNER TO kvell;
+
 --
--- Name: ref_status_idx; Type: INDEX; Schema: public; Owner: kvell
+-- Name: fee_rules_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-CREATE INDEX ref_status_idx ON public.transactions USING btree (reference_id, status);
+ALTER SEQUENCE public.fee_rules_id_seq OWNED BY public.fee_rules.id;
 
 
 --
--- Name: type_id_idx; Type: INDEX; Schema: public; Owner: kvell
+-- Name: profile_fee_plans; Type: TABLE; Schema: public; Owner: kvell
 --
 
-CREATE INDEX type_id_idx ON public.channels USING btree (type_id);
+CREATE TABLE public.profile_fee_plans (
+    profile_id integer NOT NULL,
+    plan_id integer NOT NULL
+);
+
 
+ALTER TABLE public.profile_fee_plans OWNER TO kvell;
 
 --
--- Name: accounts accounts_channel_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: transaction_fees; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.accounts
-    ADD CONSTRAINT accounts_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES public.channels(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE TABLE public.transaction_fees (
+    transaction_id integer NOT NULL,
+    plan_id integer NOT NULL,
+    rule_id integer NOT NULL,
+    brand character varying(255),
+    amount integer NOT NULL,
+    currency_id integer NOT NULL,
+    charged boolean DEFAULT false NOT NULL,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
 
 
+ALTER TABLE public.transaction_fees OWNER TO kvell;
+
 --
--- Name: accounts accounts_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: ledger_entries; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.accounts
-    ADD CONSTRAINT accounts_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE TABLE public.ledger_entries (
+    id integer NOT NULL,
+    transaction_id integer NOT NULL,
+    kind character varying(255) NOT NULL,
+    dispute_id integer,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
 
 
+ALTER TABLE public.ledger_entries OWNER TO kvell;
+
 --
--- Name: profiles profiles_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: ledger_entries_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.profiles
-    ADD CONSTRAINT profiles_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE SEQUENCE public.ledger_entries_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.ledger_entries_id_seq OWNER TO kvell;
+
 --
--- Name: routes routes_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: ledger_entries_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routes
-    ADD CONSTRAINT routes_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.accounts(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+ALTER SEQUENCE public.ledger_entries_id_seq OWNED BY public.ledger_entries.id;
 
 
 --
--- Name: routes routes_instrument_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: ledger_lines; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routes
-    ADD CONSTRAINT routes_instrument_id_fkey FOREIGN KEY (instrument_id) REFERENCES public.instruments(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE TABLE public.ledger_lines (
+    id integer NOT NULL,
+    entry_id integer NOT NULL,
+    account_type character varying(255) NOT NULL,
+    owner_id integer,
+    currency_id integer NOT NULL,
+    amount bigint NOT NULL
+);
 
 
+ALTER TABLE public.ledger_lines OWNER TO kvell;
+
 --
--- Name: routes routes_router_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: ledger_lines_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.routes
-    ADD CONSTRAINT routes_router_id_fkey FOREIGN KEY (router_id) REFERENCES public.routers(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE SEQUENCE public.ledger_lines_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.ledger_lines_id_seq OWNER TO kvell;
+
 --
--- Name: transactions transactions_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: ledger_lines_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions
-    ADD CONSTRAINT transactions_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.accounts(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+ALTER SEQUENCE public.ledger_lines_id_seq OWNED BY public.ledger_lines.id;
 
 
 --
--- Name: transactions transactions_currency_converted_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: disputes; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions
-    ADD CONSTRAINT transactions_currency_converted_id_fkey FOREIGN KEY (currency_converted_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE TABLE public.disputes (
+    id integer NOT NULL,
+    transaction_id integer NOT NULL,
+    profile_id integer NOT NULL,
+    state character varying(255) NOT NULL,
+    reason text,
+    amount integer NOT NULL,
+    currency_id integer NOT NULL,
+    deadline timestamp with time zone,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
 
+ALTER TABLE public.disputes OWNER TO kvell;
 
 --
--- Name: transactions transactions_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: disputes_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions
-    ADD CONSTRAINT transactions_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE SEQUENCE public.disputes_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
 
+ALTER TABLE public.disputes_id_seq OWNER TO kvell;
 
 --
--- Name: transactions transactions_instrument_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: disputes_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions
-    ADD CONSTRAINT transactions_instrument_id_fkey FOREIGN KEY (instrument_id) REFERENCES public.instruments(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+ALTER SEQUENCE public.disputes_id_seq OWNED BY public.disputes.id;
 
 
 --
--- Name: transactions transactions_profile_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: dispute_events; Type: TABLE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions
-    ADD CONSTRAINT transactions_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES public.profiles(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE TABLE public.dispute_events (
+    id integer NOT NULL,
+    dispute_id integer NOT NULL,
+    state character varying(255) NOT NULL,
+    note text,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
 
 
+ALTER TABLE public.dispute_events OWNER TO kvell;
+
 --
--- Name: transactions transactions_reference_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+-- Name: dispute_events_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
 --
 
-ALTER TABLE ONLY public.transactions
-    ADD CONSTRAINT transactions_reference_id_fkey FOREIGN KEY (reference_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+CREATE SEQUENCE public.dispute_events_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.dispute_events_id_seq OWNER TO kvell;
+
+--
+-- Name: dispute_events_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
+--
+
+ALTER SEQUENCE public.dispute_events_id_seq OWNED BY public.dispute_events.id;
+
+
+--
+-- Name: dispute_evidence; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.dispute_evidence (
+    id integer NOT NULL,
+    dispute_id integer NOT NULL,
+    filename character varying(255) NOT NULL,
+    content_type character varying(255),
+    size bigint NOT NULL,
+    description text,
+    path text NOT NULL,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.dispute_evidence OWNER TO kvell;
+
+--
+-- Name: dispute_evidence_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
+--
+
+CREATE SEQUENCE public.dispute_evidence_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.dispute_evidence_id_seq OWNER TO kvell;
+
+--
+-- Name: dispute_evidence_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
+--
+
+ALTER SEQUENCE public.dispute_evidence_id_seq OWNED BY public.dispute_evidence.id;
+
+
+--
+-- Name: webhooks; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.webhooks (
+    profile_id integer NOT NULL,
+    url text NOT NULL,
+    secret character varying(255)
+);
+
+
+ALTER TABLE public.webhooks OWNER TO kvell;
+
+--
+-- Name: limits; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.limits (
+    id integer NOT NULL,
+    scope character varying(255) NOT NULL,
+    scope_id integer NOT NULL,
+    kind character varying(255) NOT NULL,
+    value bigint NOT NULL,
+    brand character varying(255),
+    card_type character varying(255),
+    country character(2)
+);
+
+
+ALTER TABLE public.limits OWNER TO kvell;
+
+--
+-- Name: limits_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
+--
+
+CREATE SEQUENCE public.limits_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.limits_id_seq OWNER TO kvell;
+
+--
+-- Name: limits_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
+--
+
+ALTER SEQUENCE public.limits_id_seq OWNED BY public.limits.id;
+
+
+--
+-- Name: risk_rules; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.risk_rules (
+    id integer NOT NULL,
+    profile_id integer,
+    key character varying(255) NOT NULL,
+    action character varying(255) NOT NULL,
+    score integer DEFAULT 0 NOT NULL,
+    settings jsonb,
+    enabled boolean DEFAULT true NOT NULL
+);
+
+
+ALTER TABLE public.risk_rules OWNER TO kvell;
+
+--
+-- Name: risk_rules_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
+--
+
+CREATE SEQUENCE public.risk_rules_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.risk_rules_id_seq OWNER TO kvell;
+
+--
+-- Name: risk_rules_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
+--
+
+ALTER SEQUENCE public.risk_rules_id_seq OWNED BY public.risk_rules.id;
+
+
+--
+-- Name: risk_blocklist; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.risk_blocklist (
+    id integer NOT NULL,
+    kind character varying(255) NOT NULL,
+    value character varying(255) NOT NULL,
+    comment text,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.risk_blocklist OWNER TO kvell;
+
+--
+-- Name: risk_blocklist_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
+--
+
+CREATE SEQUENCE public.risk_blocklist_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.risk_blocklist_id_seq OWNER TO kvell;
+
+--
+-- Name: risk_blocklist_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
+--
+
+ALTER SEQUENCE public.risk_blocklist_id_seq OWNED BY public.risk_blocklist.id;
+
+
+--
+-- Name: risk_assessments; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.risk_assessments (
+    id integer NOT NULL,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    profile_id integer NOT NULL,
+    transaction_id integer,
+    order_id character varying(255),
+    customer text,
+    score integer NOT NULL,
+    decision character varying(255) NOT NULL,
+    rules jsonb NOT NULL
+);
+
+
+ALTER TABLE public.risk_assessments OWNER TO kvell;
+
+--
+-- Name: risk_assessments_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
+--
+
+CREATE SEQUENCE public.risk_assessments_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.risk_assessments_id_seq OWNER TO kvell;
+
+--
+-- Name: risk_assessments_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
+--
+
+ALTER SEQUENCE public.risk_assessments_id_seq OWNED BY public.risk_assessments.id;
+
+
+--
+-- Name: reviews; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.reviews (
+    id integer NOT NULL,
+    created timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    deadline timestamp without time zone NOT NULL,
+    transaction_id integer NOT NULL,
+    assessment_id integer,
+    operation character varying(255) NOT NULL,
+    state character varying(255) NOT NULL,
+    operator character varying(255),
+    comment text,
+    decided timestamp without time zone,
+    result_id integer
+);
+
+
+ALTER TABLE public.reviews OWNER TO kvell;
+
+--
+-- Name: reviews_id_seq; Type: SEQUENCE; Schema: public; Owner: kvell
+--
+
+CREATE SEQUENCE public.reviews_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.reviews_id_seq OWNER TO kvell;
+
+--
+-- Name: reviews_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: kvell
+--
+
+ALTER SEQUENCE public.reviews_id_seq OWNED BY public.reviews.id;
+
+
+--
+-- Name: bins; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.bins (
+    prefix character varying(8) NOT NULL,
+    brand character varying(255) NOT NULL,
+    type character varying(255),
+    level character varying(255),
+    issuer character varying(255),
+    country character(2)
+);
+
+
+ALTER TABLE public.bins OWNER TO kvell;
+
+--
+-- Name: transaction_bins; Type: TABLE; Schema: public; Owner: kvell
+--
+
+CREATE TABLE public.transaction_bins (
+    transaction_id integer NOT NULL,
+    prefix character varying(8) NOT NULL,
+    brand character varying(255),
+    type character varying(255),
+    level character varying(255),
+    issuer character varying(255),
+    country character(2)
+);
+
+
+ALTER TABLE public.transaction_bins OWNER TO kvell;
+
+--
+-- Name: accounts id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.accounts ALTER COLUMN id SET DEFAULT nextval('public.accounts_id_seq'::regclass);
+
+
+--
+-- Name: currencies id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.currencies ALTER COLUMN id SET DEFAULT nextval('public.currencies_id_seq'::regclass);
+
+
+--
+-- Name: profiles id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.profiles ALTER COLUMN id SET DEFAULT nextval('public.profiles_id_seq'::regclass);
+
+
+--
+-- Name: routes id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routes ALTER COLUMN id SET DEFAULT nextval('public.routes_id_seq'::regclass);
+
+
+--
+-- Name: transactions id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions ALTER COLUMN id SET DEFAULT nextval('public.transactions_id_seq'::regclass);
+
+
+--
+-- Name: bank_journal id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.bank_journal ALTER COLUMN id SET DEFAULT nextval('public.bank_journal_id_seq'::regclass);
+
+
+--
+-- Name: reconciliations id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reconciliations ALTER COLUMN id SET DEFAULT nextval('public.reconciliations_id_seq'::regclass);
+
+
+--
+-- Name: reconciliation_items id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reconciliation_items ALTER COLUMN id SET DEFAULT nextval('public.reconciliation_items_id_seq'::regclass);
+
+
+--
+-- Name: rates id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.rates ALTER COLUMN id SET DEFAULT nextval('public.rates_id_seq'::regclass);
+
+
+--
+-- Name: fee_plans id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.fee_plans ALTER COLUMN id SET DEFAULT nextval('public.fee_plans_id_seq'::regclass);
+
+
+--
+-- Name: fee_rules id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.fee_rules ALTER COLUMN id SET DEFAULT nextval('public.fee_rules_id_seq'::regclass);
+
+
+--
+-- Name: ledger_entries id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_entries ALTER COLUMN id SET DEFAULT nextval('public.ledger_entries_id_seq'::regclass);
+
+
+--
+-- Name: ledger_lines id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_lines ALTER COLUMN id SET DEFAULT nextval('public.ledger_lines_id_seq'::regclass);
+
+
+--
+-- Name: disputes id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.disputes ALTER COLUMN id SET DEFAULT nextval('public.disputes_id_seq'::regclass);
+
+
+--
+-- Name: dispute_events id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.dispute_events ALTER COLUMN id SET DEFAULT nextval('public.dispute_events_id_seq'::regclass);
+
+
+--
+-- Name: dispute_evidence id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.dispute_evidence ALTER COLUMN id SET DEFAULT nextval('public.dispute_evidence_id_seq'::regclass);
+
+
+--
+-- Name: limits id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.limits ALTER COLUMN id SET DEFAULT nextval('public.limits_id_seq'::regclass);
+
+
+--
+-- Name: risk_rules id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_rules ALTER COLUMN id SET DEFAULT nextval('public.risk_rules_id_seq'::regclass);
+
+
+--
+-- Name: risk_blocklist id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_blocklist ALTER COLUMN id SET DEFAULT nextval('public.risk_blocklist_id_seq'::regclass);
+
+
+--
+-- Name: risk_assessments id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_assessments ALTER COLUMN id SET DEFAULT nextval('public.risk_assessments_id_seq'::regclass);
+
+
+--
+-- Name: reviews id; Type: DEFAULT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reviews ALTER COLUMN id SET DEFAULT nextval('public.reviews_id_seq'::regclass);
+
+
+--
+-- Name: accounts accounts_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.accounts
+    ADD CONSTRAINT accounts_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: channels channel_key_uix; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.channels
+    ADD CONSTRAINT channel_key_uix UNIQUE (key);
+
+
+--
+-- Name: channels channels_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.channels
+    ADD CONSTRAINT channels_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: currencies currencies_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.currencies
+    ADD CONSTRAINT currencies_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: currencies currency_numeric_code_uix; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.currencies
+    ADD CONSTRAINT currency_numeric_code_uix UNIQUE (numeric_code);
+
+
+--
+-- Name: instruments instruments_key_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.instruments
+    ADD CONSTRAINT instruments_key_key UNIQUE (key);
+
+
+--
+-- Name: instruments instruments_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.instruments
+    ADD CONSTRAINT instruments_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: profiles profiles_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.profiles
+    ADD CONSTRAINT profiles_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: routers routers_key_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routers
+    ADD CONSTRAINT routers_key_key UNIQUE (key);
+
+
+--
+-- Name: routers routers_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routers
+    ADD CONSTRAINT routers_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: routes routes_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routes
+    ADD CONSTRAINT routes_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: routes routes_profile_id_instrument_id_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routes
+    ADD CONSTRAINT routes_profile_id_instrument_id_key UNIQUE (profile_id, instrument_id);
+
+
+--
+-- Name: transactions transactions_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: plugin_states plugin_states_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.plugin_states
+    ADD CONSTRAINT plugin_states_pkey PRIMARY KEY (kind, plugin_id);
+
+
+--
+-- Name: bank_journal bank_journal_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.bank_journal
+    ADD CONSTRAINT bank_journal_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: reconciliations reconciliations_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reconciliations
+    ADD CONSTRAINT reconciliations_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: reconciliation_items reconciliation_items_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reconciliation_items
+    ADD CONSTRAINT reconciliation_items_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: rates rates_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.rates
+    ADD CONSTRAINT rates_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: fee_plans fee_plans_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.fee_plans
+    ADD CONSTRAINT fee_plans_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: fee_plans fee_plans_name_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.fee_plans
+    ADD CONSTRAINT fee_plans_name_key UNIQUE (name);
+
+
+--
+-- Name: fee_rules fee_rules_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.fee_rules
+    ADD CONSTRAINT fee_rules_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: profile_fee_plans profile_fee_plans_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.profile_fee_plans
+    ADD CONSTRAINT profile_fee_plans_pkey PRIMARY KEY (profile_id);
+
+
+--
+-- Name: transaction_fees transaction_fees_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transaction_fees
+    ADD CONSTRAINT transaction_fees_pkey PRIMARY KEY (transaction_id);
+
+
+--
+-- Name: ledger_entries ledger_entries_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_entries
+    ADD CONSTRAINT ledger_entries_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: ledger_lines ledger_lines_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_lines
+    ADD CONSTRAINT ledger_lines_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: disputes disputes_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.disputes
+    ADD CONSTRAINT disputes_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: dispute_events dispute_events_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.dispute_events
+    ADD CONSTRAINT dispute_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: dispute_evidence dispute_evidence_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.dispute_evidence
+    ADD CONSTRAINT dispute_evidence_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhooks webhooks_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.webhooks
+    ADD CONSTRAINT webhooks_pkey PRIMARY KEY (profile_id);
+
+
+--
+-- Name: limits limits_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.limits
+    ADD CONSTRAINT limits_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: risk_rules risk_rules_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_rules
+    ADD CONSTRAINT risk_rules_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: risk_blocklist risk_blocklist_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_blocklist
+    ADD CONSTRAINT risk_blocklist_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: risk_blocklist risk_blocklist_kind_value_key; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_blocklist
+    ADD CONSTRAINT risk_blocklist_kind_value_key UNIQUE (kind, value);
+
+
+--
+-- Name: risk_assessments risk_assessments_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_assessments
+    ADD CONSTRAINT risk_assessments_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: reviews reviews_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reviews
+    ADD CONSTRAINT reviews_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: bins bins_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.bins
+    ADD CONSTRAINT bins_pkey PRIMARY KEY (prefix);
+
+
+--
+-- Name: transaction_bins transaction_bins_pkey; Type: CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transaction_bins
+    ADD CONSTRAINT transaction_bins_pkey PRIMARY KEY (transaction_id);
+
+
+--
+-- Name: ledger_entries_transaction_id_kind_dispute_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE UNIQUE INDEX ledger_entries_transaction_id_kind_dispute_idx ON public.ledger_entries USING btree (transaction_id, kind, COALESCE(dispute_id, 0));
+
+
+--
+-- Name: limits_scope_scope_id_kind_card_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE UNIQUE INDEX limits_scope_scope_id_kind_card_idx ON public.limits USING btree (scope, scope_id, kind, COALESCE(brand, ''::character varying), COALESCE(card_type, ''::character varying), COALESCE(country, ''::bpchar));
+
+
+--
+-- Name: ref_status_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX ref_status_idx ON public.transactions USING btree (reference_id, status);
+
+
+--
+-- Name: transactions_account_id_created_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX transactions_account_id_created_idx ON public.transactions USING btree (account_id, created);
+
+
+--
+-- Name: transactions_customer_created_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX transactions_customer_created_idx ON public.transactions USING btree (customer, created);
+
+
+--
+-- Name: transactions_instrument_created_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX transactions_instrument_created_idx ON public.transactions USING btree (instrument, created);
+
+
+--
+-- Name: transactions_profile_id_created_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX transactions_profile_id_created_idx ON public.transactions USING btree (profile_id, created);
+
+
+--
+-- Name: type_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX type_id_idx ON public.channels USING btree (type_id);
+
+
+--
+-- Name: bank_journal_transaction_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX bank_journal_transaction_id_idx ON public.bank_journal USING btree (transaction_id);
+
+
+--
+-- Name: reconciliation_items_reconciliation_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX reconciliation_items_reconciliation_id_idx ON public.reconciliation_items USING btree (reconciliation_id, status);
+
+
+--
+-- Name: rates_currencies_valid_from_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE UNIQUE INDEX rates_currencies_valid_from_idx ON public.rates USING btree (from_currency_id, to_currency_id, valid_from);
+
+
+--
+-- Name: fee_rules_plan_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX fee_rules_plan_id_idx ON public.fee_rules USING btree (plan_id);
+
+
+--
+-- Name: ledger_lines_account_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX ledger_lines_account_idx ON public.ledger_lines USING btree (account_type, owner_id, currency_id);
+
+
+--
+-- Name: ledger_lines_entry_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX ledger_lines_entry_id_idx ON public.ledger_lines USING btree (entry_id);
+
+
+--
+-- Name: disputes_transaction_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX disputes_transaction_id_idx ON public.disputes USING btree (transaction_id);
+
+
+--
+-- Name: disputes_state_deadline_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX disputes_state_deadline_idx ON public.disputes USING btree (state, deadline);
+
+
+--
+-- Name: dispute_events_dispute_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX dispute_events_dispute_id_idx ON public.dispute_events USING btree (dispute_id);
+
+
+--
+-- Name: dispute_evidence_dispute_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX dispute_evidence_dispute_id_idx ON public.dispute_evidence USING btree (dispute_id);
+
+
+--
+-- Name: risk_assessments_transaction_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX risk_assessments_transaction_id_idx ON public.risk_assessments USING btree (transaction_id);
+
+
+--
+-- Name: risk_assessments_decision_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX risk_assessments_decision_idx ON public.risk_assessments USING btree (decision);
+
+
+--
+-- Name: reviews_transaction_id_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX reviews_transaction_id_idx ON public.reviews USING btree (transaction_id);
+
+
+--
+-- Name: reviews_state_deadline_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX reviews_state_deadline_idx ON public.reviews USING btree (state, deadline);
+
+
+--
+-- Name: bins_brand_idx; Type: INDEX; Schema: public; Owner: kvell
+--
+
+CREATE INDEX bins_brand_idx ON public.bins USING btree (brand);
+
+
+--
+-- Name: transactions transactions_status_notify; Type: TRIGGER; Schema: public; Owner: kvell
+--
+
+CREATE TRIGGER transactions_status_notify AFTER INSERT OR UPDATE ON public.transactions FOR EACH ROW EXECUTE FUNCTION public.notify_transaction_status();
+
+
+--
+-- Name: accounts accounts_channel_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.accounts
+    ADD CONSTRAINT accounts_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES public.channels(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: accounts accounts_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.accounts
+    ADD CONSTRAINT accounts_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: profiles profiles_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.profiles
+    ADD CONSTRAINT profiles_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: routes routes_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routes
+    ADD CONSTRAINT routes_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.accounts(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: routes routes_instrument_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routes
+    ADD CONSTRAINT routes_instrument_id_fkey FOREIGN KEY (instrument_id) REFERENCES public.instruments(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: routes routes_router_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.routes
+    ADD CONSTRAINT routes_router_id_fkey FOREIGN KEY (router_id) REFERENCES public.routers(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transactions transactions_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.accounts(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transactions transactions_currency_converted_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_currency_converted_id_fkey FOREIGN KEY (currency_converted_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transactions transactions_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transactions transactions_instrument_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_instrument_id_fkey FOREIGN KEY (instrument_id) REFERENCES public.instruments(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transactions transactions_profile_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES public.profiles(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transactions transactions_reference_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_reference_id_fkey FOREIGN KEY (reference_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: bank_journal bank_journal_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.bank_journal
+    ADD CONSTRAINT bank_journal_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: bank_journal bank_journal_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.bank_journal
+    ADD CONSTRAINT bank_journal_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.accounts(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: reconciliations reconciliations_channel_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reconciliations
+    ADD CONSTRAINT reconciliations_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES public.channels(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: reconciliation_items reconciliation_items_reconciliation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reconciliation_items
+    ADD CONSTRAINT reconciliation_items_reconciliation_id_fkey FOREIGN KEY (reconciliation_id) REFERENCES public.reconciliations(id) ON UPDATE RESTRICT ON DELETE CASCADE;
+
+
+--
+-- Name: reconciliation_items reconciliation_items_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reconciliation_items
+    ADD CONSTRAINT reconciliation_items_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: rates rates_from_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.rates
+    ADD CONSTRAINT rates_from_currency_id_fkey FOREIGN KEY (from_currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: rates rates_to_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.rates
+    ADD CONSTRAINT rates_to_currency_id_fkey FOREIGN KEY (to_currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: fee_rules fee_rules_plan_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.fee_rules
+    ADD CONSTRAINT fee_rules_plan_id_fkey FOREIGN KEY (plan_id) REFERENCES public.fee_plans(id) ON UPDATE RESTRICT ON DELETE CASCADE;
+
+
+--
+-- Name: fee_rules fee_rules_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.fee_rules
+    ADD CONSTRAINT fee_rules_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.accounts(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: profile_fee_plans profile_fee_plans_profile_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.profile_fee_plans
+    ADD CONSTRAINT profile_fee_plans_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES public.profiles(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: profile_fee_plans profile_fee_plans_plan_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.profile_fee_plans
+    ADD CONSTRAINT profile_fee_plans_plan_id_fkey FOREIGN KEY (plan_id) REFERENCES public.fee_plans(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transaction_fees transaction_fees_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transaction_fees
+    ADD CONSTRAINT transaction_fees_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transaction_fees transaction_fees_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transaction_fees
+    ADD CONSTRAINT transaction_fees_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: ledger_entries ledger_entries_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_entries
+    ADD CONSTRAINT ledger_entries_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: ledger_entries ledger_entries_dispute_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_entries
+    ADD CONSTRAINT ledger_entries_dispute_id_fkey FOREIGN KEY (dispute_id) REFERENCES public.disputes(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: ledger_lines ledger_lines_entry_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_lines
+    ADD CONSTRAINT ledger_lines_entry_id_fkey FOREIGN KEY (entry_id) REFERENCES public.ledger_entries(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: ledger_lines ledger_lines_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.ledger_lines
+    ADD CONSTRAINT ledger_lines_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: disputes disputes_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.disputes
+    ADD CONSTRAINT disputes_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: disputes disputes_profile_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.disputes
+    ADD CONSTRAINT disputes_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES public.profiles(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: disputes disputes_currency_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.disputes
+    ADD CONSTRAINT disputes_currency_id_fkey FOREIGN KEY (currency_id) REFERENCES public.currencies(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: dispute_events dispute_events_dispute_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.dispute_events
+    ADD CONSTRAINT dispute_events_dispute_id_fkey FOREIGN KEY (dispute_id) REFERENCES public.disputes(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: dispute_evidence dispute_evidence_dispute_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.dispute_evidence
+    ADD CONSTRAINT dispute_evidence_dispute_id_fkey FOREIGN KEY (dispute_id) REFERENCES public.disputes(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: webhooks webhooks_profile_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.webhooks
+    ADD CONSTRAINT webhooks_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES public.profiles(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: risk_rules risk_rules_profile_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_rules
+    ADD CONSTRAINT risk_rules_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES public.profiles(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: risk_assessments risk_assessments_profile_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_assessments
+    ADD CONSTRAINT risk_assessments_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES public.profiles(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: risk_assessments risk_assessments_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.risk_assessments
+    ADD CONSTRAINT risk_assessments_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: reviews reviews_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reviews
+    ADD CONSTRAINT reviews_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: reviews reviews_assessment_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reviews
+    ADD CONSTRAINT reviews_assessment_id_fkey FOREIGN KEY (assessment_id) REFERENCES public.risk_assessments(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: reviews reviews_result_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.reviews
+    ADD CONSTRAINT reviews_result_id_fkey FOREIGN KEY (result_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+
+--
+-- Name: transaction_bins transaction_bins_transaction_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: kvell
+--
+
+ALTER TABLE ONLY public.transaction_bins
+    ADD CONSTRAINT transaction_bins_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES public.transactions(id) ON UPDATE RESTRICT ON DELETE RESTRICT;
 
 
 --
@@ -0,0 +1,271 @@
+package disputes
+
+import (
+	"fmt"
+	"time"
+	"github.com/serg666/gateway/ledger"
+	"github.com/serg666/gateway/webhooks"
+	"github.com/serg666/repository"
+)
+
+// Dispute states
+const (
+	RETRIEVAL      = "retrieval_request"
+	CHARGEBACK     = "chargeback"
+	REPRESENTMENT  = "representment"
+	PREARBITRATION = "pre_arbitration"
+	WON            = "won"
+	LOST           = "lost"
+)
+
+// Transitions are the states reachable from every state. Won and lost are
+// final
+var Transitions = map[string][]string{
+	RETRIEVAL:      {CHARGEBACK, WON},
+	CHARGEBACK:     {REPRESENTMENT, LOST},
+	REPRESENTMENT:  {PREARBITRATION, WON, LOST},
+	PREARBITRATION: {WON, LOST},
+}
+
+// Expiring are the states waiting for the merchant response. Dispute in such
+// state is lost when its deadline passes
+var Expiring = []string{CHARGEBACK, PREARBITRATION}
+
+// CanTransit will check that dispute in the state can be moved to another one
+func CanTransit(from string, to string) bool {
+	for _, state := range Transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsFinal will check that the state is final
+func IsFinal(state string) bool {
+	_, ok := Transitions[state]
+	return !ok
+}
+
+// Dispute is the claim of the issuer on the original transaction. Amount is
+// in minor units of the transaction currency
+type Dispute struct {
+	Id            *int                 `json:"id"`
+	TransactionId *int                 `json:"transaction_id"`
+	ProfileId     *int                 `json:"profile_id"`
+	State         *string              `json:"state"`
+	Reason        *string              `json:"reason"`
+	Amount        *uint                `json:"amount"`
+	Currency      *repository.Currency `json:"currency"`
+	Deadline      *time.Time           `json:"deadline"`
+	Created       *time.Time           `json:"created"`
+	Updated       *time.Time           `json:"updated"`
+	Events        []*Event             `json:"events,omitempty"`
+	Evidence      []*Evidence          `json:"evidence,omitempty"`
+}
+
+// Event is the state change of the dispute
+type Event struct {
+	Id        *int       `json:"id"`
+	DisputeId *int       `json:"dispute_id"`
+	State     *string    `json:"state"`
+	Note      *string    `json:"note"`
+	Created   *time.Time `json:"created"`
+}
+
+// Lifecycle moves disputes between states posting chargebacks to the ledger
+// and notifying merchants
+type Lifecycle struct {
+	Store       DisputeRepository
+	LedgerStore ledger.LedgerRepository
+	Webhooks    webhooks.WebhookRepository
+	// Deadlines are days given to respond in the state
+	Deadlines   map[string]int
+	LoggerFunc  repository.LoggerFunc
+}
+
+func (l *Lifecycle) deadline(state string, deadline *time.Time) *time.Time {
+	if deadline != nil || IsFinal(state) {
+		return deadline
+	}
+
+	days, ok := l.Deadlines[state]
+	if !ok || days <= 0 {
+		return nil
+	}
+
+	at := time.Now().AddDate(0, 0, days)
+	return &at
+}
+
+func (l *Lifecycle) notify(dispute *Dispute) {
+	webhooks.Notify(l.Webhooks, l.LoggerFunc, *dispute.ProfileId, "dispute."+*dispute.State, dispute)
+}
+
+// Open will open dispute on the successful transaction in retrieval request
+// or chargeback state. Amount defaults to the transaction amount
+func (l *Lifecycle) Open(
+	ctx interface{},
+	transaction *repository.Transaction,
+	state string,
+	reason *string,
+	amount *uint,
+	deadline *time.Time,
+) (error, *Dispute) {
+	if state != RETRIEVAL && state != CHARGEBACK {
+		return fmt.Errorf("dispute can not be opened in %s state", state), nil
+	}
+
+	if !transaction.IsSuccess() {
+		return fmt.Errorf("transaction has wrong state: %s", *transaction.Status), nil
+	}
+
+	if amount == nil {
+		amount = transaction.Amount
+	}
+
+	if *amount == 0 || *amount > *transaction.Amount {
+		return fmt.Errorf("dispute amount %d exceeds transaction amount %d", *amount, *transaction.Amount), nil
+	}
+
+	err, _, opened := l.Store.Query(ctx, NewDisputeSpecificationByTransactionID(*transaction.Id))
+	if err != nil {
+		return fmt.Errorf("failed to query disputes: %v", err), nil
+	}
+
+	for _, dispute := range opened {
+		if !IsFinal(*dispute.State) {
+			return fmt.Errorf("transaction has got active dispute <%d>", *dispute.Id), nil
+		}
+	}
+
+	dispute := &Dispute{
+		TransactionId: transaction.Id,
+		ProfileId:     transaction.Profile.Id,
+		State:         &state,
+		Reason:        reason,
+		Amount:        amount,
+		Currency:      transaction.Currency,
+		Deadline:      l.deadline(state, deadline),
+	}
+
+	if err := l.Store.Add(ctx, dispute, &Event{State: &state, Note: reason}); err != nil {
+		return fmt.Errorf("failed to add dispute: %v", err), nil
+	}
+
+	if state == CHARGEBACK {
+		if err := ledger.PostChargeback(ctx, l.LedgerStore, transaction, dispute.Id, *amount, false); err != nil {
+			l.LoggerFunc(ctx).Warningf("failed to post chargeback of dispute <%d>: %v", *dispute.Id, err)
+		}
+	}
+
+	l.notify(dispute)
+
+	return nil, dispute
+}
+
+// Transit will move the dispute of the transaction to the state. Chargeback
+// is debited to the merchant and credited back when the dispute is won
+func (l *Lifecycle) Transit(
+	ctx interface{},
+	dispute *Dispute,
+	transaction *repository.Transaction,
+	state string,
+	note *string,
+	deadline *time.Time,
+) error {
+	from := *dispute.State
+	if !CanTransit(from, state) {
+		return fmt.Errorf("dispute can not be moved from %s to %s", from, state)
+	}
+
+	dispute.State = &state
+	dispute.Deadline = l.deadline(state, deadline)
+
+	if err, _ := l.Store.Update(ctx, dispute, &Event{State: &state, Note: note}); err != nil {
+		return fmt.Errorf("failed to update dispute: %v", err)
+	}
+
+	// @note: every active state but retrieval request follows the chargeback
+	var err error
+	switch {
+	case state == CHARGEBACK:
+		err = ledger.PostChargeback(ctx, l.LedgerStore, transaction, dispute.Id, *dispute.Amount, false)
+	case state == WON && from != RETRIEVAL:
+		err = ledger.PostChargeback(ctx, l.LedgerStore, transaction, dispute.Id, *dispute.Amount, true)
+	}
+
+	if err != nil {
+		l.LoggerFunc(ctx).Warningf("failed to post chargeback of dispute <%d>: %v", *dispute.Id, err)
+	}
+
+	l.notify(dispute)
+
+	return nil
+}
+
+// Expire will lose disputes which have not been responded before deadline
+func (l *Lifecycle) Expire(ctx interface{}, transactionStore repository.TransactionRepository) error {
+	err, _, overdue := l.Store.Query(ctx, NewDisputeSpecificationOverdue(time.Now(), Expiring))
+	if err != nil {
+		return fmt.Errorf("failed to query overdue disputes: %v", err)
+	}
+
+	for _, dispute := range overdue {
+		err, _, transactions := transactionStore.Query(ctx, repository.NewTransactionSpecificationByID(*dispute.TransactionId))
+		if err != nil {
+			return fmt.Errorf("failed to query transaction: %v", err)
+		}
+
+		if len(transactions) == 0 {
+			l.LoggerFunc(ctx).Errorf("transaction <%d> of dispute <%d> not found", *dispute.TransactionId, *dispute.Id)
+			continue
+		}
+
+		note := "deadline passed"
+		if err := l.Transit(ctx, dispute, transactions[0], LOST, &note, nil); err != nil {
+			l.LoggerFunc(ctx).Errorf("can not expire dispute <%d>: %v", *dispute.Id, err)
+			continue
+		}
+
+		l.LoggerFunc(ctx).Printf("dispute <%d> lost due to passed deadline", *dispute.Id)
+	}
+
+	return nil
+}
+
+// Schedule will periodically expire overdue disputes
+func (l *Lifecycle) Schedule(interval time.Duration, transactionStore repository.TransactionRepository) {
+	if interval <= 0 {
+		l.LoggerFunc(nil).Warningf("dispute expiry has got no interval, it is not scheduled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := l.Expire(nil, transactionStore); err != nil {
+				l.LoggerFunc(nil).Errorf("can not expire disputes: %v", err)
+			}
+		}
+	}()
+}
+
+func NewLifecycle(
+	store DisputeRepository,
+	ledgerStore ledger.LedgerRepository,
+	webhookStore webhooks.WebhookRepository,
+	deadlines map[string]int,
+	loggerFunc repository.LoggerFunc,
+) *Lifecycle {
+	return &Lifecycle{
+		Store:       store,
+		LedgerStore: ledgerStore,
+		Webhooks:    webhookStore,
+		Deadlines:   deadlines,
+		LoggerFunc:  loggerFunc,
+	}
+}
@@ -0,0 +1,51 @@
+package disputes
+
+import (
+	"io"
+	"os"
+	"fmt"
+	"time"
+	"regexp"
+	"path/filepath"
+)
+
+// Evidence is the file attached to the dispute. Files are kept in the local
+// evidence directory
+type Evidence struct {
+	Id          *int       `json:"id"`
+	DisputeId   *int       `json:"dispute_id"`
+	Filename    *string    `json:"filename"`
+	ContentType *string    `json:"content_type"`
+	Size        *int64     `json:"size"`
+	Description *string    `json:"description"`
+	Path        *string    `json:"-"`
+	Created     *time.Time `json:"created"`
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// SaveEvidence will store the file of the dispute under the directory and
+// return its path and size
+func SaveEvidence(dir string, disputeId int, filename string, r io.Reader) (error, string, int64) {
+	disputeDir := filepath.Join(dir, fmt.Sprintf("%d", disputeId))
+	if err := os.MkdirAll(disputeDir, 0750); err != nil {
+		return fmt.Errorf("can not create evidence directory: %v", err), "", 0
+	}
+
+	name := unsafeChars.ReplaceAllString(filepath.Base(filename), "_")
+	path := filepath.Join(disputeDir, fmt.Sprintf("%d_%s", time.Now().UnixNano(), name))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return fmt.Errorf("can not create evidence file: %v", err), "", 0
+	}
+	defer file.Close()
+
+	size, err := io.Copy(file, r)
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("can not write evidence file: %v", err), "", 0
+	}
+
+	return nil, path, size
+}
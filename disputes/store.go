@@ -0,0 +1,319 @@
+package disputes
+
+import (
+	"fmt"
+	"time"
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+type DisputeSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type disputeSpecificationByID struct {
+	id int
+}
+
+func (ds *disputeSpecificationByID) ToSqlClauses() (string, []interface{}) {
+	return "where d.id=$1", []interface{}{ds.id}
+}
+
+func NewDisputeSpecificationByID(id int) DisputeSpecification {
+	return &disputeSpecificationByID{id: id}
+}
+
+type disputeSpecificationByTransactionID struct {
+	id int
+}
+
+func (ds *disputeSpecificationByTransactionID) ToSqlClauses() (string, []interface{}) {
+	return "where d.transaction_id=$1 order by d.id desc", []interface{}{ds.id}
+}
+
+func NewDisputeSpecificationByTransactionID(id int) DisputeSpecification {
+	return &disputeSpecificationByTransactionID{id: id}
+}
+
+type disputeSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (ds *disputeSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by d.id desc limit $1 offset $2", []interface{}{ds.limit, ds.offset}
+}
+
+func NewDisputeSpecificationWithLimitAndOffset(limit int, offset int) DisputeSpecification {
+	return &disputeSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type disputeSpecificationByStateWithLimitAndOffset struct {
+	state  string
+	limit  int
+	offset int
+}
+
+func (ds *disputeSpecificationByStateWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "where d.state=$1 order by d.id desc limit $2 offset $3", []interface{}{ds.state, ds.limit, ds.offset}
+}
+
+func NewDisputeSpecificationByStateWithLimitAndOffset(state string, limit int, offset int) DisputeSpecification {
+	return &disputeSpecificationByStateWithLimitAndOffset{state: state, limit: limit, offset: offset}
+}
+
+type disputeSpecificationOverdue struct {
+	at     time.Time
+	states []string
+}
+
+func (ds *disputeSpecificationOverdue) ToSqlClauses() (string, []interface{}) {
+	return "where d.state=any($1) and d.deadline<$2 order by d.deadline", []interface{}{ds.states, ds.at}
+}
+
+// NewDisputeSpecificationOverdue will select disputes in the states with
+// deadline before the time
+func NewDisputeSpecificationOverdue(at time.Time, states []string) DisputeSpecification {
+	return &disputeSpecificationOverdue{at: at, states: states}
+}
+
+type DisputeRepository interface {
+	// Add will insert the dispute with its first event
+	Add(ctx interface{}, dispute *Dispute, event *Event) error
+	// Update will update state and deadline of the dispute adding the event
+	Update(ctx interface{}, dispute *Dispute, event *Event) (error, bool)
+	Query(ctx interface{}, specification DisputeSpecification) (error, int, []*Dispute)
+	Events(ctx interface{}, disputeId int) (error, []*Event)
+
+	AddEvidence(ctx interface{}, evidence *Evidence) error
+	Evidence(ctx interface{}, disputeId int) (error, []*Evidence)
+}
+
+type PGPoolDisputeStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func addEvent(ctx context.Context, tx pgx.Tx, event *Event) error {
+	return tx.QueryRow(
+		ctx,
+		"insert into dispute_events (dispute_id, state, note) values ($1, $2, $3) returning id, created",
+		event.DisputeId,
+		event.State,
+		event.Note,
+	).Scan(&event.Id, &event.Created)
+}
+
+func (ds *PGPoolDisputeStore) Add(ctx interface{}, dispute *Dispute, event *Event) error {
+	tx, err := ds.pool.Begin(tracing.ContextOf(ctx))
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(tracing.ContextOf(ctx))
+
+	if err := tx.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into disputes (
+			transaction_id,
+			profile_id,
+			state,
+			reason,
+			amount,
+			currency_id,
+			deadline
+		) values ($1, $2, $3, $4, $5, $6, $7) returning id, created, updated`,
+		dispute.TransactionId,
+		dispute.ProfileId,
+		dispute.State,
+		dispute.Reason,
+		dispute.Amount,
+		dispute.Currency.Id,
+		dispute.Deadline,
+	).Scan(&dispute.Id, &dispute.Created, &dispute.Updated); err != nil {
+		return err
+	}
+
+	event.DisputeId = dispute.Id
+	if err := addEvent(tracing.ContextOf(ctx), tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit(tracing.ContextOf(ctx))
+}
+
+func (ds *PGPoolDisputeStore) Update(ctx interface{}, dispute *Dispute, event *Event) (error, bool) {
+	tx, err := ds.pool.Begin(tracing.ContextOf(ctx))
+	if err != nil {
+		return err, false
+	}
+	defer tx.Rollback(tracing.ContextOf(ctx))
+
+	err = tx.QueryRow(
+		tracing.ContextOf(ctx),
+		`update disputes set state=$2, deadline=$3, updated=CURRENT_TIMESTAMP
+		where id=$1 returning updated`,
+		dispute.Id,
+		dispute.State,
+		dispute.Deadline,
+	).Scan(&dispute.Updated)
+
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("dispute with id=%d not found", *dispute.Id), true
+	}
+
+	if err != nil {
+		return err, false
+	}
+
+	event.DisputeId = dispute.Id
+	if err := addEvent(tracing.ContextOf(ctx), tx, event); err != nil {
+		return err, false
+	}
+
+	return tx.Commit(tracing.ContextOf(ctx)), false
+}
+
+func (ds *PGPoolDisputeStore) Query(ctx interface{}, specification DisputeSpecification) (error, int, []*Dispute) {
+	var disputes []*Dispute
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := ds.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			d.id,
+			d.transaction_id,
+			d.profile_id,
+			d.state,
+			d.reason,
+			d.amount,
+			d.deadline,
+			d.created,
+			d.updated,
+			c.id,
+			c.numeric_code,
+			c.name,
+			c.char_code,
+			c.exponent,
+			count(*) over()
+		from disputes d
+		join currencies c on c.id=d.currency_id %s`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		dispute := &Dispute{Currency: &repository.Currency{}}
+		if err := rows.Scan(
+			&dispute.Id,
+			&dispute.TransactionId,
+			&dispute.ProfileId,
+			&dispute.State,
+			&dispute.Reason,
+			&dispute.Amount,
+			&dispute.Deadline,
+			&dispute.Created,
+			&dispute.Updated,
+			&dispute.Currency.Id,
+			&dispute.Currency.NumericCode,
+			&dispute.Currency.Name,
+			&dispute.Currency.CharCode,
+			&dispute.Currency.Exponent,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, disputes
+}
+
+func (ds *PGPoolDisputeStore) Events(ctx interface{}, disputeId int) (error, []*Event) {
+	var events []*Event
+
+	rows, err := ds.pool.Query(
+		tracing.ContextOf(ctx),
+		"select id, dispute_id, state, note, created from dispute_events where dispute_id=$1 order by id",
+		disputeId,
+	)
+	if err != nil {
+		return err, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := &Event{}
+		if err := rows.Scan(&event.Id, &event.DisputeId, &event.State, &event.Note, &event.Created); err != nil {
+			return err, nil
+		}
+		events = append(events, event)
+	}
+
+	return rows.Err(), events
+}
+
+func (ds *PGPoolDisputeStore) AddEvidence(ctx interface{}, evidence *Evidence) error {
+	return ds.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into dispute_evidence (dispute_id, filename, content_type, size, description, path)
+		values ($1, $2, $3, $4, $5, $6) returning id, created`,
+		evidence.DisputeId,
+		evidence.Filename,
+		evidence.ContentType,
+		evidence.Size,
+		evidence.Description,
+		evidence.Path,
+	).Scan(&evidence.Id, &evidence.Created)
+}
+
+func (ds *PGPoolDisputeStore) Evidence(ctx interface{}, disputeId int) (error, []*Evidence) {
+	var evidence []*Evidence
+
+	rows, err := ds.pool.Query(
+		tracing.ContextOf(ctx),
+		`select id, dispute_id, filename, content_type, size, description, path, created
+		from dispute_evidence where dispute_id=$1 order by id`,
+		disputeId,
+	)
+	if err != nil {
+		return err, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &Evidence{}
+		if err := rows.Scan(
+			&item.Id,
+			&item.DisputeId,
+			&item.Filename,
+			&item.ContentType,
+			&item.Size,
+			&item.Description,
+			&item.Path,
+			&item.Created,
+		); err != nil {
+			return err, nil
+		}
+		evidence = append(evidence, item)
+	}
+
+	return rows.Err(), evidence
+}
+
+func NewPGPoolDisputeStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) DisputeRepository {
+	return &PGPoolDisputeStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
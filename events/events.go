@@ -0,0 +1,182 @@
+package events
+
+import (
+	"sync"
+	"time"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/repository"
+)
+
+// Channel is the channel transaction status changes are notified on by
+// the trigger of the transactions table
+const Channel = "transaction_status"
+
+// reconnect is the time between attempts to listen again after the
+// connection is lost
+const reconnect = 5 * time.Second
+
+// Status is the status of the transaction as notified
+type Status struct {
+	Id           int     `json:"id"`
+	ProfileId    int     `json:"profile_id"`
+	Type         string  `json:"type"`
+	Status       string  `json:"status"`
+	ResponseCode *string `json:"response_code"`
+	ReferenceId  *int    `json:"reference_id"`
+}
+
+// StatusOf will return the status of the transaction as it would be
+// notified
+func StatusOf(transaction *repository.Transaction) *Status {
+	status := &Status{
+		Id:           *transaction.Id,
+		ProfileId:    *transaction.Profile.Id,
+		Type:         *transaction.Type,
+		Status:       *transaction.Status,
+		ResponseCode: transaction.ResponseCode,
+	}
+
+	if transaction.Reference != nil {
+		status.ReferenceId = transaction.Reference.Id
+	}
+
+	return status
+}
+
+// Subscription receives status changes of transactions of the profile or
+// of the one transaction
+type Subscription struct {
+	C             chan *Status
+	profileId     int
+	transactionId *int
+	broker        *Broker
+}
+
+func (s *Subscription) matches(status *Status) bool {
+	return s.transactionId == nil || *s.transactionId == status.Id
+}
+
+// Done is closed when the broker is closed and the subscription gets no
+// more changes
+func (s *Subscription) Done() <-chan struct{} {
+	return s.broker.done
+}
+
+// Close will stop the subscription
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	delete(s.broker.subscriptions[s.profileId], s)
+	if len(s.broker.subscriptions[s.profileId]) == 0 {
+		delete(s.broker.subscriptions, s.profileId)
+	}
+}
+
+// Broker listens to status changes notified by the database and passes
+// them to subscriptions. Changes made by any gateway instance are notified
+// to every instance
+type Broker struct {
+	pool          *pgxpool.Pool
+	loggerFunc    repository.LoggerFunc
+	mu            sync.Mutex
+	subscriptions map[int]map[*Subscription]struct{}
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+// Close will end all subscriptions, streams of them are closed then
+func (b *Broker) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+}
+
+// Subscribe will subscribe to changes of transactions of the profile or the
+// one transaction if its id is given
+func (b *Broker) Subscribe(profileId int, transactionId *int) *Subscription {
+	s := &Subscription{
+		C:             make(chan *Status, 16),
+		profileId:     profileId,
+		transactionId: transactionId,
+		broker:        b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscriptions[profileId] == nil {
+		b.subscriptions[profileId] = make(map[*Subscription]struct{})
+	}
+	b.subscriptions[profileId][s] = struct{}{}
+
+	return s
+}
+
+func (b *Broker) publish(status *Status) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscriptions[status.ProfileId] {
+		if !s.matches(status) {
+			continue
+		}
+
+		// @note: slow subscribers must not hold up others
+		select {
+		case s.C <- status:
+		default:
+			b.loggerFunc(nil).Warningf("status %s of transaction %d dropped for slow subscriber", status.Status, status.Id)
+		}
+	}
+}
+
+func (b *Broker) listen() error {
+	conn, err := b.pool.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(context.Background(), "listen "+Channel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(context.Background())
+		if err != nil {
+			return err
+		}
+
+		status := &Status{}
+		if err := json.Unmarshal([]byte(notification.Payload), status); err != nil {
+			b.loggerFunc(nil).Warningf("can not unmarshal transaction status: %v", err)
+			continue
+		}
+
+		b.publish(status)
+	}
+}
+
+// Listen will listen to status changes in background. One connection of
+// the pool is kept for it
+func (b *Broker) Listen() {
+	go func() {
+		for {
+			err := b.listen()
+			b.loggerFunc(nil).Errorf("can not listen to transaction status changes: %v", err)
+			time.Sleep(reconnect)
+		}
+	}()
+}
+
+func NewBroker(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) *Broker {
+	return &Broker{
+		pool:          pool,
+		loggerFunc:    loggerFunc,
+		subscriptions: make(map[int]map[*Subscription]struct{}),
+		done:          make(chan struct{}),
+	}
+}
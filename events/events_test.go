@@ -0,0 +1,158 @@
+package events
+
+import (
+	"sync"
+	"reflect"
+	"testing"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// newBroker will return the broker without the pool. Statuses are published
+// directly, warnings are kept by the hook
+func newBroker() (*Broker, *test.Hook) {
+	logger, hook := test.NewNullLogger()
+	return NewBroker(nil, func(interface{}) logrus.FieldLogger { return logger }), hook
+}
+
+// received will drain statuses delivered to the subscription
+func received(s *Subscription) []int {
+	var ids []int
+	for {
+		select {
+		case status := <-s.C:
+			ids = append(ids, status.Id)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	one := 1
+
+	b, _ := newBroker()
+	profile := b.Subscribe(10, nil)
+	transaction := b.Subscribe(10, &one)
+	other := b.Subscribe(20, nil)
+
+	for _, status := range []*Status{
+		{Id: 1, ProfileId: 10, Status: "new"},
+		{Id: 2, ProfileId: 10, Status: "new"},
+		{Id: 1, ProfileId: 10, Status: "success"},
+		{Id: 3, ProfileId: 30, Status: "new"},
+	} {
+		b.publish(status)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		subscription *Subscription
+		want         []int
+	}{
+		{name: "profile", subscription: profile, want: []int{1, 2, 1}},
+		{name: "transaction", subscription: transaction, want: []int{1, 1}},
+		{name: "other profile", subscription: other},
+	} {
+		if got := received(tt.subscription); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s subscription received %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	b, hook := newBroker()
+	slow := b.Subscribe(10, nil)
+	fast := b.Subscribe(10, nil)
+
+	// @note: the buffer of the slow one is full, publish must not block
+	for i := 0; i <= cap(slow.C); i++ {
+		b.publish(&Status{Id: i, ProfileId: 10, Status: "new"})
+		received(fast)
+	}
+
+	if n := len(received(slow)); n != cap(slow.C) {
+		t.Errorf("slow subscription received %d statuses, want %d", n, cap(slow.C))
+	}
+
+	if len(hook.Entries) != 1 || hook.LastEntry().Level != logrus.WarnLevel {
+		t.Errorf("dropped status is not warned about: %v", hook.Entries)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	b, _ := newBroker()
+	first := b.Subscribe(10, nil)
+	second := b.Subscribe(10, nil)
+
+	first.Close()
+	b.publish(&Status{Id: 1, ProfileId: 10, Status: "new"})
+
+	if got := received(first); len(got) != 0 {
+		t.Errorf("closed subscription received %v", got)
+	}
+	if got := received(second); len(got) != 1 {
+		t.Errorf("open subscription received %v", got)
+	}
+
+	second.Close()
+	if len(b.subscriptions) != 0 {
+		t.Errorf("subscriptions of the profile are kept: %v", b.subscriptions)
+	}
+}
+
+// TestConcurrentSubscriptions is meant to be run with -race
+func TestConcurrentSubscriptions(t *testing.T) {
+	b, _ := newBroker()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s := b.Subscribe(10, nil)
+				received(s)
+				s.Close()
+			}
+		}()
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.publish(&Status{Id: id, ProfileId: 10, Status: "new"})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(b.subscriptions) != 0 {
+		t.Errorf("subscriptions are kept: %v", b.subscriptions)
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b, _ := newBroker()
+	s := b.Subscribe(10, nil)
+
+	select {
+	case <-s.Done():
+		t.Fatalf("subscription is done before the broker is closed")
+	default:
+	}
+
+	// @note: shutdown hooks may close the broker more than once
+	b.Close()
+	b.Close()
+
+	select {
+	case <-s.Done():
+	default:
+		t.Fatalf("subscription is not done after the broker is closed")
+	}
+
+	select {
+	case <-b.Subscribe(10, nil).Done():
+	default:
+		t.Errorf("subscription made after close is not done")
+	}
+}
@@ -0,0 +1,187 @@
+package fees
+
+import (
+	"fmt"
+	"math"
+	"github.com/serg666/repository"
+)
+
+// Plan is the named set of fee rules assignable to profiles
+type Plan struct {
+	Id    *int    `json:"id"`
+	Name  *string `json:"name"`
+	Rules []*Rule `json:"rules,omitempty"`
+}
+
+// Captured are transaction types moving money to the merchant. Rules
+// without type match them only, so a preauthorization and its confirmation
+// are not billed twice. Fees of other types need the rule of the type
+var Captured = []string{
+	repository.AUTH,
+	repository.CONFIRMAUTH,
+	repository.REBILL,
+}
+
+func captured(kind string) bool {
+	for _, c := range Captured {
+		if c == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rule is the fee of transactions matching account, card brand and
+// transaction type. Empty criteria match any value, empty type matches
+// Captured types. Amounts are in minor units of the profile currency,
+// Percent is applied to transaction amount
+type Rule struct {
+	Id        *int     `json:"id"`
+	PlanId    *int     `json:"plan_id"`
+	AccountId *int     `json:"account_id"`
+	Brand     *string  `json:"brand"`
+	Type      *string  `json:"type"`
+	Fixed     *uint    `json:"fixed"`
+	Percent   *float64 `json:"percent"`
+	Min       *uint    `json:"min"`
+	Max       *uint    `json:"max"`
+}
+
+func (r *Rule) matches(transaction *repository.Transaction, brand *string) bool {
+	if r.AccountId != nil && *r.AccountId != *transaction.Account.Id {
+		return false
+	}
+
+	if r.Type == nil && !captured(*transaction.Type) {
+		return false
+	}
+
+	if r.Type != nil && *r.Type != *transaction.Type {
+		return false
+	}
+
+	if r.Brand != nil && (brand == nil || *r.Brand != *brand) {
+		return false
+	}
+
+	return true
+}
+
+func (r *Rule) specificity() int {
+	specificity := 0
+	for _, set := range []bool{r.AccountId != nil, r.Brand != nil, r.Type != nil} {
+		if set {
+			specificity++
+		}
+	}
+
+	return specificity
+}
+
+// Calculate will return fee of the amount
+func (r *Rule) Calculate(amount uint) uint {
+	fee := 0.0
+	if r.Fixed != nil {
+		fee += float64(*r.Fixed)
+	}
+
+	if r.Percent != nil {
+		fee += float64(amount) * *r.Percent / 100
+	}
+
+	result := uint(math.Round(fee))
+
+	if r.Min != nil && result < *r.Min {
+		result = *r.Min
+	}
+
+	if r.Max != nil && result > *r.Max {
+		result = *r.Max
+	}
+
+	return result
+}
+
+// Select will return the most specific rule matching the transaction.
+// Rules of the same specificity are taken in order
+func Select(rules []*Rule, transaction *repository.Transaction, brand *string) *Rule {
+	var selected *Rule
+
+	for _, rule := range rules {
+		if !rule.matches(transaction, brand) {
+			continue
+		}
+
+		if selected == nil || rule.specificity() > selected.specificity() {
+			selected = rule
+		}
+	}
+
+	return selected
+}
+
+// Fee is the fee of the transaction. It is charged when the transaction
+// becomes successful
+type Fee struct {
+	TransactionId *int                 `json:"transaction_id"`
+	PlanId        *int                 `json:"plan_id"`
+	RuleId        *int                 `json:"rule_id"`
+	Brand         *string              `json:"brand"`
+	Amount        *uint                `json:"amount"`
+	Currency      *repository.Currency `json:"currency"`
+	Charged       *bool                `json:"charged"`
+}
+
+// Compute will compute and store fee of the new transaction by the plan of
+// its profile. Transactions of profiles without plan have got no fee.
+// Operations on the reference inherit the card brand of the reference
+func Compute(ctx interface{}, store FeeRepository, transaction *repository.Transaction, brand *string) (error, *Fee) {
+	err, planId := store.PlanOf(ctx, *transaction.Profile.Id)
+	if err != nil {
+		return fmt.Errorf("failed to query fee plan: %v", err), nil
+	}
+
+	if planId == nil {
+		return nil, nil
+	}
+
+	if brand == nil && transaction.Reference != nil {
+		err, reference := store.QueryFee(ctx, *transaction.Reference.Id)
+		if err != nil {
+			return fmt.Errorf("failed to query reference fee: %v", err), nil
+		}
+
+		if reference != nil {
+			brand = reference.Brand
+		}
+	}
+
+	err, _, rules := store.QueryRules(ctx, NewRuleSpecificationByPlanID(*planId))
+	if err != nil {
+		return fmt.Errorf("failed to query fee rules: %v", err), nil
+	}
+
+	rule := Select(rules, transaction, brand)
+	if rule == nil {
+		return nil, nil
+	}
+
+	amount := rule.Calculate(*transaction.Amount)
+	charged := false
+	fee := &Fee{
+		TransactionId: transaction.Id,
+		PlanId:        planId,
+		RuleId:        rule.Id,
+		Brand:         brand,
+		Amount:        &amount,
+		Currency:      transaction.Profile.Currency,
+		Charged:       &charged,
+	}
+
+	if err := store.AddFee(ctx, fee); err != nil {
+		return fmt.Errorf("failed to add fee: %v", err), nil
+	}
+
+	return nil, fee
+}
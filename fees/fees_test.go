@@ -0,0 +1,230 @@
+package fees
+
+import (
+	"fmt"
+	"testing"
+	"github.com/serg666/repository"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func number(n int) *int {
+	return &n
+}
+
+func amount(n uint) *uint {
+	return &n
+}
+
+func percent(p float64) *float64 {
+	return &p
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   Rule
+		amount uint
+		want   uint
+	}{
+		{name: "no fee", rule: Rule{}, amount: 1000, want: 0},
+		{name: "fixed", rule: Rule{Fixed: amount(30)}, amount: 1000, want: 30},
+		{name: "percent", rule: Rule{Percent: percent(2.5)}, amount: 1000, want: 25},
+		{name: "fixed and percent", rule: Rule{Fixed: amount(30), Percent: percent(2.5)}, amount: 1000, want: 55},
+		{name: "rounded half up", rule: Rule{Percent: percent(1.5)}, amount: 100, want: 2},
+		{name: "rounded down", rule: Rule{Percent: percent(1.4)}, amount: 100, want: 1},
+		{name: "min", rule: Rule{Percent: percent(1), Min: amount(50)}, amount: 1000, want: 50},
+		{name: "max", rule: Rule{Percent: percent(10), Max: amount(50)}, amount: 1000, want: 50},
+		{name: "within bounds", rule: Rule{Percent: percent(3), Min: amount(10), Max: amount(50)}, amount: 1000, want: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Calculate(tt.amount); got != tt.want {
+				t.Errorf("Calculate(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	auth := repository.AUTH
+	transaction := &repository.Transaction{
+		Account: &repository.Account{Id: number(1)},
+		Type:    &auth,
+	}
+
+	generic := &Rule{Id: number(1)}
+	second := &Rule{Id: number(2)}
+	byBrand := &Rule{Id: number(3), Brand: str("visa")}
+	byType := &Rule{Id: number(4), Type: str(repository.REFUND)}
+	byAccount := &Rule{Id: number(5), AccountId: number(1)}
+	byAccountAndBrand := &Rule{Id: number(6), AccountId: number(1), Brand: str("visa")}
+	otherAccount := &Rule{Id: number(7), AccountId: number(2), Brand: str("visa"), Type: str(repository.AUTH)}
+
+	tests := []struct {
+		name  string
+		rules []*Rule
+		brand *string
+		want  *Rule
+	}{
+		{name: "no rules", want: nil},
+		{name: "nothing matches", rules: []*Rule{byType, otherAccount}, brand: str("visa"), want: nil},
+		{name: "generic", rules: []*Rule{generic, byType}, want: generic},
+		{name: "first of the same specificity", rules: []*Rule{generic, second}, want: generic},
+		{name: "brand is more specific", rules: []*Rule{generic, byBrand}, brand: str("visa"), want: byBrand},
+		{name: "brand unknown", rules: []*Rule{generic, byBrand}, want: generic},
+		{name: "brand differs", rules: []*Rule{generic, byBrand}, brand: str("mir"), want: generic},
+		{name: "most specific wins", rules: []*Rule{byAccountAndBrand, byBrand, byAccount, generic}, brand: str("visa"), want: byAccountAndBrand},
+		{name: "account of other transaction", rules: []*Rule{otherAccount, byAccount}, brand: str("visa"), want: byAccount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Select(tt.rules, transaction, tt.brand)
+			if got != tt.want {
+				id := func(r *Rule) interface{} {
+					if r == nil {
+						return nil
+					}
+					return *r.Id
+				}
+				t.Errorf("Select = rule %v, want rule %v", id(got), id(tt.want))
+			}
+		})
+	}
+}
+
+func TestSelectType(t *testing.T) {
+	generic := &Rule{Id: number(1)}
+	byRefund := &Rule{Id: number(2), Type: str(repository.REFUND)}
+
+	tests := []struct {
+		kind string
+		want *Rule
+	}{
+		{kind: repository.AUTH, want: generic},
+		{kind: repository.CONFIRMAUTH, want: generic},
+		{kind: repository.REBILL, want: generic},
+		{kind: repository.PREAUTH, want: nil},
+		{kind: repository.REVERSAL, want: nil},
+		{kind: repository.REFUND, want: byRefund},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			kind := tt.kind
+			transaction := &repository.Transaction{
+				Account: &repository.Account{Id: number(1)},
+				Type:    &kind,
+			}
+
+			if got := Select([]*Rule{generic, byRefund}, transaction, nil); got != tt.want {
+				t.Errorf("Select = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// memoryFeeStore keeps fees of transactions, the plan of every profile is
+// the one with the given rules
+type memoryFeeStore struct {
+	rules []*Rule
+	fees  map[int]*Fee
+}
+
+func (ms *memoryFeeStore) AddPlan(ctx interface{}, plan *Plan) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryFeeStore) DeletePlan(ctx interface{}, plan *Plan) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryFeeStore) QueryPlans(ctx interface{}, specification PlanSpecification) (error, int, []*Plan) {
+	return fmt.Errorf("not implemented"), 0, nil
+}
+
+func (ms *memoryFeeStore) AddRule(ctx interface{}, rule *Rule) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryFeeStore) DeleteRule(ctx interface{}, rule *Rule) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryFeeStore) QueryRules(ctx interface{}, specification RuleSpecification) (error, int, []*Rule) {
+	return nil, len(ms.rules), ms.rules
+}
+
+func (ms *memoryFeeStore) AssignPlan(ctx interface{}, profileId int, planId int) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryFeeStore) UnassignPlan(ctx interface{}, profileId int) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryFeeStore) PlanOf(ctx interface{}, profileId int) (error, *int) {
+	return nil, number(1)
+}
+
+func (ms *memoryFeeStore) AddFee(ctx interface{}, fee *Fee) error {
+	ms.fees[*fee.TransactionId] = fee
+	return nil
+}
+
+func (ms *memoryFeeStore) Charge(ctx interface{}, transactionId int) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryFeeStore) QueryFee(ctx interface{}, transactionId int) (error, *Fee) {
+	return nil, ms.fees[transactionId]
+}
+
+func TestComputePreAuthAndConfirm(t *testing.T) {
+	store := &memoryFeeStore{
+		rules: []*Rule{{Id: number(1), Fixed: amount(30)}},
+		fees:  map[int]*Fee{},
+	}
+
+	profile := &repository.Profile{Id: number(10)}
+	account := &repository.Account{Id: number(1)}
+	preauth := &repository.Transaction{
+		Id:      number(1),
+		Profile: profile,
+		Account: account,
+		Type:    str(repository.PREAUTH),
+		Amount:  amount(1000),
+	}
+	confirm := &repository.Transaction{
+		Id:        number(2),
+		Profile:   profile,
+		Account:   account,
+		Type:      str(repository.CONFIRMAUTH),
+		Amount:    amount(1000),
+		Reference: preauth,
+	}
+
+	err, fee := Compute(nil, store, preauth, str("visa"))
+	if err != nil {
+		t.Fatalf("Compute of preauth failed: %v", err)
+	}
+	if fee != nil {
+		t.Errorf("preauth is charged %d", *fee.Amount)
+	}
+
+	err, fee = Compute(nil, store, confirm, str("visa"))
+	if err != nil {
+		t.Fatalf("Compute of confirm failed: %v", err)
+	}
+	if fee == nil || *fee.Amount != 30 || *fee.Brand != "visa" {
+		t.Fatalf("confirm fee = %+v, want 30 of visa", fee)
+	}
+
+	if len(store.fees) != 1 {
+		t.Errorf("fees = %v, want the one of confirm", store.fees)
+	}
+}
@@ -0,0 +1,338 @@
+package fees
+
+import (
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+type PlanSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type planSpecificationByID struct {
+	id int
+}
+
+func (ps *planSpecificationByID) ToSqlClauses() (string, []interface{}) {
+	return "where id=$1", []interface{}{ps.id}
+}
+
+func NewPlanSpecificationByID(id int) PlanSpecification {
+	return &planSpecificationByID{id: id}
+}
+
+type planSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (ps *planSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id limit $1 offset $2", []interface{}{ps.limit, ps.offset}
+}
+
+func NewPlanSpecificationWithLimitAndOffset(limit int, offset int) PlanSpecification {
+	return &planSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type RuleSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type ruleSpecificationByPlanID struct {
+	id int
+}
+
+func (rs *ruleSpecificationByPlanID) ToSqlClauses() (string, []interface{}) {
+	return "where plan_id=$1", []interface{}{rs.id}
+}
+
+func NewRuleSpecificationByPlanID(id int) RuleSpecification {
+	return &ruleSpecificationByPlanID{id: id}
+}
+
+type FeeRepository interface {
+	AddPlan(ctx interface{}, plan *Plan) error
+	DeletePlan(ctx interface{}, plan *Plan) (error, bool)
+	QueryPlans(ctx interface{}, specification PlanSpecification) (error, int, []*Plan)
+
+	AddRule(ctx interface{}, rule *Rule) error
+	DeleteRule(ctx interface{}, rule *Rule) (error, bool)
+	QueryRules(ctx interface{}, specification RuleSpecification) (error, int, []*Rule)
+
+	// AssignPlan will assign the plan to the profile replacing previous one
+	AssignPlan(ctx interface{}, profileId int, planId int) error
+	UnassignPlan(ctx interface{}, profileId int) (error, bool)
+	// PlanOf will return plan id of the profile or nil
+	PlanOf(ctx interface{}, profileId int) (error, *int)
+
+	AddFee(ctx interface{}, fee *Fee) error
+	// Charge will mark fee of the transaction charged
+	Charge(ctx interface{}, transactionId int) (error, bool)
+	// QueryFee will return fee of the transaction or nil
+	QueryFee(ctx interface{}, transactionId int) (error, *Fee)
+}
+
+type PGPoolFeeStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (fs *PGPoolFeeStore) AddPlan(ctx interface{}, plan *Plan) error {
+	return fs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		"insert into fee_plans (name) values ($1) returning id",
+		plan.Name,
+	).Scan(&plan.Id)
+}
+
+func (fs *PGPoolFeeStore) DeletePlan(ctx interface{}, plan *Plan) (error, bool) {
+	ct, err := fs.pool.Exec(tracing.ContextOf(ctx), "delete from fee_plans where id=$1", plan.Id)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("fee plan with id=%d not found", *plan.Id), true
+	}
+
+	return nil, false
+}
+
+func (fs *PGPoolFeeStore) QueryPlans(ctx interface{}, specification PlanSpecification) (error, int, []*Plan) {
+	var plans []*Plan
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := fs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf("select id, name, count(*) over() from fee_plans %s", where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		plan := &Plan{}
+		if err := rows.Scan(&plan.Id, &plan.Name, &overall); err != nil {
+			return err, 0, nil
+		}
+		plans = append(plans, plan)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, plans
+}
+
+func (fs *PGPoolFeeStore) AddRule(ctx interface{}, rule *Rule) error {
+	return fs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into fee_rules (plan_id, account_id, brand, type, fixed, percent, min, max)
+		values ($1, $2, $3, $4, $5, $6, $7, $8) returning id`,
+		rule.PlanId,
+		rule.AccountId,
+		rule.Brand,
+		rule.Type,
+		rule.Fixed,
+		rule.Percent,
+		rule.Min,
+		rule.Max,
+	).Scan(&rule.Id)
+}
+
+func (fs *PGPoolFeeStore) DeleteRule(ctx interface{}, rule *Rule) (error, bool) {
+	ct, err := fs.pool.Exec(
+		tracing.ContextOf(ctx),
+		"delete from fee_rules where id=$1 and plan_id=$2",
+		rule.Id,
+		rule.PlanId,
+	)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("fee rule with id=%d not found", *rule.Id), true
+	}
+
+	return nil, false
+}
+
+func (fs *PGPoolFeeStore) QueryRules(ctx interface{}, specification RuleSpecification) (error, int, []*Rule) {
+	var rules []*Rule
+
+	where, args := specification.ToSqlClauses()
+	rows, err := fs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select id, plan_id, account_id, brand, type, fixed, percent, min, max
+		from fee_rules %s order by id`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := &Rule{}
+		if err := rows.Scan(
+			&rule.Id,
+			&rule.PlanId,
+			&rule.AccountId,
+			&rule.Brand,
+			&rule.Type,
+			&rule.Fixed,
+			&rule.Percent,
+			&rule.Min,
+			&rule.Max,
+		); err != nil {
+			return err, 0, nil
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, len(rules), rules
+}
+
+func (fs *PGPoolFeeStore) AssignPlan(ctx interface{}, profileId int, planId int) error {
+	_, err := fs.pool.Exec(
+		tracing.ContextOf(ctx),
+		`insert into profile_fee_plans (profile_id, plan_id) values ($1, $2)
+		on conflict (profile_id) do update set plan_id=excluded.plan_id`,
+		profileId,
+		planId,
+	)
+
+	return err
+}
+
+func (fs *PGPoolFeeStore) UnassignPlan(ctx interface{}, profileId int) (error, bool) {
+	ct, err := fs.pool.Exec(tracing.ContextOf(ctx), "delete from profile_fee_plans where profile_id=$1", profileId)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("profile <%d> has got no fee plan", profileId), true
+	}
+
+	return nil, false
+}
+
+func (fs *PGPoolFeeStore) PlanOf(ctx interface{}, profileId int) (error, *int) {
+	var planId int
+
+	err := fs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		"select plan_id from profile_fee_plans where profile_id=$1",
+		profileId,
+	).Scan(&planId)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return err, nil
+	}
+
+	return nil, &planId
+}
+
+func (fs *PGPoolFeeStore) AddFee(ctx interface{}, fee *Fee) error {
+	_, err := fs.pool.Exec(
+		tracing.ContextOf(ctx),
+		`insert into transaction_fees (transaction_id, plan_id, rule_id, brand, amount, currency_id, charged)
+		values ($1, $2, $3, $4, $5, $6, $7)`,
+		fee.TransactionId,
+		fee.PlanId,
+		fee.RuleId,
+		fee.Brand,
+		fee.Amount,
+		fee.Currency.Id,
+		fee.Charged,
+	)
+
+	return err
+}
+
+func (fs *PGPoolFeeStore) Charge(ctx interface{}, transactionId int) (error, bool) {
+	ct, err := fs.pool.Exec(
+		tracing.ContextOf(ctx),
+		"update transaction_fees set charged=true where transaction_id=$1",
+		transactionId,
+	)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("fee of transaction <%d> not found", transactionId), true
+	}
+
+	return nil, false
+}
+
+func (fs *PGPoolFeeStore) QueryFee(ctx interface{}, transactionId int) (error, *Fee) {
+	fee := &Fee{Currency: &repository.Currency{}}
+
+	err := fs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`select
+			f.transaction_id,
+			f.plan_id,
+			f.rule_id,
+			f.brand,
+			f.amount,
+			f.charged,
+			c.id,
+			c.numeric_code,
+			c.name,
+			c.char_code,
+			c.exponent
+		from transaction_fees f
+		join currencies c on c.id=f.currency_id
+		where f.transaction_id=$1`,
+		transactionId,
+	).Scan(
+		&fee.TransactionId,
+		&fee.PlanId,
+		&fee.RuleId,
+		&fee.Brand,
+		&fee.Amount,
+		&fee.Charged,
+		&fee.Currency.Id,
+		&fee.Currency.NumericCode,
+		&fee.Currency.Name,
+		&fee.Currency.CharCode,
+		&fee.Currency.Exponent,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return err, nil
+	}
+
+	return nil, fee
+}
+
+func NewPGPoolFeeStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) FeeRepository {
+	return &PGPoolFeeStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"net/http"
 	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/plugins"
 	"github.com/serg666/repository"
 )
 
@@ -43,6 +44,7 @@ type accountHandler struct {
 	store         repository.AccountRepository
 	currencyStore repository.CurrencyRepository
 	channelStore  repository.ChannelRepository
+	stateStore    plugins.PluginStateRepository
 }
 
 func (ah *accountHandler) CreateAccountHandler(c *gin.Context) {
@@ -100,6 +102,13 @@ func (ah *accountHandler) CreateAccountHandler(c *gin.Context) {
 		return
 	}
 
+	if err := plugins.CheckNotRetired(c, ah.stateStore, plugins.BankChannelKind, *channels[0].Id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	account := &repository.Account{
 		IsEnabled:                 req.IsEnabled,
 		IsTest:                    req.IsTest,
@@ -266,6 +275,13 @@ func (ah *accountHandler) PatchAccountHandler(c *gin.Context) {
 			})
 			return
 		}
+
+		if err := plugins.CheckNotRetired(c, ah.stateStore, plugins.BankChannelKind, *channels[0].Id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
 		channel = channels[0]
 	}
 
@@ -308,6 +324,7 @@ func NewAccountHandler(
 	store repository.AccountRepository,
 	currencyStore repository.CurrencyRepository,
 	channelStore repository.ChannelRepository,
+	stateStore plugins.PluginStateRepository,
 	loggerFunc repository.LoggerFunc,
 ) *accountHandler {
 	return &accountHandler{
@@ -315,5 +332,6 @@ func NewAccountHandler(
 		store:         store,
 		currencyStore: currencyStore,
 		channelStore:  channelStore,
+		stateStore:    stateStore,
 	}
 }
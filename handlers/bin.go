@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/repository"
+)
+
+type BinsRequest struct {
+	LimitAndOffsetRequest
+	Brand *string `form:"brand" binding:"omitempty,oneof=visa mastercard mir amex unionpay jcb"`
+}
+
+type binHandler struct {
+	loggerFunc repository.LoggerFunc
+	store      bins.BinRepository
+}
+
+func (bh *binHandler) ImportBinsHandler(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	err, list := bins.ParseCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, imported := bh.store.Import(c, list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	bh.loggerFunc(c).Printf("%d bins imported from %s", imported, fileHeader.Filename)
+
+	c.JSON(http.StatusOK, gin.H{
+		"imported": imported,
+	})
+}
+
+func (bh *binHandler) GetBinsHandler(c *gin.Context) {
+	var req BinsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	specification := bins.NewBinSpecificationWithLimitAndOffset(req.Limit, req.Offset)
+	if req.Brand != nil {
+		specification = bins.NewBinSpecificationByBrandWithLimitAndOffset(*req.Brand, req.Limit, req.Offset)
+	}
+
+	err, overall, list := bh.store.Query(c, specification)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"bins": list,
+	})
+}
+
+// GetBinHandler will lookup card metadata by the BIN or the longer prefix
+// of the card number
+func (bh *binHandler) GetBinHandler(c *gin.Context) {
+	var req struct {
+		Bin string `uri:"bin" binding:"required,numeric,min=6,max=19"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, bin := bins.Lookup(c, bh.store, req.Bin)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, bin)
+}
+
+func (bh *binHandler) DeleteBinHandler(c *gin.Context) {
+	prefix := c.Params.ByName("bin")
+	bin := &bins.Bin{Prefix: &prefix}
+
+	err, notfound := bh.store.Delete(c, bin)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, bin)
+}
+
+func NewBinHandler(store bins.BinRepository, loggerFunc repository.LoggerFunc) *binHandler {
+	return &binHandler{
+		loggerFunc: loggerFunc,
+		store:      store,
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/repository"
+)
+
+type breakerHandler struct {
+	loggerFunc   repository.LoggerFunc
+	accountStore repository.AccountRepository
+}
+
+func (bh *breakerHandler) GetBreakersHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"breakers": breaker.Statuses(),
+	})
+}
+
+func (bh *breakerHandler) ResetBreakerHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, accounts := bh.accountStore.Query(c, repository.NewAccountSpecificationByID(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(accounts) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "account not found",
+		})
+		return
+	}
+
+	circuit := breaker.ForAccount(id)
+	circuit.Reset()
+	bh.loggerFunc(c).Printf("%s has been reset", circuit)
+
+	c.JSON(http.StatusOK, circuit.Status())
+}
+
+func NewBreakerHandler(accountStore repository.AccountRepository, loggerFunc repository.LoggerFunc) *breakerHandler {
+	return &breakerHandler{
+		loggerFunc:   loggerFunc,
+		accountStore: accountStore,
+	}
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/disputes"
+	"github.com/serg666/repository"
+)
+
+type OpenDisputeRequest struct {
+	State    string     `json:"state" binding:"required,oneof=retrieval_request chargeback"`
+	Reason   *string    `json:"reason" binding:"omitempty,notempty"`
+	Amount   *uint      `json:"amount" binding:"omitempty,gt=0"`
+	Deadline *time.Time `json:"deadline"`
+}
+
+type TransitDisputeRequest struct {
+	State    string     `json:"state" binding:"required,oneof=chargeback representment pre_arbitration won lost"`
+	Note     *string    `json:"note" binding:"omitempty,notempty"`
+	Deadline *time.Time `json:"deadline"`
+}
+
+type DisputesRequest struct {
+	LimitAndOffsetRequest
+	State *string `form:"state"`
+}
+
+type disputeHandler struct {
+	loggerFunc       repository.LoggerFunc
+	transactionStore repository.TransactionRepository
+	lifecycle        *disputes.Lifecycle
+	evidenceDir      string
+}
+
+func (dh *disputeHandler) transaction(c *gin.Context, id int) (error, int, *repository.Transaction) {
+	err, _, transactions := dh.transactionStore.Query(c, repository.NewTransactionSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(transactions) == 0 {
+		return fmt.Errorf("Transaction with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, transactions[0]
+}
+
+func (dh *disputeHandler) dispute(c *gin.Context) (error, int, *disputes.Dispute) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	err, _, list := dh.lifecycle.Store.Query(c, disputes.NewDisputeSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("Dispute with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, list[0]
+}
+
+func (dh *disputeHandler) OpenDisputeHandler(c *gin.Context) {
+	var req OpenDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	tid, err := strconv.Atoi(c.Params.ByName("tid"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, transaction := dh.transaction(c, tid)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, dispute := dh.lifecycle.Open(c, transaction, req.State, req.Reason, req.Amount, req.Deadline)
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	dh.loggerFunc(c).Printf("dispute <%d> opened on transaction <%d>", *dispute.Id, tid)
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+func (dh *disputeHandler) GetTransactionDisputesHandler(c *gin.Context) {
+	tid, err := strconv.Atoi(c.Params.ByName("tid"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, overall, list := dh.lifecycle.Store.Query(c, disputes.NewDisputeSpecificationByTransactionID(tid))
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"disputes": list,
+	})
+}
+
+func (dh *disputeHandler) GetDisputesHandler(c *gin.Context) {
+	var req DisputesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	specification := disputes.NewDisputeSpecificationWithLimitAndOffset(req.Limit, req.Offset)
+	if req.State != nil {
+		specification = disputes.NewDisputeSpecificationByStateWithLimitAndOffset(*req.State, req.Limit, req.Offset)
+	}
+
+	err, overall, list := dh.lifecycle.Store.Query(c, specification)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"disputes": list,
+	})
+}
+
+func (dh *disputeHandler) GetDisputeHandler(c *gin.Context) {
+	err, status, dispute := dh.dispute(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err, dispute.Events = dh.lifecycle.Store.Events(c, *dispute.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err, dispute.Evidence = dh.lifecycle.Store.Evidence(c, *dispute.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+func (dh *disputeHandler) TransitDisputeHandler(c *gin.Context) {
+	var req TransitDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, dispute := dh.dispute(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, transaction := dh.transaction(c, *dispute.TransactionId)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := dh.lifecycle.Transit(c, dispute, transaction, req.State, req.Note, req.Deadline); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	dh.loggerFunc(c).Printf("dispute <%d> moved to %s", *dispute.Id, *dispute.State)
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+func (dh *disputeHandler) AddEvidenceHandler(c *gin.Context) {
+	err, status, dispute := dh.dispute(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if disputes.IsFinal(*dispute.State) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": fmt.Sprintf("Dispute has wrong state: %s", *dispute.State),
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	err, path, size := disputes.SaveEvidence(dh.evidenceDir, *dispute.Id, fileHeader.Filename, file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	contentType := fileHeader.Header.Get("Content-Type")
+	evidence := &disputes.Evidence{
+		DisputeId:   dispute.Id,
+		Filename:    &fileHeader.Filename,
+		ContentType: &contentType,
+		Size:        &size,
+		Path:        &path,
+	}
+
+	if description, ok := c.GetPostForm("description"); ok {
+		evidence.Description = &description
+	}
+
+	if err := dh.lifecycle.Store.AddEvidence(c, evidence); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, evidence)
+}
+
+func (dh *disputeHandler) GetEvidenceHandler(c *gin.Context) {
+	eid, err := strconv.Atoi(c.Params.ByName("eid"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, dispute := dh.dispute(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, evidence := dh.lifecycle.Store.Evidence(c, *dispute.Id)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	for _, item := range evidence {
+		if *item.Id == eid {
+			c.FileAttachment(*item.Path, *item.Filename)
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"message": fmt.Sprintf("Evidence with id=%v not found", eid),
+	})
+}
+
+func NewDisputeHandler(
+	lifecycle *disputes.Lifecycle,
+	transactionStore repository.TransactionRepository,
+	evidenceDir string,
+	loggerFunc repository.LoggerFunc,
+) *disputeHandler {
+	return &disputeHandler{
+		loggerFunc:       loggerFunc,
+		transactionStore: transactionStore,
+		lifecycle:        lifecycle,
+		evidenceDir:      evidenceDir,
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"io"
+	"fmt"
+	"time"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-contrib/sse"
+	"github.com/serg666/gateway/events"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/repository"
+)
+
+// retry is the time in milliseconds browsers wait before reconnecting to
+// the stream
+const retry = 3000
+
+type eventHandler struct {
+	loggerFunc       repository.LoggerFunc
+	profileStore     repository.ProfileRepository
+	transactionStore repository.TransactionRepository
+	broker           *events.Broker
+	heartbeat        time.Duration
+}
+
+func (eh *eventHandler) profile(c *gin.Context) (error, int, *repository.Profile) {
+	id, err := strconv.Atoi(c.Params.ByName("pid"))
+	if err !=  nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	err, _, profiles := eh.profileStore.Query(c, repository.NewProfileSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(profiles) == 0 {
+		return fmt.Errorf("Profile with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, profiles[0]
+}
+
+func (eh *eventHandler) send(c *gin.Context, status *events.Status) {
+	c.Render(-1, sse.Event{
+		Id:    fmt.Sprintf("%d-%s", status.Id, status.Status),
+		Event: "status",
+		Retry: retry,
+		Data:  status,
+	})
+}
+
+// stream will send status changes of the subscription until the client
+// goes away, the current status first if any. Heartbeats keep proxies from
+// closing the idle connection
+func (eh *eventHandler) stream(c *gin.Context, subscription *events.Subscription, current *events.Status) {
+	defer subscription.Close()
+
+	heartbeat := time.NewTicker(eh.heartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	// @note: the write timeout of the server is meant for usual requests,
+	// it would cut the stream
+	if err := config.ClearWriteDeadline(c.Request); err != nil {
+		eh.loggerFunc(c).Warningf("can not clear write deadline of the stream: %v", err)
+	}
+
+	// @note: the client knows it is subscribed before the first event
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	if current != nil {
+		eh.send(c, current)
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case status := <-subscription.C:
+			eh.send(c, status)
+			return true
+		case <-heartbeat.C:
+			c.Render(-1, sse.Event{
+				Event: "heartbeat",
+				Data:  time.Now().Unix(),
+			})
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		case <-subscription.Done():
+			return false
+		}
+	})
+}
+
+// ProfileEventsHandler will stream status changes of all transactions of
+// the profile. Last-Event-ID is not honored: changes made while the client
+// was reconnecting are not replayed, it should query transactions of the
+// profile it is interested in after reconnect. The transaction stream needs
+// no replay, it starts with the current status
+func (eh *eventHandler) ProfileEventsHandler(c *gin.Context) {
+	err, status, profile := eh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	eh.stream(c, eh.broker.Subscribe(*profile.Id, nil), nil)
+}
+
+// TransactionEventsHandler will stream status changes of the transaction
+// starting with its current status
+func (eh *eventHandler) TransactionEventsHandler(c *gin.Context) {
+	err, status, profile := eh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	tid, err := strconv.Atoi(c.Params.ByName("tid"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// @note: subscribed before the transaction is read, so no change
+	// is missed in between
+	subscription := eh.broker.Subscribe(*profile.Id, &tid)
+
+	err, _, transactions := eh.transactionStore.Query(c, repository.NewTransactionSpecificationByID(tid))
+	if err !=  nil {
+		subscription.Close()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(transactions) == 0 || *transactions[0].Profile.Id != *profile.Id {
+		subscription.Close()
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": fmt.Sprintf("transaction with id=%v not found", tid),
+		})
+		return
+	}
+
+	eh.stream(c, subscription, events.StatusOf(transactions[0]))
+}
+
+func NewEventHandler(
+	profileStore repository.ProfileRepository,
+	transactionStore repository.TransactionRepository,
+	broker *events.Broker,
+	heartbeat time.Duration,
+	loggerFunc repository.LoggerFunc,
+) *eventHandler {
+	if heartbeat <= 0 {
+		heartbeat = 15
+	}
+
+	return &eventHandler{
+		loggerFunc:       loggerFunc,
+		profileStore:     profileStore,
+		transactionStore: transactionStore,
+		broker:           broker,
+		heartbeat:        heartbeat * time.Second,
+	}
+}
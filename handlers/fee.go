@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/fees"
+	"github.com/serg666/repository"
+)
+
+type CreateFeePlanRequest struct {
+	Name *string `json:"name" binding:"required,notempty"`
+}
+
+type CreateFeeRuleRequest struct {
+	AccountId *int     `json:"account_id"`
+	Brand     *string  `json:"brand" binding:"omitempty,notempty"`
+	Type      *string  `json:"type" binding:"omitempty,oneof=auth preauth confirmauth reversal refund rebill"`
+	Fixed     *uint    `json:"fixed"`
+	Percent   *float64 `json:"percent" binding:"omitempty,gte=0,lte=100"`
+	Min       *uint    `json:"min"`
+	Max       *uint    `json:"max" binding:"omitempty,gtefield=Min"`
+}
+
+type AssignFeePlanRequest struct {
+	PlanId *int `json:"plan_id" binding:"required"`
+}
+
+type feeHandler struct {
+	loggerFunc   repository.LoggerFunc
+	profileStore repository.ProfileRepository
+	accountStore repository.AccountRepository
+	store        fees.FeeRepository
+}
+
+func (fh *feeHandler) plan(c *gin.Context, id int) (error, int, *fees.Plan) {
+	err, _, plans := fh.store.QueryPlans(c, fees.NewPlanSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(plans) == 0 {
+		return fmt.Errorf("Fee plan with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, plans[0]
+}
+
+func (fh *feeHandler) CreateFeePlanHandler(c *gin.Context) {
+	var req CreateFeePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	plan := &fees.Plan{Name: req.Name}
+
+	if err := fh.store.AddPlan(c, plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func (fh *feeHandler) GetFeePlansHandler(c *gin.Context) {
+	var req LimitAndOffsetRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, overall, plans := fh.store.QueryPlans(c, fees.NewPlanSpecificationWithLimitAndOffset(
+		req.Limit,
+		req.Offset,
+	))
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"plans": plans,
+	})
+}
+
+func (fh *feeHandler) GetFeePlanHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, plan := fh.plan(c, id)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, plan.Rules = fh.store.QueryRules(c, fees.NewRuleSpecificationByPlanID(id))
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func (fh *feeHandler) DeleteFeePlanHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	plan := &fees.Plan{Id: &id}
+
+	err, notfound := fh.store.DeletePlan(c, plan)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func (fh *feeHandler) CreateFeeRuleHandler(c *gin.Context) {
+	var req CreateFeeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.Fixed == nil && req.Percent == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Either fixed or percent is required",
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, plan := fh.plan(c, id)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.AccountId != nil {
+		err, _, accounts := fh.accountStore.Query(c, repository.NewAccountSpecificationByID(*req.AccountId))
+		if err !=  nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if len(accounts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": fmt.Sprintf("Account with id=%v not found", *req.AccountId),
+			})
+			return
+		}
+	}
+
+	rule := &fees.Rule{
+		PlanId:    plan.Id,
+		AccountId: req.AccountId,
+		Brand:     req.Brand,
+		Type:      req.Type,
+		Fixed:     req.Fixed,
+		Percent:   req.Percent,
+		Min:       req.Min,
+		Max:       req.Max,
+	}
+
+	if err := fh.store.AddRule(c, rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (fh *feeHandler) DeleteFeeRuleHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	rid, err := strconv.Atoi(c.Params.ByName("rid"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	rule := &fees.Rule{Id: &rid, PlanId: &id}
+
+	err, notfound := fh.store.DeleteRule(c, rule)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (fh *feeHandler) profile(c *gin.Context) (error, int, *repository.Profile) {
+	id, err := strconv.Atoi(c.Params.ByName("pid"))
+	if err !=  nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	err, _, profiles := fh.profileStore.Query(c, repository.NewProfileSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(profiles) == 0 {
+		return fmt.Errorf("Profile with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, profiles[0]
+}
+
+func (fh *feeHandler) GetProfileFeePlanHandler(c *gin.Context) {
+	err, status, profile := fh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, planId := fh.store.PlanOf(c, *profile.Id)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if planId == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": fmt.Sprintf("Profile with id=%v has got no fee plan", *profile.Id),
+		})
+		return
+	}
+
+	err, status, plan := fh.plan(c, *planId)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func (fh *feeHandler) AssignFeePlanHandler(c *gin.Context) {
+	var req AssignFeePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, profile := fh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, plan := fh.plan(c, *req.PlanId)
+	if err !=  nil {
+		if status == http.StatusNotFound {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := fh.store.AssignPlan(c, *profile.Id, *plan.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	fh.loggerFunc(c).Printf("fee plan <%d> assigned to profile <%d>", *plan.Id, *profile.Id)
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func (fh *feeHandler) UnassignFeePlanHandler(c *gin.Context) {
+	err, status, profile := fh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, notfound := fh.store.UnassignPlan(c, *profile.Id)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func NewFeeHandler(
+	store fees.FeeRepository,
+	profileStore repository.ProfileRepository,
+	accountStore repository.AccountRepository,
+	loggerFunc repository.LoggerFunc,
+) *feeHandler {
+	return &feeHandler{
+		loggerFunc:   loggerFunc,
+		profileStore: profileStore,
+		accountStore: accountStore,
+		store:        store,
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/health"
+	"github.com/serg666/repository"
+)
+
+type healthHandler struct {
+	loggerFunc repository.LoggerFunc
+	checker    *health.Checker
+}
+
+// HealthzHandler will tell the orchestrator the gateway is alive. It does
+// not check dependencies, the gateway can not fix them by restart
+func (hh *healthHandler) HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// ReadyzHandler will tell the orchestrator whether the gateway can serve
+// payments. Status of every dependency is returned
+func (hh *healthHandler) ReadyzHandler(c *gin.Context) {
+	err, results := hh.checker.Run(c.Request.Context())
+
+	checks := make(map[string]string, len(results))
+	for name, result := range results {
+		checks[name] = "ok"
+		if result != nil {
+			checks[name] = result.Error()
+		}
+	}
+
+	if hh.checker.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "draining",
+			"checks": checks,
+		})
+		return
+	}
+
+	if err != nil {
+		hh.loggerFunc(c).Warningf("not ready: %v", err)
+
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "failing",
+			"checks": checks,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"checks": checks,
+	})
+}
+
+func NewHealthHandler(checker *health.Checker, loggerFunc repository.LoggerFunc) *healthHandler {
+	return &healthHandler{
+		loggerFunc: loggerFunc,
+		checker:    checker,
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/journal"
+	"github.com/serg666/repository"
+)
+
+type journalHandler struct {
+	loggerFunc       repository.LoggerFunc
+	transactionStore repository.TransactionRepository
+	journalStore     journal.JournalRepository
+}
+
+func (jh *journalHandler) GetTransactionJournalHandler(c *gin.Context) {
+	tid, err := strconv.Atoi(c.Params.ByName("tid"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, transactions := jh.transactionStore.Query(c, repository.NewTransactionSpecificationByID(tid))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(transactions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "transaction not found",
+		})
+		return
+	}
+
+	err, _, entries := jh.journalStore.Query(c, journal.NewJournalSpecificationByTransactionID(tid))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction": transactions[0],
+		"journal":     entries,
+	})
+}
+
+func NewJournalHandler(
+	transactionStore repository.TransactionRepository,
+	journalStore journal.JournalRepository,
+	loggerFunc repository.LoggerFunc,
+) *journalHandler {
+	return &journalHandler{
+		loggerFunc:       loggerFunc,
+		transactionStore: transactionStore,
+		journalStore:     journalStore,
+	}
+}
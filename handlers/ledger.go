@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/ledger"
+	"github.com/serg666/repository"
+)
+
+type StatementRequest struct {
+	LimitAndOffsetRequest
+	CurrencyCode *int    `form:"currency" binding:"required"`
+	From         *string `form:"from"`
+	To           *string `form:"to"`
+}
+
+type ledgerHandler struct {
+	loggerFunc    repository.LoggerFunc
+	profileStore  repository.ProfileRepository
+	currencyStore repository.CurrencyRepository
+	store         ledger.LedgerRepository
+}
+
+func (lh *ledgerHandler) profile(c *gin.Context) (error, int, *repository.Profile) {
+	id, err := strconv.Atoi(c.Params.ByName("pid"))
+	if err !=  nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	err, _, profiles := lh.profileStore.Query(c, repository.NewProfileSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(profiles) == 0 {
+		return fmt.Errorf("Profile with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, profiles[0]
+}
+
+func (lh *ledgerHandler) GetBalanceHandler(c *gin.Context) {
+	err, status, profile := lh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, balances := lh.store.Balances(c, ledger.MERCHANT, profile.Id)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if balances == nil {
+		balances = []*ledger.Balance{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profile_id": *profile.Id,
+		"balances": balances,
+	})
+}
+
+func (lh *ledgerHandler) GetStatementHandler(c *gin.Context) {
+	var req StatementRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// @note: to date is included in the statement
+	var from time.Time
+	to := time.Now().AddDate(0, 0, 1)
+
+	if req.From != nil {
+		parsed, err := time.Parse("2006-01-02", *req.From)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		from = parsed
+	}
+
+	if req.To != nil {
+		parsed, err := time.Parse("2006-01-02", *req.To)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	err, status, profile := lh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, currencies := lh.currencyStore.Query(c, repository.NewCurrencySpecificationByNumericCode(*req.CurrencyCode))
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(currencies) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": fmt.Sprintf("Currency with code=%v not found", *req.CurrencyCode),
+		})
+		return
+	}
+	currency := currencies[0]
+
+	err, overall, statement := lh.store.Statement(c, ledger.StatementFilter{
+		AccountType: ledger.MERCHANT,
+		OwnerId:     profile.Id,
+		CurrencyId:  *currency.Id,
+		From:        from,
+		To:          to,
+		Limit:       req.Limit,
+		Offset:      req.Offset,
+	})
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if statement.Lines == nil {
+		statement.Lines = []*ledger.StatementLine{}
+	}
+
+	exponent := 0
+	if currency.Exponent != nil {
+		exponent = *currency.Exponent
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profile_id": *profile.Id,
+		"currency": currency,
+		"overall": overall,
+		"opening": statement.Opening,
+		"opening_amount": ledger.FormatAmount(statement.Opening, exponent),
+		"closing": statement.Closing,
+		"closing_amount": ledger.FormatAmount(statement.Closing, exponent),
+		"lines": statement.Lines,
+	})
+}
+
+func NewLedgerHandler(
+	store ledger.LedgerRepository,
+	profileStore repository.ProfileRepository,
+	currencyStore repository.CurrencyRepository,
+	loggerFunc repository.LoggerFunc,
+) *ledgerHandler {
+	return &ledgerHandler{
+		loggerFunc:    loggerFunc,
+		profileStore:  profileStore,
+		currencyStore: currencyStore,
+		store:         store,
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/limits"
+	"github.com/serg666/repository"
+)
+
+type CreateLimitRequest struct {
+	Scope    string  `json:"scope" binding:"required,oneof=profile account route"`
+	ScopeId  *int    `json:"scope_id" binding:"required"`
+	Kind     string  `json:"kind" binding:"required,oneof=max_amount daily_turnover monthly_turnover card_hourly_count customer_daily_cards"`
+	Value    *int64  `json:"value" binding:"required,gte=0"`
+	// @note: limit is enforced on matching cards only when any of these
+	// is set
+	Brand    *string `json:"brand" binding:"omitempty,oneof=visa mastercard mir amex unionpay jcb"`
+	CardType *string `json:"card_type" binding:"omitempty,oneof=debit credit prepaid"`
+	Country  *string `json:"country" binding:"omitempty,len=2,alpha"`
+}
+
+type LimitsRequest struct {
+	LimitAndOffsetRequest
+	Scope   *string `form:"scope" binding:"required_with=ScopeId,omitempty,oneof=profile account route"`
+	ScopeId *int    `form:"scope_id" binding:"required_with=Scope"`
+}
+
+type LimitUsageRequest struct {
+	Card     *int    `form:"card"`
+	Customer *string `form:"customer"`
+}
+
+type limitHandler struct {
+	loggerFunc   repository.LoggerFunc
+	profileStore repository.ProfileRepository
+	accountStore repository.AccountRepository
+	routeStore   repository.RouteRepository
+	store        limits.LimitRepository
+}
+
+func (lh *limitHandler) checkScope(c *gin.Context, scope string, id int) (error, int) {
+	var err error
+	var found int
+
+	switch scope {
+	case limits.PROFILE:
+		var profiles []*repository.Profile
+		err, _, profiles = lh.profileStore.Query(c, repository.NewProfileSpecificationByID(id))
+		found = len(profiles)
+	case limits.ACCOUNT:
+		var accounts []*repository.Account
+		err, _, accounts = lh.accountStore.Query(c, repository.NewAccountSpecificationByID(id))
+		found = len(accounts)
+	case limits.ROUTE:
+		var routes []*repository.Route
+		err, _, routes = lh.routeStore.Query(c, repository.NewRouteSpecificationByID(id))
+		found = len(routes)
+	}
+
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	if found == 0 {
+		return fmt.Errorf("%s with id=%v not found", scope, id), http.StatusBadRequest
+	}
+
+	return nil, http.StatusOK
+}
+
+func (lh *limitHandler) limit(c *gin.Context) (error, int, *limits.Limit) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	err, _, list := lh.store.Query(c, limits.NewLimitSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("Limit with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, list[0]
+}
+
+func (lh *limitHandler) CreateLimitHandler(c *gin.Context) {
+	var req CreateLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err, status := lh.checkScope(c, req.Scope, *req.ScopeId); err != nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.Country != nil {
+		country := strings.ToUpper(*req.Country)
+		req.Country = &country
+	}
+
+	limit := &limits.Limit{
+		Scope:    &req.Scope,
+		ScopeId:  req.ScopeId,
+		Kind:     &req.Kind,
+		Value:    req.Value,
+		Brand:    req.Brand,
+		CardType: req.CardType,
+		Country:  req.Country,
+	}
+
+	if err := lh.store.Add(c, limit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func (lh *limitHandler) GetLimitsHandler(c *gin.Context) {
+	var req LimitsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	specification := limits.NewLimitSpecificationWithLimitAndOffset(req.Limit, req.Offset)
+	if req.Scope != nil {
+		specification = limits.NewLimitSpecificationByScopeWithLimitAndOffset(
+			*req.Scope,
+			*req.ScopeId,
+			req.Limit,
+			req.Offset,
+		)
+	}
+
+	err, overall, list := lh.store.Query(c, specification)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"limits": list,
+	})
+}
+
+func (lh *limitHandler) GetLimitHandler(c *gin.Context) {
+	err, status, limit := lh.limit(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func (lh *limitHandler) GetLimitUsageHandler(c *gin.Context) {
+	var req LimitUsageRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, limit := lh.limit(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if *limit.Kind == limits.CARDHOURLY && req.Card == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "card is required",
+		})
+		return
+	}
+
+	if *limit.Kind == limits.CUSTOMERCARDS && req.Customer == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "customer is required",
+		})
+		return
+	}
+
+	err, used := lh.store.Usage(c, limit, limits.Subject{
+		Card:     req.Card,
+		Customer: req.Customer,
+	})
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	remaining := *limit.Value - used
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	c.JSON(http.StatusOK, &limits.Usage{
+		Limit:     limit,
+		Used:      used,
+		Remaining: remaining,
+	})
+}
+
+func (lh *limitHandler) DeleteLimitHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	limit := &limits.Limit{Id: &id}
+
+	err, notfound := lh.store.Delete(c, limit)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func NewLimitHandler(
+	store limits.LimitRepository,
+	profileStore repository.ProfileRepository,
+	accountStore repository.AccountRepository,
+	routeStore repository.RouteRepository,
+	loggerFunc repository.LoggerFunc,
+) *limitHandler {
+	return &limitHandler{
+		loggerFunc:   loggerFunc,
+		profileStore: profileStore,
+		accountStore: accountStore,
+		routeStore:   routeStore,
+		store:        store,
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/plugins"
+	"github.com/serg666/gateway/plugins/channels"
+	"github.com/serg666/repository"
+)
+
+type RetirePluginRequest struct {
+	Reason *string `json:"reason" binding:"omitempty,notempty"`
+}
+
+type PluginStatus struct {
+	Kind   string               `json:"kind"`
+	Id     int                  `json:"id"`
+	Key    string               `json:"key"`
+	Loaded bool                 `json:"loaded"`
+	State  *plugins.PluginState `json:"state"`
+}
+
+type pluginHandler struct {
+	loggerFunc      repository.LoggerFunc
+	routerStore     repository.RouterRepository
+	instrumentStore repository.InstrumentRepository
+	channelStore    repository.ChannelRepository
+	stateStore      plugins.PluginStateRepository
+}
+
+func (ph *pluginHandler) GetPluginsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, plugins.Describe())
+}
+
+func (ph *pluginHandler) statuses(c *gin.Context) (error, []PluginStatus) {
+	var statuses []PluginStatus
+
+	states := make(map[string]map[int]*plugins.PluginState)
+	for _, kind := range []string{plugins.RouterKind, plugins.InstrumentKind, plugins.BankChannelKind} {
+		err, _, kindStates := ph.stateStore.Query(c, plugins.NewPluginStateSpecificationByKind(kind))
+		if err != nil {
+			return err, nil
+		}
+
+		states[kind] = make(map[int]*plugins.PluginState)
+		for _, state := range kindStates {
+			states[kind][*state.PluginId] = state
+		}
+	}
+
+	err, _, routers := ph.routerStore.Query(c, repository.NewRouterWithoutSpecification())
+	if err != nil {
+		return err, nil
+	}
+
+	for _, router := range routers {
+		_, loaded := plugins.Routers[*router.Id]
+		statuses = append(statuses, PluginStatus{
+			Kind:   plugins.RouterKind,
+			Id:     *router.Id,
+			Key:    *router.Key,
+			Loaded: loaded,
+			State:  states[plugins.RouterKind][*router.Id],
+		})
+	}
+
+	err, _, instruments := ph.instrumentStore.Query(c, repository.NewInstrumentWithoutSpecification())
+	if err != nil {
+		return err, nil
+	}
+
+	for _, instrument := range instruments {
+		_, loaded := plugins.PaymentInstruments[*instrument.Id]
+		statuses = append(statuses, PluginStatus{
+			Kind:   plugins.InstrumentKind,
+			Id:     *instrument.Id,
+			Key:    *instrument.Key,
+			Loaded: loaded,
+			State:  states[plugins.InstrumentKind][*instrument.Id],
+		})
+	}
+
+	err, _, bankChannels := ph.channelStore.Query(c, repository.NewChannelSpecificationByTypeID(channels.BankChannelType))
+	if err != nil {
+		return err, nil
+	}
+
+	for _, bankChannel := range bankChannels {
+		_, loaded := plugins.BankChannels[*bankChannel.Id]
+		statuses = append(statuses, PluginStatus{
+			Kind:   plugins.BankChannelKind,
+			Id:     *bankChannel.Id,
+			Key:    *bankChannel.Key,
+			Loaded: loaded,
+			State:  states[plugins.BankChannelKind][*bankChannel.Id],
+		})
+	}
+
+	return nil, statuses
+}
+
+func (ph *pluginHandler) GetPluginStatesHandler(c *gin.Context) {
+	err, statuses := ph.statuses(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"plugins": statuses,
+	})
+}
+
+func (ph *pluginHandler) params(c *gin.Context) (error, string, int) {
+	kind := c.Params.ByName("kind")
+	switch kind {
+	case
+		plugins.RouterKind,
+		plugins.InstrumentKind,
+		plugins.BankChannelKind:
+	default:
+		return fmt.Errorf("unknown plugin kind: %s", kind), "", 0
+	}
+
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		return err, "", 0
+	}
+
+	return nil, kind, id
+}
+
+// exists will tell whether the plugin is in the routers, instruments or
+// channels table
+func (ph *pluginHandler) exists(c *gin.Context, kind string, id int) (error, bool) {
+	switch kind {
+	case plugins.RouterKind:
+		err, _, routers := ph.routerStore.Query(c, repository.NewRouterSpecificationByID(id))
+		return err, len(routers) > 0
+	case plugins.InstrumentKind:
+		err, _, instruments := ph.instrumentStore.Query(c, repository.NewInstrumentSpecificationByID(id))
+		return err, len(instruments) > 0
+	}
+
+	err, _, bankChannels := ph.channelStore.Query(c, repository.NewChannelSpecificationByID(id))
+	if err != nil || len(bankChannels) == 0 {
+		return err, false
+	}
+
+	return nil, *bankChannels[0].TypeId == channels.BankChannelType
+}
+
+func (ph *pluginHandler) RetirePluginHandler(c *gin.Context) {
+	var req RetirePluginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, kind, id := ph.params(c)
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, found := ph.exists(c, kind, id)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": fmt.Sprintf("%s with id=%v not found", kind, id),
+		})
+		return
+	}
+
+	state := plugins.RETIRED
+	pluginState := &plugins.PluginState{
+		Kind:     &kind,
+		PluginId: &id,
+		State:    &state,
+		Reason:   req.Reason,
+	}
+
+	if err := ph.stateStore.Add(c, pluginState); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pluginState)
+}
+
+func (ph *pluginHandler) ActivatePluginHandler(c *gin.Context) {
+	err, kind, id := ph.params(c)
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	loaded := false
+	switch kind {
+	case plugins.RouterKind:
+		_, loaded = plugins.Routers[id]
+	case plugins.InstrumentKind:
+		_, loaded = plugins.PaymentInstruments[id]
+	case plugins.BankChannelKind:
+		_, loaded = plugins.BankChannels[id]
+	}
+
+	if !loaded {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": fmt.Sprintf("%s <%d> is not loaded", kind, id),
+		})
+		return
+	}
+
+	pluginState := &plugins.PluginState{
+		Kind:     &kind,
+		PluginId: &id,
+	}
+
+	err, notfound := ph.stateStore.Delete(c, pluginState)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pluginState)
+}
+
+func NewPluginHandler(
+	routerStore repository.RouterRepository,
+	instrumentStore repository.InstrumentRepository,
+	channelStore repository.ChannelRepository,
+	stateStore plugins.PluginStateRepository,
+	loggerFunc repository.LoggerFunc,
+) *pluginHandler {
+	return &pluginHandler{
+		loggerFunc:      loggerFunc,
+		routerStore:     routerStore,
+		instrumentStore: instrumentStore,
+		channelStore:    channelStore,
+		stateStore:      stateStore,
+	}
+}
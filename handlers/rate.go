@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/rates"
+	"github.com/serg666/repository"
+)
+
+type CreateRateRequest struct {
+	FromCode  *int       `json:"from_code" binding:"required"`
+	ToCode    *int       `json:"to_code" binding:"required,nefield=FromCode"`
+	Rate      *float64   `json:"rate" binding:"required,gt=0"`
+	ValidFrom *time.Time `json:"valid_from" binding:"required"`
+	ValidTo   *time.Time `json:"valid_to" binding:"omitempty,gtfield=ValidFrom"`
+}
+
+type ImportRatesRequest struct {
+	Format string `form:"format,default=csv" binding:"oneof=csv ecb"`
+}
+
+type EffectiveRateRequest struct {
+	FromCode *int    `form:"from_code" binding:"required"`
+	ToCode   *int    `form:"to_code" binding:"required"`
+	At       *string `form:"at"`
+}
+
+type rateHandler struct {
+	loggerFunc    repository.LoggerFunc
+	currencyStore repository.CurrencyRepository
+	store         rates.RateRepository
+}
+
+func (rh *rateHandler) currency(c *gin.Context, code int) (error, *repository.Currency) {
+	err, _, currencies := rh.currencyStore.Query(c, repository.NewCurrencySpecificationByNumericCode(code))
+	if err != nil {
+		return err, nil
+	}
+
+	if len(currencies) == 0 {
+		return fmt.Errorf("Currency with code=%v not found", code), nil
+	}
+
+	return nil, currencies[0]
+}
+
+func (rh *rateHandler) CreateRateHandler(c *gin.Context) {
+	var req CreateRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, from := rh.currency(c, *req.FromCode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, to := rh.currency(c, *req.ToCode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	source := "api"
+	rate := &rates.Rate{
+		From:      from,
+		To:        to,
+		Rate:      req.Rate,
+		ValidFrom: req.ValidFrom,
+		ValidTo:   req.ValidTo,
+		Source:    &source,
+	}
+
+	if err := rh.store.Add(c, rate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+func (rh *rateHandler) ImportRatesHandler(c *gin.Context) {
+	var req ImportRatesRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	err, currencies := rates.LoadCurrencies(c, rh.currencyStore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, imported := rates.Read(req.Format, file, fileHeader.Filename, currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	for _, rate := range imported {
+		if err := rh.store.Add(c, rate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	rh.loggerFunc(c).Printf("%d rates imported from %s", len(imported), fileHeader.Filename)
+
+	c.JSON(http.StatusOK, gin.H{
+		"imported": len(imported),
+		"rates": imported,
+	})
+}
+
+func (rh *rateHandler) GetEffectiveRateHandler(c *gin.Context) {
+	var req EffectiveRateRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	at := time.Now()
+	if req.At != nil {
+		parsed, err := rates.ParseTime(*req.At)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		at = parsed
+	}
+
+	err, from := rh.currency(c, *req.FromCode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, to := rh.currency(c, *req.ToCode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, list := rh.store.Query(c, rates.NewRateSpecificationByCurrenciesAt(*from.Id, *to.Id, at))
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(list) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": fmt.Sprintf("Rate %s/%s not found at %s", *from.CharCode, *to.CharCode, at.Format(time.RFC3339)),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, list[0])
+}
+
+func (rh *rateHandler) GetRatesHandler(c *gin.Context) {
+	var req LimitAndOffsetRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, overall, list := rh.store.Query(c, rates.NewRateSpecificationWithLimitAndOffset(
+		req.Limit,
+		req.Offset,
+	))
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"rates": list,
+	})
+}
+
+func (rh *rateHandler) GetRateHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, list := rh.store.Query(c, rates.NewRateSpecificationByID(id))
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(list) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": fmt.Sprintf("Rate with id=%v not found", id),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, list[0])
+}
+
+func (rh *rateHandler) DeleteRateHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	rate := &rates.Rate{Id: &id}
+
+	err, notfound := rh.store.Delete(c, rate)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+func NewRateHandler(
+	store rates.RateRepository,
+	currencyStore repository.CurrencyRepository,
+	loggerFunc repository.LoggerFunc,
+) *rateHandler {
+	return &rateHandler{
+		loggerFunc:    loggerFunc,
+		currencyStore: currencyStore,
+		store:         store,
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"time"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/reconciliation"
+	"github.com/serg666/repository"
+)
+
+type CreateReconciliationRequest struct {
+	Date   *string `form:"date" binding:"required,notempty"`
+	Format string  `form:"format,default=csv"`
+}
+
+type GetReconciliationRequest struct {
+	Status *string `form:"status" binding:"omitempty,oneof=matched missing extra amount_mismatch"`
+}
+
+type reconciliationHandler struct {
+	loggerFunc   repository.LoggerFunc
+	channelStore repository.ChannelRepository
+	store        reconciliation.ReconciliationRepository
+}
+
+func (rh *reconciliationHandler) CreateReconciliationHandler(c *gin.Context) {
+	var req CreateReconciliationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", *req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, channels := rh.channelStore.Query(c, repository.NewChannelSpecificationByID(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(channels) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "channel not found",
+		})
+		return
+	}
+
+	err, parser := reconciliation.GetParser(id, req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	err, records := parser.Parse(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, settled := rh.store.Settled(c, id, date, date.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result := &reconciliation.Reconciliation{
+		ChannelId: &id,
+		Format:    &req.Format,
+		Filename:  &fileHeader.Filename,
+		Date:      &date,
+		Items:     reconciliation.Match(records, settled),
+	}
+	result.Summarize()
+
+	if err := rh.store.Add(c, result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	rh.loggerFunc(c).Printf(
+		"reconciliation <%d> of channel <%d>: matched=%d missing=%d extra=%d mismatched=%d",
+		*result.Id,
+		id,
+		*result.Matched,
+		*result.Missing,
+		*result.Extra,
+		*result.Mismatched,
+	)
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (rh *reconciliationHandler) GetReconciliationsHandler(c *gin.Context) {
+	var req LimitAndOffsetRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, overall, reconciliations := rh.store.Query(c, reconciliation.NewReconciliationSpecificationWithLimitAndOffset(
+		req.Limit,
+		req.Offset,
+	))
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"reconciliations": reconciliations,
+	})
+}
+
+func (rh *reconciliationHandler) GetReconciliationHandler(c *gin.Context) {
+	var req GetReconciliationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, _, reconciliations := rh.store.Query(c, reconciliation.NewReconciliationSpecificationByID(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(reconciliations) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "reconciliation not found",
+		})
+		return
+	}
+
+	spec := reconciliation.NewItemSpecificationByReconciliationID(id)
+	if req.Status != nil {
+		spec = reconciliation.NewItemSpecificationByReconciliationIDAndStatus(id, *req.Status)
+	}
+
+	err, _, items := rh.store.QueryItems(c, spec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result := reconciliations[0]
+	result.Items = items
+
+	c.JSON(http.StatusOK, result)
+}
+
+func NewReconciliationHandler(
+	channelStore repository.ChannelRepository,
+	store reconciliation.ReconciliationRepository,
+	loggerFunc repository.LoggerFunc,
+) *reconciliationHandler {
+	return &reconciliationHandler{
+		loggerFunc:   loggerFunc,
+		channelStore: channelStore,
+		store:        store,
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"time"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/reports"
+	"github.com/serg666/repository"
+)
+
+type TurnOverReportRequest struct {
+	From      *string `form:"from" binding:"required,notempty"`
+	To        *string `form:"to" binding:"required,notempty"`
+	ProfileId *int    `form:"profile"`
+	AccountId *int    `form:"account"`
+	Format    string  `form:"format,default=json" binding:"oneof=json csv"`
+}
+
+type reportHandler struct {
+	loggerFunc repository.LoggerFunc
+	store      reports.ReportRepository
+}
+
+func (rh *reportHandler) GetTurnOverReportHandler(c *gin.Context) {
+	var req TurnOverReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	from, err := time.Parse("2006-01-02", *req.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	to, err := time.Parse("2006-01-02", *req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// @note: to date is included in the report
+	err, rows := rh.store.TurnOver(c, reports.TurnOverFilter{
+		From:      from,
+		To:        to.AddDate(0, 0, 1),
+		ProfileId: req.ProfileId,
+		AccountId: req.AccountId,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	contentType := "application/json; charset=utf-8"
+	if req.Format == reports.CSV {
+		contentType = "text/csv; charset=utf-8"
+		c.Header("Content-Disposition", "attachment; filename=turnover.csv")
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", contentType)
+	if err := reports.WriteTurnOver(c.Writer, req.Format, rows); err != nil {
+		rh.loggerFunc(c).Errorf("can not write turnover report: %v", err)
+	}
+}
+
+func NewReportHandler(store reports.ReportRepository, loggerFunc repository.LoggerFunc) *reportHandler {
+	return &reportHandler{
+		loggerFunc: loggerFunc,
+		store:      store,
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/logging"
+	"github.com/serg666/gateway/reviews"
+	"github.com/serg666/repository"
+)
+
+type DecideReviewRequest struct {
+	Operator string  `json:"operator" binding:"required"`
+	Comment  *string `json:"comment" binding:"omitempty,notempty"`
+}
+
+type ReviewsRequest struct {
+	LimitAndOffsetRequest
+	State *string `form:"state" binding:"omitempty,oneof=pending approved rejected expired cancelled"`
+}
+
+type reviewHandler struct {
+	loggerFunc repository.LoggerFunc
+	store      reviews.ReviewRepository
+	th         *transactionHandler
+}
+
+func (rh *reviewHandler) review(c *gin.Context) (error, int, *reviews.Review) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	err, _, list := rh.store.Query(c, reviews.NewReviewSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("Review with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, list[0]
+}
+
+// decide will close the review with the state and release the held
+// transaction. Approved transaction is confirmed if the review requires it,
+// rejected and expired ones are reversed
+func (rh *reviewHandler) decide(
+	c *gin.Context,
+	review *reviews.Review,
+	state string,
+	operator *string,
+	comment *string,
+) (error, int, *repository.Transaction) {
+	err, _, transactions := rh.th.transactionStore.Query(c, repository.NewTransactionSpecificationByID(*review.TransactionId))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(transactions) == 0 {
+		return fmt.Errorf("Transaction with id=%v not found", *review.TransactionId), http.StatusInternalServerError, nil
+	}
+
+	transaction := transactions[0]
+
+	if state == reviews.APPROVED && !transaction.IsSuccess() {
+		return fmt.Errorf("Transaction has wrong state: %s", *transaction.Status), http.StatusBadRequest, nil
+	}
+
+	err, bankApi := rh.th.bankApi(transaction)
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	review.State = &state
+	review.Operator = operator
+	review.Comment = comment
+
+	// @note: review is closed first, so the held transaction is released once
+	if err, notfound := rh.store.Close(c, review); err != nil {
+		if notfound {
+			return err, http.StatusConflict, nil
+		}
+		return err, http.StatusInternalServerError, nil
+	}
+
+	var kind string
+	var operation func(*gin.Context, *repository.Transaction) error
+
+	switch {
+	case state == reviews.APPROVED && *review.Operation == reviews.CONFIRM:
+		kind, operation = repository.CONFIRMAUTH, bankApi.Confirm
+	case state != reviews.APPROVED && transaction.IsSuccess():
+		kind, operation = repository.REVERSAL, bankApi.Reverse
+	default:
+		return nil, http.StatusOK, nil
+	}
+
+	rh.loggerFunc(c).Printf("review <%d> %s, making %s of transaction <%d>", *review.Id, state, kind, *transaction.Id)
+
+	err, status, result := rh.th.onReference(c, kind, transaction, *transaction.Amount, false, operation)
+	if err != nil {
+		return err, status, nil
+	}
+
+	review.ResultId = result.Id
+	if err := rh.store.Result(c, review); err != nil {
+		rh.loggerFunc(c).Warningf("failed to store review result: %v", err)
+	}
+
+	return nil, http.StatusOK, result
+}
+
+func (rh *reviewHandler) decideHandler(c *gin.Context, state string) {
+	var req DecideReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, review := rh.review(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !review.IsPending() {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": fmt.Sprintf("Review has already been %s", *review.State),
+		})
+		return
+	}
+
+	err, status, transaction := rh.decide(c, review, state, &req.Operator, req.Comment)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"review": review,
+		"transaction": transaction,
+	})
+}
+
+func (rh *reviewHandler) ApproveReviewHandler(c *gin.Context) {
+	rh.decideHandler(c, reviews.APPROVED)
+}
+
+func (rh *reviewHandler) RejectReviewHandler(c *gin.Context) {
+	rh.decideHandler(c, reviews.REJECTED)
+}
+
+func (rh *reviewHandler) GetReviewsHandler(c *gin.Context) {
+	var req ReviewsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	specification := reviews.NewReviewSpecificationWithLimitAndOffset(req.Limit, req.Offset)
+	if req.State != nil {
+		specification = reviews.NewReviewSpecificationByStateWithLimitAndOffset(*req.State, req.Limit, req.Offset)
+	}
+
+	err, overall, list := rh.store.Query(c, specification)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"reviews": list,
+	})
+}
+
+func (rh *reviewHandler) GetReviewHandler(c *gin.Context) {
+	err, status, review := rh.review(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// Expire will decline held transactions which reviews are overdue
+func (rh *reviewHandler) Expire(c *gin.Context) error {
+	err, _, list := rh.store.Query(c, reviews.NewReviewSpecificationOverdue(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	comment := "review sla expired"
+	for _, review := range list {
+		if err, _, _ := rh.decide(c, review, reviews.EXPIRED, nil, &comment); err != nil {
+			rh.loggerFunc(c).Warningf("can not expire review <%d>: %v", *review.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// Schedule will expire overdue reviews with the interval
+func (rh *reviewHandler) Schedule(interval time.Duration) {
+	if interval <= 0 {
+		rh.loggerFunc(nil).Warningf("review expiry has got no interval, it is not scheduled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for t := range ticker.C {
+			c := logging.Background(fmt.Sprintf("review-expiry-%d", t.Unix()))
+			if err := rh.Expire(c); err != nil {
+				rh.loggerFunc(c).Errorf("can not expire reviews: %v", err)
+			}
+		}
+	}()
+}
+
+func NewReviewHandler(
+	store reviews.ReviewRepository,
+	th *transactionHandler,
+	loggerFunc repository.LoggerFunc,
+) *reviewHandler {
+	return &reviewHandler{
+		loggerFunc: loggerFunc,
+		store:      store,
+		th:         th,
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+	"testing"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/logging"
+	"github.com/serg666/gateway/plugins"
+	"github.com/serg666/gateway/plugins/channels"
+	"github.com/serg666/gateway/reviews"
+	"github.com/serg666/repository"
+)
+
+// testChannelId is the id of the bank channel registered by the tests
+const testChannelId = 9999
+
+// idleChannel is the bank channel without operations, reviews of
+// unsuccessful transactions are expired without bank calls
+type idleChannel struct {
+	channels.BankChannel
+}
+
+func init() {
+	plugins.BankChannels[testChannelId] = &plugins.BankChannel{
+		Key: "test",
+		Plugin: func(
+			*config.Config,
+			*repository.Account,
+			*repository.Instrument,
+			interface{},
+			repository.SessionRepository,
+			repository.TransactionRepository,
+			repository.LoggerFunc,
+		) (error, channels.BankChannel) {
+			return nil, &idleChannel{}
+		},
+	}
+}
+
+// memoryTransactionStore returns the only transaction it keeps
+type memoryTransactionStore struct {
+	repository.TransactionRepository
+	transaction *repository.Transaction
+}
+
+func (ms *memoryTransactionStore) Query(ctx interface{}, specification repository.Specification) (error, int, []*repository.Transaction) {
+	return nil, 1, []*repository.Transaction{ms.transaction}
+}
+
+// memoryReviewStore returns overdue reviews and keeps the closed ones with
+// request ids of their contexts
+type memoryReviewStore struct {
+	overdue  []*reviews.Review
+	err      error
+	decided  map[int]bool
+	closed   map[int]string
+	requests map[int]string
+}
+
+func (ms *memoryReviewStore) Add(ctx interface{}, review *reviews.Review) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryReviewStore) Query(ctx interface{}, specification reviews.ReviewSpecification) (error, int, []*reviews.Review) {
+	return ms.err, len(ms.overdue), ms.overdue
+}
+
+func (ms *memoryReviewStore) Close(ctx interface{}, review *reviews.Review) (error, bool) {
+	if ms.decided[*review.Id] {
+		return fmt.Errorf("review <%d> has already been decided", *review.Id), true
+	}
+
+	ms.closed[*review.Id] = *review.State
+	ms.requests[*review.Id] = logging.RequestId(ctx.(*gin.Context))
+	return nil, false
+}
+
+func (ms *memoryReviewStore) Result(ctx interface{}, review *reviews.Review) error {
+	return fmt.Errorf("not implemented")
+}
+
+func overdueReview(id int) *reviews.Review {
+	review := reviews.NewReview(1, nil, reviews.CONFIRM, -time.Minute)
+	review.Id = &id
+	return review
+}
+
+func heldTransaction(status string) *repository.Transaction {
+	id, channelId := 1, testChannelId
+	accountId, amount := 20, uint(1000)
+	kind, key := repository.PREAUTH, "card"
+
+	return &repository.Transaction{
+		Id:         &id,
+		Type:       &kind,
+		Status:     &status,
+		Amount:     &amount,
+		Account:    &repository.Account{Id: &accountId, Channel: &repository.Channel{Id: &channelId}},
+		Instrument: &repository.Instrument{Key: &key},
+	}
+}
+
+func TestExpire(t *testing.T) {
+	tests := []struct {
+		name    string
+		overdue []*reviews.Review
+		decided map[int]bool
+		err     error
+		closed  map[int]string
+		warned  int
+	}{
+		{
+			name:    "overdue reviews expired",
+			overdue: []*reviews.Review{overdueReview(1), overdueReview(2)},
+			closed:  map[int]string{1: reviews.EXPIRED, 2: reviews.EXPIRED},
+		},
+		{
+			name:    "review decided meanwhile",
+			overdue: []*reviews.Review{overdueReview(1), overdueReview(2)},
+			decided: map[int]bool{1: true},
+			closed:  map[int]string{2: reviews.EXPIRED},
+			warned:  1,
+		},
+		{
+			name: "query failed",
+			err:  fmt.Errorf("db is down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			store := &memoryReviewStore{
+				overdue:  tt.overdue,
+				err:      tt.err,
+				decided:  tt.decided,
+				closed:   map[int]string{},
+				requests: map[int]string{},
+			}
+			rh := NewReviewHandler(store, &transactionHandler{
+				transactionStore: &memoryTransactionStore{transaction: heldTransaction("new")},
+				loggerFunc:       func(interface{}) logrus.FieldLogger { return logger },
+			}, func(interface{}) logrus.FieldLogger { return logger })
+
+			err := rh.Expire(logging.Background("review-expiry"))
+			if tt.err != nil {
+				if err == nil {
+					t.Fatalf("query error is not returned")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expire failed: %v", err)
+			}
+
+			if fmt.Sprint(store.closed) != fmt.Sprint(tt.closed) {
+				t.Errorf("closed reviews = %v, want %v", store.closed, tt.closed)
+			}
+
+			for id, rid := range store.requests {
+				if rid != "review-expiry" {
+					t.Errorf("review <%d> is closed with request id %q", id, rid)
+				}
+			}
+
+			if len(hook.Entries) != tt.warned {
+				t.Errorf("warnings = %v, want %d", hook.Entries, tt.warned)
+			}
+		})
+	}
+}
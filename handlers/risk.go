@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/risk"
+	"github.com/serg666/repository"
+)
+
+type CreateRiskRuleRequest struct {
+	ProfileId *int                    `json:"profile_id"`
+	Key       string                  `json:"key" binding:"required"`
+	Action    string                  `json:"action" binding:"required,oneof=allow force3ds review deny"`
+	Score     int                     `json:"score" binding:"gte=0"`
+	Settings  *map[string]interface{} `json:"settings"`
+	Enabled   *bool                   `json:"enabled"`
+}
+
+type BlockRequest struct {
+	Kind    string  `json:"kind" binding:"required,oneof=pan_hash ip customer bin_country"`
+	Value   *string `json:"value" binding:"required_without=PAN"`
+	PAN     *string `json:"pan" binding:"required_without=Value,omitempty,numeric"`
+	Comment *string `json:"comment"`
+}
+
+type BlocklistRequest struct {
+	LimitAndOffsetRequest
+	Kind *string `form:"kind" binding:"omitempty,oneof=pan_hash ip customer bin_country"`
+}
+
+type AssessmentsRequest struct {
+	LimitAndOffsetRequest
+	Decision *string `form:"decision" binding:"omitempty,oneof=allow force3ds review deny"`
+}
+
+type riskHandler struct {
+	loggerFunc   repository.LoggerFunc
+	profileStore repository.ProfileRepository
+	store        risk.RiskRepository
+}
+
+func (rh *riskHandler) CreateRuleHandler(c *gin.Context) {
+	var req CreateRiskRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if _, ok := risk.Rules[req.Key]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": fmt.Sprintf("risk rule <%s> not registered", req.Key),
+		})
+		return
+	}
+
+	if req.ProfileId != nil {
+		err, _, profiles := rh.profileStore.Query(c, repository.NewProfileSpecificationByID(*req.ProfileId))
+		if err !=  nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if len(profiles) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": fmt.Sprintf("Profile with id=%v not found", *req.ProfileId),
+			})
+			return
+		}
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	rule := &risk.Rule{
+		ProfileId: req.ProfileId,
+		Key:       &req.Key,
+		Action:    &req.Action,
+		Score:     &req.Score,
+		Settings:  req.Settings,
+		Enabled:   &enabled,
+	}
+
+	if err := rh.store.AddRule(c, rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (rh *riskHandler) GetRulesHandler(c *gin.Context) {
+	var req LimitAndOffsetRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, overall, rules := rh.store.QueryRules(c, risk.NewRuleSpecificationWithLimitAndOffset(req.Limit, req.Offset))
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"rules": rules,
+	})
+}
+
+func (rh *riskHandler) DeleteRuleHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	rule := &risk.Rule{Id: &id}
+
+	err, notfound := rh.store.DeleteRule(c, rule)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (rh *riskHandler) BlockHandler(c *gin.Context) {
+	var req BlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// @note: PAN is never stored, only its hash
+	if req.PAN != nil {
+		if req.Kind != risk.PANHASH {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": fmt.Sprintf("pan can not be blocked as %s", req.Kind),
+			})
+			return
+		}
+
+		hash := risk.HashPAN(*req.PAN)
+		req.Value = &hash
+	}
+
+	value := strings.ToLower(strings.TrimSpace(*req.Value))
+	entry := &risk.BlocklistEntry{
+		Kind:    &req.Kind,
+		Value:   &value,
+		Comment: req.Comment,
+	}
+
+	if err := rh.store.Block(c, entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (rh *riskHandler) GetBlocklistHandler(c *gin.Context) {
+	var req BlocklistRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	specification := risk.NewBlocklistSpecificationWithLimitAndOffset(req.Limit, req.Offset)
+	if req.Kind != nil {
+		specification = risk.NewBlocklistSpecificationByKindWithLimitAndOffset(*req.Kind, req.Limit, req.Offset)
+	}
+
+	err, overall, entries := rh.store.QueryBlocklist(c, specification)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"blocklist": entries,
+	})
+}
+
+func (rh *riskHandler) UnblockHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err !=  nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	entry := &risk.BlocklistEntry{Id: &id}
+
+	err, notfound := rh.store.Unblock(c, entry)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (rh *riskHandler) GetAssessmentsHandler(c *gin.Context) {
+	var req AssessmentsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	specification := risk.NewAssessmentSpecificationWithLimitAndOffset(req.Limit, req.Offset)
+	if req.Decision != nil {
+		specification = risk.NewAssessmentSpecificationByDecisionWithLimitAndOffset(*req.Decision, req.Limit, req.Offset)
+	}
+
+	err, overall, assessments := rh.store.QueryAssessments(c, specification)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overall": overall,
+		"assessments": assessments,
+	})
+}
+
+func NewRiskHandler(
+	store risk.RiskRepository,
+	profileStore repository.ProfileRepository,
+	loggerFunc repository.LoggerFunc,
+) *riskHandler {
+	return &riskHandler{
+		loggerFunc:   loggerFunc,
+		profileStore: profileStore,
+		store:        store,
+	}
+}
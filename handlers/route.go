@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"net/http"
 	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/plugins"
 	"github.com/serg666/repository"
 )
 
@@ -33,6 +34,30 @@ type routeHandler struct {
 	routerStore     repository.RouterRepository
 	currencyStore   repository.CurrencyRepository
 	channelStore    repository.ChannelRepository
+	stateStore      plugins.PluginStateRepository
+}
+
+// checkNotRetired will return an error if route refers to retired plugin
+func (rh *routeHandler) checkNotRetired(c *gin.Context, route *repository.Route) error {
+	if route.Instrument != nil {
+		if err := plugins.CheckNotRetired(c, rh.stateStore, plugins.InstrumentKind, *route.Instrument.Id); err != nil {
+			return err
+		}
+	}
+
+	if route.Router != nil {
+		if err := plugins.CheckNotRetired(c, rh.stateStore, plugins.RouterKind, *route.Router.Id); err != nil {
+			return err
+		}
+	}
+
+	if route.Account != nil && route.Account.Channel != nil {
+		if err := plugins.CheckNotRetired(c, rh.stateStore, plugins.BankChannelKind, *route.Account.Channel.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (rh *routeHandler) CreateRouteHandler(c *gin.Context) {
@@ -126,6 +151,14 @@ func (rh *routeHandler) CreateRouteHandler(c *gin.Context) {
 		Router:     router,
 		Settings:   req.Settings,
 	}
+
+	if err := rh.checkNotRetired(c, route); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	if err := rh.routeStore.Add(c, route); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -331,6 +364,13 @@ func (rh *routeHandler) PatchRouteHandler(c *gin.Context) {
 		Settings:   req.Settings,
 	}
 
+	if err := rh.checkNotRetired(c, route); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	err, notfound := rh.routeStore.Update(c, route)
 
 	if notfound {
@@ -358,6 +398,7 @@ func NewRouteHandler(
 	routerStore repository.RouterRepository,
 	currencyStore repository.CurrencyRepository,
 	channelStore repository.ChannelRepository,
+	stateStore plugins.PluginStateRepository,
 	loggerFunc repository.LoggerFunc,
 ) *routeHandler {
 	return &routeHandler{
@@ -369,5 +410,6 @@ func NewRouteHandler(
 		routerStore:     routerStore,
 		currencyStore:   currencyStore,
 		channelStore:    channelStore,
+		stateStore:      stateStore,
 	}
 }
@@ -2,14 +2,27 @@ package handlers
 
 import (
 	"fmt"
+	"time"
 	"strconv"
 	"net/http"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/fees"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/gateway/ledger"
+	"github.com/serg666/gateway/limits"
 	"github.com/serg666/gateway/config"
 	"github.com/serg666/gateway/plugins"
 	"github.com/serg666/gateway/plugins/channels"
+	"github.com/serg666/gateway/rates"
+	"github.com/serg666/gateway/risk"
+	"github.com/serg666/gateway/reviews"
 	"github.com/serg666/gateway/validators"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/gateway/logging"
 	"github.com/serg666/repository"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type transactionHandler struct {
@@ -25,6 +38,430 @@ type transactionHandler struct {
 	cardStore        repository.CardRepository
 	transactionStore repository.TransactionRepository
 	sessionStore     repository.SessionRepository
+	stateStore       plugins.PluginStateRepository
+	rateStore        rates.RateRepository
+	feeStore         fees.FeeRepository
+	ledgerStore      ledger.LedgerRepository
+	limitStore       limits.LimitRepository
+	riskStore        risk.RiskRepository
+	reviewStore      reviews.ReviewRepository
+	binStore         bins.BinRepository
+}
+
+// fee will compute fee of the new transaction. Brand is nil for operations
+// on the reference
+func (th *transactionHandler) fee(c *gin.Context, transaction *repository.Transaction, brand *string) {
+	if err, _ := fees.Compute(c, th.feeStore, transaction, brand); err != nil {
+		th.loggerFunc(c).Warningf("failed to compute fee: %v", err)
+	}
+}
+
+// lookup will return card metadata of the payment and keep it for routers
+func (th *transactionHandler) lookup(c *gin.Context, card *repository.Card) *bins.Bin {
+	pan := string(*card.PAN)
+
+	err, bin := bins.Lookup(c, th.binStore, pan)
+	if err != nil {
+		th.loggerFunc(c).Warningf("failed to lookup bin: %v", err)
+		bin = bins.Unknown(pan)
+	}
+
+	bins.Set(c, bin)
+	return bin
+}
+
+// binOf will return card metadata of the transaction or nil
+func (th *transactionHandler) binOf(c *gin.Context, transaction *repository.Transaction) *bins.Bin {
+	err, bin := th.binStore.OfTransaction(c, *transaction.Id)
+	if err != nil {
+		th.loggerFunc(c).Warningf("failed to query transaction bin: %v", err)
+	}
+
+	return bin
+}
+
+// attach will keep card metadata with the new transaction
+func (th *transactionHandler) attach(c *gin.Context, transaction *repository.Transaction, bin *bins.Bin) {
+	if bin == nil {
+		return
+	}
+
+	if err := th.binStore.Attach(c, *transaction.Id, bin); err != nil {
+		th.loggerFunc(c).Warningf("failed to attach bin: %v", err)
+	}
+}
+
+// abort will answer the request with the error. Limit violations are
+// answered with their code
+func (th *transactionHandler) abort(c *gin.Context, status int, err error) {
+	if violation, ok := err.(*limits.Violation); ok {
+		th.loggerFunc(c).Printf("transaction rejected: %v", violation)
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": violation.Error(),
+			"code": violation.Code,
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"message": err.Error(),
+	})
+}
+
+// limited will answer the request if the transaction exceeds limits of its
+// profile, account or route. Otherwise limits are held until release is
+// called
+func (th *transactionHandler) limited(
+	c *gin.Context,
+	transaction *repository.Transaction,
+	route *repository.Route,
+	bin *bins.Bin,
+) (bool, func()) {
+	err, release := limits.Check(c, th.limitStore, transaction, route, bin)
+	if err == nil {
+		return false, release
+	}
+
+	th.abort(c, http.StatusInternalServerError, err)
+	return true, release
+}
+
+// screen will assess the payment and answer the request if it is denied
+func (th *transactionHandler) screen(c *gin.Context, input *risk.Input) (bool, *risk.Assessment) {
+	thresholds := risk.Thresholds{
+		Review: th.cfg.Risk.Review,
+		Deny:   th.cfg.Risk.Deny,
+	}
+
+	err, assessment := risk.Evaluate(c, th.riskStore, thresholds, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return true, nil
+	}
+
+	th.loggerFunc(c).Printf("risk assessment <%d>: %s (score %d)", *assessment.Id, assessment.Decision, assessment.Score)
+
+	if assessment.Decision == risk.DENY {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "payment denied by risk rules",
+			"code": "RISK_DENIED",
+			"risk": assessment,
+		})
+		return true, assessment
+	}
+
+	return false, assessment
+}
+
+// force3DS will tell whether the assessment requires 3DS and mark the
+// transaction with it. Payment is denied when the channel of the route can
+// not require 3DS, the first result is true then
+func (th *transactionHandler) force3DS(
+	c *gin.Context,
+	assessment *risk.Assessment,
+	route *repository.Route,
+	transaction *repository.Transaction,
+) (bool, bool) {
+	if assessment.Decision != risk.FORCE3DS {
+		return false, false
+	}
+
+	if err := plugins.CheckBankChannelForce3DS(route.Account); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": fmt.Sprintf("3DS is required by risk rules: %v", err),
+			"code": "RISK_DENIED",
+			"risk": assessment,
+		})
+		return true, false
+	}
+
+	if transaction.AdditionalData == nil {
+		transaction.AdditionalData = &repository.AdditionalData{}
+	}
+	(*transaction.AdditionalData)["force_3ds"] = true
+
+	return false, true
+}
+
+// unholdable will answer the request if the payment to be reviewed can not be
+// held with the account. Held payments are released by reversal
+func (th *transactionHandler) unholdable(
+	c *gin.Context,
+	assessment *risk.Assessment,
+	account *repository.Account,
+	instrument *repository.Instrument,
+	operations ...string,
+) bool {
+	if assessment.Decision != risk.REVIEW {
+		return false
+	}
+
+	err := fmt.Errorf("reversal not allowed")
+	if *account.ReversalEnabled {
+		err = nil
+		for _, operation := range append(operations, channels.REVERSE) {
+			if err = plugins.CheckBankChannelCapability(account, instrument, operation); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": fmt.Sprintf("payment can not be held for review: %v", err),
+			"code": "RISK_DENIED",
+			"risk": assessment,
+		})
+		return true
+	}
+
+	return false
+}
+
+// hold will put the transaction to the review queue. It is made before the
+// bank call, so no payment is held by the bank without the review. The
+// transaction is declined and the request is answered if it can not be held
+func (th *transactionHandler) hold(
+	c *gin.Context,
+	assessment *risk.Assessment,
+	transaction *repository.Transaction,
+	operation string,
+) bool {
+	review := reviews.NewReview(*transaction.Id, assessment.Id, operation, th.cfg.Reviews.SLA * time.Second)
+	if err := th.reviewStore.Add(c, review); err != nil {
+		mess := fmt.Sprintf("can not hold transaction for review: %v", err)
+		transaction.Declined(&mess)
+
+		if err, notfound := th.transactionStore.Update(c, transaction); err != nil {
+			th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": mess,
+		})
+		return true
+	}
+
+	th.loggerFunc(c).Printf("transaction <%d> held for review <%d>", *transaction.Id, *review.Id)
+	return false
+}
+
+// declined tells whether the transaction has been declined
+func declined(transaction *repository.Transaction) bool {
+	return transaction.InFinalState() && !transaction.IsSuccess()
+}
+
+// respond will answer with the transaction. Held transaction is answered
+// with its review
+func (th *transactionHandler) respond(c *gin.Context, transaction *repository.Transaction, held bool) {
+	if !held {
+		c.JSON(http.StatusOK, transaction)
+		return
+	}
+
+	err, body := th.details(c, transaction)
+	if err != nil {
+		th.loggerFunc(c).Warningf("failed to get transaction details: %v", err)
+		c.JSON(http.StatusOK, transaction)
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// held will answer the request if the transaction is held for review
+func (th *transactionHandler) held(c *gin.Context, transaction *repository.Transaction) bool {
+	err, review := reviews.Pending(c, th.reviewStore, *transaction.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return true
+	}
+
+	if review != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": fmt.Sprintf("Transaction is held for review <%d>", *review.Id),
+		})
+		return true
+	}
+
+	return false
+}
+
+// cancelReview will close pending review of the transaction released by
+// the merchant
+func (th *transactionHandler) cancelReview(c *gin.Context, transaction *repository.Transaction) {
+	err, review := reviews.Pending(c, th.reviewStore, *transaction.Id)
+	if err != nil {
+		th.loggerFunc(c).Warningf("failed to query review: %v", err)
+		return
+	}
+
+	if review == nil {
+		return
+	}
+
+	state := reviews.CANCELLED
+	review.State = &state
+	if err, _ := th.reviewStore.Close(c, review); err != nil {
+		th.loggerFunc(c).Warningf("failed to cancel review: %v", err)
+	}
+}
+
+// details will return the transaction with its fee, risk assessment, review
+// and card metadata
+func (th *transactionHandler) details(c *gin.Context, transaction *repository.Transaction) (error, map[string]interface{}) {
+	err, fee := th.feeStore.QueryFee(c, *transaction.Id)
+	if err != nil {
+		return err, nil
+	}
+
+	err, assessment := risk.QueryAssessment(c, th.riskStore, *transaction.Id)
+	if err != nil {
+		return err, nil
+	}
+
+	err, _, list := th.reviewStore.Query(c, reviews.NewReviewSpecificationByTransactionID(*transaction.Id))
+	if err != nil {
+		return err, nil
+	}
+
+	var review *reviews.Review
+	if len(list) > 0 {
+		review = list[0]
+	}
+
+	err, bin := th.binStore.OfTransaction(c, *transaction.Id)
+	if err != nil {
+		return err, nil
+	}
+
+	// @note: transaction is marshaled as is and the rest is added to it
+	var body map[string]interface{}
+	raw, err := json.Marshal(transaction)
+	if err == nil {
+		err = json.Unmarshal(raw, &body)
+	}
+	if err != nil {
+		return err, nil
+	}
+
+	body["fee"] = fee
+	body["risk"] = assessment
+	body["review"] = review
+	body["bin"] = bin
+
+	return nil, body
+}
+
+// link will link the assessment to the created transaction
+func (th *transactionHandler) link(c *gin.Context, assessment *risk.Assessment, transaction *repository.Transaction) {
+	if err := th.riskStore.Link(c, assessment, *transaction.Id); err != nil {
+		th.loggerFunc(c).Warningf("failed to link risk assessment: %v", err)
+	}
+}
+
+// onReference will make the operation of the kind with the amount on the
+// reference transaction. Charges are new payments by the card of the
+// reference, they take the current rate and are checked against limits
+func (th *transactionHandler) onReference(
+	c *gin.Context,
+	kind string,
+	transaction *repository.Transaction,
+	amount uint,
+	charge bool,
+	operation func(*gin.Context, *repository.Transaction) error,
+) (error, int, *repository.Transaction) {
+	newTransaction := repository.NewTransaction(kind,
+		transaction.OrderId,
+		transaction.Profile,
+		transaction.Account,
+		transaction.Instrument,
+		transaction.InstrumentId,
+		&amount,
+		transaction.Customer,
+		transaction,
+		nil,
+	)
+
+	rateOf := transaction
+	if charge {
+		rateOf = nil
+	}
+
+	if err := rates.Apply(c, th.rateStore, newTransaction, rateOf); err != nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	bin := th.binOf(c, transaction)
+
+	release := func() {}
+	if charge {
+		var err error
+		if err, release = limits.Check(c, th.limitStore, newTransaction, nil, bin); err != nil {
+			return err, http.StatusInternalServerError, nil
+		}
+	}
+
+	if err := th.transactionStore.Add(c, newTransaction); err != nil {
+		release()
+		return err, http.StatusInternalServerError, nil
+	}
+
+	logging.Transaction(c, newTransaction)
+
+	var brand *string
+	if bin != nil {
+		brand = bin.Brand
+	}
+
+	th.fee(c, newTransaction, brand)
+	th.attach(c, newTransaction, bin)
+	release()
+
+	if err := operation(c, newTransaction); err != nil {
+		mess := err.Error()
+		newTransaction.Declined(&mess)
+	}
+
+	if err, notfound := th.transactionStore.Update(c, newTransaction); err != nil {
+		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
+	}
+
+	th.finalize(c, newTransaction)
+
+	return nil, http.StatusOK, newTransaction
+}
+
+// finalize will charge fee of the transaction and post it to the ledger if
+// the transaction has become successful
+func (th *transactionHandler) finalize(c *gin.Context, transaction *repository.Transaction) {
+	// @note: declined payment has nothing to be reviewed
+	if declined(transaction) {
+		th.cancelReview(c, transaction)
+		return
+	}
+
+	if !transaction.IsSuccess() {
+		return
+	}
+
+	// @note: notfound means the transaction has got no fee
+	if err, notfound := th.feeStore.Charge(c, *transaction.Id); err != nil && !notfound {
+		th.loggerFunc(c).Warningf("failed to charge fee: %v", err)
+	}
+
+	err, fee := th.feeStore.QueryFee(c, *transaction.Id)
+	if err != nil {
+		th.loggerFunc(c).Warningf("failed to query fee: %v", err)
+	}
+
+	if err := ledger.Post(c, th.ledgerStore, transaction, fee); err != nil {
+		th.loggerFunc(c).Warningf("failed to post transaction to ledger: %v", err)
+	}
 }
 
 func (th *transactionHandler) route(
@@ -34,7 +471,12 @@ func (th *transactionHandler) route(
 	instrumentStore interface{},
 	instrumentRequester plugins.InstrumentRequesterFunc,
 	request interface{},
-) (error, *repository.Route) {
+) (err error, route *repository.Route) {
+	end := tracing.Start(c, "route.lookup", attribute.Int("profile.id", *profile.Id))
+	defer func() {
+		end(err)
+	}()
+
 	err, _, routes := th.routeStore.Query(c, repository.NewRouteSpecificationByProfileAndInstrument(
 		profile,
 		instrument,
@@ -48,15 +490,23 @@ func (th *transactionHandler) route(
 		return fmt.Errorf("Route by profile and instrument not found: %v", err), nil
 	}
 
-	route := routes[0]
+	route = routes[0]
 
 	if route.Router != nil {
+		if err := plugins.CheckNotRetired(c, th.stateStore, plugins.RouterKind, *route.Router.Id); err != nil {
+			return err, nil
+		}
+
 		err, routerApi := plugins.RouterApi(route, th.accountStore, instrumentStore, instrumentRequester, th.loggerFunc)
 		if err != nil {
 			return fmt.Errorf("Can not get router: %v", err), nil
 		}
 
-		if err := routerApi.Route(c, route, request); err != nil {
+		end := tracing.Start(c, "router.route", attribute.String("router.key", *route.Router.Key))
+		err = routerApi.Route(c, route, request)
+		end(err)
+
+		if err != nil {
 			return fmt.Errorf("Can not get route: %v", err), nil
 		}
 	}
@@ -64,45 +514,95 @@ func (th *transactionHandler) route(
 	return nil, route
 }
 
-func (th *transactionHandler) validate(c *gin.Context) (error, *repository.Transaction, channels.BankChannel) {
+// checkNotRetired will return an error if new payments can not be made
+// with the account and the instrument
+func (th *transactionHandler) checkNotRetired(
+	c *gin.Context,
+	account *repository.Account,
+	instrument *repository.Instrument,
+) error {
+	if err := plugins.CheckNotRetired(c, th.stateStore, plugins.InstrumentKind, *instrument.Id); err != nil {
+		return err
+	}
+
+	return plugins.CheckNotRetired(c, th.stateStore, plugins.BankChannelKind, *account.Channel.Id)
+}
+
+// checkAvailable will return an error if the circuit of the account is open.
+// Bank requests of the new payment fail fast from then on
+func (th *transactionHandler) checkAvailable(c *gin.Context, account *repository.Account) error {
+	if err := plugins.CheckAvailable(account); err != nil {
+		return err
+	}
+
+	c.Request = c.Request.WithContext(breaker.Guard(c.Request.Context()))
+	return nil
+}
+
+// load will return the transaction of the profile. It does not need the bank
+// channel, so transactions of retired channels are still readable
+func (th *transactionHandler) load(c *gin.Context) (error, *repository.Transaction) {
 	pid, err := strconv.Atoi(c.Params.ByName("pid"))
 	if err !=  nil {
-		return fmt.Errorf("invalid profile id: %v", err), nil, nil
+		return fmt.Errorf("invalid profile id: %v", err), nil
 	}
 
+	end := tracing.Start(c, "profile.lookup", attribute.Int("profile.id", pid))
 	err, _, profiles := th.profileStore.Query(c, repository.NewProfileSpecificationByID(pid))
+	end(err)
 
 	if err != nil {
-		return fmt.Errorf("faild to query profile store: %v", err), nil, nil
+		return fmt.Errorf("faild to query profile store: %v", err), nil
 	}
 
 	if len(profiles) == 0 {
-		return fmt.Errorf("profile with id=%v not found", pid), nil, nil
+		return fmt.Errorf("profile with id=%v not found", pid), nil
 	}
 
 	profile := profiles[0]
 
 	tid, err := strconv.Atoi(c.Params.ByName("tid"))
 	if err !=  nil {
-		return fmt.Errorf("invalid transaction id: %v", err), nil, nil
+		return fmt.Errorf("invalid transaction id: %v", err), nil
 	}
 
 	err, _, transactions := th.transactionStore.Query(c, repository.NewTransactionSpecificationByID(tid))
 
 	if err != nil {
-		return fmt.Errorf("faild to query transaction store: %v", err), nil, nil
+		return fmt.Errorf("faild to query transaction store: %v", err), nil
 	}
 
 	if len(transactions) == 0 {
-		return fmt.Errorf("transaction with id=%v not found", tid), nil, nil
+		return fmt.Errorf("transaction with id=%v not found", tid), nil
 	}
 
 	transaction := transactions[0]
 
 	if *transaction.Profile.Id != *profile.Id {
-		return fmt.Errorf("incorrect transaction id: %v", tid), nil, nil
+		return fmt.Errorf("incorrect transaction id: %v", tid), nil
 	}
 
+	logging.Transaction(c, transaction)
+
+	return nil, transaction
+}
+
+func (th *transactionHandler) validate(c *gin.Context) (error, *repository.Transaction, channels.BankChannel) {
+	err, transaction := th.load(c)
+	if err != nil {
+		return err, nil, nil
+	}
+
+	err, bankApi := th.bankApi(transaction)
+	if err != nil {
+		return err, nil, nil
+	}
+
+	return nil, transaction, bankApi
+}
+
+// bankApi will return bank channel of the transaction
+func (th *transactionHandler) bankApi(transaction *repository.Transaction) (error, channels.BankChannel) {
 	var instrumentStore interface{}
 
 	// @note: depends on instrument type
@@ -113,10 +613,10 @@ func (th *transactionHandler) validate(c *gin.Context) (error, *repository.Trans
 
 	err, bankApi := plugins.BankApi(th.cfg, transaction.Account, transaction.Instrument, instrumentStore, th.sessionStore, th.transactionStore, th.loggerFunc)
 	if err != nil {
-		return fmt.Errorf("faild to get bank api: %v", err), nil, nil
+		return fmt.Errorf("faild to get bank api: %v", err), nil
 	}
 
-	return nil, transaction, bankApi
+	return nil, bankApi
 }
 
 func (th *transactionHandler) ProcessParesHandler(c *gin.Context) {
@@ -143,6 +643,13 @@ func (th *transactionHandler) ProcessParesHandler(c *gin.Context) {
 		return
 	}
 
+	if err := plugins.CheckBankChannelThreeDS(transaction.Account, channels.ThreeDSVer10); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
 
 	if err := bankApi.ProcessPares(c, transaction, req.Pares); err != nil {
@@ -154,6 +661,8 @@ func (th *transactionHandler) ProcessParesHandler(c *gin.Context) {
 		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
 	}
 
+	th.finalize(c, transaction)
+
 	c.JSON(http.StatusOK, transaction)
 }
 
@@ -181,6 +690,13 @@ func (th *transactionHandler) ProcessCresHandler(c *gin.Context) {
 		return
 	}
 
+	if err := plugins.CheckBankChannelThreeDS(transaction.Account, channels.ThreeDSVer20); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
 
 	if err := bankApi.ProcessCres(c, transaction, req.Cres); err != nil {
@@ -192,6 +708,8 @@ func (th *transactionHandler) ProcessCresHandler(c *gin.Context) {
 		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
 	}
 
+	th.finalize(c, transaction)
+
 	c.JSON(http.StatusOK, transaction)
 }
 
@@ -219,6 +737,13 @@ func (th *transactionHandler) CompleteMethodUrlHandler(c *gin.Context) {
 		return
 	}
 
+	if err := plugins.CheckBankChannelThreeDS(transaction.Account, channels.ThreeDSVer20); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
 
 	if err := bankApi.CompleteMethodUrl(c, transaction, *req.Completed); err != nil {
@@ -230,6 +755,8 @@ func (th *transactionHandler) CompleteMethodUrlHandler(c *gin.Context) {
 		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
 	}
 
+	th.finalize(c, transaction)
+
 	c.JSON(http.StatusOK, transaction)
 }
 
@@ -285,34 +812,23 @@ func (th *transactionHandler) ReverseHandler(c *gin.Context) {
 		return
 	}
 
-	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
-
-	newTransaction := repository.NewTransaction(repository.REVERSAL,
-		transaction.OrderId,
-		transaction.Profile,
-		transaction.Account,
-		transaction.Instrument,
-		transaction.InstrumentId,
-		&req.Amount,
-		transaction.Customer,
-		transaction,
-		nil,
-	)
-
-	if err := th.transactionStore.Add(c, newTransaction); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := plugins.CheckBankChannelCapability(transaction.Account, transaction.Instrument, channels.REVERSE); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	if err := bankApi.Reverse(c, newTransaction); err != nil {
-		mess := err.Error()
-		newTransaction.Declined(&mess)
+	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
+
+	err, status, newTransaction := th.onReference(c, repository.REVERSAL, transaction, req.Amount, false, bankApi.Reverse)
+	if err !=  nil {
+		th.abort(c, status, err)
+		return
 	}
 
-	if err, notfound := th.transactionStore.Update(c, newTransaction); err != nil {
-		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
+	if newTransaction.IsSuccess() {
+		th.cancelReview(c, transaction)
 	}
 
 	c.JSON(http.StatusOK, newTransaction)
@@ -370,41 +886,26 @@ func (th *transactionHandler) RefundHandler(c *gin.Context) {
 		return
 	}
 
-	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
-
-	newTransaction := repository.NewTransaction(repository.REFUND,
-		transaction.OrderId,
-		transaction.Profile,
-		transaction.Account,
-		transaction.Instrument,
-		transaction.InstrumentId,
-		&req.Amount,
-		transaction.Customer,
-		transaction,
-		nil,
-	)
-
-	if err := th.transactionStore.Add(c, newTransaction); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := plugins.CheckBankChannelCapability(transaction.Account, transaction.Instrument, channels.REFUND); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	if err := bankApi.Refund(c, newTransaction); err != nil {
-		mess := err.Error()
-		newTransaction.Declined(&mess)
-	}
+	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
 
-	if err, notfound := th.transactionStore.Update(c, newTransaction); err != nil {
-		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
+	err, status, newTransaction := th.onReference(c, repository.REFUND, transaction, req.Amount, false, bankApi.Refund)
+	if err !=  nil {
+		th.abort(c, status, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, newTransaction)
 }
 
 func (th *transactionHandler) GetTransactionHandler(c *gin.Context) {
-	err, transaction, _ := th.validate(c)
+	err, transaction := th.load(c)
 	if err !=  nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
@@ -412,7 +913,15 @@ func (th *transactionHandler) GetTransactionHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	err, body := th.details(c, transaction)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
 }
 
 func (th *transactionHandler) RebillHandler(c *gin.Context) {
@@ -446,6 +955,10 @@ func (th *transactionHandler) RebillHandler(c *gin.Context) {
 		return
 	}
 
+	if th.held(c, transaction) {
+		return
+	}
+
 	if !*transaction.Account.RebillEnabled {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "Rebill not allowed",
@@ -453,34 +966,33 @@ func (th *transactionHandler) RebillHandler(c *gin.Context) {
 		return
 	}
 
-	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
-
-	newTransaction := repository.NewTransaction(repository.REBILL,
-		transaction.OrderId,
-		transaction.Profile,
-		transaction.Account,
-		transaction.Instrument,
-		transaction.InstrumentId,
-		&req.Amount,
-		transaction.Customer,
-		transaction,
-		nil,
-	)
+	if err := th.checkNotRetired(c, transaction.Account, transaction.Instrument); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 
-	if err := th.transactionStore.Add(c, newTransaction); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := th.checkAvailable(c, transaction.Account); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	if err := bankApi.Rebill(c, newTransaction); err != nil {
-		mess := err.Error()
-		newTransaction.Declined(&mess)
+	if err := plugins.CheckBankChannelCapability(transaction.Account, transaction.Instrument, channels.REBILL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
 	}
 
-	if err, notfound := th.transactionStore.Update(c, newTransaction); err != nil {
-		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
+	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
+
+	err, status, newTransaction := th.onReference(c, repository.REBILL, transaction, req.Amount, true, bankApi.Rebill)
+	if err !=  nil {
+		th.abort(c, status, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, newTransaction)
@@ -517,6 +1029,10 @@ func (th *transactionHandler) ConfirmPreAuthHandler(c *gin.Context) {
 		return
 	}
 
+	if th.held(c, transaction) {
+		return
+	}
+
 	if req.Amount > *transaction.Amount {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": fmt.Sprintf("Incorrect amount: %d", req.Amount),
@@ -531,34 +1047,19 @@ func (th *transactionHandler) ConfirmPreAuthHandler(c *gin.Context) {
 		return
 	}
 
-	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
-
-	newTransaction := repository.NewTransaction(repository.CONFIRMAUTH,
-		transaction.OrderId,
-		transaction.Profile,
-		transaction.Account,
-		transaction.Instrument,
-		transaction.InstrumentId,
-		&req.Amount,
-		transaction.Customer,
-		transaction,
-		nil,
-	)
-
-	if err := th.transactionStore.Add(c, newTransaction); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := plugins.CheckBankChannelCapability(transaction.Account, transaction.Instrument, channels.CONFIRM); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	if err := bankApi.Confirm(c, newTransaction); err != nil {
-		mess := err.Error()
-		newTransaction.Declined(&mess)
-	}
+	th.loggerFunc(c).Printf("using account: %v", transaction.Account)
 
-	if err, notfound := th.transactionStore.Update(c, newTransaction); err != nil {
-		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
+	err, status, newTransaction := th.onReference(c, repository.CONFIRMAUTH, transaction, req.Amount, false, bankApi.Confirm)
+	if err !=  nil {
+		th.abort(c, status, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, newTransaction)
@@ -581,7 +1082,9 @@ func (th *transactionHandler) CardAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	end := tracing.Start(c, "profile.lookup", attribute.Int("profile.id", id))
 	err, _, profiles := th.profileStore.Query(c, repository.NewProfileSpecificationByID(id))
+	end(err)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -629,7 +1132,9 @@ func (th *transactionHandler) CardAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	end = tracing.Start(c, "instrument.from_request", attribute.String("instrument.key", *instrument.Key))
 	err, instrumentInstance := instrumentApi.FromRequest(c, req)
+	end(err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -645,6 +1150,21 @@ func (th *transactionHandler) CardAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	bin := th.lookup(c, card)
+
+	stop, assessment := th.screen(c, risk.NewInput(
+		profile,
+		card,
+		bin,
+		&req.OrderId,
+		&req.Customer,
+		req.Amount,
+		&req.BrowserInfo,
+	))
+	if stop {
+		return
+	}
+
 	err, route := th.route(c, profile, instrument, th.cardStore, validators.CardAuthorizationInstrumentRequester, req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -661,10 +1181,42 @@ func (th *transactionHandler) CardAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	if err := th.checkNotRetired(c, route.Account, instrument); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := th.checkAvailable(c, route.Account); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := plugins.CheckBankChannelCapability(route.Account, instrument, channels.AUTHORIZE); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if th.unholdable(c, assessment, route.Account, instrument, channels.PREAUTHORIZE, channels.CONFIRM) {
+		return
+	}
+
+	// @note: authorization to be reviewed is held as preauthorization and
+	// confirmed on approval, card data is not kept to authorize it later
+	kind := repository.AUTH
+	if assessment.Decision == risk.REVIEW {
+		kind = repository.PREAUTH
+	}
+
 	th.loggerFunc(c).Printf("using account: %v", route.Account)
 
 	transaction := repository.NewTransaction(
-		repository.AUTH,
+		kind,
 		&req.OrderId,
 		profile,
 		route.Account,
@@ -676,14 +1228,49 @@ func (th *transactionHandler) CardAuthorizeHandler(c *gin.Context) {
 		&req.BrowserInfo,
 	)
 
+	if err := rates.Apply(c, th.rateStore, transaction, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	stop, forced := th.force3DS(c, assessment, route, transaction)
+	if stop {
+		return
+	}
+
+	stop, release := th.limited(c, transaction, route, bin)
+	if stop {
+		return
+	}
+
 	if err := th.transactionStore.Add(c, transaction); err != nil {
+		release()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	if err := bankApi.Authorize(c, transaction, req); err != nil {
+	logging.Transaction(c, transaction)
+
+	th.link(c, assessment, transaction)
+	th.attach(c, transaction, bin)
+	release()
+	th.fee(c, transaction, bin.Brand)
+
+	held := assessment.Decision == risk.REVIEW
+	if held && th.hold(c, assessment, transaction, reviews.CONFIRM) {
+		return
+	}
+
+	operation := func() error { return bankApi.Authorize(c, transaction, req, forced) }
+	if assessment.Decision == risk.REVIEW {
+		operation = func() error { return bankApi.PreAuthorize(c, transaction, validators.CardPreAuthorizeRequest{CardAuthorizeRequest: req}, forced) }
+	}
+
+	if err := operation(); err != nil {
 		mess := err.Error()
 		transaction.Declined(&mess)
 	}
@@ -692,7 +1279,9 @@ func (th *transactionHandler) CardAuthorizeHandler(c *gin.Context) {
 		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	th.finalize(c, transaction)
+
+	th.respond(c, transaction, held && !declined(transaction))
 }
 
 func (th *transactionHandler) CardPreAuthorizeHandler(c *gin.Context) {
@@ -712,7 +1301,9 @@ func (th *transactionHandler) CardPreAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	end := tracing.Start(c, "profile.lookup", attribute.Int("profile.id", id))
 	err, _, profiles := th.profileStore.Query(c, repository.NewProfileSpecificationByID(id))
+	end(err)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -760,7 +1351,9 @@ func (th *transactionHandler) CardPreAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	end = tracing.Start(c, "instrument.from_request", attribute.String("instrument.key", *instrument.Key))
 	err, instrumentInstance := instrumentApi.FromRequest(c, req)
+	end(err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -776,6 +1369,21 @@ func (th *transactionHandler) CardPreAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	bin := th.lookup(c, card)
+
+	stop, assessment := th.screen(c, risk.NewInput(
+		profile,
+		card,
+		bin,
+		&req.OrderId,
+		&req.Customer,
+		req.Amount,
+		&req.BrowserInfo,
+	))
+	if stop {
+		return
+	}
+
 	err, route := th.route(c, profile, instrument, th.cardStore, validators.CardPreAuthorizationInstrumentRequester, req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -792,6 +1400,31 @@ func (th *transactionHandler) CardPreAuthorizeHandler(c *gin.Context) {
 		return
 	}
 
+	if err := th.checkNotRetired(c, route.Account, instrument); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := th.checkAvailable(c, route.Account); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := plugins.CheckBankChannelCapability(route.Account, instrument, channels.PREAUTHORIZE); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if th.unholdable(c, assessment, route.Account, instrument) {
+		return
+	}
+
 	th.loggerFunc(c).Printf("using account: %v", route.Account)
 
 	transaction := repository.NewTransaction(
@@ -807,14 +1440,44 @@ func (th *transactionHandler) CardPreAuthorizeHandler(c *gin.Context) {
 		&req.BrowserInfo,
 	)
 
+	if err := rates.Apply(c, th.rateStore, transaction, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	stop, forced := th.force3DS(c, assessment, route, transaction)
+	if stop {
+		return
+	}
+
+	stop, release := th.limited(c, transaction, route, bin)
+	if stop {
+		return
+	}
+
 	if err := th.transactionStore.Add(c, transaction); err != nil {
+		release()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	if err := bankApi.PreAuthorize(c, transaction, req); err != nil {
+	logging.Transaction(c, transaction)
+
+	th.link(c, assessment, transaction)
+	th.attach(c, transaction, bin)
+	release()
+	th.fee(c, transaction, bin.Brand)
+
+	held := assessment.Decision == risk.REVIEW
+	if held && th.hold(c, assessment, transaction, reviews.RELEASE) {
+		return
+	}
+
+	if err := bankApi.PreAuthorize(c, transaction, req, forced); err != nil {
 		mess := err.Error()
 		transaction.Declined(&mess)
 	}
@@ -823,7 +1486,9 @@ func (th *transactionHandler) CardPreAuthorizeHandler(c *gin.Context) {
 		th.loggerFunc(c).Warningf("failed to update transaction: %v (notfound: %v)", err, notfound)
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	th.finalize(c, transaction)
+
+	th.respond(c, transaction, held && !declined(transaction))
 }
 
 func NewTransactionHandler(
@@ -837,6 +1502,14 @@ func NewTransactionHandler(
 	cardStore repository.CardRepository,
 	transactionStore repository.TransactionRepository,
 	sessionStore repository.SessionRepository,
+	stateStore plugins.PluginStateRepository,
+	rateStore rates.RateRepository,
+	feeStore fees.FeeRepository,
+	ledgerStore ledger.LedgerRepository,
+	limitStore limits.LimitRepository,
+	riskStore risk.RiskRepository,
+	reviewStore reviews.ReviewRepository,
+	binStore bins.BinRepository,
 	cfg *config.Config,
 	loggerFunc repository.LoggerFunc,
 ) *transactionHandler {
@@ -853,5 +1526,13 @@ func NewTransactionHandler(
 		cardStore:        cardStore,
 		transactionStore: transactionStore,
 		sessionStore:     sessionStore,
+		stateStore:       stateStore,
+		rateStore:        rateStore,
+		feeStore:         feeStore,
+		ledgerStore:      ledgerStore,
+		limitStore:       limitStore,
+		riskStore:        riskStore,
+		reviewStore:      reviewStore,
+		binStore:         binStore,
 	}
 }
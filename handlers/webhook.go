@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/webhooks"
+	"github.com/serg666/repository"
+)
+
+type SetWebhookRequest struct {
+	Url    *string `json:"url" binding:"required,url"`
+	Secret *string `json:"secret" binding:"omitempty,notempty"`
+}
+
+type webhookHandler struct {
+	loggerFunc   repository.LoggerFunc
+	profileStore repository.ProfileRepository
+	store        webhooks.WebhookRepository
+}
+
+func (wh *webhookHandler) profile(c *gin.Context) (error, int, *repository.Profile) {
+	id, err := strconv.Atoi(c.Params.ByName("pid"))
+	if err !=  nil {
+		return err, http.StatusBadRequest, nil
+	}
+
+	err, _, profiles := wh.profileStore.Query(c, repository.NewProfileSpecificationByID(id))
+	if err != nil {
+		return err, http.StatusInternalServerError, nil
+	}
+
+	if len(profiles) == 0 {
+		return fmt.Errorf("Profile with id=%v not found", id), http.StatusNotFound, nil
+	}
+
+	return nil, http.StatusOK, profiles[0]
+}
+
+func (wh *webhookHandler) GetWebhookHandler(c *gin.Context) {
+	err, status, profile := wh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, webhook := wh.store.Get(c, *profile.Id)
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": fmt.Sprintf("Profile with id=%v has got no webhook", *profile.Id),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (wh *webhookHandler) SetWebhookHandler(c *gin.Context) {
+	var req SetWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, status, profile := wh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	webhook := &webhooks.Webhook{
+		ProfileId: profile.Id,
+		Url:       req.Url,
+		Secret:    req.Secret,
+	}
+
+	if err := wh.store.Set(c, webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (wh *webhookHandler) DeleteWebhookHandler(c *gin.Context) {
+	err, status, profile := wh.profile(c)
+	if err !=  nil {
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err, notfound := wh.store.Delete(c, *profile.Id)
+
+	if notfound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err !=  nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func NewWebhookHandler(
+	store webhooks.WebhookRepository,
+	profileStore repository.ProfileRepository,
+	loggerFunc repository.LoggerFunc,
+) *webhookHandler {
+	return &webhookHandler{
+		loggerFunc:   loggerFunc,
+		profileStore: profileStore,
+		store:        store,
+	}
+}
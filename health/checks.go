@@ -0,0 +1,83 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+	"context"
+	"net/http"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/plugins"
+	"github.com/serg666/repository"
+)
+
+// Postgres will check the connection of the pool
+func Postgres(pool *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return pool.Ping(ctx)
+	}
+}
+
+// HTTP will check the service at the URL is reachable. Any response but
+// server errors is fine, the service may not serve the URL itself
+func HTTP(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		r, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return fmt.Errorf("can not make new request: %v", err)
+		}
+
+		res, err := client.Do(r)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("status %d", res.StatusCode)
+		}
+
+		return nil
+	}
+}
+
+// Plugins will check every plugin in the database is loaded or retired, as
+// it is checked at start, and out-of-process channels are healthy
+func Plugins(
+	routerStore repository.RouterRepository,
+	instrumentStore repository.InstrumentRepository,
+	channelStore repository.ChannelRepository,
+	stateStore plugins.PluginStateRepository,
+) Check {
+	return func(ctx context.Context) error {
+		if err := plugins.CheckRouters(routerStore, stateStore); err != nil {
+			return err
+		}
+
+		if err := plugins.CheckPaymentInstruments(instrumentStore, stateStore); err != nil {
+			return err
+		}
+
+		if err := plugins.CheckBankChannels(channelStore, stateStore); err != nil {
+			return err
+		}
+
+		var ids []int
+		for id := range plugins.BankChannels {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+
+		for _, id := range ids {
+			bc := plugins.BankChannels[id]
+			if bc.Health == nil {
+				continue
+			}
+
+			if err := bc.Health(); err != nil {
+				return fmt.Errorf("%s: %v", bc, err)
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"context"
+	"sync/atomic"
+)
+
+// Check is the check of the dependency. The dependency is ok if it returns
+// nil
+type Check func(ctx context.Context) error
+
+type named struct {
+	name  string
+	check Check
+}
+
+// Checker runs checks of the dependencies the gateway needs to serve
+// payments and keeps whether the gateway is shutting down
+type Checker struct {
+	mu       sync.RWMutex
+	checks   []named
+	timeout  time.Duration
+	draining int32
+}
+
+// Add will add the check of the dependency under the name
+func (hc *Checker) Add(name string, check Check) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.checks = append(hc.checks, named{name: name, check: check})
+}
+
+// Drain will make the gateway not ready from now on. It is called on
+// graceful shutdown so the orchestrator stops sending requests
+func (hc *Checker) Drain() {
+	atomic.StoreInt32(&hc.draining, 1)
+}
+
+func (hc *Checker) Draining() bool {
+	return atomic.LoadInt32(&hc.draining) == 1
+}
+
+// Run will run checks at once and return the first failure and errors by
+// the check name, nil for passed ones
+func (hc *Checker) Run(ctx context.Context) (error, map[string]error) {
+	hc.mu.RLock()
+	checks := hc.checks
+	hc.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(checks))
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}(i, nc.check)
+	}
+	wg.Wait()
+
+	var failed error
+	results := make(map[string]error, len(checks))
+	for i, nc := range checks {
+		results[nc.name] = errs[i]
+		if errs[i] != nil && failed == nil {
+			failed = fmt.Errorf("%s: %v", nc.name, errs[i])
+		}
+	}
+
+	return failed, results
+}
+
+// DefaultTimeout is used if the timeout is not set
+const DefaultTimeout = 5 * time.Second
+
+// NewChecker makes the checker giving checks the timeout at all
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Checker{timeout: timeout}
+}
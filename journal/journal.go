@@ -0,0 +1,212 @@
+package journal
+
+import (
+	"fmt"
+	"time"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/masking"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+// Store is the journal of bank exchanges. Exchanges are not journaled if nil
+var Store JournalRepository
+
+// Entry is one request to the bank and its response. Card data is masked
+// by the exchange, so no store gets it
+type Entry struct {
+	Id            *int       `json:"id"`
+	Created       *time.Time `json:"created"`
+	TransactionId *int       `json:"transaction_id"`
+	AccountId     *int       `json:"account_id"`
+	Channel       *string    `json:"channel"`
+	Method        *string    `json:"method"`
+	Url           *string    `json:"url"`
+	Request       *string    `json:"request"`
+	StatusCode    *int       `json:"status_code"`
+	Response      *string    `json:"response"`
+	Error         *string    `json:"error"`
+	// Latency in milliseconds
+	Latency       *int       `json:"latency"`
+}
+
+type JournalSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type journalSpecificationByTransactionID struct {
+	id int
+}
+
+func (js *journalSpecificationByTransactionID) ToSqlClauses() (string, []interface{}) {
+	return "where transaction_id=$1", []interface{}{js.id}
+}
+
+func NewJournalSpecificationByTransactionID(id int) JournalSpecification {
+	return &journalSpecificationByTransactionID{id: id}
+}
+
+type JournalRepository interface {
+	Add(ctx interface{}, entry *Entry) error
+	Query(ctx interface{}, specification JournalSpecification) (error, int, []*Entry)
+}
+
+type PGPoolJournalStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (js *PGPoolJournalStore) Add(ctx interface{}, entry *Entry) error {
+	return js.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into bank_journal (
+			transaction_id,
+			account_id,
+			channel,
+			method,
+			url,
+			request,
+			status_code,
+			response,
+			error,
+			latency
+		) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id, created`,
+		entry.TransactionId,
+		entry.AccountId,
+		entry.Channel,
+		entry.Method,
+		entry.Url,
+		entry.Request,
+		entry.StatusCode,
+		entry.Response,
+		entry.Error,
+		entry.Latency,
+	).Scan(&entry.Id, &entry.Created)
+}
+
+func (js *PGPoolJournalStore) Query(ctx interface{}, specification JournalSpecification) (error, int, []*Entry) {
+	var entries []*Entry
+
+	where, args := specification.ToSqlClauses()
+	rows, err := js.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			id,
+			created,
+			transaction_id,
+			account_id,
+			channel,
+			method,
+			url,
+			request,
+			status_code,
+			response,
+			error,
+			latency
+		from bank_journal %s order by id`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := &Entry{}
+		if err := rows.Scan(
+			&entry.Id,
+			&entry.Created,
+			&entry.TransactionId,
+			&entry.AccountId,
+			&entry.Channel,
+			&entry.Method,
+			&entry.Url,
+			&entry.Request,
+			&entry.StatusCode,
+			&entry.Response,
+			&entry.Error,
+			&entry.Latency,
+		); err != nil {
+			return err, 0, nil
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, len(entries), entries
+}
+
+func NewPGPoolJournalStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) JournalRepository {
+	return &PGPoolJournalStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
+
+// Exchange collects one bank exchange to be recorded in the journal
+type Exchange struct {
+	started time.Time
+	entry   *Entry
+}
+
+// Begin will start the exchange of the account for the transaction
+func Begin(
+	transaction *repository.Transaction,
+	account *repository.Account,
+	channel string,
+	method string,
+	url string,
+	request string,
+) *Exchange {
+	url = masking.Mask(url)
+	request = masking.Mask(request)
+
+	entry := &Entry{
+		AccountId: account.Id,
+		Channel:   &channel,
+		Method:    &method,
+		Url:       &url,
+		Request:   &request,
+	}
+
+	if transaction != nil {
+		entry.TransactionId = transaction.Id
+	}
+
+	return &Exchange{
+		started: time.Now(),
+		entry:   entry,
+	}
+}
+
+// End will record the exchange in the journal. Journal failures are only
+// logged, they never fail the bank operation
+func (e *Exchange) End(c interface{}, logger repository.LoggerFunc, statusCode int, response string, err error) {
+	if Store == nil {
+		return
+	}
+
+	latency := int(time.Since(e.started) / time.Millisecond)
+	e.entry.Latency = &latency
+
+	if statusCode != 0 {
+		e.entry.StatusCode = &statusCode
+	}
+
+	if response != "" {
+		response = masking.Mask(response)
+		e.entry.Response = &response
+	}
+
+	if err != nil {
+		message := masking.Mask(err.Error())
+		e.entry.Error = &message
+	}
+
+	if err := Store.Add(c, e.entry); err != nil {
+		logger(c).Warningf("can not add bank exchange to journal: %v", err)
+	}
+}
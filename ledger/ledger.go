@@ -0,0 +1,210 @@
+package ledger
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"github.com/serg666/gateway/fees"
+	"github.com/serg666/repository"
+)
+
+// Ledger account types. Merchant accounts belong to profiles, clearing
+// accounts belong to acquirer accounts and revenue account is the gateway one
+const (
+	MERCHANT = "merchant"
+	CLEARING = "clearing"
+	REVENUE  = "revenue"
+)
+
+// Entry kinds. There is at most one entry of each kind per transaction,
+// chargeback entries are per dispute since the transaction may be disputed
+// again after the dispute is over
+const (
+	CAPTURE            = "capture"
+	REFUND             = "refund"
+	FEE                = "fee"
+	CHARGEBACK         = "chargeback"
+	CHARGEBACKREVERSAL = "chargeback_reversal"
+)
+
+// Line is the posting to the ledger account. Amount is in minor units, it is
+// positive for credit and negative for debit, so positive balance of merchant
+// account is the amount owed to the merchant
+type Line struct {
+	Id          *int                 `json:"id"`
+	EntryId     *int                 `json:"entry_id"`
+	AccountType *string              `json:"account_type"`
+	OwnerId     *int                 `json:"owner_id"`
+	Currency    *repository.Currency `json:"currency"`
+	Amount      *int64               `json:"amount"`
+}
+
+// Entry is the balanced set of lines posted for the transaction
+type Entry struct {
+	Id            *int       `json:"id"`
+	TransactionId *int       `json:"transaction_id"`
+	Kind          *string    `json:"kind"`
+	DisputeId     *int       `json:"dispute_id"`
+	Created       *time.Time `json:"created"`
+	Lines         []*Line    `json:"lines"`
+}
+
+// Balanced will check that lines of the entry sum up to zero in each currency
+func (e *Entry) Balanced() bool {
+	sums := make(map[int]int64)
+	for _, line := range e.Lines {
+		sums[*line.Currency.Id] += *line.Amount
+	}
+
+	for _, sum := range sums {
+		if sum != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func line(accountType string, ownerId *int, currency *repository.Currency, amount int64) *Line {
+	return &Line{
+		AccountType: &accountType,
+		OwnerId:     ownerId,
+		Currency:    currency,
+		Amount:      &amount,
+	}
+}
+
+// NewEntry will return entry moving amount from the debited account to the
+// credited one
+func NewEntry(
+	transactionId *int,
+	kind string,
+	currency *repository.Currency,
+	amount uint,
+	debitType string,
+	debitOwnerId *int,
+	creditType string,
+	creditOwnerId *int,
+) *Entry {
+	return &Entry{
+		TransactionId: transactionId,
+		Kind:          &kind,
+		Lines:         []*Line{
+			line(debitType, debitOwnerId, currency, -int64(amount)),
+			line(creditType, creditOwnerId, currency, int64(amount)),
+		},
+	}
+}
+
+// converted will return the amount of the transaction in the currency the
+// acquirer settles in. Partial amounts are converted at the rate of the
+// transaction
+func converted(transaction *repository.Transaction, amount uint) uint {
+	if amount == *transaction.Amount || *transaction.Amount == 0 {
+		return *transaction.AmountConverted
+	}
+
+	return uint(math.Round(float64(amount) * float64(*transaction.AmountConverted) / float64(*transaction.Amount)))
+}
+
+// NewClearingEntry will return entry moving amount of the transaction from
+// the clearing account of the acquirer to the merchant, or back if toMerchant
+// is false. Clearing account is posted in the converted currency and amount,
+// since the acquirer settles in it. The currency exchange is balanced by the
+// gateway revenue account in both currencies
+func NewClearingEntry(transaction *repository.Transaction, kind string, amount uint, toMerchant bool) *Entry {
+	profileId := transaction.Profile.Id
+	accountId := transaction.Account.Id
+	currency := transaction.Currency
+
+	settlement := transaction.CurrencyConverted
+	if settlement == nil || transaction.AmountConverted == nil || *settlement.Id == *currency.Id {
+		if toMerchant {
+			return NewEntry(transaction.Id, kind, currency, amount, CLEARING, accountId, MERCHANT, profileId)
+		}
+		return NewEntry(transaction.Id, kind, currency, amount, MERCHANT, profileId, CLEARING, accountId)
+	}
+
+	sign := int64(1)
+	if !toMerchant {
+		sign = -1
+	}
+
+	settled := int64(converted(transaction, amount))
+
+	return &Entry{
+		TransactionId: transaction.Id,
+		Kind:          &kind,
+		Lines:         []*Line{
+			line(CLEARING, accountId, settlement, -sign * settled),
+			line(REVENUE, nil, settlement, sign * settled),
+			line(REVENUE, nil, currency, -sign * int64(amount)),
+			line(MERCHANT, profileId, currency, sign * int64(amount)),
+		},
+	}
+}
+
+// Entries will return ledger entries of the successful transaction. Captured
+// amount is credited to the merchant and debited to the clearing account of
+// the acquirer, refunds do the opposite. Only preauthorizations are reversed
+// and they have not been captured, so reversals move nothing. Charged fee is
+// moved from the merchant to the gateway revenue
+func Entries(transaction *repository.Transaction, fee *fees.Fee) []*Entry {
+	var entries []*Entry
+
+	if !transaction.IsSuccess() {
+		return entries
+	}
+
+	amount := *transaction.Amount
+
+	switch *transaction.Type {
+	case repository.AUTH, repository.CONFIRMAUTH, repository.REBILL:
+		entries = append(entries, NewClearingEntry(transaction, CAPTURE, amount, true))
+	case repository.REFUND:
+		entries = append(entries, NewClearingEntry(transaction, REFUND, amount, false))
+	}
+
+	if fee != nil && *fee.Charged && *fee.Amount > 0 {
+		entries = append(entries, NewEntry(transaction.Id, FEE, fee.Currency, *fee.Amount, MERCHANT, transaction.Profile.Id, REVENUE, nil))
+	}
+
+	return entries
+}
+
+// Post will post ledger entries of the successful transaction. Entries
+// already posted are skipped
+func Post(ctx interface{}, store LedgerRepository, transaction *repository.Transaction, fee *fees.Fee) error {
+	for _, entry := range Entries(transaction, fee) {
+		if err := store.Add(ctx, entry); err != nil {
+			return fmt.Errorf("failed to post %s entry: %v", *entry.Kind, err)
+		}
+	}
+
+	return nil
+}
+
+// PostChargeback will post chargeback of the dispute debiting the merchant.
+// Won dispute is posted with reversed set to credit the amount back
+func PostChargeback(
+	ctx interface{},
+	store LedgerRepository,
+	transaction *repository.Transaction,
+	disputeId *int,
+	amount uint,
+	reversed bool,
+) error {
+	entry := NewClearingEntry(transaction, CHARGEBACK, amount, false)
+
+	if reversed {
+		entry = NewClearingEntry(transaction, CHARGEBACKREVERSAL, amount, true)
+	}
+
+	entry.DisputeId = disputeId
+
+	if err := store.Add(ctx, entry); err != nil {
+		return fmt.Errorf("failed to post %s entry: %v", *entry.Kind, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,288 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"github.com/serg666/gateway/fees"
+	"github.com/serg666/repository"
+)
+
+func currency(id int, charCode string) *repository.Currency {
+	return &repository.Currency{Id: &id, CharCode: &charCode}
+}
+
+var (
+	rub = currency(1, "RUB")
+	usd = currency(2, "USD")
+)
+
+func amount(n uint) *uint {
+	return &n
+}
+
+// transaction will return successful transaction of profile 10 on account
+// 20 converted to the currency given
+func transaction(kind string, value uint, converted *repository.Currency, convertedValue uint) *repository.Transaction {
+	id := 1
+	profileId := 10
+	accountId := 20
+	status := repository.SUCCESS
+
+	return &repository.Transaction{
+		Id:                &id,
+		Type:              &kind,
+		Status:            &status,
+		Profile:           &repository.Profile{Id: &profileId, Currency: usd},
+		Account:           &repository.Account{Id: &accountId, Currency: converted},
+		Currency:          usd,
+		Amount:            &value,
+		CurrencyConverted: converted,
+		AmountConverted:   &convertedValue,
+	}
+}
+
+// lines will print lines of the entry as account:owner:currency:amount in
+// stable order
+func lines(entry *Entry) string {
+	var printed []string
+	for _, line := range entry.Lines {
+		owner := "-"
+		if line.OwnerId != nil {
+			owner = fmt.Sprint(*line.OwnerId)
+		}
+		printed = append(printed, fmt.Sprintf("%s:%s:%s:%d", *line.AccountType, owner, *line.Currency.CharCode, *line.Amount))
+	}
+	sort.Strings(printed)
+
+	return strings.Join(printed, " ")
+}
+
+func TestBalanced(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *Entry
+		want  bool
+	}{
+		{
+			name:  "two lines",
+			entry: NewEntry(nil, CAPTURE, rub, 100, CLEARING, nil, MERCHANT, nil),
+			want:  true,
+		},
+		{
+			name: "balanced in each currency",
+			entry: &Entry{Lines: []*Line{
+				line(CLEARING, nil, rub, -7500),
+				line(REVENUE, nil, rub, 7500),
+				line(REVENUE, nil, usd, -100),
+				line(MERCHANT, nil, usd, 100),
+			}},
+			want: true,
+		},
+		{
+			name: "balanced in sum only",
+			entry: &Entry{Lines: []*Line{
+				line(CLEARING, nil, rub, -100),
+				line(MERCHANT, nil, usd, 100),
+			}},
+			want: false,
+		},
+		{
+			name: "unbalanced",
+			entry: &Entry{Lines: []*Line{
+				line(CLEARING, nil, rub, -100),
+				line(MERCHANT, nil, rub, 99),
+			}},
+			want: false,
+		},
+		{
+			name:  "empty",
+			entry: &Entry{},
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.Balanced(); got != tt.want {
+				t.Errorf("Balanced = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntries(t *testing.T) {
+	charged := true
+	notCharged := false
+	fee := &fees.Fee{Amount: amount(3), Currency: usd, Charged: &charged}
+
+	declined := transaction(repository.AUTH, 100, usd, 100)
+	status := "declined"
+	declined.Status = &status
+
+	reversalOf := func(reference *repository.Transaction) *repository.Transaction {
+		reversal := transaction(repository.REVERSAL, 100, usd, 100)
+		reversal.Reference = reference
+		return reversal
+	}
+
+	tests := []struct {
+		name        string
+		transaction *repository.Transaction
+		fee         *fees.Fee
+		want        []string
+	}{
+		{
+			name:        "capture",
+			transaction: transaction(repository.AUTH, 100, usd, 100),
+			want:        []string{"capture: clearing:20:USD:-100 merchant:10:USD:100"},
+		},
+		{
+			name:        "capture with fee",
+			transaction: transaction(repository.REBILL, 100, usd, 100),
+			fee:         fee,
+			want: []string{
+				"capture: clearing:20:USD:-100 merchant:10:USD:100",
+				"fee: merchant:10:USD:-3 revenue:-:USD:3",
+			},
+		},
+		{
+			name:        "fee not charged",
+			transaction: transaction(repository.AUTH, 100, usd, 100),
+			fee:         &fees.Fee{Amount: amount(3), Currency: usd, Charged: &notCharged},
+			want:        []string{"capture: clearing:20:USD:-100 merchant:10:USD:100"},
+		},
+		{
+			name:        "converted capture",
+			transaction: transaction(repository.CONFIRMAUTH, 100, rub, 7500),
+			want:        []string{"capture: clearing:20:RUB:-7500 merchant:10:USD:100 revenue:-:RUB:7500 revenue:-:USD:-100"},
+		},
+		{
+			name:        "converted refund",
+			transaction: transaction(repository.REFUND, 50, rub, 3750),
+			want:        []string{"refund: clearing:20:RUB:3750 merchant:10:USD:-50 revenue:-:RUB:-3750 revenue:-:USD:50"},
+		},
+		{
+			name:        "reversal of preauthorization",
+			transaction: reversalOf(transaction(repository.PREAUTH, 100, usd, 100)),
+		},
+		{
+			name:        "preauthorization",
+			transaction: transaction(repository.PREAUTH, 100, usd, 100),
+		},
+		{
+			name:        "declined",
+			transaction: declined,
+			fee:         fee,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range Entries(tt.transaction, tt.fee) {
+				if !entry.Balanced() {
+					t.Errorf("%s entry is not balanced", *entry.Kind)
+				}
+				got = append(got, *entry.Kind+": "+lines(entry))
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Entries =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestNewClearingEntry(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     uint
+		toMerchant bool
+		want       string
+	}{
+		{
+			name:       "whole amount",
+			amount:     100,
+			toMerchant: true,
+			want:       "clearing:20:RUB:-7533 merchant:10:USD:100 revenue:-:RUB:7533 revenue:-:USD:-100",
+		},
+		{
+			name:   "partial amount at the rate of the transaction",
+			amount: 30,
+			want:   "clearing:20:RUB:2260 merchant:10:USD:-30 revenue:-:RUB:-2260 revenue:-:USD:30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := NewClearingEntry(transaction(repository.AUTH, 100, rub, 7533), CHARGEBACK, tt.amount, tt.toMerchant)
+			if !entry.Balanced() {
+				t.Errorf("entry is not balanced")
+			}
+
+			if got := lines(entry); got != tt.want {
+				t.Errorf("lines = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// memoryLedgerStore keeps entries by the unique key of ledger_entries
+type memoryLedgerStore struct {
+	entries map[string]*Entry
+}
+
+func (ms *memoryLedgerStore) Add(ctx interface{}, entry *Entry) error {
+	disputeId := 0
+	if entry.DisputeId != nil {
+		disputeId = *entry.DisputeId
+	}
+
+	key := fmt.Sprintf("%d:%s:%d", *entry.TransactionId, *entry.Kind, disputeId)
+	if _, ok := ms.entries[key]; !ok {
+		ms.entries[key] = entry
+	}
+
+	return nil
+}
+
+func (ms *memoryLedgerStore) Balances(ctx interface{}, accountType string, ownerId *int) (error, []*Balance) {
+	return fmt.Errorf("not implemented"), nil
+}
+
+func (ms *memoryLedgerStore) Statement(ctx interface{}, filter StatementFilter) (error, int, *Statement) {
+	return fmt.Errorf("not implemented"), 0, nil
+}
+
+func TestPostChargeback(t *testing.T) {
+	store := &memoryLedgerStore{entries: map[string]*Entry{}}
+	tx := transaction(repository.AUTH, 100, usd, 100)
+	first, second := 1, 2
+
+	for _, post := range []struct {
+		disputeId *int
+		reversed  bool
+	}{
+		{disputeId: &first},
+		{disputeId: &first, reversed: true},
+		{disputeId: &first, reversed: true},
+		{disputeId: &second},
+	} {
+		if err := PostChargeback(nil, store, tx, post.disputeId, 40, post.reversed); err != nil {
+			t.Fatalf("PostChargeback failed: %v", err)
+		}
+	}
+
+	want := []string{"1:chargeback:1", "1:chargeback:2", "1:chargeback_reversal:1"}
+	var got []string
+	for key := range store.entries {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,256 @@
+package ledger
+
+import (
+	"fmt"
+	"time"
+	"encoding/json"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/reports"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+// FormatAmount will format signed amount in minor units as decimal
+func FormatAmount(amount int64, exponent int) string {
+	if amount < 0 {
+		return "-" + reports.FormatAmount(uint(-amount), exponent)
+	}
+
+	return reports.FormatAmount(uint(amount), exponent)
+}
+
+// Balance is the balance of the ledger account in the currency
+type Balance struct {
+	Currency *repository.Currency `json:"currency"`
+	Balance  int64                `json:"balance"`
+}
+
+func (b *Balance) MarshalJSON() ([]byte, error) {
+	type balance Balance
+	return json.Marshal(&struct {
+		*balance
+		Amount string `json:"amount"`
+	}{
+		balance: (*balance)(b),
+		Amount:  FormatAmount(b.Balance, exponent(b.Currency)),
+	})
+}
+
+func exponent(currency *repository.Currency) int {
+	if currency.Exponent == nil {
+		return 0
+	}
+
+	return *currency.Exponent
+}
+
+// StatementFilter limits statement of the ledger account in the currency to
+// the period. Period includes From and excludes To
+type StatementFilter struct {
+	AccountType string
+	OwnerId     *int
+	CurrencyId  int
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Offset      int
+}
+
+// StatementLine is the posting to the account with the balance after it
+type StatementLine struct {
+	EntryId       int       `json:"entry_id"`
+	TransactionId int       `json:"transaction_id"`
+	Kind          string    `json:"kind"`
+	Created       time.Time `json:"created"`
+	Amount        int64     `json:"amount"`
+	Balance       int64     `json:"balance"`
+}
+
+// Statement is the list of postings within the period with balances at its
+// start and end
+type Statement struct {
+	Opening int64            `json:"opening"`
+	Closing int64            `json:"closing"`
+	Lines   []*StatementLine `json:"lines"`
+}
+
+type LedgerRepository interface {
+	// Add will post balanced entry. Entry of the same kind already posted
+	// for the transaction and dispute is kept as is
+	Add(ctx interface{}, entry *Entry) error
+	Balances(ctx interface{}, accountType string, ownerId *int) (error, []*Balance)
+	Statement(ctx interface{}, filter StatementFilter) (error, int, *Statement)
+}
+
+type PGPoolLedgerStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (ls *PGPoolLedgerStore) Add(ctx interface{}, entry *Entry) error {
+	if !entry.Balanced() {
+		return fmt.Errorf("%s entry of transaction <%d> is not balanced", *entry.Kind, *entry.TransactionId)
+	}
+
+	tx, err := ls.pool.Begin(tracing.ContextOf(ctx))
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(tracing.ContextOf(ctx))
+
+	err = tx.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into ledger_entries (transaction_id, kind, dispute_id) values ($1, $2, $3)
+		on conflict (transaction_id, kind, coalesce(dispute_id, 0)) do nothing
+		returning id, created`,
+		entry.TransactionId,
+		entry.Kind,
+		entry.DisputeId,
+	).Scan(&entry.Id, &entry.Created)
+
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, line := range entry.Lines {
+		line.EntryId = entry.Id
+		if err := tx.QueryRow(
+			tracing.ContextOf(ctx),
+			`insert into ledger_lines (entry_id, account_type, owner_id, currency_id, amount)
+			values ($1, $2, $3, $4, $5) returning id`,
+			line.EntryId,
+			line.AccountType,
+			line.OwnerId,
+			line.Currency.Id,
+			line.Amount,
+		).Scan(&line.Id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(tracing.ContextOf(ctx))
+}
+
+func (ls *PGPoolLedgerStore) Balances(ctx interface{}, accountType string, ownerId *int) (error, []*Balance) {
+	var balances []*Balance
+
+	rows, err := ls.pool.Query(
+		tracing.ContextOf(ctx),
+		`select c.id, c.numeric_code, c.name, c.char_code, c.exponent, sum(l.amount)
+		from ledger_lines l
+		join currencies c on c.id=l.currency_id
+		where l.account_type=$1 and l.owner_id is not distinct from $2
+		group by c.id, c.numeric_code, c.name, c.char_code, c.exponent
+		order by c.char_code`,
+		accountType,
+		ownerId,
+	)
+	if err != nil {
+		return err, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		balance := &Balance{Currency: &repository.Currency{}}
+		if err := rows.Scan(
+			&balance.Currency.Id,
+			&balance.Currency.NumericCode,
+			&balance.Currency.Name,
+			&balance.Currency.CharCode,
+			&balance.Currency.Exponent,
+			&balance.Balance,
+		); err != nil {
+			return err, nil
+		}
+		balances = append(balances, balance)
+	}
+
+	return rows.Err(), balances
+}
+
+func (ls *PGPoolLedgerStore) Statement(ctx interface{}, filter StatementFilter) (error, int, *Statement) {
+	var overall int
+	statement := &Statement{}
+
+	if err := ls.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`select
+			coalesce(sum(l.amount) filter (where e.created<$4), 0),
+			coalesce(sum(l.amount) filter (where e.created<$5), 0)
+		from ledger_lines l
+		join ledger_entries e on e.id=l.entry_id
+		where l.account_type=$1 and l.owner_id is not distinct from $2 and l.currency_id=$3`,
+		filter.AccountType,
+		filter.OwnerId,
+		filter.CurrencyId,
+		filter.From,
+		filter.To,
+	).Scan(&statement.Opening, &statement.Closing); err != nil {
+		return err, 0, nil
+	}
+
+	rows, err := ls.pool.Query(
+		tracing.ContextOf(ctx),
+		`select entry_id, transaction_id, kind, created, amount, balance, count(*) over()
+		from (
+			select
+				l.id,
+				l.entry_id,
+				e.transaction_id,
+				e.kind,
+				e.created,
+				l.amount,
+				sum(l.amount) over (order by l.id) as balance
+			from ledger_lines l
+			join ledger_entries e on e.id=l.entry_id
+			where l.account_type=$1 and l.owner_id is not distinct from $2 and l.currency_id=$3
+		) s
+		where created>=$4 and created<$5
+		order by id limit $6 offset $7`,
+		filter.AccountType,
+		filter.OwnerId,
+		filter.CurrencyId,
+		filter.From,
+		filter.To,
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		line := &StatementLine{}
+		if err := rows.Scan(
+			&line.EntryId,
+			&line.TransactionId,
+			&line.Kind,
+			&line.Created,
+			&line.Amount,
+			&line.Balance,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		statement.Lines = append(statement.Lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, statement
+}
+
+func NewPGPoolLedgerStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) LedgerRepository {
+	return &PGPoolLedgerStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
@@ -0,0 +1,198 @@
+package limits
+
+import (
+	"fmt"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/repository"
+)
+
+// Scopes limits attach to
+const (
+	PROFILE = "profile"
+	ACCOUNT = "account"
+	ROUTE   = "route"
+)
+
+// Limit kinds. Amounts are in minor units of the profile currency for
+// profile and route scopes and of the account currency for account scope
+const (
+	MAXAMOUNT       = "max_amount"
+	DAILYTURNOVER   = "daily_turnover"
+	MONTHLYTURNOVER = "monthly_turnover"
+	// CARDHOURLY is the number of transactions per card within last hour
+	CARDHOURLY      = "card_hourly_count"
+	// CUSTOMERCARDS is the number of distinct cards per customer within
+	// last day
+	CUSTOMERCARDS   = "customer_daily_cards"
+)
+
+// Codes are error codes of rejections by limit kind
+var Codes = map[string]string{
+	MAXAMOUNT:       "LIMIT_MAX_AMOUNT",
+	DAILYTURNOVER:   "LIMIT_DAILY_TURNOVER",
+	MONTHLYTURNOVER: "LIMIT_MONTHLY_TURNOVER",
+	CARDHOURLY:      "LIMIT_CARD_VELOCITY",
+	CUSTOMERCARDS:   "LIMIT_CUSTOMER_CARDS",
+}
+
+// Limited are transaction types limits are enforced on and counted by
+var Limited = []string{
+	repository.AUTH,
+	repository.PREAUTH,
+	repository.REBILL,
+}
+
+// DECLINED is the status of transactions declined by the bank. They have
+// moved no money, so they are the only ones left out of turnover. Scopes
+// are released before the bank call, so transactions in progress have to
+// be counted, otherwise concurrent payments pass the limit together
+const DECLINED = "declined"
+
+// Limit is enforced on transactions of cards with the brand, type and
+// country. Nil card conditions match any card
+type Limit struct {
+	Id       *int    `json:"id"`
+	Scope    *string `json:"scope"`
+	ScopeId  *int    `json:"scope_id"`
+	Kind     *string `json:"kind"`
+	Value    *int64  `json:"value"`
+	Brand    *string `json:"brand"`
+	CardType *string `json:"card_type"`
+	Country  *string `json:"country"`
+}
+
+// Conditional will tell whether the limit is enforced on some cards only
+func (l *Limit) Conditional() bool {
+	return l.Brand != nil || l.CardType != nil || l.Country != nil
+}
+
+// Applies will tell whether the limit is enforced on the card
+func (l *Limit) Applies(bin *bins.Bin) bool {
+	return !l.Conditional() || bin.Matches(l.Brand, l.CardType, l.Country)
+}
+
+// Subject is the transaction usage is counted for
+type Subject struct {
+	Card     *int
+	Customer *string
+}
+
+// Usage is the current usage of the limit
+type Usage struct {
+	Limit     *Limit `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining int64  `json:"remaining"`
+}
+
+// Violation is the rejection of the transaction by the limit
+type Violation struct {
+	Code      string `json:"code"`
+	Limit     *Limit `json:"limit"`
+	Used      int64  `json:"used"`
+	Requested int64  `json:"requested"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf(
+		"%s limit <%d> of %s <%d> exceeded: used %d, requested %d, allowed %d",
+		*v.Limit.Kind,
+		*v.Limit.Id,
+		*v.Limit.Scope,
+		*v.Limit.ScopeId,
+		v.Used,
+		v.Requested,
+		*v.Limit.Value,
+	)
+}
+
+// amount will return transaction amount in the currency of the limit scope
+func amount(limit *Limit, transaction *repository.Transaction) int64 {
+	if *limit.Scope == ACCOUNT && transaction.AmountConverted != nil {
+		return int64(*transaction.AmountConverted)
+	}
+
+	return int64(*transaction.Amount)
+}
+
+// requested will return how much the transaction adds to the usage
+func requested(limit *Limit, transaction *repository.Transaction) int64 {
+	switch *limit.Kind {
+	case MAXAMOUNT, DAILYTURNOVER, MONTHLYTURNOVER:
+		return amount(limit, transaction)
+	case CARDHOURLY:
+		return 1
+	}
+
+	// @note: distinct cards usage already includes the card of the
+	// transaction
+	return 0
+}
+
+// Check will check the new transaction against limits of its profile,
+// account and route. Route is nil when the transaction has not been routed,
+// bin is nil when card of the transaction is unknown. Exceeded limit is
+// returned as *Violation. Passed transaction holds scopes of its limits, so
+// concurrent checks wait until release is called. It is to be called once
+// the transaction and its bin are added, so the next check counts them.
+// Release is never nil
+func Check(
+	ctx interface{},
+	store LimitRepository,
+	transaction *repository.Transaction,
+	route *repository.Route,
+	bin *bins.Bin,
+) (error, func()) {
+	var routeId *int
+	if route != nil {
+		routeId = route.Id
+	}
+
+	err, _, list := store.Query(ctx, NewLimitSpecificationByScopes(
+		transaction.Profile.Id,
+		transaction.Account.Id,
+		routeId,
+	))
+	if err != nil {
+		return fmt.Errorf("failed to query limits: %v", err), func() {}
+	}
+
+	var applied []*Limit
+	for _, limit := range list {
+		if limit.Applies(bin) {
+			applied = append(applied, limit)
+		}
+	}
+
+	err, release := store.Lock(ctx, applied)
+	if err != nil {
+		return fmt.Errorf("failed to lock limits: %v", err), func() {}
+	}
+
+	subject := Subject{
+		Card:     transaction.InstrumentId,
+		Customer: transaction.Customer,
+	}
+
+	for _, limit := range applied {
+		var used int64
+		if *limit.Kind != MAXAMOUNT {
+			if err, used = store.Usage(ctx, limit, subject); err != nil {
+				release()
+				return fmt.Errorf("failed to count usage of limit <%d>: %v", *limit.Id, err), func() {}
+			}
+		}
+
+		requested := requested(limit, transaction)
+		if used+requested > *limit.Value {
+			release()
+			return &Violation{
+				Code:      Codes[*limit.Kind],
+				Limit:     limit,
+				Used:      used,
+				Requested: requested,
+			}, func() {}
+		}
+	}
+
+	return nil, release
+}
@@ -0,0 +1,409 @@
+package limits
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"strings"
+	"testing"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/repository"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func number(n int) *int {
+	return &n
+}
+
+func limit(id int, scope string, scopeId int, kind string, value int64) *Limit {
+	return &Limit{
+		Id:      &id,
+		Scope:   &scope,
+		ScopeId: &scopeId,
+		Kind:    &kind,
+		Value:   &value,
+	}
+}
+
+// memoryLimitStore returns all its limits and usage by limit id. Locks
+// taken and released are counted
+type memoryLimitStore struct {
+	limits   []*Limit
+	usage    map[int]int64
+	err      error
+	locked   int
+	released int
+}
+
+func (ms *memoryLimitStore) Add(ctx interface{}, limit *Limit) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryLimitStore) Delete(ctx interface{}, limit *Limit) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryLimitStore) Query(ctx interface{}, specification LimitSpecification) (error, int, []*Limit) {
+	return nil, len(ms.limits), ms.limits
+}
+
+func (ms *memoryLimitStore) Usage(ctx interface{}, limit *Limit, subject Subject) (error, int64) {
+	if ms.err != nil {
+		return ms.err, 0
+	}
+
+	return nil, ms.usage[*limit.Id]
+}
+
+func (ms *memoryLimitStore) Lock(ctx interface{}, list []*Limit) (error, func()) {
+	ms.locked++
+	return nil, func() {
+		ms.released++
+	}
+}
+
+func TestCheck(t *testing.T) {
+	visa := &bins.Bin{Prefix: str("411111"), Brand: str(bins.VISA), Type: str(bins.DEBIT), Country: str("RU")}
+	mastercard := &bins.Bin{Prefix: str("555555"), Brand: str(bins.MASTERCARD)}
+
+	onlyMastercard := limit(5, PROFILE, 10, MAXAMOUNT, 100)
+	onlyMastercard.Brand = str(bins.MASTERCARD)
+
+	tests := []struct {
+		name   string
+		limits []*Limit
+		usage  map[int]int64
+		bin    *bins.Bin
+		err    error
+		code   string
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name:   "max amount within",
+			limits: []*Limit{limit(1, PROFILE, 10, MAXAMOUNT, 1000)},
+			bin:    visa,
+		},
+		{
+			name:   "max amount exceeded",
+			limits: []*Limit{limit(1, PROFILE, 10, MAXAMOUNT, 999)},
+			bin:    visa,
+			code:   "LIMIT_MAX_AMOUNT",
+		},
+		{
+			name:   "account limit in converted amount",
+			limits: []*Limit{limit(1, ACCOUNT, 20, MAXAMOUNT, 70000)},
+			bin:    visa,
+			code:   "LIMIT_MAX_AMOUNT",
+		},
+		{
+			name:   "daily turnover within",
+			limits: []*Limit{limit(2, PROFILE, 10, DAILYTURNOVER, 5000)},
+			usage:  map[int]int64{2: 4000},
+			bin:    visa,
+		},
+		{
+			name:   "monthly turnover exceeded",
+			limits: []*Limit{limit(2, PROFILE, 10, MONTHLYTURNOVER, 5000)},
+			usage:  map[int]int64{2: 4001},
+			bin:    visa,
+			code:   "LIMIT_MONTHLY_TURNOVER",
+		},
+		{
+			name:   "card velocity exceeded",
+			limits: []*Limit{limit(3, ROUTE, 30, CARDHOURLY, 3)},
+			usage:  map[int]int64{3: 3},
+			bin:    visa,
+			code:   "LIMIT_CARD_VELOCITY",
+		},
+		{
+			name:   "customer cards include the card",
+			limits: []*Limit{limit(4, PROFILE, 10, CUSTOMERCARDS, 3)},
+			usage:  map[int]int64{4: 3},
+			bin:    visa,
+		},
+		{
+			name:   "customer cards exceeded",
+			limits: []*Limit{limit(4, PROFILE, 10, CUSTOMERCARDS, 3)},
+			usage:  map[int]int64{4: 4},
+			bin:    visa,
+			code:   "LIMIT_CUSTOMER_CARDS",
+		},
+		{
+			name:   "conditional limit of other cards",
+			limits: []*Limit{onlyMastercard},
+			bin:    visa,
+		},
+		{
+			name:   "conditional limit of the card",
+			limits: []*Limit{onlyMastercard},
+			bin:    mastercard,
+			code:   "LIMIT_MAX_AMOUNT",
+		},
+		{
+			name:   "conditional limit of unknown card",
+			limits: []*Limit{onlyMastercard},
+		},
+		{
+			name:   "usage not counted",
+			limits: []*Limit{limit(2, PROFILE, 10, DAILYTURNOVER, 5000)},
+			bin:    visa,
+			err:    fmt.Errorf("db is down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryLimitStore{limits: tt.limits, usage: tt.usage, err: tt.err}
+
+			kind := repository.AUTH
+			transaction := &repository.Transaction{
+				Profile:         &repository.Profile{Id: number(10)},
+				Account:         &repository.Account{Id: number(20)},
+				Type:            &kind,
+				Amount:          func() *uint { a := uint(1000); return &a }(),
+				AmountConverted: func() *uint { a := uint(75000); return &a }(),
+				InstrumentId:    number(7),
+				Customer:        str("customer"),
+			}
+			route := &repository.Route{Id: number(30)}
+
+			err, release := Check(nil, store, transaction, route, tt.bin)
+			if release == nil {
+				t.Fatalf("release is nil")
+			}
+
+			switch {
+			case tt.err != nil:
+				if err == nil {
+					t.Fatalf("store error is not returned")
+				}
+			case tt.code != "":
+				violation, ok := err.(*Violation)
+				if !ok {
+					t.Fatalf("error = %v, want violation %s", err, tt.code)
+				}
+				if violation.Code != tt.code {
+					t.Errorf("code = %s, want %s", violation.Code, tt.code)
+				}
+			case err != nil:
+				t.Fatalf("Check failed: %v", err)
+			}
+
+			release()
+
+			// @note: scopes are released once whatever the result is
+			if store.released != store.locked {
+				t.Errorf("locked %d times, released %d times", store.locked, store.released)
+			}
+		})
+	}
+}
+
+func TestViolationError(t *testing.T) {
+	violation := &Violation{
+		Code:      Codes[DAILYTURNOVER],
+		Limit:     limit(2, PROFILE, 10, DAILYTURNOVER, 5000),
+		Used:      4500,
+		Requested: 1000,
+	}
+
+	want := "daily_turnover limit <2> of profile <10> exceeded: used 4500, requested 1000, allowed 5000"
+	if got := violation.Error(); got != want {
+		t.Errorf("Error = %s, want %s", got, want)
+	}
+}
+
+// lockingStore locks scopes with one mutex, as if every limit was of the
+// same scope. Added transactions are counted in the usage of every limit.
+// Usage is slow, so unserialized checks would both see it before either
+// transaction is added
+type lockingStore struct {
+	memoryLimitStore
+	scope sync.Mutex
+	mu    sync.Mutex
+	added int64
+}
+
+func (ls *lockingStore) Usage(ctx interface{}, limit *Limit, subject Subject) (error, int64) {
+	ls.mu.Lock()
+	used := ls.added
+	ls.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	return nil, used
+}
+
+func (ls *lockingStore) Lock(ctx interface{}, list []*Limit) (error, func()) {
+	ls.scope.Lock()
+	return nil, ls.scope.Unlock
+}
+
+func (ls *lockingStore) add(amount int64) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.added += amount
+}
+
+func TestConcurrentChecks(t *testing.T) {
+	store := &lockingStore{memoryLimitStore: memoryLimitStore{
+		limits: []*Limit{limit(2, PROFILE, 10, DAILYTURNOVER, 1500)},
+	}}
+
+	var (
+		wg         sync.WaitGroup
+		start      = make(chan struct{})
+		passed     = make(chan int, 2)
+		violations = make(chan int, 2)
+	)
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			<-start
+
+			kind := repository.AUTH
+			transaction := &repository.Transaction{
+				Profile:      &repository.Profile{Id: number(10)},
+				Account:      &repository.Account{Id: number(20)},
+				Type:         &kind,
+				Amount:       func() *uint { a := uint(1000); return &a }(),
+				InstrumentId: number(id),
+			}
+
+			err, release := Check(nil, store, transaction, nil, nil)
+			if err != nil {
+				violations <- id
+				return
+			}
+
+			// @note: the transaction is added before release and counted
+			// while it is in progress
+			store.add(1000)
+			release()
+			passed <- id
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	if len(passed) != 1 || len(violations) != 1 {
+		t.Errorf("%d checks passed and %d rejected, want one of each", len(passed), len(violations))
+	}
+}
+
+func TestUsageQuery(t *testing.T) {
+	card := 7
+	customer := "customer"
+	subject := Subject{Card: &card, Customer: &customer}
+
+	onlyMir := limit(6, ROUTE, 30, CARDHOURLY, 3)
+	onlyMir.Brand = str(bins.MIR)
+
+	tests := []struct {
+		name    string
+		limit   *Limit
+		subject Subject
+		clauses []string
+		args    int
+		err     string
+	}{
+		{
+			name:  "max amount is not counted",
+			limit: limit(1, PROFILE, 10, MAXAMOUNT, 1000),
+		},
+		{
+			name:    "turnover counts transactions in progress",
+			limit:   limit(2, PROFILE, 10, DAILYTURNOVER, 5000),
+			clauses: []string{"sum(t.amount)", "t.profile_id=$1", "t.status<>$3", "date_trunc('day'"},
+			args:    3,
+		},
+		{
+			name:    "account turnover in converted amount",
+			limit:   limit(3, ACCOUNT, 20, MONTHLYTURNOVER, 5000),
+			clauses: []string{"sum(t.amount_converted)", "t.account_id=$1", "t.status<>$3", "date_trunc('month'"},
+			args:    3,
+		},
+		{
+			name:    "card velocity of the route",
+			limit:   limit(4, ROUTE, 30, CARDHOURLY, 3),
+			clauses: []string{"from routes where id=$1", "t.instrument=$3", "interval '1 hour'"},
+			args:    3,
+		},
+		{
+			name:    "conditional limit",
+			limit:   onlyMir,
+			clauses: []string{"transaction_bins", "b.brand=$4", "b.type=$5", "b.country=$6"},
+			args:    6,
+		},
+		{
+			name:    "customer unknown",
+			limit:   limit(5, PROFILE, 10, CUSTOMERCARDS, 3),
+			subject: Subject{Card: &card},
+			err:     "customer is required to count customer_daily_cards usage",
+		},
+		{
+			name:  "unknown scope",
+			limit: limit(7, "merchant", 10, DAILYTURNOVER, 3),
+			err:   "unknown limit scope: merchant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.subject
+			if s.Card == nil && s.Customer == nil {
+				s = subject
+			}
+
+			err, query, args := usageQuery(tt.limit, s)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("usageQuery failed: %v", err)
+			}
+
+			for _, clause := range tt.clauses {
+				if !strings.Contains(query, clause) {
+					t.Errorf("query has no %s:\n%s", clause, query)
+				}
+			}
+
+			if len(args) != tt.args {
+				t.Errorf("query has %d args, want %d", len(args), tt.args)
+			}
+
+			if len(args) >= 3 && *tt.limit.Kind == DAILYTURNOVER && args[2] != DECLINED {
+				t.Errorf("turnover leaves out %v, want %s", args[2], DECLINED)
+			}
+		})
+	}
+}
+
+func TestLockKeys(t *testing.T) {
+	keys := lockKeys([]*Limit{
+		limit(1, ROUTE, 30, CARDHOURLY, 3),
+		limit(2, PROFILE, 10, DAILYTURNOVER, 5000),
+		limit(3, ACCOUNT, 20, MAXAMOUNT, 1000),
+		limit(4, PROFILE, 10, MAXAMOUNT, 1000),
+	})
+
+	// @note: every check locks scopes in the same order, so they do not
+	// deadlock
+	want := "limits:account:20 limits:profile:10 limits:route:30"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("keys = %s, want %s", got, want)
+	}
+}
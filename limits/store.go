@@ -0,0 +1,337 @@
+package limits
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+type LimitSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type limitSpecificationByID struct {
+	id int
+}
+
+func (ls *limitSpecificationByID) ToSqlClauses() (string, []interface{}) {
+	return "where id=$1", []interface{}{ls.id}
+}
+
+func NewLimitSpecificationByID(id int) LimitSpecification {
+	return &limitSpecificationByID{id: id}
+}
+
+type limitSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (ls *limitSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id desc limit $1 offset $2", []interface{}{ls.limit, ls.offset}
+}
+
+func NewLimitSpecificationWithLimitAndOffset(limit int, offset int) LimitSpecification {
+	return &limitSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type limitSpecificationByScopeWithLimitAndOffset struct {
+	scope   string
+	scopeId int
+	limit   int
+	offset  int
+}
+
+func (ls *limitSpecificationByScopeWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "where scope=$1 and scope_id=$2 order by id desc limit $3 offset $4", []interface{}{
+		ls.scope,
+		ls.scopeId,
+		ls.limit,
+		ls.offset,
+	}
+}
+
+func NewLimitSpecificationByScopeWithLimitAndOffset(scope string, scopeId int, limit int, offset int) LimitSpecification {
+	return &limitSpecificationByScopeWithLimitAndOffset{scope: scope, scopeId: scopeId, limit: limit, offset: offset}
+}
+
+type limitSpecificationByScopes struct {
+	profileId *int
+	accountId *int
+	routeId   *int
+}
+
+func (ls *limitSpecificationByScopes) ToSqlClauses() (string, []interface{}) {
+	return `where (scope=$1 and scope_id=$2) or (scope=$3 and scope_id=$4) or (scope=$5 and scope_id=$6)
+		order by id`, []interface{}{
+		PROFILE,
+		ls.profileId,
+		ACCOUNT,
+		ls.accountId,
+		ROUTE,
+		ls.routeId,
+	}
+}
+
+// NewLimitSpecificationByScopes will select limits of the profile, account
+// and route. Nil ids match nothing
+func NewLimitSpecificationByScopes(profileId *int, accountId *int, routeId *int) LimitSpecification {
+	return &limitSpecificationByScopes{profileId: profileId, accountId: accountId, routeId: routeId}
+}
+
+type LimitRepository interface {
+	Add(ctx interface{}, limit *Limit) error
+	Delete(ctx interface{}, limit *Limit) (error, bool)
+	Query(ctx interface{}, specification LimitSpecification) (error, int, []*Limit)
+	// Usage will count current usage of the limit by the subject
+	Usage(ctx interface{}, limit *Limit, subject Subject) (error, int64)
+	// Lock will serialize checks of limits of the same scopes until
+	// release is called
+	Lock(ctx interface{}, list []*Limit) (err error, release func())
+}
+
+type PGPoolLimitStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (ls *PGPoolLimitStore) Add(ctx interface{}, limit *Limit) error {
+	return ls.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into limits (scope, scope_id, kind, value, brand, card_type, country)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (scope, scope_id, kind, coalesce(brand, ''), coalesce(card_type, ''), coalesce(country, ''))
+		do update set value=excluded.value
+		returning id`,
+		limit.Scope,
+		limit.ScopeId,
+		limit.Kind,
+		limit.Value,
+		limit.Brand,
+		limit.CardType,
+		limit.Country,
+	).Scan(&limit.Id)
+}
+
+func (ls *PGPoolLimitStore) Delete(ctx interface{}, limit *Limit) (error, bool) {
+	ct, err := ls.pool.Exec(tracing.ContextOf(ctx), "delete from limits where id=$1", limit.Id)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("limit with id=%d not found", *limit.Id), true
+	}
+
+	return nil, false
+}
+
+func (ls *PGPoolLimitStore) Query(ctx interface{}, specification LimitSpecification) (error, int, []*Limit) {
+	var list []*Limit
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := ls.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select id, scope, scope_id, kind, value, brand, card_type, country, count(*) over()
+		from limits %s`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		limit := &Limit{}
+		if err := rows.Scan(
+			&limit.Id,
+			&limit.Scope,
+			&limit.ScopeId,
+			&limit.Kind,
+			&limit.Value,
+			&limit.Brand,
+			&limit.CardType,
+			&limit.Country,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		list = append(list, limit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, list
+}
+
+func scopeClause(scope string) (string, error) {
+	switch scope {
+	case PROFILE:
+		return "t.profile_id=$1", nil
+	case ACCOUNT:
+		return "t.account_id=$1", nil
+	case ROUTE:
+		return "(t.profile_id, t.instrument_id)=(select profile_id, instrument_id from routes where id=$1)", nil
+	}
+
+	return "", fmt.Errorf("unknown limit scope: %s", scope)
+}
+
+// usageQuery will return the query counting usage of the limit by the
+// subject. Max amount has no usage, the query is empty then
+func usageQuery(limit *Limit, subject Subject) (error, string, []interface{}) {
+	scope, err := scopeClause(*limit.Scope)
+	if err != nil {
+		return err, "", nil
+	}
+
+	amount := "t.amount"
+	if *limit.Scope == ACCOUNT {
+		amount = "t.amount_converted"
+	}
+
+	args := []interface{}{limit.ScopeId, Limited}
+
+	var query string
+	switch *limit.Kind {
+	case MAXAMOUNT:
+		return nil, "", nil
+	case DAILYTURNOVER, MONTHLYTURNOVER:
+		period := "day"
+		if *limit.Kind == MONTHLYTURNOVER {
+			period = "month"
+		}
+		args = append(args, DECLINED)
+		query = fmt.Sprintf(`select coalesce(sum(%s), 0) from transactions t
+			where %s and t.type=any($2) and t.status<>$3 and t.created>=date_trunc('%s', localtimestamp)`,
+			amount,
+			scope,
+			period,
+		)
+	case CARDHOURLY:
+		if subject.Card == nil {
+			return fmt.Errorf("card is required to count %s usage", *limit.Kind), "", nil
+		}
+		args = append(args, *subject.Card)
+		query = fmt.Sprintf(`select count(*) from transactions t
+			where %s and t.type=any($2) and t.instrument=$3 and t.created>=localtimestamp - interval '1 hour'`,
+			scope,
+		)
+	case CUSTOMERCARDS:
+		if subject.Customer == nil {
+			return fmt.Errorf("customer is required to count %s usage", *limit.Kind), "", nil
+		}
+		// @note: card of the new transaction is counted too
+		args = append(args, *subject.Customer, subject.Card)
+		query = fmt.Sprintf(`select count(distinct card) from (
+				select t.instrument as card from transactions t
+				where %s and t.type=any($2) and t.customer=$3 and t.created>=localtimestamp - interval '1 day'
+				union
+				select $4::integer where $4::integer is not null
+			) cards`,
+			scope,
+		)
+	default:
+		return fmt.Errorf("unknown limit kind: %s", *limit.Kind), "", nil
+	}
+
+	// @note: conditional limit counts transactions of matching cards only
+	if limit.Conditional() {
+		n := len(args)
+		args = append(args, limit.Brand, limit.CardType, limit.Country)
+		query = strings.Replace(query, "where ", fmt.Sprintf(`where exists(
+				select 1 from transaction_bins b where b.transaction_id=t.id
+				and ($%d::text is null or b.brand=$%d)
+				and ($%d::text is null or b.type=$%d)
+				and ($%d::text is null or b.country=$%d)
+			) and `, n+1, n+1, n+2, n+2, n+3, n+3), 1)
+	}
+
+	return nil, query, args
+}
+
+func (ls *PGPoolLimitStore) Usage(ctx interface{}, limit *Limit, subject Subject) (error, int64) {
+	var used int64
+
+	err, query, args := usageQuery(limit, subject)
+	if err != nil {
+		return err, 0
+	}
+
+	if query == "" {
+		return nil, 0
+	}
+
+	if err := ls.pool.QueryRow(tracing.ContextOf(ctx), query, args...).Scan(&used); err != nil {
+		return err, 0
+	}
+
+	return nil, used
+}
+
+// lockKeys will return advisory lock keys of scopes of the limits in the
+// order they are locked
+func lockKeys(list []*Limit) []string {
+	keys := make(map[string]struct{})
+	for _, limit := range list {
+		keys[fmt.Sprintf("limits:%s:%d", *limit.Scope, *limit.ScopeId)] = struct{}{}
+	}
+
+	ordered := make([]string, 0, len(keys))
+	for key := range keys {
+		ordered = append(ordered, key)
+	}
+	sort.Strings(ordered)
+
+	return ordered
+}
+
+// Lock will take session advisory locks of scopes of the limits on the
+// dedicated connection. Session locks are used since transactions are added
+// by the repository out of any database transaction of ours, so the lock
+// has to outlive the usage query. Scopes are locked in the same order by
+// everyone, so checks do not deadlock
+func (ls *PGPoolLimitStore) Lock(ctx interface{}, list []*Limit) (error, func()) {
+	ordered := lockKeys(list)
+	if len(ordered) == 0 {
+		return nil, func() {}
+	}
+
+	conn, err := ls.pool.Acquire(tracing.ContextOf(ctx))
+	if err != nil {
+		return err, func() {}
+	}
+
+	release := func() {
+		// @note: connection keeping locks must not get back to the pool,
+		// locks are released even if the request has gone
+		if _, err := conn.Exec(context.Background(), "select pg_advisory_unlock_all()"); err != nil {
+			ls.loggerFunc(nil).Warningf("can not unlock limits: %v", err)
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}
+
+	for _, key := range ordered {
+		if _, err := conn.Exec(tracing.ContextOf(ctx), "select pg_advisory_lock(hashtext($1))", key); err != nil {
+			release()
+			return err, func() {}
+		}
+	}
+
+	return nil, release
+}
+
+func NewPGPoolLimitStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) LimitRepository {
+	return &PGPoolLimitStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
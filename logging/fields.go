@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"context"
+	"strconv"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-contrib/requestid"
+	"github.com/sirupsen/logrus"
+	"github.com/serg666/repository"
+)
+
+// Keys of the request logging fields
+const (
+	profileKey     = "logging.profile_id"
+	transactionKey = "logging.transaction_id"
+	channelKey     = "logging.channel"
+	requestKey     = "logging.request_id"
+)
+
+// Background will return the context of work made outside of requests, like
+// scheduled jobs, so it is logged and passed to bank channels with the
+// request id given
+func Background(rid string) *gin.Context {
+	c := &gin.Context{}
+	c.Request, _ = http.NewRequestWithContext(context.Background(), http.MethodPost, "/", nil)
+	c.Set(requestKey, rid)
+	return c
+}
+
+// RequestId will return id of the request or the one given to Background
+func RequestId(c *gin.Context) string {
+	if value, ok := c.Get(requestKey); ok {
+		return value.(string)
+	}
+
+	if c.Writer == nil {
+		return ""
+	}
+
+	return requestid.Get(c)
+}
+
+// Transaction will add profile, transaction and channel of the transaction
+// to logs of the request
+func Transaction(c *gin.Context, transaction *repository.Transaction) {
+	if c == nil || transaction == nil {
+		return
+	}
+
+	if transaction.Profile != nil && transaction.Profile.Id != nil {
+		c.Set(profileKey, *transaction.Profile.Id)
+	}
+
+	if transaction.Id != nil {
+		c.Set(transactionKey, *transaction.Id)
+	}
+
+	if account := transaction.Account; account != nil && account.Channel != nil && account.Channel.Key != nil {
+		c.Set(channelKey, *account.Channel.Key)
+	}
+}
+
+// fields will return logging fields of the request. Profile and transaction
+// are taken from the path until they are known
+func fields(ctx interface{}) logrus.Fields {
+	c, ok := ctx.(*gin.Context)
+	if !ok || c == nil {
+		return logrus.Fields{}
+	}
+
+	result := logrus.Fields{
+		"request_id": RequestId(c),
+	}
+
+	if value, ok := c.Get(profileKey); ok {
+		result["profile_id"] = value
+	} else if pid, err := strconv.Atoi(c.Param("pid")); err == nil {
+		result["profile_id"] = pid
+	}
+
+	if value, ok := c.Get(transactionKey); ok {
+		result["transaction_id"] = value
+	} else if tid, err := strconv.Atoi(c.Param("tid")); err == nil {
+		result["transaction_id"] = tid
+	}
+
+	if value, ok := c.Get(channelKey); ok {
+		result["channel"] = value
+	}
+
+	return result
+}
@@ -0,0 +1,249 @@
+package logging
+
+import (
+	"io"
+	"os"
+	"fmt"
+	"sync"
+	"strings"
+	"runtime"
+	"io/ioutil"
+	"log/syslog"
+	"github.com/sirupsen/logrus"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/masking"
+	lsyslog "github.com/sirupsen/logrus/hooks/syslog"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Formats
+const (
+	TEXT = "text"
+	JSON = "json"
+)
+
+// Outputs
+const (
+	STDERR = "stderr"
+	STDOUT = "stdout"
+	FILE   = "file"
+	SYSLOG = "syslog"
+)
+
+// Logger is the logger of the gateway. Packages with own levels get loggers
+// of their own sharing the output. Loggers are replaced at once on reload
+type Logger struct {
+	mu      sync.RWMutex
+	current *loggers
+}
+
+type loggers struct {
+	base     *logrus.Logger
+	packages map[string]*logrus.Logger
+	callers  sync.Map
+	closer   io.Closer
+}
+
+// maskingFormatter masks card data in messages and fields of every entry
+// before it is written to any output
+type maskingFormatter struct {
+	logrus.Formatter
+}
+
+func (mf *maskingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	masked := *entry
+	masked.Message = masking.Mask(entry.Message)
+	masked.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			masked.Data[key] = masking.Mask(v)
+		case error:
+			masked.Data[key] = masking.Mask(v.Error())
+		default:
+			masked.Data[key] = value
+		}
+	}
+
+	return mf.Formatter.Format(&masked)
+}
+
+func formatter(format string) (error, logrus.Formatter) {
+	switch format {
+	case "", TEXT:
+		return nil, &logrus.TextFormatter{
+			FullTimestamp:          true,
+			DisableLevelTruncation: true,
+		}
+	case JSON:
+		return nil, &logrus.JSONFormatter{}
+	}
+
+	return fmt.Errorf("unknown log format %s", format), nil
+}
+
+// New will make the logger as configured. It must be closed to flush the
+// output
+func New(cfg *config.Config) (error, *Logger) {
+	err, current := build(cfg)
+	if err != nil {
+		return err, nil
+	}
+
+	return nil, &Logger{current: current}
+}
+
+func build(cfg *config.Config) (error, *loggers) {
+	err, f := formatter(cfg.LogRus.Format)
+	if err != nil {
+		return err, nil
+	}
+
+	base := logrus.New()
+	base.SetLevel(cfg.LogRus.Level)
+	base.SetFormatter(&maskingFormatter{Formatter: f})
+
+	logger := &loggers{
+		base:     base,
+		packages: make(map[string]*logrus.Logger),
+	}
+
+	switch cfg.LogRus.Output {
+	case "", STDERR:
+		base.SetOutput(os.Stderr)
+	case STDOUT:
+		base.SetOutput(os.Stdout)
+	case FILE:
+		file := &lumberjack.Logger{
+			Filename:   cfg.LogRus.File.Path,
+			MaxSize:    cfg.LogRus.File.MaxSize,
+			MaxBackups: cfg.LogRus.File.MaxBackups,
+			MaxAge:     cfg.LogRus.File.MaxAge,
+			Compress:   cfg.LogRus.File.Compress,
+		}
+		base.SetOutput(file)
+		logger.closer = file
+	case SYSLOG:
+		tag := cfg.LogRus.Syslog.Tag
+		if tag == "" {
+			tag = "gateway"
+		}
+
+		// @note: empty network and address is the local syslog
+		hook, err := lsyslog.NewSyslogHook(
+			cfg.LogRus.Syslog.Network,
+			cfg.LogRus.Syslog.Address,
+			syslog.LOG_INFO|syslog.LOG_DAEMON,
+			tag,
+		)
+		if err != nil {
+			return fmt.Errorf("can not connect to syslog: %v", err), nil
+		}
+		base.AddHook(hook)
+		base.SetOutput(ioutil.Discard)
+		logger.closer = hook.Writer
+	default:
+		return fmt.Errorf("unknown log output %s", cfg.LogRus.Output), nil
+	}
+
+	for name, level := range cfg.LogRus.Packages {
+		logger.packages[name] = &logrus.Logger{
+			Out:          base.Out,
+			Hooks:        base.Hooks,
+			Formatter:    base.Formatter,
+			ReportCaller: base.ReportCaller,
+			Level:        level,
+			ExitFunc:     base.ExitFunc,
+		}
+	}
+
+	return nil, logger
+}
+
+// pkg will return import path of the package of the function
+func pkg(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+
+	return function
+}
+
+// caller will return the logger of the package the log is made in. Level of
+// the package is looked up by its import path or name
+func (l *loggers) caller() *logrus.Logger {
+	if len(l.packages) == 0 {
+		return l.base
+	}
+
+	pcs := make([]uintptr, 8)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+
+		// @note: method value wrappers of Func are skipped
+		path := pkg(frame.Function)
+		if path != "github.com/serg666/gateway/logging" {
+			if cached, ok := l.callers.Load(frame.PC); ok {
+				return cached.(*logrus.Logger)
+			}
+
+			logger := l.base
+			if pl, ok := l.packages[path]; ok {
+				logger = pl
+			} else if pl, ok := l.packages[path[strings.LastIndex(path, "/")+1:]]; ok {
+				logger = pl
+			}
+
+			l.callers.Store(frame.PC, logger)
+			return logger
+		}
+
+		if !more {
+			return l.base
+		}
+	}
+}
+
+// Func will return the logger with fields of the request if any. It is the
+// logger func given to stores, handlers and plugins
+func (l *Logger) Func(c interface{}) logrus.FieldLogger {
+	l.mu.RLock()
+	current := l.current
+	l.mu.RUnlock()
+
+	return current.caller().WithFields(fields(c))
+}
+
+// Reload will replace loggers by ones made as configured. Loggers are kept
+// if the new ones can not be made
+func (l *Logger) Reload(cfg *config.Config) error {
+	err, next := build(cfg)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	previous := l.current
+	l.current = next
+	l.mu.Unlock()
+
+	return previous.close()
+}
+
+func (l *loggers) close() error {
+	if l.closer == nil {
+		return nil
+	}
+
+	return l.closer.Close()
+}
+
+// Close will flush and close the output
+func (l *Logger) Close() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.current.close()
+}
@@ -0,0 +1,217 @@
+package masking
+
+import (
+	"regexp"
+	"strings"
+	"net/url"
+)
+
+// PANKeys are keys of card numbers. Keys are compared in lower case without
+// separators, so card_number and cardNumber are the same key
+var PANKeys = map[string]bool{
+	"pan":                  true,
+	"cardnumber":           true,
+	"cardno":               true,
+	"primaryaccountnumber": true,
+}
+
+// SecretKeys are keys of values which are masked at all
+var SecretKeys = map[string]bool{
+	"cvv":          true,
+	"cvv2":         true,
+	"cvc":          true,
+	"cvc2":         true,
+	"cvn":          true,
+	"securitycode": true,
+	"pin":          true,
+	"pinblock":     true,
+	"track1":       true,
+	"track2":       true,
+	"trackdata":    true,
+	"cavv":         true,
+	"password":     true,
+	"passwd":       true,
+}
+
+const (
+	secretMask = "***"
+	panMask    = "******"
+)
+
+var (
+	separators = strings.NewReplacer("_", "", "-", "", ".", "")
+
+	jsonPair    = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*"|-?\d+)`)
+	formPair    = regexp.MustCompile(`(^|[?&\s])([^=&?\s"'<>]+)=([^&\s"'<>]*)`)
+	xmlElement  = regexp.MustCompile(`<([A-Za-z_][\w:.-]*)(\s[^>]*)?>([^<]*)</([A-Za-z_][\w:.-]*)>`)
+	xmlAttr     = regexp.MustCompile(`(\s)([A-Za-z_][\w:.-]*)(\s*=\s*)("[^"]*"|'[^']*')`)
+	structField = regexp.MustCompile(`\b([A-Za-z_]\w*)(:)([^\s,&}\]")]+)`)
+	digits      = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// normalize will bring the key to the form of known keys. Keys of nested
+// form values like card[pan] and namespaced XML names are by the last name
+func normalize(key string) string {
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		key = unescaped
+	}
+
+	key = strings.TrimRight(key, "]")
+	if i := strings.LastIndexAny(key, "[:"); i >= 0 {
+		key = key[i+1:]
+	}
+
+	return separators.Replace(strings.ToLower(key))
+}
+
+// value will mask the value of the key. Nil means the key is not sensitive
+func value(key string, v string) *string {
+	key = normalize(key)
+
+	if PANKeys[key] {
+		masked := PAN(v)
+		return &masked
+	}
+
+	if SecretKeys[key] {
+		masked := secretMask
+		return &masked
+	}
+
+	return nil
+}
+
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// PAN will mask the card number keeping first six and last four digits as
+// PCI DSS allows. Values too short to be card numbers are masked at all
+func PAN(pan string) string {
+	var number strings.Builder
+	for _, r := range pan {
+		if r >= '0' && r <= '9' {
+			number.WriteRune(r)
+		}
+	}
+
+	n := number.String()
+	if len(n) < 13 {
+		return secretMask
+	}
+
+	return n[:6] + panMask + n[len(n)-4:]
+}
+
+// replace will replace matches of the regexp by the result of the func given
+// submatches of the match
+func replace(re *regexp.Regexp, s string, f func(groups []string) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, match := range matches {
+		groups := make([]string, len(match)/2)
+		for i := range groups {
+			if match[2*i] >= 0 {
+				groups[i] = s[match[2*i]:match[2*i+1]]
+			}
+		}
+
+		result.WriteString(s[last:match[0]])
+		result.WriteString(f(groups))
+		last = match[1]
+	}
+	result.WriteString(s[last:])
+
+	return result.String()
+}
+
+// Mask will mask card data and secrets in the text. JSON, form encoded and
+// XML payloads, printed structs and payloads embedded in log messages are
+// masked by known keys, card numbers are masked anywhere they are found
+func Mask(s string) string {
+	if s == "" {
+		return s
+	}
+
+	// @note: masked numbers become strings, they are not numbers anymore
+	s = replace(jsonPair, s, func(g []string) string {
+		if masked := value(g[1], strings.Trim(g[3], `"`)); masked != nil {
+			return `"` + g[1] + `"` + g[2] + `"` + *masked + `"`
+		}
+		return g[0]
+	})
+
+	s = replace(xmlElement, s, func(g []string) string {
+		if g[1] != g[4] {
+			return g[0]
+		}
+		if masked := value(g[1], g[3]); masked != nil {
+			return "<" + g[1] + g[2] + ">" + *masked + "</" + g[4] + ">"
+		}
+		return g[0]
+	})
+
+	s = replace(xmlAttr, s, func(g []string) string {
+		quote := g[4][:1]
+		if masked := value(g[2], strings.Trim(g[4], quote)); masked != nil {
+			return g[1] + g[2] + g[3] + quote + *masked + quote
+		}
+		return g[0]
+	})
+
+	// @note: quoted values are left to XML attributes
+	s = replace(formPair, s, func(g []string) string {
+		if g[3] == "" {
+			return g[0]
+		}
+		if masked := value(g[2], g[3]); masked != nil {
+			return g[1] + g[2] + "=" + *masked
+		}
+		return g[0]
+	})
+
+	s = replace(structField, s, func(g []string) string {
+		if masked := value(g[1], g[3]); masked != nil {
+			return g[1] + g[2] + *masked
+		}
+		return g[0]
+	})
+
+	// @note: card numbers under unknown keys or without any are found by
+	// the check digit
+	return digits.ReplaceAllStringFunc(s, func(number string) string {
+		if luhn(separators.Replace(strings.ReplaceAll(number, " ", ""))) {
+			return PAN(number)
+		}
+		return number
+	})
+}
+
+// Pointer will mask the text the pointer is to. Nil is returned as is
+func Pointer(s *string) *string {
+	if s == nil {
+		return nil
+	}
+
+	masked := Mask(*s)
+	return &masked
+}
@@ -0,0 +1,110 @@
+package masking
+
+import (
+	"testing"
+)
+
+func TestPAN(t *testing.T) {
+	tests := []struct {
+		name string
+		pan  string
+		want string
+	}{
+		{name: "plain", pan: "4111111111111111", want: "411111******1111"},
+		{name: "spaced", pan: "4111 1111 1111 1111", want: "411111******1111"},
+		{name: "nineteen digits", pan: "6759649826438453000", want: "675964******3000"},
+		{name: "too short", pan: "411111", want: secretMask},
+		{name: "empty", pan: "", want: secretMask},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PAN(tt.pan); got != tt.want {
+				t.Errorf("PAN(%q) = %q, want %q", tt.pan, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "empty",
+			in:   "",
+			want: "",
+		},
+		{
+			name: "json",
+			in:   `{"pan": "4111111111111111", "cvv": "123", "amount": 100}`,
+			want: `{"pan": "411111******1111", "cvv": "***", "amount": 100}`,
+		},
+		{
+			name: "json number and separated key",
+			in:   `{"card_number":4111111111111111,"securityCode":"123"}`,
+			want: `{"card_number":"411111******1111","securityCode":"***"}`,
+		},
+		{
+			name: "form",
+			in:   "userName=merchant&password=secret&pan=4111111111111111&cvc=123&amount=100",
+			want: "userName=merchant&password=***&pan=411111******1111&cvc=***&amount=100",
+		},
+		{
+			name: "nested form key",
+			in:   "card%5Bpan%5D=4111111111111111&card%5Bcvc%5D=123",
+			want: "card%5Bpan%5D=411111******1111&card%5Bcvc%5D=***",
+		},
+		{
+			name: "xml",
+			in:   `<Card><PAN>4111111111111111</PAN><CVV2>123</CVV2></Card>`,
+			want: `<Card><PAN>411111******1111</PAN><CVV2>***</CVV2></Card>`,
+		},
+		{
+			name: "xml attribute",
+			in:   `<card pan="4111111111111111" cvc='123'/>`,
+			want: `<card pan="411111******1111" cvc='***'/>`,
+		},
+		{
+			name: "printed struct",
+			in:   "{Pan:4111111111111111 Cvv:123 Amount:100}",
+			want: "{Pan:411111******1111 Cvv:*** Amount:100}",
+		},
+		{
+			name: "card number without key",
+			in:   "declined card 4111 1111 1111 1111 by issuer",
+			want: "declined card 411111******1111 by issuer",
+		},
+		{
+			name: "number failing check digit",
+			in:   "order 1234567890123456 created",
+			want: "order 1234567890123456 created",
+		},
+		{
+			name: "nothing sensitive",
+			in:   `{"orderNumber": "42", "amount": 100}`,
+			want: `{"orderNumber": "42", "amount": 100}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mask(tt.in); got != tt.want {
+				t.Errorf("Mask(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointer(t *testing.T) {
+	if Pointer(nil) != nil {
+		t.Fatalf("nil is not kept")
+	}
+
+	s := "pan=4111111111111111"
+	if got := *Pointer(&s); got != "pan=411111******1111" {
+		t.Errorf("Pointer(%q) = %q", s, got)
+	}
+}
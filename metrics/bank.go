@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"time"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/plugins/channels"
+	"github.com/serg666/repository"
+)
+
+// bankChannel measures calls of the bank channel of the account
+type bankChannel struct {
+	channels.BankChannel
+	channel string
+	account string
+}
+
+func threeDSVersion(transaction *repository.Transaction) string {
+	if transaction.ThreeDSecure20 != nil {
+		return channels.ThreeDSVer20
+	}
+
+	return channels.ThreeDSVer10
+}
+
+func (bc *bankChannel) observe(operation string, transaction *repository.Transaction, start time.Time, err error) {
+	BankDuration.WithLabelValues(bc.channel, bc.account, operation).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		BankErrors.WithLabelValues(bc.channel, bc.account, operation).Inc()
+	}
+
+	switch operation {
+	case "authorize", "preauthorize", "complete_method_url":
+		if transaction.Is3DSWaiting() {
+			ThreeDS.WithLabelValues(bc.channel, threeDSVersion(transaction), "challenge").Inc()
+		}
+	case "process_pares", "process_cres":
+		stage := "failure"
+		if err == nil && transaction.IsSuccess() {
+			stage = "success"
+		}
+		ThreeDS.WithLabelValues(bc.channel, threeDSVersion(transaction), stage).Inc()
+	}
+
+	// @note: failed calls are declined by the caller, they are counted
+	// with error status
+	if err != nil || transaction.InFinalState() {
+		status := "error"
+		if err == nil {
+			status = *transaction.Status
+		}
+
+		code := ""
+		if transaction.ResponseCode != nil {
+			code = *transaction.ResponseCode
+		}
+
+		Transactions.WithLabelValues(bc.channel, *transaction.Type, status, code).Inc()
+	}
+}
+
+func (bc *bankChannel) Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
+	start := time.Now()
+	err := bc.BankChannel.Authorize(c, transaction, request, force3DS)
+	bc.observe("authorize", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
+	start := time.Now()
+	err := bc.BankChannel.PreAuthorize(c, transaction, request, force3DS)
+	bc.observe("preauthorize", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) Confirm(c *gin.Context, transaction *repository.Transaction) error {
+	start := time.Now()
+	err := bc.BankChannel.Confirm(c, transaction)
+	bc.observe("confirm", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) Reverse(c *gin.Context, transaction *repository.Transaction) error {
+	start := time.Now()
+	err := bc.BankChannel.Reverse(c, transaction)
+	bc.observe("reverse", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) Refund(c *gin.Context, transaction *repository.Transaction) error {
+	start := time.Now()
+	err := bc.BankChannel.Refund(c, transaction)
+	bc.observe("refund", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) Rebill(c *gin.Context, transaction *repository.Transaction) error {
+	start := time.Now()
+	err := bc.BankChannel.Rebill(c, transaction)
+	bc.observe("rebill", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) ProcessCres(c *gin.Context, transaction *repository.Transaction, cres string) error {
+	start := time.Now()
+	err := bc.BankChannel.ProcessCres(c, transaction, cres)
+	bc.observe("process_cres", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) ProcessPares(c *gin.Context, transaction *repository.Transaction, pares string) error {
+	start := time.Now()
+	err := bc.BankChannel.ProcessPares(c, transaction, pares)
+	bc.observe("process_pares", transaction, start, err)
+	return err
+}
+
+func (bc *bankChannel) CompleteMethodUrl(c *gin.Context, transaction *repository.Transaction, completed bool) error {
+	start := time.Now()
+	err := bc.BankChannel.CompleteMethodUrl(c, transaction, completed)
+	bc.observe("complete_method_url", transaction, start, err)
+	return err
+}
+
+// BankChannel will return the bank channel of the account measuring its calls
+func BankChannel(api channels.BankChannel, account *repository.Account) channels.BankChannel {
+	channel := strconv.Itoa(*account.Channel.Id)
+	if account.Channel.Key != nil {
+		channel = *account.Channel.Key
+	}
+
+	return &bankChannel{
+		BankChannel: api,
+		channel:     channel,
+		account:     strconv.Itoa(*account.Id),
+	}
+}
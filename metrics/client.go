@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"time"
+	"strconv"
+	"net/http"
+)
+
+// Transport will count outbound HTTP requests made with the base transport
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	res, err := base.RoundTrip(r)
+	ClientDuration.WithLabelValues(r.URL.Host, r.Method).Observe(time.Since(start).Seconds())
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	ClientRequests.WithLabelValues(r.URL.Host, r.Method, status).Inc()
+
+	return res, err
+}
+
+func (t *Transport) CloseIdleConnections() {
+	if ci, ok := t.Base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// Instrument will return the client counting its requests. Clients already
+// instrumented are returned as is
+func Instrument(client *http.Client) *http.Client {
+	if _, ok := client.Transport.(*Transport); ok {
+		return client
+	}
+
+	return &http.Client{
+		Timeout:       client.Timeout,
+		Jar:           client.Jar,
+		CheckRedirect: client.CheckRedirect,
+		Transport:     &Transport{Base: client.Transport},
+	}
+}
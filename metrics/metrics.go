@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gateway"
+
+var (
+	// HTTPRequests and HTTPDuration are requests served by route
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// BankDuration and BankErrors are calls of bank channels
+	BankDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bank_call_duration_seconds",
+		Help:      "Bank channel call latency by channel, account and operation.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"channel", "account", "operation"})
+
+	BankErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bank_call_errors_total",
+		Help:      "Failed bank channel calls by channel, account and operation.",
+	}, []string{"channel", "account", "operation"})
+
+	// Transactions are transactions reached final state, approval and
+	// decline rates are derived from them
+	Transactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_total",
+		Help:      "Transactions in final state by channel, type, status and response code.",
+	}, []string{"channel", "type", "status", "response_code"})
+
+	// ThreeDS are 3DS challenges and their results
+	ThreeDS = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "threeds_total",
+		Help:      "3DS challenges and their results by channel and version.",
+	}, []string{"channel", "version", "stage"})
+
+	// ClientRequests and ClientDuration are outbound HTTP requests
+	ClientRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_client_requests_total",
+		Help:      "Outbound HTTP requests by host, method and status.",
+	}, []string{"host", "method", "status"})
+
+	ClientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_client_request_duration_seconds",
+		Help:      "Outbound HTTP request latency by host and method.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"host", "method"})
+)
+
+// Handler will serve metrics of the default registry
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector exports statistics of the pg pool on scrape
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+}
+
+func poolDesc(name string, help string, pool string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgpool", name),
+		help,
+		nil,
+		prometheus.Labels{"pool": pool},
+	)
+}
+
+func (pc *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pc.acquireCount
+	ch <- pc.acquireDuration
+	ch <- pc.canceledAcquireCount
+	ch <- pc.emptyAcquireCount
+	ch <- pc.acquiredConns
+	ch <- pc.idleConns
+	ch <- pc.constructingConns
+	ch <- pc.totalConns
+	ch <- pc.maxConns
+}
+
+func (pc *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := pc.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(pc.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(pc.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(pc.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(pc.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(pc.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(pc.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+}
+
+// RegisterPool will export statistics of the pg pool with the name
+func RegisterPool(name string, pool *pgxpool.Pool) error {
+	return prometheus.Register(&poolCollector{
+		pool:                 pool,
+		acquireCount:         poolDesc("acquire_total", "Connections acquired from the pool.", name),
+		acquireDuration:      poolDesc("acquire_duration_seconds_total", "Time spent acquiring connections.", name),
+		canceledAcquireCount: poolDesc("canceled_acquire_total", "Acquires canceled by context.", name),
+		emptyAcquireCount:    poolDesc("empty_acquire_total", "Acquires waited for a connection.", name),
+		acquiredConns:        poolDesc("acquired_connections", "Connections in use.", name),
+		idleConns:            poolDesc("idle_connections", "Idle connections.", name),
+		constructingConns:    poolDesc("constructing_connections", "Connections being established.", name),
+		totalConns:           poolDesc("connections", "Connections in the pool.", name),
+		maxConns:             poolDesc("max_connections", "Maximum size of the pool.", name),
+	})
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"time"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/metrics"
+)
+
+// Metrics will count requests and their latency by route. Requests which
+// do not match any route are counted together
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
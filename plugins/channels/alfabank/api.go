@@ -16,16 +16,33 @@ import (
 	"github.com/serg666/gateway/validators"
 	"github.com/serg666/gateway/client"
 	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/masking"
+	"github.com/serg666/gateway/journal"
+	"github.com/serg666/gateway/tracing"
 	"github.com/serg666/gateway/plugins"
 	"github.com/serg666/gateway/plugins/instruments/card"
 	"github.com/serg666/gateway/plugins/channels"
 	"github.com/serg666/repository"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
 	Id  = 2
 	Key = "alfabank"
-	Registered = plugins.RegisterBankChannel(Id, Key, func(
+	Capabilities = channels.Capabilities{
+		Operations: []string{
+			channels.AUTHORIZE,
+			channels.PREAUTHORIZE,
+			channels.CONFIRM,
+			channels.REVERSE,
+			channels.REFUND,
+			channels.REBILL,
+		},
+		Instruments:     []string{bankcard.Key},
+		ThreeDSVersions: []string{channels.ThreeDSVer10, channels.ThreeDSVer20},
+		Force3DS:        true,
+	}
+	Registered = plugins.RegisterBankChannel(Id, Key, Capabilities, AlfaBankSettings{}, func(
 		cfg              *config.Config,
 		account          *repository.Account,
 		instrument       *repository.Instrument,
@@ -52,8 +69,15 @@ var (
 			return fmt.Errorf("can not decode alfabank account settings: %v", err), nil
 		}
 
+		err, httpClient := client.ForAccount(cfg, account)
+		if err != nil {
+			return fmt.Errorf("can not get alfabank http client: %v", err), nil
+		}
+
 		return nil, &AlfaBankChannel{
 			cfg:              cfg,
+			account:          account,
+			httpClient:       httpClient,
 			logger:           logger,
 			instrumentStore:  instrumentStore,
 			sessionStore:     sessionStore,
@@ -81,12 +105,15 @@ type ClientInfo struct {
 }
 
 type AlfaBankSettings struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login      string           `json:"login"`
+	Password   string           `json:"password"`
+	HttpClient *client.Settings `json:"http_client"`
 }
 
 type AlfaBankChannel struct {
 	cfg              *config.Config
+	account          *repository.Account
+	httpClient       *http.Client
 	logger           repository.LoggerFunc
 	instrumentStore  interface{}
 	sessionStore     repository.SessionRepository
@@ -94,26 +121,29 @@ type AlfaBankChannel struct {
 	settings         *AlfaBankSettings
 }
 
-func (abc *AlfaBankChannel) maskParams(data string) string {
-	sampleRegexp := regexp.MustCompile(`PAN=[^&]+([^&]{4})`)
-	result := sampleRegexp.ReplaceAllString(data, "PAN=******$1")
-	sampleRegexp = regexp.MustCompile(`CVC=[^&]+`)
-	result = sampleRegexp.ReplaceAllString(result, "CVC=***")
-	sampleRegexp = regexp.MustCompile(`password=[^&]+`)
-	result = sampleRegexp.ReplaceAllString(result, "password=******")
-	return result
-}
-
 func (abc *AlfaBankChannel) makeRequest(
 	c *gin.Context,
+	transaction *repository.Transaction,
 	method string,
 	url string,
 	data string,
-) (error, *map[string]interface{}) {
+) (err error, jsonResp *map[string]interface{}) {
+	attributes := []attribute.KeyValue{attribute.Int("account.id", *abc.account.Id)}
+	if transaction != nil && transaction.Id != nil {
+		attributes = append(attributes, attribute.Int("transaction.id", *transaction.Id))
+	}
+
+	end := tracing.Start(c, fmt.Sprintf("%s %s", Key, url), attributes...)
+	defer func() {
+		end(err)
+	}()
+
+	abc.cfg.RLock()
 	uri := fmt.Sprintf("%s/%s", abc.cfg.Alfabank.Ecom.Url, url)
+	abc.cfg.RUnlock()
 	abc.logger(c).Printf("Requesting: %s", uri)
-	abc.logger(c).Printf("Params: %s", abc.maskParams(data))
-	r, err := http.NewRequest(method, uri, strings.NewReader(data))
+	abc.logger(c).Printf("Params: %s", masking.Mask(data))
+	r, err := http.NewRequestWithContext(tracing.Context(c), method, uri, strings.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("can not make new request: %v", err), nil
 	}
@@ -121,29 +151,36 @@ func (abc *AlfaBankChannel) makeRequest(
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data)))
 
-	res, err := client.Client.Do(r)
+	exchange := journal.Begin(transaction, abc.account, Key, method, uri, data)
+
+	res, err := abc.httpClient.Do(r)
 	if err != nil {
+		exchange.End(c, abc.logger, 0, "", err)
 		return fmt.Errorf("can not do request: %v", err), nil
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
+		exchange.End(c, abc.logger, res.StatusCode, "", err)
 		return fmt.Errorf("can not read body: %v", err), nil
 	}
 
-	abc.logger(c).Printf("response body: %s", string(body))
+	exchange.End(c, abc.logger, res.StatusCode, string(body), nil)
+
+	abc.logger(c).Printf("response body: %s", masking.Mask(string(body)))
 
-	var jsonResp map[string]interface{}
-	if err := json.Unmarshal(body, &jsonResp); err != nil {
+	var resp map[string]interface{}
+	if err := json.Unmarshal(body, &resp); err != nil {
 		return fmt.Errorf("can not unmarshal body: %v", err), nil
 	}
 
-	return nil, &jsonResp
+	return nil, &resp
 }
 
 func (abc *AlfaBankChannel) putBrowserInfo(
 	c *gin.Context,
+	transaction *repository.Transaction,
 	browserInfo *repository.BrowserInfo,
 	serverUrl *string,
 	transId *string,
@@ -157,7 +194,7 @@ func (abc *AlfaBankChannel) putBrowserInfo(
 	abc.logger(c).Debugf("requesting: %s", *serverUrl)
 	data := url.Values{}
 
-	r, err := http.NewRequest("POST", *serverUrl, strings.NewReader(data.Encode()))
+	r, err := http.NewRequestWithContext(tracing.Context(c), "POST", *serverUrl, strings.NewReader(data.Encode()))
 	if err != nil {
 		return fmt.Errorf("can not make new request: %v", err)
 	}
@@ -167,8 +204,11 @@ func (abc *AlfaBankChannel) putBrowserInfo(
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
 
-	res, err := client.Client.Do(r)
+	exchange := journal.Begin(transaction, abc.account, Key, "POST", *serverUrl, data.Encode())
+
+	res, err := abc.httpClient.Do(r)
 	if err != nil {
+		exchange.End(c, abc.logger, 0, "", err)
 		return fmt.Errorf("can not do request: %v", err)
 	}
 	defer res.Body.Close()
@@ -176,10 +216,13 @@ func (abc *AlfaBankChannel) putBrowserInfo(
 	abc.logger(c).Debugf("response code: %d", res.StatusCode)
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
+		exchange.End(c, abc.logger, res.StatusCode, "", err)
 		return fmt.Errorf("can not read response body: %v", err)
 	}
 
-	abc.logger(c).Debugf("response body: %s", string(body))
+	exchange.End(c, abc.logger, res.StatusCode, string(body), nil)
+
+	abc.logger(c).Debugf("response body: %s", masking.Mask(string(body)))
 	re := regexp.MustCompile(`(https?://[^\"\s>]+)`)
 	links := re.FindAll(body, -1)
 	abc.logger(c).Printf("links: %q", links)
@@ -213,11 +256,11 @@ func (abc *AlfaBankChannel) putBrowserInfo(
 		return fmt.Errorf("can not marshal client info: %v", err)
 	}
 
-	abc.logger(c).Printf("json body: %s", string(jsonbody))
+	abc.logger(c).Printf("json body: %s", masking.Mask(string(jsonbody)))
 	postData := url.Values{}
 	postData.Set("threeDSServerTransID", *transId)
 	postData.Set("clientInfo", string(jsonbody))
-	nr, err := http.NewRequest("POST", clientUrl, strings.NewReader(postData.Encode()))
+	nr, err := http.NewRequestWithContext(tracing.Context(c), "POST", clientUrl, strings.NewReader(postData.Encode()))
 	if err != nil {
 		return fmt.Errorf("can not make client info request: %v", err)
 	}
@@ -228,8 +271,11 @@ func (abc *AlfaBankChannel) putBrowserInfo(
 	nr.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	nr.Header.Add("Content-Length", strconv.Itoa(len(postData.Encode())))
 
-	nres, err := client.Client.Do(nr)
+	clientExchange := journal.Begin(transaction, abc.account, Key, "POST", clientUrl, postData.Encode())
+
+	nres, err := abc.httpClient.Do(nr)
 	if err != nil {
+		clientExchange.End(c, abc.logger, 0, "", err)
 		return fmt.Errorf("can not do client info request: %v", err)
 	}
 	defer nres.Body.Close()
@@ -237,10 +283,13 @@ func (abc *AlfaBankChannel) putBrowserInfo(
 	abc.logger(c).Debugf("client response code: %d", nres.StatusCode)
 	nbody, err := ioutil.ReadAll(nres.Body)
 	if err != nil {
+		clientExchange.End(c, abc.logger, nres.StatusCode, "", err)
 		return fmt.Errorf("can not read client info response body: %v", err)
 	}
 
-	abc.logger(c).Debugf("client response body: %s", string(nbody))
+	clientExchange.End(c, abc.logger, nres.StatusCode, string(nbody), nil)
+
+	abc.logger(c).Debugf("client response body: %s", masking.Mask(string(nbody)))
 
 	return nil
 }
@@ -485,16 +534,17 @@ func (abc *AlfaBankChannel) updateTransaction(c *gin.Context, transaction *repos
 		data.Set("userName", abc.settings.Login)
 		data.Set("password", abc.settings.Password)
 		data.Set("orderId", *transaction.RemoteId)
-		if err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/getOrderStatusExtended.do", data.Encode()); err == nil {
+		if err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/getOrderStatusExtended.do", data.Encode()); err == nil {
 			state, actionCode, actionCodeDescr, rrn, authCode, bindingId := abc.parseState(c, jsonResp)
 
 			transaction.ResponseCode = actionCode
 			transaction.AuthCode = authCode
 			transaction.RRN = rrn
 			if bindingId != nil {
-				transaction.AdditionalData = &repository.AdditionalData{
-					"bindingId": *bindingId,
+				if transaction.AdditionalData == nil {
+					transaction.AdditionalData = &repository.AdditionalData{}
 				}
+				(*transaction.AdditionalData)["bindingId"] = *bindingId
 			}
 
 			switch state {
@@ -521,6 +571,7 @@ func (abc *AlfaBankChannel) processCard(
 	card bankcard.Card,
 	termUrl string,
 	registerMethod string,
+	force3DS bool,
 ) error {
 	data := url.Values{}
 	data.Set("userName", abc.settings.Login)
@@ -531,8 +582,11 @@ func (abc *AlfaBankChannel) processCard(
 	data.Set("clientId", *transaction.Customer)
 	// @note: we do not use return url at all
 	data.Set("returnUrl", "1")
+	if force3DS {
+		data.Set("features", "FORCE_TDS")
+	}
 
-	err, jsonResp := abc.makeRequest(c, "POST", fmt.Sprintf("ab/rest/%s", registerMethod), data.Encode())
+	err, jsonResp := abc.makeRequest(c, transaction, "POST", fmt.Sprintf("ab/rest/%s", registerMethod), data.Encode())
 	if err != nil {
 		return fmt.Errorf("can not make register order request: %v", err)
 	}
@@ -552,10 +606,10 @@ func (abc *AlfaBankChannel) processCard(
 			data.Set("TEXT", card.Holder)
 			data.Set("threeDSVer2FinishUrl", termUrl)
 
-			if err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/paymentorder.do", data.Encode()); err == nil {
+			if err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/paymentorder.do", data.Encode()); err == nil {
 				if is3ds20, transId, serverUrl, methodUrl, methodData := abc.is3DS20(c, jsonResp); is3ds20 {
 					//3ds20
-					if err := abc.putBrowserInfo(c, transaction.BrowserInfo, serverUrl, transId); err != nil {
+					if err := abc.putBrowserInfo(c, transaction, transaction.BrowserInfo, serverUrl, transId); err != nil {
 						abc.logger(c).Warningf("can not put browser info: %v", err)
 					}
 					data.Set("threeDSServerTransId", *transId)
@@ -575,7 +629,7 @@ func (abc *AlfaBankChannel) processCard(
 						}
 						transaction.WaitMethodUrl()
 					} else {
-						if err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/paymentorder.do", data.Encode()); err == nil {
+						if err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/paymentorder.do", data.Encode()); err == nil {
 							if iscreq, acs, creq := abc.isCREQ(c, jsonResp); iscreq {
 								transaction.ThreeDSecure20 = &repository.ThreeDSecure20{
 									AcsUrl: acs,
@@ -617,22 +671,22 @@ func (abc *AlfaBankChannel) processCard(
 	return nil
 }
 
-func (abc *AlfaBankChannel) Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}) error {
+func (abc *AlfaBankChannel) Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
 	req, ok := request.(validators.CardAuthorizeRequest)
 	if !ok {
 		return fmt.Errorf("request has wrong type")
 	}
 
-	return abc.processCard(c, transaction, req.Card, req.ThreeDSVer2TermUrl, "register.do")
+	return abc.processCard(c, transaction, req.Card, req.ThreeDSVer2TermUrl, "register.do", force3DS)
 }
 
-func (abc *AlfaBankChannel) PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}) error {
+func (abc *AlfaBankChannel) PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
 	req, ok := request.(validators.CardPreAuthorizeRequest)
 	if !ok {
 		return fmt.Errorf("request has wrong type")
 	}
 
-	return abc.processCard(c, transaction, req.Card, req.ThreeDSVer2TermUrl, "registerPreAuth.do")
+	return abc.processCard(c, transaction, req.Card, req.ThreeDSVer2TermUrl, "registerPreAuth.do", force3DS)
 }
 
 func (abc *AlfaBankChannel) Confirm(c *gin.Context, transaction *repository.Transaction) error {
@@ -674,7 +728,7 @@ func (abc *AlfaBankChannel) Confirm(c *gin.Context, transaction *repository.Tran
 	data.Set("amount", strconv.Itoa(int(*transaction.AmountConverted)))
 	transaction.RemoteId = transaction.Reference.RemoteId
 
-	err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/deposit.do", data.Encode())
+	err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/deposit.do", data.Encode())
 	if err != nil {
 		return fmt.Errorf("can not make deposit request: %v", err)
 	}
@@ -723,7 +777,7 @@ func (abc *AlfaBankChannel) Reverse(c *gin.Context, transaction *repository.Tran
 	data.Set("orderId", *transaction.Reference.RemoteId)
 	transaction.RemoteId = transaction.Reference.RemoteId
 
-	err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/reverse.do", data.Encode())
+	err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/reverse.do", data.Encode())
 	if err != nil {
 		return fmt.Errorf("can not make reverse request: %v", err)
 	}
@@ -768,7 +822,7 @@ func (abc *AlfaBankChannel) Refund(c *gin.Context, transaction *repository.Trans
 	data.Set("amount", strconv.Itoa(int(*transaction.AmountConverted)))
 	transaction.RemoteId = transaction.Reference.RemoteId
 
-	err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/refund.do", data.Encode())
+	err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/refund.do", data.Encode())
 	if err != nil {
 		return fmt.Errorf("can not make refund request: %v", err)
 	}
@@ -796,7 +850,7 @@ func (abc *AlfaBankChannel) Rebill(c *gin.Context, transaction *repository.Trans
 	// @note: we do not use return url at all
 	data.Set("returnUrl", "1")
 
-	err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/register.do", data.Encode())
+	err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/register.do", data.Encode())
 	if err != nil {
 		return fmt.Errorf("can not make register order request: %v", err)
 	}
@@ -818,7 +872,7 @@ func (abc *AlfaBankChannel) Rebill(c *gin.Context, transaction *repository.Trans
 					data.Set("mdOrder", remoteId)
 					data.Set("ip", transaction.Reference.BrowserInfo.IP)
 					data.Set("bindingId", bid)
-					abc.makeRequest(c, "POST", "ab/rest/paymentOrderBinding.do", data.Encode())
+					abc.makeRequest(c, transaction, "POST", "ab/rest/paymentOrderBinding.do", data.Encode())
 					abc.updateTransaction(c, transaction)
 				} else {
 					return errors.New("bindingId has wrong type")
@@ -847,7 +901,7 @@ func (abc *AlfaBankChannel) ProcessPares(c *gin.Context, transaction *repository
 	data.Set("PaRes", pares)
 	data.Set("MD", *transaction.RemoteId)
 
-	abc.makeRequest(c, "POST", "ab/rest/finish3ds.do", data.Encode())
+	abc.makeRequest(c, transaction, "POST", "ab/rest/finish3ds.do", data.Encode())
 	abc.updateTransaction(c, transaction)
 
 	return nil
@@ -883,7 +937,7 @@ func (abc *AlfaBankChannel) ProcessCres(c *gin.Context, transaction *repository.
 	data.Set("password", abc.settings.Password)
 	data.Set("tDsTransId", tDsTransId)
 
-	abc.makeRequest(c, "POST", "ab/rest/finish3dsVer2.do", data.Encode())
+	abc.makeRequest(c, transaction, "POST", "ab/rest/finish3dsVer2.do", data.Encode())
 	abc.updateTransaction(c, transaction)
 
 	return nil
@@ -916,7 +970,7 @@ func (abc *AlfaBankChannel) CompleteMethodUrl(c *gin.Context, transaction *repos
 		return errors.New("session data query has wrong type")
 	}
 
-	if err, jsonResp := abc.makeRequest(c, "POST", "ab/rest/paymentorder.do", qwr); err == nil {
+	if err, jsonResp := abc.makeRequest(c, transaction, "POST", "ab/rest/paymentorder.do", qwr); err == nil {
 		if iscreq, acs, creq := abc.isCREQ(c, jsonResp); iscreq {
 			transaction.ThreeDSecure20 = &repository.ThreeDSecure20{
 				AcsUrl: acs,
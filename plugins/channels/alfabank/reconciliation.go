@@ -0,0 +1,24 @@
+package alfabank
+
+import (
+	"github.com/serg666/gateway/reconciliation"
+)
+
+// @note: alfabank registry is semicolon separated:
+// orderId;rrn;approvalCode;amount;currency;date. Refunds have got negative
+// amount
+var ReconciliationRegistered = reconciliation.RegisterParser(Id, reconciliation.CSV, &reconciliation.CSVParser{
+	Comma:      ';',
+	SkipHeader: true,
+	Columns:    reconciliation.CSVColumns{
+		RemoteId: 0,
+		RRN:      1,
+		AuthCode: 2,
+		Type:     -1,
+		Amount:   3,
+		Currency: 4,
+		Date:     5,
+	},
+	Exponent:   2,
+	DateLayout: "02.01.2006 15:04:05",
+})
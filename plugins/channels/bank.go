@@ -6,8 +6,10 @@ import (
 )
 
 type BankChannel interface {
-	Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}) error
-	PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}) error
+	// Authorize and PreAuthorize must authenticate the cardholder by 3DS
+	// if force3DS is set. It is set for channels capable of it only
+	Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error
+	PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error
 	Confirm(c *gin.Context, transaction *repository.Transaction) error
 	Reverse(c *gin.Context, transaction *repository.Transaction) error
 	Refund(c *gin.Context, transaction *repository.Transaction) error
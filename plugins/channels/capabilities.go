@@ -0,0 +1,87 @@
+package channels
+
+import (
+	"fmt"
+)
+
+const (
+	AUTHORIZE    = "authorize"
+	PREAUTHORIZE = "preauthorize"
+	CONFIRM      = "confirm"
+	REVERSE      = "reverse"
+	REFUND       = "refund"
+	REBILL       = "rebill"
+)
+
+const (
+	ThreeDSVer10 = "1.0"
+	ThreeDSVer20 = "2.0"
+)
+
+// Capabilities describes what a bank channel is able to do. Empty
+// Currencies means that the channel accepts any currency
+type Capabilities struct {
+	Operations      []string `json:"operations"`
+	Instruments     []string `json:"instruments"`
+	Currencies      []int    `json:"currencies"`
+	ThreeDSVersions []string `json:"threeds_versions"`
+	// Force3DS tells the channel is able to require 3DS authentication
+	// of the payment the bank would let through without it
+	Force3DS        bool     `json:"force_3ds"`
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Capabilities) CheckOperation(operation string) error {
+	if !contains(c.Operations, operation) {
+		return fmt.Errorf("operation <%s> not supported", operation)
+	}
+
+	return nil
+}
+
+func (c *Capabilities) CheckInstrument(instrument string) error {
+	if !contains(c.Instruments, instrument) {
+		return fmt.Errorf("instrument <%s> not supported", instrument)
+	}
+
+	return nil
+}
+
+func (c *Capabilities) CheckCurrency(numericCode int) error {
+	if len(c.Currencies) == 0 {
+		return nil
+	}
+
+	for _, code := range c.Currencies {
+		if code == numericCode {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("currency <%d> not supported", numericCode)
+}
+
+func (c *Capabilities) CheckThreeDSVersion(version string) error {
+	if !contains(c.ThreeDSVersions, version) {
+		return fmt.Errorf("3DS version <%s> not supported", version)
+	}
+
+	return nil
+}
+
+func (c *Capabilities) CheckForce3DS() error {
+	if !c.Force3DS {
+		return fmt.Errorf("forcing of 3DS not supported")
+	}
+
+	return nil
+}
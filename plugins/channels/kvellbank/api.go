@@ -13,7 +13,19 @@ import (
 var (
 	Id  = 1
 	Key = "kvellbank"
-	Registered = plugins.RegisterBankChannel(Id, Key, func(
+	Capabilities = channels.Capabilities{
+		Operations: []string{
+			channels.AUTHORIZE,
+			channels.PREAUTHORIZE,
+			channels.CONFIRM,
+			channels.REVERSE,
+			channels.REFUND,
+			channels.REBILL,
+		},
+		Instruments:     []string{bankcard.Key},
+		ThreeDSVersions: []string{channels.ThreeDSVer10, channels.ThreeDSVer20},
+	}
+	Registered = plugins.RegisterBankChannel(Id, Key, Capabilities, nil, func(
 		cfg              *config.Config,
 		account          *repository.Account,
 		instrument       *repository.Instrument,
@@ -44,12 +56,12 @@ type KvellBankChannel struct {
 	logger           repository.LoggerFunc
 }
 
-func (kbc *KvellBankChannel) Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}) error {
+func (kbc *KvellBankChannel) Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
 	kbc.logger(c).Print("authorize int")
 	return nil
 }
 
-func (kbc *KvellBankChannel) PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}) error {
+func (kbc *KvellBankChannel) PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
 	kbc.logger(c).Print("preauthorize int")
 	return nil
 }
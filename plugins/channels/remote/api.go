@@ -0,0 +1,343 @@
+package remote
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"errors"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"github.com/serg666/gateway/logging"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/gateway/plugins"
+	"github.com/serg666/gateway/plugins/channels"
+	"github.com/serg666/repository"
+)
+
+// Plugin keeps connection to the channel process and its health state
+type Plugin struct {
+	cfg      config.RemoteChannel
+	conn     *grpc.ClientConn
+	logger   repository.LoggerFunc
+	settings map[string]interface{}
+
+	// timeout limits every call to the plugin, interval is the time
+	// between health checks
+	timeout  time.Duration
+	interval time.Duration
+
+	mu     sync.RWMutex
+	health error
+
+	stop chan struct{}
+}
+
+// registered are remote channels registered, they are stopped by Close
+var registered []*Plugin
+
+func (p *Plugin) String() string {
+	return fmt.Sprintf("remote bank channel <%s> (%s)", p.cfg.Key, p.cfg.Address)
+}
+
+func (p *Plugin) Health() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.health
+}
+
+func (p *Plugin) setHealth(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if (err == nil) != (p.health == nil) {
+		if err != nil {
+			p.logger(nil).Warningf("%s became unhealthy: %v", p, err)
+		} else {
+			p.logger(nil).Printf("%s became healthy", p)
+		}
+	}
+
+	p.health = err
+}
+
+// defaultDuration will return seconds of the config as duration, or the
+// default duration if not configured
+func defaultDuration(seconds time.Duration, value time.Duration) time.Duration {
+	if seconds <= 0 {
+		return value
+	}
+
+	return seconds * time.Second
+}
+
+func (p *Plugin) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	resp, err := grpc_health_v1.NewHealthClient(p.conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{
+		Service: ServiceName,
+	})
+	if err != nil {
+		p.setHealth(fmt.Errorf("health check failed: %v", err))
+		return
+	}
+
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		p.setHealth(fmt.Errorf("health check status: %s", resp.Status))
+		return
+	}
+
+	p.setHealth(nil)
+}
+
+func (p *Plugin) watch() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.check()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Close will stop health checks of every remote channel and close
+// connections to them
+func Close() {
+	for _, p := range registered {
+		close(p.stop)
+		if err := p.conn.Close(); err != nil {
+			p.logger(nil).Warningf("can not close connection to %s: %v", p, err)
+		}
+	}
+
+	registered = nil
+}
+
+func (p *Plugin) describe() (error, *Description) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	var description Description
+	if err := p.conn.Invoke(
+		ctx,
+		fmt.Sprintf("/%s/Describe", ServiceName),
+		&DescribeRequest{Id: p.cfg.Id, Key: p.cfg.Key},
+		&description,
+		grpc.CallContentSubtype(codecName),
+	); err != nil {
+		return fmt.Errorf("can not describe %s: %v", p, err), nil
+	}
+
+	return nil, &description
+}
+
+func dial(rc config.RemoteChannel) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if rc.Ca != "" {
+		tlsCreds, err := credentials.NewClientTLSFromFile(rc.Ca, "")
+		if err != nil {
+			return nil, fmt.Errorf("can not load ca %s: %v", rc.Ca, err)
+		}
+		creds = tlsCreds
+	}
+
+	return grpc.Dial(rc.Address, grpc.WithTransportCredentials(creds))
+}
+
+// RegisterBankChannels will register every remote channel from config into
+// plugins.BankChannels. Channel process should be reachable on startup
+// since capabilities are requested from it
+func RegisterBankChannels(cfg *config.Config, logger repository.LoggerFunc) error {
+	for _, rc := range cfg.Plugins.Remote.Channels {
+		conn, err := dial(rc)
+		if err != nil {
+			return fmt.Errorf("can not dial remote channel %s: %v", rc.Key, err)
+		}
+
+		plugin := &Plugin{
+			cfg:      rc,
+			conn:     conn,
+			logger:   logger,
+			timeout:  defaultDuration(rc.Timeout, 10 * time.Second),
+			interval: defaultDuration(rc.HealthInterval, 10 * time.Second),
+			stop:     make(chan struct{}),
+		}
+
+		err, description := plugin.describe()
+		if err != nil {
+			return err
+		}
+
+		plugin.settings = description.Settings
+
+		if err := plugins.RegisterBankChannel(
+			rc.Id,
+			rc.Key,
+			description.Capabilities,
+			description.Settings,
+			plugin.bankChannel,
+		); err != nil {
+			return err
+		}
+
+		if err := plugins.SetBankChannelHealth(rc.Id, plugin.Health); err != nil {
+			return err
+		}
+
+		plugin.check()
+		registered = append(registered, plugin)
+		go plugin.watch()
+	}
+
+	return nil
+}
+
+func (p *Plugin) bankChannel(
+	cfg              *config.Config,
+	account          *repository.Account,
+	instrument       *repository.Instrument,
+	instrumentStore  interface{},
+	sessionStore     repository.SessionRepository,
+	transactionStore repository.TransactionRepository,
+	logger           repository.LoggerFunc,
+) (error, channels.BankChannel) {
+	if err := p.Health(); err != nil {
+		return fmt.Errorf("%s is unavailable: %v", p, err), nil
+	}
+
+	return nil, &RemoteBankChannel{
+		plugin:  p,
+		account: account,
+		logger:  logger,
+	}
+}
+
+type RemoteBankChannel struct {
+	plugin  *Plugin
+	account *repository.Account
+	logger  repository.LoggerFunc
+}
+
+func (rbc *RemoteBankChannel) invoke(
+	c *gin.Context,
+	method string,
+	transaction *repository.Transaction,
+	req *OperationRequest,
+) error {
+	// @note: transaction is sent without its account, the one with all
+	// the settings
+	sent := *transaction
+	sent.Account = nil
+
+	req.Account = NewAccount(rbc.account, rbc.plugin.settings)
+	req.Transaction = &sent
+
+	parent := context.Background()
+	if c != nil {
+		parent = c.Request.Context()
+		req.RequestId = logging.RequestId(c)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, rbc.plugin.timeout)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", req.RequestId)
+
+	circuit := breaker.ForAccount(*rbc.account.Id)
+	if breaker.Guarded(parent) {
+		if err := circuit.Allow(); err != nil {
+			return err
+		}
+	}
+
+	rbc.logger(c).Printf("calling %s: %s", rbc.plugin, method)
+
+	var result OperationResult
+	if err := rbc.plugin.conn.Invoke(
+		ctx,
+		fmt.Sprintf("/%s/%s", ServiceName, method),
+		req,
+		&result,
+		grpc.CallContentSubtype(codecName),
+	); err != nil {
+		circuit.Failure(err)
+		return fmt.Errorf("can not call %s: %v", method, err)
+	}
+
+	circuit.Success()
+
+	result.apply(transaction)
+
+	if result.Error != nil {
+		return errors.New(*result.Error)
+	}
+
+	return nil
+}
+
+func (rbc *RemoteBankChannel) withRequest(request interface{}, force3DS bool) (error, *OperationRequest) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("can not marshal request: %v", err), nil
+	}
+
+	return nil, &OperationRequest{Request: body, Force3DS: force3DS}
+}
+
+func (rbc *RemoteBankChannel) Authorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
+	err, req := rbc.withRequest(request, force3DS)
+	if err != nil {
+		return err
+	}
+
+	return rbc.invoke(c, "Authorize", transaction, req)
+}
+
+func (rbc *RemoteBankChannel) PreAuthorize(c *gin.Context, transaction *repository.Transaction, request interface{}, force3DS bool) error {
+	err, req := rbc.withRequest(request, force3DS)
+	if err != nil {
+		return err
+	}
+
+	return rbc.invoke(c, "PreAuthorize", transaction, req)
+}
+
+func (rbc *RemoteBankChannel) Confirm(c *gin.Context, transaction *repository.Transaction) error {
+	return rbc.invoke(c, "Confirm", transaction, &OperationRequest{})
+}
+
+func (rbc *RemoteBankChannel) Reverse(c *gin.Context, transaction *repository.Transaction) error {
+	return rbc.invoke(c, "Reverse", transaction, &OperationRequest{})
+}
+
+func (rbc *RemoteBankChannel) Refund(c *gin.Context, transaction *repository.Transaction) error {
+	return rbc.invoke(c, "Refund", transaction, &OperationRequest{})
+}
+
+func (rbc *RemoteBankChannel) Rebill(c *gin.Context, transaction *repository.Transaction) error {
+	return rbc.invoke(c, "Rebill", transaction, &OperationRequest{})
+}
+
+func (rbc *RemoteBankChannel) ProcessCres(c *gin.Context, transaction *repository.Transaction, cres string) error {
+	return rbc.invoke(c, "ProcessCres", transaction, &OperationRequest{Cres: cres})
+}
+
+func (rbc *RemoteBankChannel) ProcessPares(c *gin.Context, transaction *repository.Transaction, pares string) error {
+	return rbc.invoke(c, "ProcessPares", transaction, &OperationRequest{Pares: pares})
+}
+
+func (rbc *RemoteBankChannel) CompleteMethodUrl(c *gin.Context, transaction *repository.Transaction, completed bool) error {
+	return rbc.invoke(c, "CompleteMethodUrl", transaction, &OperationRequest{Completed: completed})
+}
@@ -0,0 +1,155 @@
+// Package remote implements out-of-process bank channels.
+//
+// The gateway talks to the channel process over gRPC. Messages are encoded
+// as JSON (content subtype "json"), so no generated code is needed on either
+// side. Service gateway.channels.BankChannel mirrors channels.BankChannel:
+//
+//	Describe(DescribeRequest) returns (Description)
+//	Authorize(OperationRequest) returns (OperationResult)
+//	PreAuthorize(OperationRequest) returns (OperationResult)
+//	Confirm(OperationRequest) returns (OperationResult)
+//	Reverse(OperationRequest) returns (OperationResult)
+//	Refund(OperationRequest) returns (OperationResult)
+//	Rebill(OperationRequest) returns (OperationResult)
+//	ProcessCres(OperationRequest) returns (OperationResult)
+//	ProcessPares(OperationRequest) returns (OperationResult)
+//	CompleteMethodUrl(OperationRequest) returns (OperationResult)
+//
+// Channel process must also serve grpc.health.v1.Health.
+package remote
+
+import (
+	"encoding/json"
+	"github.com/serg666/gateway/plugins/channels"
+	"github.com/serg666/repository"
+)
+
+const ServiceName = "gateway.channels.BankChannel"
+
+const (
+	StatusSuccess       = "success"
+	StatusDeclined      = "declined"
+	StatusWait3DS       = "wait3ds"
+	StatusWaitMethodUrl = "waitmethodurl"
+)
+
+type DescribeRequest struct {
+	Id  int    `json:"id"`
+	Key string `json:"key"`
+}
+
+type Description struct {
+	Capabilities channels.Capabilities  `json:"capabilities"`
+	Settings     map[string]interface{} `json:"settings"`
+}
+
+// Account is the account the operation is made with. Settings are the ones
+// described by the channel only, so credentials kept in other settings,
+// like the http client ones, are not sent to the channel process
+type Account struct {
+	Id       int                    `json:"id"`
+	IsTest   *bool                  `json:"is_test"`
+	Currency *repository.Currency   `json:"currency"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// NewAccount will return the account with settings of the schema only
+func NewAccount(account *repository.Account, schema map[string]interface{}) *Account {
+	settings := make(map[string]interface{})
+	if account.Settings != nil {
+		for key, value := range *account.Settings {
+			if _, ok := schema[key]; ok {
+				settings[key] = value
+			}
+		}
+	}
+
+	return &Account{
+		Id:       *account.Id,
+		IsTest:   account.IsTest,
+		Currency: account.Currency,
+		Settings: settings,
+	}
+}
+
+// OperationRequest is the operation on the transaction. Account of the
+// transaction is sent as Account only
+type OperationRequest struct {
+	RequestId   string                  `json:"request_id"`
+	Account     *Account                `json:"account"`
+	Transaction *repository.Transaction `json:"transaction"`
+	Request     json.RawMessage         `json:"request,omitempty"`
+	Cres        string                  `json:"cres,omitempty"`
+	Pares       string                  `json:"pares,omitempty"`
+	Completed   bool                    `json:"completed,omitempty"`
+	// Force3DS is set for Authorize and PreAuthorize which must be
+	// authenticated by 3DS. It is set if Force3DS capability is declared
+	Force3DS    bool                    `json:"force_3ds,omitempty"`
+}
+
+// OperationResult carries transaction fields changed by the channel.
+// Empty Status leaves transaction status as is
+type OperationResult struct {
+	Status           string                       `json:"status"`
+	Message          *string                      `json:"message"`
+	RemoteId         *string                      `json:"remote_id"`
+	ResponseCode     *string                      `json:"response_code"`
+	AuthCode         *string                      `json:"authcode"`
+	RRN              *string                      `json:"rrn"`
+	ThreeDSecure10   *repository.ThreeDSecure10   `json:"threedsecure10"`
+	ThreeDSecure20   *repository.ThreeDSecure20   `json:"threedsecure20"`
+	ThreeDSMethodUrl *repository.ThreeDSMethodUrl `json:"threedsmethodurl"`
+	AdditionalData   *repository.AdditionalData   `json:"additional_data"`
+	// Error is returned as error of the channels.BankChannel method
+	Error            *string                      `json:"error"`
+}
+
+func (or *OperationResult) apply(transaction *repository.Transaction) {
+	if or.RemoteId != nil {
+		transaction.RemoteId = or.RemoteId
+	}
+
+	if or.ResponseCode != nil {
+		transaction.ResponseCode = or.ResponseCode
+	}
+
+	if or.AuthCode != nil {
+		transaction.AuthCode = or.AuthCode
+	}
+
+	if or.RRN != nil {
+		transaction.RRN = or.RRN
+	}
+
+	if or.ThreeDSecure10 != nil {
+		transaction.ThreeDSecure10 = or.ThreeDSecure10
+	}
+
+	if or.ThreeDSecure20 != nil {
+		transaction.ThreeDSecure20 = or.ThreeDSecure20
+	}
+
+	if or.ThreeDSMethodUrl != nil {
+		transaction.ThreeDSMethodUrl = or.ThreeDSMethodUrl
+	}
+
+	if or.AdditionalData != nil {
+		if transaction.AdditionalData == nil {
+			transaction.AdditionalData = &repository.AdditionalData{}
+		}
+		for key, value := range *or.AdditionalData {
+			(*transaction.AdditionalData)[key] = value
+		}
+	}
+
+	switch or.Status {
+	case StatusSuccess:
+		transaction.Success()
+	case StatusDeclined:
+		transaction.Declined(or.Message)
+	case StatusWait3DS:
+		transaction.Wait3DS()
+	case StatusWaitMethodUrl:
+		transaction.WaitMethodUrl()
+	}
+}
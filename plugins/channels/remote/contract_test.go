@@ -0,0 +1,28 @@
+package remote
+
+import (
+	"reflect"
+	"testing"
+	"github.com/serg666/repository"
+)
+
+func TestNewAccount(t *testing.T) {
+	id := 20
+	settings := repository.AccountSettings{
+		"merchant_id": "m-1",
+		"password":    "secret",
+		"http_client": map[string]interface{}{"key_file": "/etc/gateway/client.key"},
+	}
+	account := &repository.Account{Id: &id, Settings: &settings}
+
+	got := NewAccount(account, map[string]interface{}{"merchant_id": "string", "password": "string"})
+
+	want := map[string]interface{}{"merchant_id": "m-1", "password": "secret"}
+	if got.Id != id || !reflect.DeepEqual(got.Settings, want) {
+		t.Errorf("account = %+v, want settings %v", got, want)
+	}
+
+	if got := NewAccount(&repository.Account{Id: &id}, nil); len(got.Settings) != 0 {
+		t.Errorf("account without settings is sent with %v", got.Settings)
+	}
+}
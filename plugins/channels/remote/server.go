@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"net"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Channel should be implemented by the channel process
+type Channel interface {
+	Describe(ctx context.Context, req *DescribeRequest) (*Description, error)
+	Authorize(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	PreAuthorize(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	Confirm(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	Reverse(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	Refund(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	Rebill(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	ProcessCres(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	ProcessPares(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+	CompleteMethodUrl(ctx context.Context, req *OperationRequest) (*OperationResult, error)
+}
+
+type operationFunc func(Channel, context.Context, *OperationRequest) (*OperationResult, error)
+
+func operationHandler(method string, operation operationFunc) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(
+			srv interface{},
+			ctx context.Context,
+			dec func(interface{}) error,
+			interceptor grpc.UnaryServerInterceptor,
+		) (interface{}, error) {
+			req := new(OperationRequest)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+
+			if interceptor == nil {
+				return operation(srv.(Channel), ctx, req)
+			}
+
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + ServiceName + "/" + method,
+			}
+
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return operation(srv.(Channel), ctx, req.(*OperationRequest))
+			})
+		},
+	}
+}
+
+func describeHandler(
+	srv interface{},
+	ctx context.Context,
+	dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (interface{}, error) {
+	req := new(DescribeRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(Channel).Describe(ctx, req)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/Describe",
+	}
+
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Channel).Describe(ctx, req.(*DescribeRequest))
+	})
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Channel)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Describe",
+			Handler:    describeHandler,
+		},
+		operationHandler("Authorize", Channel.Authorize),
+		operationHandler("PreAuthorize", Channel.PreAuthorize),
+		operationHandler("Confirm", Channel.Confirm),
+		operationHandler("Reverse", Channel.Reverse),
+		operationHandler("Refund", Channel.Refund),
+		operationHandler("Rebill", Channel.Rebill),
+		operationHandler("ProcessCres", Channel.ProcessCres),
+		operationHandler("ProcessPares", Channel.ProcessPares),
+		operationHandler("CompleteMethodUrl", Channel.CompleteMethodUrl),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterChannelServer will register channel and health services on server
+func RegisterChannelServer(server *grpc.Server, channel Channel) *health.Server {
+	server.RegisterService(&serviceDesc, channel)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+
+	return healthServer
+}
+
+// Serve is the helper for channel processes to run the plugin server
+func Serve(address string, channel Channel, opts ...grpc.ServerOption) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(opts...)
+	RegisterChannelServer(server, channel)
+
+	return server.Serve(listener)
+}
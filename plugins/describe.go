@@ -0,0 +1,178 @@
+package plugins
+
+import (
+	"sort"
+	"reflect"
+	"strings"
+	"github.com/serg666/gateway/plugins/channels"
+)
+
+type RouterDescriptor struct {
+	Id          int                    `json:"id"`
+	Key         string                 `json:"key"`
+	Instruments []string               `json:"instruments"`
+	Settings    map[string]interface{} `json:"settings"`
+}
+
+type PaymentInstrumentDescriptor struct {
+	Id  int    `json:"id"`
+	Key string `json:"key"`
+}
+
+type BankChannelDescriptor struct {
+	Id           int                    `json:"id"`
+	Key          string                 `json:"key"`
+	Type         int                    `json:"type"`
+	Capabilities channels.Capabilities  `json:"capabilities"`
+	Settings     map[string]interface{} `json:"settings"`
+	Remote       bool                   `json:"remote"`
+	HealthError  *string                `json:"health_error,omitempty"`
+}
+
+type Descriptors struct {
+	Routers            []RouterDescriptor            `json:"routers"`
+	PaymentInstruments []PaymentInstrumentDescriptor `json:"instruments"`
+	BankChannels       []BankChannelDescriptor       `json:"channels"`
+}
+
+func typeSchema(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return structSchema(t)
+	case reflect.Slice, reflect.Array:
+		return []interface{}{typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"*": typeSchema(t.Elem())}
+	case reflect.Bool:
+		return "boolean"
+	case
+		reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64,
+		reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	}
+
+	return "any"
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	schema := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		schema[name] = typeSchema(field.Type)
+	}
+
+	return schema
+}
+
+// SettingsSchema will return the description of settings fields
+// as json name -> type
+func SettingsSchema(settings interface{}) map[string]interface{} {
+	if settings == nil {
+		return map[string]interface{}{}
+	}
+
+	// @note: out-of-process plugins report schema by themselves
+	if schema, ok := settings.(map[string]interface{}); ok {
+		return schema
+	}
+
+	t := reflect.TypeOf(settings)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return map[string]interface{}{}
+	}
+
+	return structSchema(t)
+}
+
+// Describe will return registered plugins ordered by id
+func Describe() *Descriptors {
+	descriptors := &Descriptors{
+		Routers:            []RouterDescriptor{},
+		PaymentInstruments: []PaymentInstrumentDescriptor{},
+		BankChannels:       []BankChannelDescriptor{},
+	}
+
+	for id, router := range Routers {
+		descriptors.Routers = append(descriptors.Routers, RouterDescriptor{
+			Id:          id,
+			Key:         router.Key,
+			Instruments: router.Instruments,
+			Settings:    SettingsSchema(router.Settings),
+		})
+	}
+
+	for id, instrument := range PaymentInstruments {
+		descriptors.PaymentInstruments = append(descriptors.PaymentInstruments, PaymentInstrumentDescriptor{
+			Id:  id,
+			Key: instrument.Key,
+		})
+	}
+
+	for id, channel := range BankChannels {
+		descriptor := BankChannelDescriptor{
+			Id:           id,
+			Key:          channel.Key,
+			Type:         channel.Type,
+			Capabilities: channel.Capabilities,
+			Settings:     SettingsSchema(channel.Settings),
+			Remote:       channel.Health != nil,
+		}
+
+		if channel.Health != nil {
+			if err := channel.Health(); err != nil {
+				mess := err.Error()
+				descriptor.HealthError = &mess
+			}
+		}
+
+		descriptors.BankChannels = append(descriptors.BankChannels, descriptor)
+	}
+
+	sort.Slice(descriptors.Routers, func(i, j int) bool {
+		return descriptors.Routers[i].Id < descriptors.Routers[j].Id
+	})
+	sort.Slice(descriptors.PaymentInstruments, func(i, j int) bool {
+		return descriptors.PaymentInstruments[i].Id < descriptors.PaymentInstruments[j].Id
+	})
+	sort.Slice(descriptors.BankChannels, func(i, j int) bool {
+		return descriptors.BankChannels[i].Id < descriptors.BankChannels[j].Id
+	})
+
+	return descriptors
+}
@@ -3,6 +3,8 @@ package plugins
 import (
 	"fmt"
 	"github.com/serg666/gateway/config"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/gateway/metrics"
 	"github.com/serg666/gateway/plugins/routers"
 	"github.com/serg666/gateway/plugins/channels"
 	"github.com/serg666/gateway/plugins/instruments"
@@ -25,8 +27,10 @@ type RouterFunc func (
 ) (error, routers.Router)
 
 type Router struct {
-	Key    string
-	Plugin RouterFunc
+	Key         string
+	Instruments []string
+	Settings    interface{}
+	Plugin      RouterFunc
 }
 
 func (r Router) String() string {
@@ -56,14 +60,22 @@ func RouterApi(
 	return fmt.Errorf("Router with ID=%v not found", *route.Router.Id), nil
 }
 
-func RegisterRouter(id int, key string, routerFunc RouterFunc) error {
+func RegisterRouter(
+	id int,
+	key string,
+	instruments []string,
+	settings interface{},
+	routerFunc RouterFunc,
+) error {
 	if val, ok := Routers[id]; ok {
 		return fmt.Errorf("ID <%d> has already used for: %s", id, val)
 	}
 
 	Routers[id] = &Router{
-		Key:    key,
-		Plugin: routerFunc,
+		Key:         key,
+		Instruments: instruments,
+		Settings:    settings,
+		Plugin:      routerFunc,
 	}
 
 	return nil
@@ -94,16 +106,17 @@ func RegisterRouters(routerStore repository.RouterRepository) error {
 	return nil
 }
 
-func CheckRouters(routerStore repository.RouterRepository) error {
+// CheckRouters will make sure that every registered router is either
+// loaded or retired
+func CheckRouters(routerStore repository.RouterRepository, stateStore PluginStateRepository) error {
 	err, _, routers := routerStore.Query(nil, repository.NewRouterWithoutSpecification())
 	if err != nil {
 		return fmt.Errorf("Failed to query routers: %v", err)
 	}
 
-	regged := len(routers)
-	loaded := len(Routers)
-	if loaded != regged {
-		return fmt.Errorf("Loaded %d, registered %d routers", loaded, regged)
+	err, retired := retiredIDs(stateStore, RouterKind)
+	if err != nil {
+		return err
 	}
 
 	for _, router := range routers {
@@ -111,6 +124,8 @@ func CheckRouters(routerStore repository.RouterRepository) error {
 			if val.Key != *router.Key {
 				return fmt.Errorf("%s (id=%d) registered with key=%s", val, *router.Id, *router.Key)
 			}
+		} else if !retired[*router.Id] {
+			return fmt.Errorf("Router %s (id=%d) registered but not loaded and not retired", *router.Key, *router.Id)
 		}
 	}
 
@@ -180,16 +195,17 @@ func RegisterPaymentInstruments(instrumentStore repository.InstrumentRepository)
 	return nil
 }
 
-func CheckPaymentInstruments(instrumentStore repository.InstrumentRepository) error {
+// CheckPaymentInstruments will make sure that every registered payment
+// instrument is either loaded or retired
+func CheckPaymentInstruments(instrumentStore repository.InstrumentRepository, stateStore PluginStateRepository) error {
 	err, _, paymentInstruments := instrumentStore.Query(nil, repository.NewInstrumentWithoutSpecification())
 	if err != nil {
 		return fmt.Errorf("Failed to query payment instruments: %v", err)
 	}
 
-	regged := len(paymentInstruments)
-	loaded := len(PaymentInstruments)
-	if loaded != regged {
-		return fmt.Errorf("Loaded %d, registered %d payment instruments", loaded, regged)
+	err, retired := retiredIDs(stateStore, InstrumentKind)
+	if err != nil {
+		return err
 	}
 
 	for _, paymentInstrument := range paymentInstruments {
@@ -197,6 +213,12 @@ func CheckPaymentInstruments(instrumentStore repository.InstrumentRepository) er
 			if val.Key != *paymentInstrument.Key {
 				return fmt.Errorf("%s (id=%d) registered with key=%s", val, *paymentInstrument.Id, *paymentInstrument.Key)
 			}
+		} else if !retired[*paymentInstrument.Id] {
+			return fmt.Errorf(
+				"Payment instrument %s (id=%d) registered but not loaded and not retired",
+				*paymentInstrument.Key,
+				*paymentInstrument.Id,
+			)
 		}
 	}
 
@@ -214,9 +236,13 @@ type BankChannelFunc func (
 ) (error, channels.BankChannel)
 
 type BankChannel struct {
-	Key    string
-	Type   int
-	Plugin BankChannelFunc
+	Key          string
+	Type         int
+	Capabilities channels.Capabilities
+	Settings     interface{}
+	Plugin       BankChannelFunc
+	// Health is set for out-of-process channels only
+	Health       func() error
 }
 
 func (bc BankChannel) String() string {
@@ -240,26 +266,121 @@ func BankApi(
 			return fmt.Errorf("failed to initiate bank api: %v", err), nil
 		}
 
-		return nil, api
+		return nil, metrics.BankChannel(api, account)
 	}
 
 	return fmt.Errorf("Bank channel with ID=%v not found", cid), nil
 }
 
-func RegisterBankChannel(id int, key string, channelFunc BankChannelFunc) error {
+// CheckAvailable will return an error if the circuit of the account is
+// open. It is checked for new payments only, operations on transactions
+// already made with the account are always sent to the bank
+func CheckAvailable(account *repository.Account) error {
+	if err := breaker.ForAccount(*account.Id).Check(); err != nil {
+		return fmt.Errorf("account <%d> is unavailable: %v", *account.Id, err)
+	}
+
+	return nil
+}
+
+// CheckBankChannelCapability will return an error if the channel of the
+// account can not make operation with the instrument
+func CheckBankChannelCapability(
+	account *repository.Account,
+	instrument *repository.Instrument,
+	operation string,
+) error {
+	cid := *account.Channel.Id
+
+	val, ok := BankChannels[cid]
+	if !ok {
+		return fmt.Errorf("Bank channel with ID=%v not found", cid)
+	}
+
+	if err := val.Capabilities.CheckOperation(operation); err != nil {
+		return fmt.Errorf("%s: %v", val, err)
+	}
+
+	if err := val.Capabilities.CheckInstrument(*instrument.Key); err != nil {
+		return fmt.Errorf("%s: %v", val, err)
+	}
+
+	if account.Currency != nil {
+		if err := val.Capabilities.CheckCurrency(*account.Currency.NumericCode); err != nil {
+			return fmt.Errorf("%s: %v", val, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckBankChannelThreeDS will return an error if the channel of the
+// account does not support 3DS version
+func CheckBankChannelThreeDS(account *repository.Account, version string) error {
+	cid := *account.Channel.Id
+
+	val, ok := BankChannels[cid]
+	if !ok {
+		return fmt.Errorf("Bank channel with ID=%v not found", cid)
+	}
+
+	if err := val.Capabilities.CheckThreeDSVersion(version); err != nil {
+		return fmt.Errorf("%s: %v", val, err)
+	}
+
+	return nil
+}
+
+// CheckBankChannelForce3DS will return an error if the channel of the
+// account can not require 3DS authentication
+func CheckBankChannelForce3DS(account *repository.Account) error {
+	cid := *account.Channel.Id
+
+	val, ok := BankChannels[cid]
+	if !ok {
+		return fmt.Errorf("Bank channel with ID=%v not found", cid)
+	}
+
+	if err := val.Capabilities.CheckForce3DS(); err != nil {
+		return fmt.Errorf("%s: %v", val, err)
+	}
+
+	return nil
+}
+
+func RegisterBankChannel(
+	id int,
+	key string,
+	capabilities channels.Capabilities,
+	settings interface{},
+	channelFunc BankChannelFunc,
+) error {
 	if val, ok := BankChannels[id]; ok {
 		return fmt.Errorf("ID <%d> has already used for: %s", id, val)
 	}
 
 	BankChannels[id] = &BankChannel{
-		Key:    key,
-		Type:   channels.BankChannelType,
-		Plugin: channelFunc,
+		Key:          key,
+		Type:         channels.BankChannelType,
+		Capabilities: capabilities,
+		Settings:     settings,
+		Plugin:       channelFunc,
 	}
 
 	return nil
 }
 
+func SetBankChannelHealth(id int, health func() error) error {
+	val, ok := BankChannels[id]
+	if !ok {
+		return fmt.Errorf("Bank channel with ID=%v not found", id)
+	}
+
+	val.Health = health
+
+	return nil
+}
+
 func RegisterBankChannels(channelStore repository.ChannelRepository) error {
 	for Id, BankChannel := range BankChannels {
 		err, _, bankChannels := channelStore.Query(nil, repository.NewChannelSpecificationByID(Id))
@@ -286,16 +407,17 @@ func RegisterBankChannels(channelStore repository.ChannelRepository) error {
 	return nil
 }
 
-func CheckBankChannels(channelStore repository.ChannelRepository) error {
+// CheckBankChannels will make sure that every registered bank channel
+// is either loaded or retired
+func CheckBankChannels(channelStore repository.ChannelRepository, stateStore PluginStateRepository) error {
 	err, _, bankChannels := channelStore.Query(nil, repository.NewChannelSpecificationByTypeID(channels.BankChannelType))
 	if err != nil {
 		return fmt.Errorf("Failed to query bank channels: %v", err)
 	}
 
-	regged := len(bankChannels)
-	loaded := len(BankChannels)
-	if loaded != regged {
-		return fmt.Errorf("Loaded %d, registered %d bank channels", loaded, regged)
+	err, retired := retiredIDs(stateStore, BankChannelKind)
+	if err != nil {
+		return err
 	}
 
 	for _, bankChannel := range bankChannels {
@@ -303,6 +425,8 @@ func CheckBankChannels(channelStore repository.ChannelRepository) error {
 			if val.Key != *bankChannel.Key {
 				return fmt.Errorf("%s (id=%d) registered with key=%s", val, *bankChannel.Id, *bankChannel.Key)
 			}
+		} else if !retired[*bankChannel.Id] {
+			return fmt.Errorf("Bank channel %s (id=%d) registered but not loaded and not retired", *bankChannel.Key, *bankChannel.Id)
 		}
 	}
 
@@ -5,6 +5,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/gateway/breaker"
+	"github.com/serg666/gateway/masking"
 	"github.com/serg666/gateway/plugins"
 	"github.com/serg666/gateway/plugins/routers"
 	"github.com/serg666/gateway/plugins/instruments/card"
@@ -14,7 +17,7 @@ import (
 var (
 	Id  = 1
 	Key = "visamaster"
-	Registered = plugins.RegisterRouter(Id, Key, func(
+	Registered = plugins.RegisterRouter(Id, Key, []string{bankcard.Key}, VisaMasterSettings{}, func(
 		route               *repository.Route,
 		accountStore        repository.AccountRepository,
 		instrumentStore     interface{},
@@ -101,17 +104,30 @@ func (vmr *VisaMasterRouter) Route(c *gin.Context, route *repository.Route, requ
 		return fmt.Errorf("visa account %d not found", vmr.settings.MasterAcc)
 	}
 
-	vmr.logger(c).Printf("visamaster routing card: %v", card)
+	if card.PAN != nil {
+		vmr.logger(c).Printf("visamaster routing card: %s", masking.PAN(string(*card.PAN)))
+	}
 
 	macc := maccs[0]
 	vacc := vaccs[0]
 
-	switch ctype := card.Type(); ctype {
+	// @note: brand of the BIN database is preferred, it is kept by the
+	// transaction handler
+	ctype := card.Type()
+	if bin := bins.Get(c); bin != nil && bin.Brand != nil && *bin.Brand != "" {
+		ctype = *bin.Brand
+	}
+
+	switch ctype {
 	case "visa":
 		route.Account = vacc
 	case "mastercard":
 		route.Account = macc
 	}
 
+	if route.Account != nil && !breaker.Available(*route.Account.Id) {
+		return fmt.Errorf("circuit of account <%d> is open", *route.Account.Id)
+	}
+
 	return nil
 }
@@ -0,0 +1,171 @@
+package plugins
+
+import (
+	"fmt"
+	"time"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+const (
+	RouterKind      = "router"
+	InstrumentKind  = "instrument"
+	BankChannelKind = "channel"
+)
+
+const (
+	ACTIVE  = "active"
+	RETIRED = "retired"
+)
+
+// PluginState keeps state of plugin row. Plugin without state is active
+type PluginState struct {
+	Kind     *string    `json:"kind"`
+	PluginId *int       `json:"plugin_id"`
+	State    *string    `json:"state"`
+	Reason   *string    `json:"reason"`
+	Updated  *time.Time `json:"updated"`
+}
+
+func (ps *PluginState) IsRetired() bool {
+	return ps.State != nil && *ps.State == RETIRED
+}
+
+type PluginStateSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type pluginStateSpecificationByKind struct {
+	kind string
+}
+
+func (pss *pluginStateSpecificationByKind) ToSqlClauses() (string, []interface{}) {
+	return "where kind=$1", []interface{}{pss.kind}
+}
+
+func NewPluginStateSpecificationByKind(kind string) PluginStateSpecification {
+	return &pluginStateSpecificationByKind{kind: kind}
+}
+
+type pluginStateSpecificationByKindAndID struct {
+	kind string
+	id   int
+}
+
+func (pss *pluginStateSpecificationByKindAndID) ToSqlClauses() (string, []interface{}) {
+	return "where kind=$1 and plugin_id=$2", []interface{}{pss.kind, pss.id}
+}
+
+func NewPluginStateSpecificationByKindAndID(kind string, id int) PluginStateSpecification {
+	return &pluginStateSpecificationByKindAndID{kind: kind, id: id}
+}
+
+type PluginStateRepository interface {
+	// Add will insert or replace the plugin state
+	Add(ctx interface{}, state *PluginState) error
+	Delete(ctx interface{}, state *PluginState) (error, bool)
+	Query(ctx interface{}, specification PluginStateSpecification) (error, int, []*PluginState)
+}
+
+type PGPoolPluginStateStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (pss *PGPoolPluginStateStore) Add(ctx interface{}, state *PluginState) error {
+	return pss.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into plugin_states (kind, plugin_id, state, reason, updated) values ($1, $2, $3, $4, now())
+		on conflict (kind, plugin_id) do update set state=excluded.state, reason=excluded.reason, updated=excluded.updated
+		returning updated`,
+		state.Kind,
+		state.PluginId,
+		state.State,
+		state.Reason,
+	).Scan(&state.Updated)
+}
+
+func (pss *PGPoolPluginStateStore) Delete(ctx interface{}, state *PluginState) (error, bool) {
+	ct, err := pss.pool.Exec(
+		tracing.ContextOf(ctx),
+		"delete from plugin_states where kind=$1 and plugin_id=$2",
+		state.Kind,
+		state.PluginId,
+	)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("state of %s <%d> not found", *state.Kind, *state.PluginId), true
+	}
+
+	return nil, false
+}
+
+func (pss *PGPoolPluginStateStore) Query(ctx interface{}, specification PluginStateSpecification) (error, int, []*PluginState) {
+	var states []*PluginState
+
+	where, args := specification.ToSqlClauses()
+	rows, err := pss.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf("select kind, plugin_id, state, reason, updated from plugin_states %s order by kind, plugin_id", where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		state := &PluginState{}
+		if err := rows.Scan(&state.Kind, &state.PluginId, &state.State, &state.Reason, &state.Updated); err != nil {
+			return err, 0, nil
+		}
+		states = append(states, state)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, len(states), states
+}
+
+func NewPGPoolPluginStateStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) PluginStateRepository {
+	return &PGPoolPluginStateStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
+
+func retiredIDs(stateStore PluginStateRepository, kind string) (error, map[int]bool) {
+	err, _, states := stateStore.Query(nil, NewPluginStateSpecificationByKind(kind))
+	if err != nil {
+		return fmt.Errorf("Failed to query plugin states: %v", err), nil
+	}
+
+	retired := make(map[int]bool)
+	for _, state := range states {
+		if state.IsRetired() {
+			retired[*state.PluginId] = true
+		}
+	}
+
+	return nil, retired
+}
+
+// CheckNotRetired will return an error if plugin row has been retired
+func CheckNotRetired(ctx interface{}, stateStore PluginStateRepository, kind string, id int) error {
+	err, _, states := stateStore.Query(ctx, NewPluginStateSpecificationByKindAndID(kind, id))
+	if err != nil {
+		return fmt.Errorf("Failed to query plugin states: %v", err)
+	}
+
+	if len(states) > 0 && states[0].IsRetired() {
+		return fmt.Errorf("%s <%d> has been retired", kind, id)
+	}
+
+	return nil
+}
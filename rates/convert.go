@@ -0,0 +1,170 @@
+package rates
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"github.com/serg666/repository"
+)
+
+// Markup will return conversion markup of the account in percent. It is read
+// from "conversion_markup" key of account settings
+func Markup(account *repository.Account) (error, float64) {
+	if account.Settings == nil {
+		return nil, 0
+	}
+
+	raw, ok := (*account.Settings)["conversion_markup"]
+	if !ok || raw == nil {
+		return nil, 0
+	}
+
+	markup, ok := raw.(float64)
+	if !ok {
+		return fmt.Errorf("conversion markup of account <%d> has wrong type", *account.Id), 0
+	}
+
+	return nil, markup
+}
+
+func exponent(currency *repository.Currency) int {
+	if currency.Exponent == nil {
+		return 0
+	}
+
+	return *currency.Exponent
+}
+
+// PIVOT is the currency rates are crossed via if there is no direct rate.
+// ECB feed quotes every currency against it only
+const PIVOT = "EUR"
+
+// lookup will return the rate between currencies valid at the time. Missing
+// rate is crossed via PIVOT, both rates must be valid at the time
+func lookup(
+	ctx interface{},
+	store RateRepository,
+	from *repository.Currency,
+	to *repository.Currency,
+	at time.Time,
+) (error, *float64) {
+	err, _, rates := store.Query(ctx, NewRateSpecificationByCurrenciesAt(*from.Id, *to.Id, at))
+	if err != nil {
+		return fmt.Errorf("failed to query rate store: %v", err), nil
+	}
+
+	if len(rates) > 0 {
+		return nil, rates[0].Rate
+	}
+
+	if *from.CharCode == PIVOT || *to.CharCode == PIVOT {
+		return nil, nil
+	}
+
+	err, _, legs := store.Query(ctx, NewRateSpecificationByCurrencyAndCodeAt(*from.Id, PIVOT, at))
+	if err != nil {
+		return fmt.Errorf("failed to query rate store: %v", err), nil
+	}
+
+	if len(legs) == 0 {
+		return nil, nil
+	}
+
+	err, _, rates = store.Query(ctx, NewRateSpecificationByCurrenciesAt(*legs[0].To.Id, *to.Id, at))
+	if err != nil {
+		return fmt.Errorf("failed to query rate store: %v", err), nil
+	}
+
+	if len(rates) == 0 {
+		return nil, nil
+	}
+
+	rate := *legs[0].Rate * *rates[0].Rate
+	return nil, &rate
+}
+
+// Convert will convert amount in minor units of From currency to minor units
+// of To currency using the rate valid at the time increased by markup
+func Convert(
+	ctx interface{},
+	store RateRepository,
+	amount uint,
+	from *repository.Currency,
+	to *repository.Currency,
+	markup float64,
+	at time.Time,
+) (error, uint) {
+	if *from.Id == *to.Id {
+		return nil, amount
+	}
+
+	err, rate := lookup(ctx, store, from, to, at)
+	if err != nil {
+		return err, 0
+	}
+
+	if rate == nil {
+		return fmt.Errorf("rate %s/%s not found at %s", *from.CharCode, *to.CharCode, at.Format(time.RFC3339)), 0
+	}
+
+	scale := math.Pow10(exponent(to) - exponent(from))
+	converted := float64(amount) * *rate * scale * (1 + markup/100)
+
+	return nil, uint(math.Round(converted))
+}
+
+// Apply will fill converted amount and currency of the transaction. Profile
+// currency is converted to account currency. Operations on the reference
+// transaction are converted with the rate of the reference
+func Apply(ctx interface{}, store RateRepository, transaction *repository.Transaction, reference *repository.Transaction) error {
+	if transaction.Profile == nil || transaction.Profile.Currency == nil {
+		return fmt.Errorf("currency of the profile is unknown")
+	}
+
+	if transaction.Account == nil || transaction.Account.Currency == nil {
+		return fmt.Errorf("currency of the account is unknown")
+	}
+
+	from := transaction.Profile.Currency
+	to := transaction.Account.Currency
+
+	transaction.Currency = from
+	transaction.CurrencyConverted = to
+
+	if *from.Id == *to.Id {
+		amount := *transaction.Amount
+		transaction.AmountConverted = &amount
+		return nil
+	}
+
+	// @note: reference has already been converted, so its rate is used
+	// even if conversion has been disabled since then
+	if reference != nil && reference.AmountConverted != nil && *reference.Amount != 0 {
+		converted := math.Round(float64(*transaction.Amount) * float64(*reference.AmountConverted) / float64(*reference.Amount))
+		amount := uint(converted)
+		transaction.AmountConverted = &amount
+		return nil
+	}
+
+	if transaction.Account.CurrencyConversionEnabled == nil || !*transaction.Account.CurrencyConversionEnabled {
+		return fmt.Errorf(
+			"currency mismatch: profile currency %s, account <%d> currency %s, conversion disabled",
+			*from.CharCode,
+			*transaction.Account.Id,
+			*to.CharCode,
+		)
+	}
+
+	err, markup := Markup(transaction.Account)
+	if err != nil {
+		return err
+	}
+
+	err, amount := Convert(ctx, store, *transaction.Amount, from, to, markup, time.Now())
+	if err != nil {
+		return err
+	}
+
+	transaction.AmountConverted = &amount
+	return nil
+}
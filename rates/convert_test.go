@@ -0,0 +1,234 @@
+package rates
+
+import (
+	"fmt"
+	"time"
+	"testing"
+	"github.com/serg666/repository"
+)
+
+func currency(id int, numericCode int, charCode string, exponent int) *repository.Currency {
+	return &repository.Currency{
+		Id:          &id,
+		NumericCode: &numericCode,
+		CharCode:    &charCode,
+		Exponent:    &exponent,
+	}
+}
+
+var (
+	rub = currency(1, 643, "RUB", 2)
+	usd = currency(2, 840, "USD", 2)
+	eur = currency(3, 978, "EUR", 2)
+	jpy = currency(4, 392, "JPY", 0)
+)
+
+// memoryRateStore keeps rates by currency ids, only lookup of the rate
+// between currencies and to the pivot currency is supported
+type memoryRateStore struct {
+	rates map[[2]int]float64
+	err   error
+}
+
+func (ms *memoryRateStore) Add(ctx interface{}, rate *Rate) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryRateStore) Delete(ctx interface{}, rate *Rate) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryRateStore) Query(ctx interface{}, specification RateSpecification) (error, int, []*Rate) {
+	if ms.err != nil {
+		return ms.err, 0, nil
+	}
+
+	to := eur
+	_, args := specification.ToSqlClauses()
+	if _, ok := specification.(*rateSpecificationByCurrencyAndCodeAt); !ok {
+		to = &repository.Currency{Id: number(args[1].(int))}
+	}
+
+	value, ok := ms.rates[[2]int{args[0].(int), *to.Id}]
+	if !ok {
+		return nil, 0, nil
+	}
+
+	return nil, 1, []*Rate{{To: to, Rate: &value}}
+}
+
+func number(n int) *int {
+	return &n
+}
+
+func TestConvert(t *testing.T) {
+	store := &memoryRateStore{rates: map[[2]int]float64{
+		{*usd.Id, *rub.Id}: 75.5,
+		{*usd.Id, *jpy.Id}: 130,
+		{*jpy.Id, *usd.Id}: 0.0077,
+		{*rub.Id, *eur.Id}: 0.0125,
+		{*eur.Id, *jpy.Id}: 140,
+		{*jpy.Id, *eur.Id}: 0.007,
+	}}
+
+	tests := []struct {
+		name   string
+		amount uint
+		from   *repository.Currency
+		to     *repository.Currency
+		markup float64
+		want   uint
+		err    bool
+	}{
+		{name: "same currency", amount: 1000, from: rub, to: rub, want: 1000},
+		{name: "by rate", amount: 1000, from: usd, to: rub, want: 75500},
+		{name: "with markup", amount: 1000, from: usd, to: rub, markup: 2, want: 77010},
+		{name: "to currency without minor units", amount: 1050, from: usd, to: jpy, want: 1365},
+		{name: "from currency without minor units", amount: 1000, from: jpy, to: usd, want: 770},
+		{name: "rounded", amount: 1, from: usd, to: rub, want: 76},
+		{name: "crossed via euro", amount: 10000, from: rub, to: jpy, want: 175},
+		{name: "pivot rate not found", amount: 1000, from: rub, to: usd, err: true},
+		{name: "pivot not crossed", amount: 1000, from: eur, to: usd, err: true},
+		{name: "second rate not found", amount: 1000, from: jpy, to: rub, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, got := Convert(nil, store, tt.amount, tt.from, tt.to, tt.markup, time.Now())
+			if tt.err {
+				if err == nil {
+					t.Fatalf("Convert = %d, want error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Convert = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if err, _ := Convert(nil, &memoryRateStore{err: fmt.Errorf("db is down")}, 1000, usd, rub, 0, time.Now()); err == nil {
+		t.Errorf("store error is not returned")
+	}
+}
+
+func TestApply(t *testing.T) {
+	store := &memoryRateStore{rates: map[[2]int]float64{
+		{*usd.Id, *rub.Id}: 75,
+	}}
+
+	enabled := true
+	disabled := false
+	amount := func(v uint) *uint {
+		return &v
+	}
+	account := func(c *repository.Currency, conversion *bool) *repository.Account {
+		id := 1
+		return &repository.Account{Id: &id, Currency: c, CurrencyConversionEnabled: conversion}
+	}
+
+	tests := []struct {
+		name        string
+		transaction *repository.Transaction
+		reference   *repository.Transaction
+		want        uint
+		err         string
+	}{
+		{
+			name: "same currency",
+			transaction: &repository.Transaction{
+				Profile: &repository.Profile{Currency: rub},
+				Account: account(rub, &disabled),
+				Amount:  amount(1000),
+			},
+			want: 1000,
+		},
+		{
+			name: "converted by rate",
+			transaction: &repository.Transaction{
+				Profile: &repository.Profile{Currency: usd},
+				Account: account(rub, &enabled),
+				Amount:  amount(1000),
+			},
+			want: 75000,
+		},
+		{
+			name: "rate of the reference",
+			transaction: &repository.Transaction{
+				Profile: &repository.Profile{Currency: usd},
+				Account: account(rub, &disabled),
+				Amount:  amount(500),
+			},
+			reference: &repository.Transaction{
+				Amount:          amount(1000),
+				AmountConverted: amount(70000),
+			},
+			want: 35000,
+		},
+		{
+			name: "conversion disabled",
+			transaction: &repository.Transaction{
+				Profile: &repository.Profile{Currency: usd},
+				Account: account(rub, &disabled),
+				Amount:  amount(1000),
+			},
+			err: "currency mismatch: profile currency USD, account <1> currency RUB, conversion disabled",
+		},
+		{
+			name: "conversion unknown",
+			transaction: &repository.Transaction{
+				Profile: &repository.Profile{Currency: usd},
+				Account: account(rub, nil),
+				Amount:  amount(1000),
+			},
+			err: "currency mismatch: profile currency USD, account <1> currency RUB, conversion disabled",
+		},
+		{
+			name: "profile currency unknown",
+			transaction: &repository.Transaction{
+				Profile: &repository.Profile{},
+				Account: account(rub, &enabled),
+				Amount:  amount(1000),
+			},
+			err: "currency of the profile is unknown",
+		},
+		{
+			name: "account currency unknown",
+			transaction: &repository.Transaction{
+				Profile: &repository.Profile{Currency: usd},
+				Account: account(nil, &enabled),
+				Amount:  amount(1000),
+			},
+			err: "currency of the account is unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Apply(nil, store, tt.transaction, tt.reference)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+
+			if got := *tt.transaction.AmountConverted; got != tt.want {
+				t.Errorf("converted amount = %d, want %d", got, tt.want)
+			}
+
+			if tt.transaction.CurrencyConverted != tt.transaction.Account.Currency {
+				t.Errorf("converted currency is not the account currency")
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package rates
+
+import (
+	"io"
+	"os"
+	"fmt"
+	"time"
+	"strconv"
+	"strings"
+	"net/http"
+	"encoding/csv"
+	"encoding/xml"
+	"github.com/serg666/gateway/client"
+	"github.com/serg666/gateway/config"
+	"github.com/serg666/repository"
+)
+
+const (
+	ECB = "ecb"
+	CSV = "csv"
+)
+
+// CurrencyIndex finds currencies by numeric or char code
+type CurrencyIndex struct {
+	byNumericCode map[int]*repository.Currency
+	byCharCode    map[string]*repository.Currency
+}
+
+// LoadCurrencies will load all known currencies into the index
+func LoadCurrencies(ctx interface{}, currencyStore repository.CurrencyRepository) (error, *CurrencyIndex) {
+	// @note: there are less than 200 currencies in ISO 4217
+	err, _, currencies := currencyStore.Query(ctx, repository.NewCurrencySpecificationWithLimitAndOffset(1000, 0))
+	if err != nil {
+		return fmt.Errorf("failed to query currency store: %v", err), nil
+	}
+
+	index := &CurrencyIndex{
+		byNumericCode: make(map[int]*repository.Currency),
+		byCharCode:    make(map[string]*repository.Currency),
+	}
+
+	for _, currency := range currencies {
+		index.byNumericCode[*currency.NumericCode] = currency
+		index.byCharCode[strings.ToUpper(*currency.CharCode)] = currency
+	}
+
+	return nil, index
+}
+
+// Lookup will return currency by numeric or char code
+func (ci *CurrencyIndex) Lookup(code string) (error, *repository.Currency) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	if numericCode, err := strconv.Atoi(code); err == nil {
+		if currency, ok := ci.byNumericCode[numericCode]; ok {
+			return nil, currency
+		}
+	} else if currency, ok := ci.byCharCode[code]; ok {
+		return nil, currency
+	}
+
+	return fmt.Errorf("currency %s not found", code), nil
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ReadECB will read ECB euro foreign exchange reference rates. Both daily and
+// historical feeds are supported. Rates of both directions are returned,
+// other pairs are crossed via PIVOT by Convert. Currencies unknown to the
+// index are skipped
+func ReadECB(r io.Reader, source string, currencies *CurrencyIndex) (error, []*Rate) {
+	var rates []*Rate
+	var envelope ecbEnvelope
+
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return fmt.Errorf("can not decode ecb xml: %v", err), nil
+	}
+
+	err, euro := currencies.Lookup("EUR")
+	if err != nil {
+		return err, nil
+	}
+
+	for _, day := range envelope.Days {
+		validFrom, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return fmt.Errorf("invalid ecb day %s: %v", day.Time, err), nil
+		}
+
+		for _, ecbRate := range day.Rates {
+			err, currency := currencies.Lookup(ecbRate.Currency)
+			if err != nil {
+				continue
+			}
+
+			value, err := strconv.ParseFloat(ecbRate.Rate, 64)
+			if err != nil || value <= 0 {
+				return fmt.Errorf("invalid ecb rate %s of %s", ecbRate.Rate, ecbRate.Currency), nil
+			}
+			inverse := 1 / value
+
+			rates = append(rates, &Rate{
+				From:      euro,
+				To:        currency,
+				Rate:      &value,
+				ValidFrom: &validFrom,
+				Source:    &source,
+			}, &Rate{
+				From:      currency,
+				To:        euro,
+				Rate:      &inverse,
+				ValidFrom: &validFrom,
+				Source:    &source,
+			})
+		}
+	}
+
+	return nil, rates
+}
+
+// ReadCSV will read rates from CSV with header
+// from,to,rate,valid_from[,valid_to] where currencies are numeric or char
+// codes and dates are in RFC3339 or 2006-01-02 format
+func ReadCSV(r io.Reader, source string, currencies *CurrencyIndex) (error, []*Rate) {
+	var rates []*Rate
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	line := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("can not read csv: %v", err), nil
+		}
+
+		line++
+		if line == 1 {
+			continue
+		}
+
+		if len(row) < 4 {
+			return fmt.Errorf("line %d: expected at least 4 columns", line), nil
+		}
+
+		rate := &Rate{Source: &source}
+
+		if err, rate.From = currencies.Lookup(row[0]); err != nil {
+			return fmt.Errorf("line %d: %v", line, err), nil
+		}
+
+		if err, rate.To = currencies.Lookup(row[1]); err != nil {
+			return fmt.Errorf("line %d: %v", line, err), nil
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("line %d: invalid rate: %s", line, row[2]), nil
+		}
+		rate.Rate = &value
+
+		validFrom, err := ParseTime(row[3])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid valid_from: %v", line, err), nil
+		}
+		rate.ValidFrom = &validFrom
+
+		if len(row) > 4 && strings.TrimSpace(row[4]) != "" {
+			validTo, err := ParseTime(row[4])
+			if err != nil {
+				return fmt.Errorf("line %d: invalid valid_to: %v", line, err), nil
+			}
+			rate.ValidTo = &validTo
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return nil, rates
+}
+
+// ParseTime will parse RFC3339 time or date
+func ParseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", value)
+}
+
+// Read will read rates of the feed format
+func Read(format string, r io.Reader, source string, currencies *CurrencyIndex) (error, []*Rate) {
+	switch format {
+	case ECB:
+		return ReadECB(r, source, currencies)
+	case CSV:
+		return ReadCSV(r, source, currencies)
+	}
+
+	return fmt.Errorf("unknown rate feed format: %s", format), nil
+}
+
+// Open will open feed location which is either http(s) URL or local file
+func Open(location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.Open(location)
+	}
+
+	res, err := client.Client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s responded %d", location, res.StatusCode)
+	}
+
+	return res.Body, nil
+}
+
+// Import will load rates from the feed into the store
+func Import(
+	ctx interface{},
+	store RateRepository,
+	currencyStore repository.CurrencyRepository,
+	format string,
+	location string,
+) (error, int) {
+	err, currencies := LoadCurrencies(ctx, currencyStore)
+	if err != nil {
+		return err, 0
+	}
+
+	feed, err := Open(location)
+	if err != nil {
+		return fmt.Errorf("can not open rate feed: %v", err), 0
+	}
+	defer feed.Close()
+
+	err, rates := Read(format, feed, location, currencies)
+	if err != nil {
+		return err, 0
+	}
+
+	for _, rate := range rates {
+		if err := store.Add(ctx, rate); err != nil {
+			return fmt.Errorf("can not add rate: %v", err), 0
+		}
+	}
+
+	return nil, len(rates)
+}
+
+// Schedule will periodically import rates from configured feeds
+func Schedule(
+	feeds []config.RateFeed,
+	store RateRepository,
+	currencyStore repository.CurrencyRepository,
+	loggerFunc repository.LoggerFunc,
+) {
+	for _, feed := range feeds {
+		if feed.Interval <= 0 {
+			loggerFunc(nil).Warningf("rate feed %s has got no interval, it is not scheduled", feed.Location)
+			continue
+		}
+
+		go func(feed config.RateFeed) {
+			ticker := time.NewTicker(feed.Interval * time.Second)
+			defer ticker.Stop()
+
+			for {
+				if err, imported := Import(nil, store, currencyStore, feed.Format, feed.Location); err != nil {
+					loggerFunc(nil).Errorf("can not import rates from %s: %v", feed.Location, err)
+				} else {
+					loggerFunc(nil).Printf("%d rates imported from %s", imported, feed.Location)
+				}
+
+				<-ticker.C
+			}
+		}(feed)
+	}
+}
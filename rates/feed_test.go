@@ -0,0 +1,145 @@
+package rates
+
+import (
+	"math"
+	"time"
+	"strings"
+	"testing"
+	"github.com/serg666/repository"
+)
+
+func index(currencies ...*repository.Currency) *CurrencyIndex {
+	ci := &CurrencyIndex{
+		byNumericCode: make(map[int]*repository.Currency),
+		byCharCode:    make(map[string]*repository.Currency),
+	}
+
+	for _, c := range currencies {
+		ci.byNumericCode[*c.NumericCode] = c
+		ci.byCharCode[*c.CharCode] = c
+	}
+
+	return ci
+}
+
+const ecbDaily = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2022-05-06">
+			<Cube currency="USD" rate="1.0554"/>
+			<Cube currency="GBP" rate="0.85495"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestReadECB(t *testing.T) {
+	tests := []struct {
+		name  string
+		xml   string
+		index *CurrencyIndex
+		err   string
+		count int
+	}{
+		{name: "unknown currencies skipped", xml: ecbDaily, index: index(eur, usd), count: 2},
+		{name: "euro unknown", xml: ecbDaily, index: index(usd), err: "currency EUR not found"},
+		{name: "bad rate", xml: strings.Replace(ecbDaily, "1.0554", "-1", 1), index: index(eur, usd), err: "invalid ecb rate -1 of USD"},
+		{name: "bad day", xml: strings.Replace(ecbDaily, "2022-05-06", "06.05.2022", 1), index: index(eur, usd), err: `invalid ecb day 06.05.2022: parsing time "06.05.2022" as "2006-01-02": cannot parse "06.05.2022" as "2006"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, rates := ReadECB(strings.NewReader(tt.xml), ECB, tt.index)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadECB failed: %v", err)
+			}
+
+			if len(rates) != tt.count {
+				t.Fatalf("read %d rates, want %d", len(rates), tt.count)
+			}
+
+			if rates[0].From != eur || rates[0].To != usd || *rates[0].Rate != 1.0554 {
+				t.Errorf("direct rate is read wrong: %+v", rates[0])
+			}
+
+			if rates[1].From != usd || rates[1].To != eur || math.Abs(*rates[1].Rate * 1.0554 - 1) > 1e-12 {
+				t.Errorf("inverse rate is read wrong: %+v", rates[1])
+			}
+
+			if want := time.Date(2022, 5, 6, 0, 0, 0, 0, time.UTC); !rates[0].ValidFrom.Equal(want) {
+				t.Errorf("valid from = %v, want %v", rates[0].ValidFrom, want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		csv   string
+		err   string
+		count int
+	}{
+		{
+			name:  "codes and dates",
+			csv:   "from,to,rate,valid_from,valid_to\nUSD,643,75.5,2022-05-01,\nrub,usd,0.0132,2022-05-01T00:00:00Z,2022-06-01\n",
+			count: 2,
+		},
+		{
+			name: "unknown currency",
+			csv:  "from,to,rate,valid_from\nUSD,GBP,0.8,2022-05-01\n",
+			err:  "line 2: currency GBP not found",
+		},
+		{
+			name: "not positive rate",
+			csv:  "from,to,rate,valid_from\nUSD,RUB,0,2022-05-01\n",
+			err:  "line 2: invalid rate: 0",
+		},
+		{
+			name: "too few columns",
+			csv:  "from,to,rate,valid_from\nUSD,RUB,75\n",
+			err:  "line 2: expected at least 4 columns",
+		},
+		{
+			name: "invalid date",
+			csv:  "from,to,rate,valid_from\nUSD,RUB,75,01.05.2022\n",
+			err:  `line 2: invalid valid_from: parsing time "01.05.2022" as "2006-01-02": cannot parse "01.05.2022" as "2006"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, rates := ReadCSV(strings.NewReader(tt.csv), "test", index(rub, usd))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadCSV failed: %v", err)
+			}
+
+			if len(rates) != tt.count {
+				t.Fatalf("read %d rates, want %d", len(rates), tt.count)
+			}
+
+			first := rates[0]
+			if first.From != usd || first.To != rub || *first.Rate != 75.5 || first.ValidTo != nil || *first.Source != "test" {
+				t.Errorf("first rate is read wrong: %+v", first)
+			}
+
+			if second := rates[1]; second.ValidTo == nil || second.From != rub {
+				t.Errorf("second rate is read wrong: %+v", second)
+			}
+		})
+	}
+}
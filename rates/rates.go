@@ -0,0 +1,219 @@
+package rates
+
+import (
+	"fmt"
+	"time"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+// Rate is the price of one major unit of From currency in To currency
+// within validity period. ValidTo is open if nil
+type Rate struct {
+	Id        *int                 `json:"id"`
+	From      *repository.Currency `json:"from"`
+	To        *repository.Currency `json:"to"`
+	Rate      *float64             `json:"rate"`
+	ValidFrom *time.Time           `json:"valid_from"`
+	ValidTo   *time.Time           `json:"valid_to"`
+	Source    *string              `json:"source"`
+	Created   *time.Time           `json:"created"`
+}
+
+type RateSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type rateSpecificationByID struct {
+	id int
+}
+
+func (rs *rateSpecificationByID) ToSqlClauses() (string, []interface{}) {
+	return "where id=$1", []interface{}{rs.id}
+}
+
+func NewRateSpecificationByID(id int) RateSpecification {
+	return &rateSpecificationByID{id: id}
+}
+
+type rateSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (rs *rateSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id desc limit $1 offset $2", []interface{}{rs.limit, rs.offset}
+}
+
+func NewRateSpecificationWithLimitAndOffset(limit int, offset int) RateSpecification {
+	return &rateSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type rateSpecificationByCurrenciesAt struct {
+	from int
+	to   int
+	at   time.Time
+}
+
+func (rs *rateSpecificationByCurrenciesAt) ToSqlClauses() (string, []interface{}) {
+	return `where from_currency_id=$1 and to_currency_id=$2 and valid_from<=$3 and (valid_to is null or valid_to>$3)
+		order by valid_from desc, id desc limit 1`, []interface{}{rs.from, rs.to, rs.at}
+}
+
+// NewRateSpecificationByCurrenciesAt will select the latest rate between
+// currencies with given ids valid at the time
+func NewRateSpecificationByCurrenciesAt(from int, to int, at time.Time) RateSpecification {
+	return &rateSpecificationByCurrenciesAt{from: from, to: to, at: at}
+}
+
+type rateSpecificationByCurrencyAndCodeAt struct {
+	from int
+	code string
+	at   time.Time
+}
+
+func (rs *rateSpecificationByCurrencyAndCodeAt) ToSqlClauses() (string, []interface{}) {
+	return `where from_currency_id=$1 and to_currency_id=(select id from currencies where char_code=$2)
+		and valid_from<=$3 and (valid_to is null or valid_to>$3)
+		order by valid_from desc, id desc limit 1`, []interface{}{rs.from, rs.code, rs.at}
+}
+
+// NewRateSpecificationByCurrencyAndCodeAt will select the latest rate from
+// currency with given id to currency with given char code valid at the time
+func NewRateSpecificationByCurrencyAndCodeAt(from int, code string, at time.Time) RateSpecification {
+	return &rateSpecificationByCurrencyAndCodeAt{from: from, code: code, at: at}
+}
+
+type RateRepository interface {
+	// Add will insert the rate or replace the rate between the same
+	// currencies valid from the same time
+	Add(ctx interface{}, rate *Rate) error
+	Delete(ctx interface{}, rate *Rate) (error, bool)
+	Query(ctx interface{}, specification RateSpecification) (error, int, []*Rate)
+}
+
+type PGPoolRateStore struct {
+	pool          *pgxpool.Pool
+	currencyStore repository.CurrencyRepository
+	loggerFunc    repository.LoggerFunc
+}
+
+func (rs *PGPoolRateStore) Add(ctx interface{}, rate *Rate) error {
+	return rs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into rates (from_currency_id, to_currency_id, rate, valid_from, valid_to, source)
+		values ($1, $2, $3, $4, $5, $6)
+		on conflict (from_currency_id, to_currency_id, valid_from) do update
+		set rate=excluded.rate, valid_to=excluded.valid_to, source=excluded.source
+		returning id, created`,
+		rate.From.Id,
+		rate.To.Id,
+		rate.Rate,
+		rate.ValidFrom,
+		rate.ValidTo,
+		rate.Source,
+	).Scan(&rate.Id, &rate.Created)
+}
+
+func (rs *PGPoolRateStore) Delete(ctx interface{}, rate *Rate) (error, bool) {
+	ct, err := rs.pool.Exec(tracing.ContextOf(ctx), "delete from rates where id=$1", rate.Id)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("rate with id=%d not found", *rate.Id), true
+	}
+
+	return nil, false
+}
+
+func (rs *PGPoolRateStore) currency(ctx interface{}, id int) (error, *repository.Currency) {
+	err, _, currencies := rs.currencyStore.Query(ctx, repository.NewCurrencySpecificationByID(id))
+	if err != nil {
+		return err, nil
+	}
+
+	if len(currencies) == 0 {
+		return fmt.Errorf("currency with id=%d not found", id), nil
+	}
+
+	return nil, currencies[0]
+}
+
+func (rs *PGPoolRateStore) Query(ctx interface{}, specification RateSpecification) (error, int, []*Rate) {
+	var rates []*Rate
+	var fromIds, toIds []int
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			id,
+			from_currency_id,
+			to_currency_id,
+			rate,
+			valid_from,
+			valid_to,
+			source,
+			created,
+			count(*) over()
+		from rates %s`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fromId, toId int
+		rate := &Rate{}
+		if err := rows.Scan(
+			&rate.Id,
+			&fromId,
+			&toId,
+			&rate.Rate,
+			&rate.ValidFrom,
+			&rate.ValidTo,
+			&rate.Source,
+			&rate.Created,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		rates = append(rates, rate)
+		fromIds = append(fromIds, fromId)
+		toIds = append(toIds, toId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	for i, rate := range rates {
+		if err, rate.From = rs.currency(ctx, fromIds[i]); err != nil {
+			return err, 0, nil
+		}
+
+		if err, rate.To = rs.currency(ctx, toIds[i]); err != nil {
+			return err, 0, nil
+		}
+	}
+
+	return nil, overall, rates
+}
+
+func NewPGPoolRateStore(
+	pool *pgxpool.Pool,
+	currencyStore repository.CurrencyRepository,
+	loggerFunc repository.LoggerFunc,
+) RateRepository {
+	return &PGPoolRateStore{
+		pool:          pool,
+		currencyStore: currencyStore,
+		loggerFunc:    loggerFunc,
+	}
+}
@@ -0,0 +1,359 @@
+package reconciliation
+
+import (
+	"io"
+	"fmt"
+	"time"
+	"strconv"
+	"strings"
+	"encoding/csv"
+	"github.com/serg666/repository"
+)
+
+const (
+	CSV = "csv"
+)
+
+const (
+	MATCHED         = "matched"
+	MISSING         = "missing"
+	EXTRA           = "extra"
+	AMOUNT_MISMATCH = "amount_mismatch"
+)
+
+// Parsers keeps settlement file parsers by channel ID and file format
+var Parsers = make(map[int]map[string]Parser)
+
+// Record is the settled operation read from the acquirer file
+type Record struct {
+	RemoteId *string
+	RRN      *string
+	AuthCode *string
+	// Type is the transaction type of the operation. It is nil if the file
+	// does not tell it and the amount is positive
+	Type     *string
+	// Amount in minor units, negative for refunds and chargebacks
+	Amount   int
+	Currency *int
+	Date     *time.Time
+}
+
+// Parser reads settlement records from the acquirer file
+type Parser interface {
+	Parse(r io.Reader) (error, []*Record)
+}
+
+// RegisterParser will register settlement file parser of the channel
+func RegisterParser(channelId int, format string, parser Parser) error {
+	if _, ok := Parsers[channelId]; !ok {
+		Parsers[channelId] = make(map[string]Parser)
+	}
+
+	if _, ok := Parsers[channelId][format]; ok {
+		return fmt.Errorf("%s parser of channel <%d> already registered", format, channelId)
+	}
+
+	Parsers[channelId][format] = parser
+	return nil
+}
+
+// GetParser will return the parser of the channel for the file format
+func GetParser(channelId int, format string) (error, Parser) {
+	if parser, ok := Parsers[channelId][format]; ok {
+		return nil, parser
+	}
+
+	return fmt.Errorf("%s parser of channel <%d> not found", format, channelId), nil
+}
+
+// CSVColumns are zero based column numbers. Negative number means the
+// column is absent in the file
+type CSVColumns struct {
+	RemoteId int
+	RRN      int
+	AuthCode int
+	Type     int
+	Amount   int
+	Currency int
+	Date     int
+}
+
+// CSVParser is the configurable parser of acquirer CSV files
+type CSVParser struct {
+	Comma      rune
+	SkipHeader bool
+	Columns    CSVColumns
+
+	// MinorUnits is true if amounts are integers in minor units.
+	// Otherwise amounts are decimals with Exponent fraction digits
+	MinorUnits bool
+	Exponent   int
+
+	DateLayout string
+
+	// Types are transaction types by operation types of the file
+	Types map[string]string
+}
+
+func (cp *CSVParser) column(row []string, n int) *string {
+	if n < 0 || n >= len(row) {
+		return nil
+	}
+
+	value := strings.TrimSpace(row[n])
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func (cp *CSVParser) amount(value string) (int, error) {
+	if cp.MinorUnits {
+		amount, err := strconv.ParseInt(value, 10, 64)
+		return int(amount), err
+	}
+
+	sign := ""
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		sign, value = value[:1], value[1:]
+	}
+
+	value = strings.Replace(value, ",", ".", 1)
+	parts := strings.SplitN(value, ".", 2)
+
+	fraction := ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+	}
+
+	if len(fraction) > cp.Exponent {
+		return 0, fmt.Errorf("amount %s has more than %d fraction digits", value, cp.Exponent)
+	}
+
+	fraction = fraction + strings.Repeat("0", cp.Exponent-len(fraction))
+	// @note: sign is checked here, so "--1" is not parsed
+	if strings.HasPrefix(parts[0], "-") || strings.HasPrefix(parts[0], "+") {
+		return 0, fmt.Errorf("amount %s%s has two signs", sign, value)
+	}
+
+	amount, err := strconv.ParseInt(sign+parts[0]+fraction, 10, 64)
+	return int(amount), err
+}
+
+func (cp *CSVParser) Parse(r io.Reader) (error, []*Record) {
+	var records []*Record
+
+	reader := csv.NewReader(r)
+	if cp.Comma != 0 {
+		reader.Comma = cp.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	line := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("can not read csv: %v", err), nil
+		}
+
+		line++
+		if line == 1 && cp.SkipHeader {
+			continue
+		}
+
+		amount := cp.column(row, cp.Columns.Amount)
+		if amount == nil {
+			return fmt.Errorf("line %d: amount is empty", line), nil
+		}
+
+		record := &Record{
+			RemoteId: cp.column(row, cp.Columns.RemoteId),
+			RRN:      cp.column(row, cp.Columns.RRN),
+			AuthCode: cp.column(row, cp.Columns.AuthCode),
+		}
+
+		if record.Amount, err = cp.amount(*amount); err != nil {
+			return fmt.Errorf("line %d: invalid amount: %v", line, err), nil
+		}
+
+		if operation := cp.column(row, cp.Columns.Type); operation != nil {
+			kind, ok := cp.Types[*operation]
+			if !ok {
+				return fmt.Errorf("line %d: unknown operation type %s", line, *operation), nil
+			}
+			record.Type = &kind
+		} else if record.Amount < 0 {
+			kind := repository.REFUND
+			record.Type = &kind
+		}
+
+		if currency := cp.column(row, cp.Columns.Currency); currency != nil {
+			code, err := strconv.Atoi(*currency)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid currency: %v", line, err), nil
+			}
+			record.Currency = &code
+		}
+
+		if date := cp.column(row, cp.Columns.Date); date != nil && cp.DateLayout != "" {
+			created, err := time.Parse(cp.DateLayout, *date)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid date: %v", line, err), nil
+			}
+			record.Date = &created
+		}
+
+		records = append(records, record)
+	}
+
+	return nil, records
+}
+
+// Settled is the successful transaction which should be in the acquirer file
+type Settled struct {
+	TransactionId int
+	Type          string
+	RemoteId      *string
+	RRN           *string
+	AuthCode      *string
+	Amount        uint
+	Currency      int
+}
+
+// credit tells whether money of the transaction is returned to the card
+func (s *Settled) credit() bool {
+	return s.Type == repository.REFUND
+}
+
+// signed is the amount of the transaction as it is in the acquirer file
+func (s *Settled) signed() int {
+	if s.credit() {
+		return -int(s.Amount)
+	}
+
+	return int(s.Amount)
+}
+
+// sameOperation tells whether the record is of the operation type of the
+// transaction. Operations of the order share remote id, so it is not enough
+func (s *Settled) sameOperation(record *Record) bool {
+	if record.Type != nil {
+		return *record.Type == s.Type
+	}
+
+	return !s.credit()
+}
+
+func (s *Settled) matches(record *Record) bool {
+	if !s.sameOperation(record) {
+		return false
+	}
+
+	if record.RemoteId != nil && s.RemoteId != nil {
+		return *record.RemoteId == *s.RemoteId
+	}
+
+	if record.RRN == nil || s.RRN == nil || *record.RRN != *s.RRN {
+		return false
+	}
+
+	// @note: auth code is compared only if both sides have got it
+	if record.AuthCode != nil && s.AuthCode != nil {
+		return *record.AuthCode == *s.AuthCode
+	}
+
+	return true
+}
+
+// find will return the first unused transaction matching the record.
+// Transactions of the same amount are preferred, so operations of the order
+// are not paired crosswise
+func find(record *Record, settled []*Settled, used map[int]bool) *Settled {
+	var found *Settled
+	for _, s := range settled {
+		if used[s.TransactionId] || !s.matches(record) {
+			continue
+		}
+
+		if s.signed() == record.Amount {
+			return s
+		}
+
+		if found == nil {
+			found = s
+		}
+	}
+
+	return found
+}
+
+// Match will match acquirer records with settled transactions of the same
+// operation type by remote id, or by RRN and auth code if remote id is absent
+func Match(records []*Record, settled []*Settled) []*Item {
+	var items []*Item
+
+	used := make(map[int]bool)
+
+	for _, record := range records {
+		item := &Item{
+			Type:     record.Type,
+			RemoteId: record.RemoteId,
+			RRN:      record.RRN,
+			AuthCode: record.AuthCode,
+			Currency: record.Currency,
+		}
+		amount := record.Amount
+		item.Amount = &amount
+
+		found := find(record, settled, used)
+
+		status := EXTRA
+		if found != nil {
+			used[found.TransactionId] = true
+
+			transactionId := found.TransactionId
+			kind := found.Type
+			expected := found.signed()
+			item.TransactionId = &transactionId
+			item.Type = &kind
+			item.ExpectedAmount = &expected
+
+			status = MATCHED
+			if expected != record.Amount || (record.Currency != nil && *record.Currency != found.Currency) {
+				status = AMOUNT_MISMATCH
+			}
+		}
+		item.Status = &status
+
+		items = append(items, item)
+	}
+
+	for _, s := range settled {
+		if used[s.TransactionId] {
+			continue
+		}
+
+		status := MISSING
+		transactionId := s.TransactionId
+		kind := s.Type
+		expected := s.signed()
+		currency := s.Currency
+		items = append(items, &Item{
+			Status:         &status,
+			TransactionId:  &transactionId,
+			Type:           &kind,
+			RemoteId:       s.RemoteId,
+			RRN:            s.RRN,
+			AuthCode:       s.AuthCode,
+			ExpectedAmount: &expected,
+			Currency:       &currency,
+		})
+	}
+
+	return items
+}
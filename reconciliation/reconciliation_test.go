@@ -0,0 +1,276 @@
+package reconciliation
+
+import (
+	"fmt"
+	"time"
+	"strings"
+	"testing"
+	"github.com/serg666/repository"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func value(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return *s
+}
+
+func TestCSVParserParse(t *testing.T) {
+	columns := CSVColumns{
+		RemoteId: 0,
+		RRN:      1,
+		AuthCode: 2,
+		Type:     -1,
+		Amount:   3,
+		Currency: 4,
+		Date:     5,
+	}
+
+	tests := []struct {
+		name    string
+		parser  CSVParser
+		csv     string
+		err     string
+		records []Record
+	}{
+		{
+			name:   "minor units",
+			parser: CSVParser{SkipHeader: true, Columns: columns, MinorUnits: true},
+			csv:    "id,rrn,code,amount,currency,date\nr1,123,A1,1000,643,\nr2,,,-500,643,\n",
+			records: []Record{
+				{RemoteId: str("r1"), RRN: str("123"), AuthCode: str("A1"), Amount: 1000},
+				{RemoteId: str("r2"), Type: str(repository.REFUND), Amount: -500},
+			},
+		},
+		{
+			name:   "decimals",
+			parser: CSVParser{Comma: ';', Columns: columns, Exponent: 2},
+			csv:    "r1;;;10,5;643\nr2;;;-0.01;643\nr3;;;+7;643\n",
+			records: []Record{
+				{RemoteId: str("r1"), Amount: 1050},
+				{RemoteId: str("r2"), Type: str(repository.REFUND), Amount: -1},
+				{RemoteId: str("r3"), Amount: 700},
+			},
+		},
+		{
+			name: "operation types",
+			parser: CSVParser{
+				Columns: CSVColumns{RemoteId: 0, RRN: -1, AuthCode: -1, Type: 1, Amount: 2, Currency: -1, Date: -1},
+				MinorUnits: true,
+				Types: map[string]string{
+					"SALE":   repository.AUTH,
+					"RETURN": repository.REFUND,
+				},
+			},
+			csv: "r1,SALE,100\nr1,RETURN,100\n",
+			records: []Record{
+				{RemoteId: str("r1"), Type: str(repository.AUTH), Amount: 100},
+				{RemoteId: str("r1"), Type: str(repository.REFUND), Amount: 100},
+			},
+		},
+		{
+			name: "unknown operation type",
+			parser: CSVParser{
+				Columns:    CSVColumns{RemoteId: 0, RRN: -1, AuthCode: -1, Type: 1, Amount: 2, Currency: -1, Date: -1},
+				MinorUnits: true,
+				Types:      map[string]string{"SALE": repository.AUTH},
+			},
+			csv: "r1,VOID,100\n",
+			err: "line 1: unknown operation type VOID",
+		},
+		{
+			name:   "too many fraction digits",
+			parser: CSVParser{Columns: columns, Exponent: 2},
+			csv:    "r1,,,1.005,643\n",
+			err:    "line 1: invalid amount: amount 1.005 has more than 2 fraction digits",
+		},
+		{
+			name:   "two signs",
+			parser: CSVParser{Columns: columns, Exponent: 2},
+			csv:    "r1,,,--1,643\n",
+			err:    "line 1: invalid amount: amount --1 has two signs",
+		},
+		{
+			name:   "empty amount",
+			parser: CSVParser{Columns: columns, MinorUnits: true},
+			csv:    "r1,,,,643\n",
+			err:    "line 1: amount is empty",
+		},
+		{
+			name:   "invalid currency",
+			parser: CSVParser{Columns: columns, MinorUnits: true},
+			csv:    "r1,,,100,RUB\n",
+			err:    `line 1: invalid currency: strconv.Atoi: parsing "RUB": invalid syntax`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, records := tt.parser.Parse(strings.NewReader(tt.csv))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			if len(records) != len(tt.records) {
+				t.Fatalf("parsed %d records, want %d", len(records), len(tt.records))
+			}
+
+			for i, want := range tt.records {
+				got := records[i]
+				if value(got.RemoteId) != value(want.RemoteId) ||
+					value(got.RRN) != value(want.RRN) ||
+					value(got.AuthCode) != value(want.AuthCode) ||
+					value(got.Type) != value(want.Type) ||
+					got.Amount != want.Amount {
+					t.Errorf(
+						"record %d = %s/%s/%s/%s/%d, want %s/%s/%s/%s/%d",
+						i,
+						value(got.RemoteId), value(got.RRN), value(got.AuthCode), value(got.Type), got.Amount,
+						value(want.RemoteId), value(want.RRN), value(want.AuthCode), value(want.Type), want.Amount,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVParserDate(t *testing.T) {
+	parser := CSVParser{
+		Columns:    CSVColumns{RemoteId: 0, RRN: -1, AuthCode: -1, Type: -1, Amount: 1, Currency: -1, Date: 2},
+		MinorUnits: true,
+		DateLayout: "2006-01-02",
+	}
+
+	err, records := parser.Parse(strings.NewReader("r1,100,2022-05-01\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if want := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC); records[0].Date == nil || !records[0].Date.Equal(want) {
+		t.Errorf("date = %v, want %v", records[0].Date, want)
+	}
+
+	if err, _ := parser.Parse(strings.NewReader("r1,100,01.05.2022\n")); err == nil {
+		t.Errorf("invalid date parsed")
+	}
+}
+
+// result is the item as compared in tests
+func result(item *Item) string {
+	transactionId := "<nil>"
+	if item.TransactionId != nil {
+		transactionId = fmt.Sprint(*item.TransactionId)
+	}
+
+	return fmt.Sprintf("%s:%s:%s", *item.Status, transactionId, value(item.Type))
+}
+
+func TestMatch(t *testing.T) {
+	auth := func(id int, remoteId string, amount uint) *Settled {
+		return &Settled{TransactionId: id, Type: repository.AUTH, RemoteId: str(remoteId), Amount: amount, Currency: 643}
+	}
+	refund := func(id int, remoteId string, amount uint) *Settled {
+		return &Settled{TransactionId: id, Type: repository.REFUND, RemoteId: str(remoteId), Amount: amount, Currency: 643}
+	}
+
+	tests := []struct {
+		name    string
+		records []*Record
+		settled []*Settled
+		want    []string
+	}{
+		{
+			name:    "matched by remote id",
+			records: []*Record{{RemoteId: str("r1"), Amount: 1000}},
+			settled: []*Settled{auth(1, "r1", 1000)},
+			want:    []string{"matched:1:auth"},
+		},
+		{
+			name:    "amount mismatch",
+			records: []*Record{{RemoteId: str("r1"), Amount: 900}},
+			settled: []*Settled{auth(1, "r1", 1000)},
+			want:    []string{"amount_mismatch:1:auth"},
+		},
+		{
+			name:    "currency mismatch",
+			records: []*Record{{RemoteId: str("r1"), Amount: 1000, Currency: func() *int { c := 840; return &c }()}},
+			settled: []*Settled{auth(1, "r1", 1000)},
+			want:    []string{"amount_mismatch:1:auth"},
+		},
+		{
+			name:    "extra and missing",
+			records: []*Record{{RemoteId: str("r2"), Amount: 1000}},
+			settled: []*Settled{auth(1, "r1", 1000)},
+			want:    []string{"extra:<nil>:<nil>", "missing:1:auth"},
+		},
+		{
+			name: "refund by negative amount",
+			records: []*Record{
+				{RemoteId: str("r1"), Type: str(repository.REFUND), Amount: -300},
+				{RemoteId: str("r1"), Amount: 1000},
+			},
+			settled: []*Settled{auth(1, "r1", 1000), refund(2, "r1", 300)},
+			want:    []string{"matched:2:refund", "matched:1:auth"},
+		},
+		{
+			name: "partial refunds of the order by amount",
+			records: []*Record{
+				{RemoteId: str("r1"), Type: str(repository.REFUND), Amount: -200},
+				{RemoteId: str("r1"), Type: str(repository.REFUND), Amount: -100},
+			},
+			settled: []*Settled{refund(2, "r1", 100), refund(3, "r1", 200)},
+			want:    []string{"matched:3:refund", "matched:2:refund"},
+		},
+		{
+			name:    "refund record does not match payment",
+			records: []*Record{{RemoteId: str("r1"), Type: str(repository.REFUND), Amount: -1000}},
+			settled: []*Settled{auth(1, "r1", 1000)},
+			want:    []string{"extra:<nil>:refund", "missing:1:auth"},
+		},
+		{
+			name: "matched by rrn and auth code",
+			records: []*Record{
+				{RRN: str("123"), AuthCode: str("A1"), Amount: 1000},
+				{RRN: str("456"), AuthCode: str("B2"), Amount: 500},
+			},
+			settled: []*Settled{
+				{TransactionId: 1, Type: repository.AUTH, RRN: str("123"), AuthCode: str("A1"), Amount: 1000, Currency: 643},
+				{TransactionId: 2, Type: repository.AUTH, RRN: str("456"), AuthCode: str("C3"), Amount: 500, Currency: 643},
+			},
+			want: []string{"matched:1:auth", "extra:<nil>:<nil>", "missing:2:auth"},
+		},
+		{
+			name:    "transaction is matched once",
+			records: []*Record{{RemoteId: str("r1"), Amount: 1000}, {RemoteId: str("r1"), Amount: 1000}},
+			settled: []*Settled{auth(1, "r1", 1000)},
+			want:    []string{"matched:1:auth", "extra:<nil>:<nil>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := Match(tt.records, tt.settled)
+
+			var got []string
+			for _, item := range items {
+				got = append(got, result(item))
+			}
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
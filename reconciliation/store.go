@@ -0,0 +1,349 @@
+package reconciliation
+
+import (
+	"fmt"
+	"time"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+// Reconciliation is the result of one settlement file import
+type Reconciliation struct {
+	Id         *int       `json:"id"`
+	Created    *time.Time `json:"created"`
+	ChannelId  *int       `json:"channel_id"`
+	Format     *string    `json:"format"`
+	Filename   *string    `json:"filename"`
+	Date       *time.Time `json:"date"`
+	Matched    *int       `json:"matched"`
+	Missing    *int       `json:"missing"`
+	Extra      *int       `json:"extra"`
+	Mismatched *int       `json:"mismatched"`
+	Items      []*Item    `json:"items,omitempty"`
+}
+
+// Summarize will count items by status
+func (r *Reconciliation) Summarize() {
+	counts := make(map[string]int)
+	for _, item := range r.Items {
+		counts[*item.Status]++
+	}
+
+	matched, missing, extra, mismatched := counts[MATCHED], counts[MISSING], counts[EXTRA], counts[AMOUNT_MISMATCH]
+	r.Matched = &matched
+	r.Missing = &missing
+	r.Extra = &extra
+	r.Mismatched = &mismatched
+}
+
+// Item is one matched or unmatched record of the reconciliation
+type Item struct {
+	Id               *int    `json:"id"`
+	ReconciliationId *int    `json:"reconciliation_id"`
+	Status           *string `json:"status"`
+	TransactionId    *int    `json:"transaction_id"`
+	// Type is the transaction type of the operation if known
+	Type             *string `json:"type"`
+	RemoteId         *string `json:"remote_id"`
+	RRN              *string `json:"rrn"`
+	AuthCode         *string `json:"authcode"`
+	// Amount from the acquirer file, negative for refunds
+	Amount           *int    `json:"amount"`
+	// ExpectedAmount from the transaction
+	ExpectedAmount   *int    `json:"expected_amount"`
+	Currency         *int    `json:"currency"`
+}
+
+type ReconciliationSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type reconciliationSpecificationByID struct {
+	id int
+}
+
+func (rs *reconciliationSpecificationByID) ToSqlClauses() (string, []interface{}) {
+	return "where id=$1", []interface{}{rs.id}
+}
+
+func NewReconciliationSpecificationByID(id int) ReconciliationSpecification {
+	return &reconciliationSpecificationByID{id: id}
+}
+
+type reconciliationSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (rs *reconciliationSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id desc limit $1 offset $2", []interface{}{rs.limit, rs.offset}
+}
+
+func NewReconciliationSpecificationWithLimitAndOffset(limit int, offset int) ReconciliationSpecification {
+	return &reconciliationSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type ItemSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type itemSpecificationByReconciliationID struct {
+	id int
+}
+
+func (is *itemSpecificationByReconciliationID) ToSqlClauses() (string, []interface{}) {
+	return "where reconciliation_id=$1", []interface{}{is.id}
+}
+
+func NewItemSpecificationByReconciliationID(id int) ItemSpecification {
+	return &itemSpecificationByReconciliationID{id: id}
+}
+
+type itemSpecificationByReconciliationIDAndStatus struct {
+	id     int
+	status string
+}
+
+func (is *itemSpecificationByReconciliationIDAndStatus) ToSqlClauses() (string, []interface{}) {
+	return "where reconciliation_id=$1 and status=$2", []interface{}{is.id, is.status}
+}
+
+func NewItemSpecificationByReconciliationIDAndStatus(id int, status string) ItemSpecification {
+	return &itemSpecificationByReconciliationIDAndStatus{id: id, status: status}
+}
+
+type ReconciliationRepository interface {
+	// Add will insert the reconciliation with its items
+	Add(ctx interface{}, reconciliation *Reconciliation) error
+	Query(ctx interface{}, specification ReconciliationSpecification) (error, int, []*Reconciliation)
+	QueryItems(ctx interface{}, specification ItemSpecification) (error, int, []*Item)
+	// Settled will return successful transactions of the channel
+	// which should be settled within the period
+	Settled(ctx interface{}, channelId int, from time.Time, to time.Time) (error, []*Settled)
+}
+
+type PGPoolReconciliationStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (rs *PGPoolReconciliationStore) Add(ctx interface{}, reconciliation *Reconciliation) error {
+	tx, err := rs.pool.Begin(tracing.ContextOf(ctx))
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(tracing.ContextOf(ctx))
+
+	if err := tx.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into reconciliations (
+			channel_id,
+			format,
+			filename,
+			date,
+			matched,
+			missing,
+			extra,
+			mismatched
+		) values ($1, $2, $3, $4, $5, $6, $7, $8) returning id, created`,
+		reconciliation.ChannelId,
+		reconciliation.Format,
+		reconciliation.Filename,
+		reconciliation.Date,
+		reconciliation.Matched,
+		reconciliation.Missing,
+		reconciliation.Extra,
+		reconciliation.Mismatched,
+	).Scan(&reconciliation.Id, &reconciliation.Created); err != nil {
+		return err
+	}
+
+	for _, item := range reconciliation.Items {
+		item.ReconciliationId = reconciliation.Id
+		if err := tx.QueryRow(
+			tracing.ContextOf(ctx),
+			`insert into reconciliation_items (
+				reconciliation_id,
+				status,
+				transaction_id,
+				type,
+				remote_id,
+				rrn,
+				authcode,
+				amount,
+				expected_amount,
+				currency
+			) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id`,
+			item.ReconciliationId,
+			item.Status,
+			item.TransactionId,
+			item.Type,
+			item.RemoteId,
+			item.RRN,
+			item.AuthCode,
+			item.Amount,
+			item.ExpectedAmount,
+			item.Currency,
+		).Scan(&item.Id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(tracing.ContextOf(ctx))
+}
+
+func (rs *PGPoolReconciliationStore) Query(ctx interface{}, specification ReconciliationSpecification) (error, int, []*Reconciliation) {
+	var reconciliations []*Reconciliation
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			id,
+			created,
+			channel_id,
+			format,
+			filename,
+			date,
+			matched,
+			missing,
+			extra,
+			mismatched,
+			count(*) over()
+		from reconciliations %s`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		reconciliation := &Reconciliation{}
+		if err := rows.Scan(
+			&reconciliation.Id,
+			&reconciliation.Created,
+			&reconciliation.ChannelId,
+			&reconciliation.Format,
+			&reconciliation.Filename,
+			&reconciliation.Date,
+			&reconciliation.Matched,
+			&reconciliation.Missing,
+			&reconciliation.Extra,
+			&reconciliation.Mismatched,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		reconciliations = append(reconciliations, reconciliation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, reconciliations
+}
+
+func (rs *PGPoolReconciliationStore) QueryItems(ctx interface{}, specification ItemSpecification) (error, int, []*Item) {
+	var items []*Item
+
+	where, args := specification.ToSqlClauses()
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			id,
+			reconciliation_id,
+			status,
+			transaction_id,
+			type,
+			remote_id,
+			rrn,
+			authcode,
+			amount,
+			expected_amount,
+			currency
+		from reconciliation_items %s order by id`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &Item{}
+		if err := rows.Scan(
+			&item.Id,
+			&item.ReconciliationId,
+			&item.Status,
+			&item.TransactionId,
+			&item.Type,
+			&item.RemoteId,
+			&item.RRN,
+			&item.AuthCode,
+			&item.Amount,
+			&item.ExpectedAmount,
+			&item.Currency,
+		); err != nil {
+			return err, 0, nil
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, len(items), items
+}
+
+func (rs *PGPoolReconciliationStore) Settled(ctx interface{}, channelId int, from time.Time, to time.Time) (error, []*Settled) {
+	var settled []*Settled
+
+	// @note: preauth is settled by its confirmation, reversal is not settled at all
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		`select
+			t.id,
+			t.type,
+			t.remote_id,
+			t.rrn,
+			t.authcode,
+			t.amount_converted,
+			c.numeric_code
+		from transactions t
+		join accounts a on a.id=t.account_id
+		join currencies c on c.id=t.currency_converted_id
+		where a.channel_id=$1 and t.status=$2 and t.type=any($3) and t.created>=$4 and t.created<$5
+		order by t.id`,
+		channelId,
+		repository.SUCCESS,
+		[]string{repository.AUTH, repository.CONFIRMAUTH, repository.REBILL, repository.REFUND},
+		from,
+		to,
+	)
+	if err != nil {
+		return err, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Settled{}
+		if err := rows.Scan(&s.TransactionId, &s.Type, &s.RemoteId, &s.RRN, &s.AuthCode, &s.Amount, &s.Currency); err != nil {
+			return err, nil
+		}
+		settled = append(settled, s)
+	}
+
+	return rows.Err(), settled
+}
+
+func NewPGPoolReconciliationStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) ReconciliationRepository {
+	return &PGPoolReconciliationStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
@@ -0,0 +1,219 @@
+package reports
+
+import (
+	"io"
+	"fmt"
+	"time"
+	"strings"
+	"strconv"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+const (
+	CSV  = "csv"
+	JSON = "json"
+)
+
+// TurnOverTypes are transaction types included in turnover reports
+var TurnOverTypes = []string{
+	repository.AUTH,
+	repository.CONFIRMAUTH,
+	repository.REFUND,
+	repository.REVERSAL,
+	repository.REBILL,
+}
+
+// TurnOverFilter limits the report period and optionally profile and account.
+// Period includes From and excludes To
+type TurnOverFilter struct {
+	From      time.Time
+	To        time.Time
+	ProfileId *int
+	AccountId *int
+}
+
+// TurnOverRow is the total of successful transactions of one type in one
+// currency made by the account of the profile within the day
+type TurnOverRow struct {
+	Day        time.Time `json:"day"`
+	ProfileId  int       `json:"profile_id"`
+	ProfileKey string    `json:"profile_key"`
+	AccountId  int       `json:"account_id"`
+	Type       string    `json:"type"`
+	Currency   string    `json:"currency"`
+	Count      int       `json:"count"`
+	// Sum in minor units
+	Sum        uint      `json:"sum"`
+	// Fees charged in minor units of the same currency
+	Fees       uint      `json:"fees_sum"`
+	Exponent   int       `json:"-"`
+}
+
+// Amount will return sum in major units respecting the currency exponent
+func (tr *TurnOverRow) Amount() string {
+	return FormatAmount(tr.Sum, tr.Exponent)
+}
+
+func (tr *TurnOverRow) MarshalJSON() ([]byte, error) {
+	type row TurnOverRow
+	return json.Marshal(&struct {
+		*row
+		Day    string `json:"day"`
+		Amount string `json:"amount"`
+		Fees   string `json:"fees"`
+	}{
+		row:    (*row)(tr),
+		Day:    tr.Day.Format("2006-01-02"),
+		Amount: tr.Amount(),
+		Fees:   FormatAmount(tr.Fees, tr.Exponent),
+	})
+}
+
+// FormatAmount will format amount in minor units as decimal
+func FormatAmount(amount uint, exponent int) string {
+	digits := strconv.FormatUint(uint64(amount), 10)
+	if exponent <= 0 {
+		return digits
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+type ReportRepository interface {
+	TurnOver(ctx interface{}, filter TurnOverFilter) (error, []*TurnOverRow)
+}
+
+type PGPoolReportStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (rs *PGPoolReportStore) TurnOver(ctx interface{}, filter TurnOverFilter) (error, []*TurnOverRow) {
+	var rows []*TurnOverRow
+
+	where := []string{"t.status=$1", "t.type=any($2)", "t.created>=$3", "t.created<$4"}
+	args := []interface{}{repository.SUCCESS, TurnOverTypes, filter.From, filter.To}
+
+	if filter.ProfileId != nil {
+		args = append(args, *filter.ProfileId)
+		where = append(where, fmt.Sprintf("t.profile_id=$%d", len(args)))
+	}
+
+	if filter.AccountId != nil {
+		args = append(args, *filter.AccountId)
+		where = append(where, fmt.Sprintf("t.account_id=$%d", len(args)))
+	}
+
+	result, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			date_trunc('day', t.created at time zone 'UTC') as day,
+			t.profile_id,
+			p.key,
+			t.account_id,
+			t.type,
+			c.char_code,
+			coalesce(c.exponent, 0),
+			count(*),
+			sum(t.amount),
+			coalesce(sum(f.amount), 0)
+		from transactions t
+		join profiles p on p.id=t.profile_id
+		join currencies c on c.id=t.currency_id
+		left join transaction_fees f on f.transaction_id=t.id and f.charged
+		where %s
+		group by day, t.profile_id, p.key, t.account_id, t.type, c.char_code, c.exponent
+		order by day, t.profile_id, t.account_id, t.type, c.char_code`, strings.Join(where, " and ")),
+		args...,
+	)
+	if err != nil {
+		return err, nil
+	}
+	defer result.Close()
+
+	for result.Next() {
+		row := &TurnOverRow{}
+		var sum, fees int64
+		if err := result.Scan(
+			&row.Day,
+			&row.ProfileId,
+			&row.ProfileKey,
+			&row.AccountId,
+			&row.Type,
+			&row.Currency,
+			&row.Exponent,
+			&row.Count,
+			&sum,
+			&fees,
+		); err != nil {
+			return err, nil
+		}
+		row.Sum = uint(sum)
+		row.Fees = uint(fees)
+		rows = append(rows, row)
+	}
+
+	return result.Err(), rows
+}
+
+func NewPGPoolReportStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) ReportRepository {
+	return &PGPoolReportStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
+
+// WriteTurnOver will write report rows in CSV or JSON format
+func WriteTurnOver(w io.Writer, format string, rows []*TurnOverRow) error {
+	switch format {
+	case JSON:
+		if rows == nil {
+			rows = []*TurnOverRow{}
+		}
+		return json.NewEncoder(w).Encode(rows)
+	case CSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{
+			"day",
+			"profile_id",
+			"profile_key",
+			"account_id",
+			"type",
+			"currency",
+			"count",
+			"amount",
+			"fees",
+		}); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := writer.Write([]string{
+				row.Day.Format("2006-01-02"),
+				strconv.Itoa(row.ProfileId),
+				row.ProfileKey,
+				strconv.Itoa(row.AccountId),
+				row.Type,
+				row.Currency,
+				strconv.Itoa(row.Count),
+				row.Amount(),
+				FormatAmount(row.Fees, row.Exponent),
+			}); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		return writer.Error()
+	}
+
+	return fmt.Errorf("unknown report format: %s", format)
+}
@@ -0,0 +1,57 @@
+package reviews
+
+import (
+	"time"
+)
+
+// Operations made on approval of the held transaction. Authorizations are held
+// as preauthorizations and confirmed on approval, preauthorizations are just
+// released to the merchant
+const (
+	CONFIRM = "confirm"
+	RELEASE = "release"
+)
+
+// States of the review
+const (
+	PENDING   = "pending"
+	APPROVED  = "approved"
+	REJECTED  = "rejected"
+	EXPIRED   = "expired"
+	CANCELLED = "cancelled"
+)
+
+// Review is the held transaction waiting for the operator decision. Result is
+// the transaction made by the decision
+type Review struct {
+	Id            *int       `json:"id"`
+	Created       *time.Time `json:"created"`
+	Deadline      *time.Time `json:"deadline"`
+	TransactionId *int       `json:"transaction_id"`
+	AssessmentId  *int       `json:"assessment_id"`
+	Operation     *string    `json:"operation"`
+	State         *string    `json:"state"`
+	Operator      *string    `json:"operator"`
+	Comment       *string    `json:"comment"`
+	Decided       *time.Time `json:"decided"`
+	ResultId      *int       `json:"result_id"`
+}
+
+func (r *Review) IsPending() bool {
+	return *r.State == PENDING
+}
+
+// NewReview will return pending review of the transaction to be decided
+// within the sla
+func NewReview(transactionId int, assessmentId *int, operation string, sla time.Duration) *Review {
+	state := PENDING
+	deadline := time.Now().Add(sla)
+
+	return &Review{
+		Deadline:      &deadline,
+		TransactionId: &transactionId,
+		AssessmentId:  assessmentId,
+		Operation:     &operation,
+		State:         &state,
+	}
+}
@@ -0,0 +1,219 @@
+package reviews
+
+import (
+	"fmt"
+	"time"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+type ReviewSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type reviewSpecificationByID struct {
+	id int
+}
+
+func (rs *reviewSpecificationByID) ToSqlClauses() (string, []interface{}) {
+	return "where id=$1", []interface{}{rs.id}
+}
+
+func NewReviewSpecificationByID(id int) ReviewSpecification {
+	return &reviewSpecificationByID{id: id}
+}
+
+type reviewSpecificationByTransactionID struct {
+	id int
+}
+
+func (rs *reviewSpecificationByTransactionID) ToSqlClauses() (string, []interface{}) {
+	return "where transaction_id=$1 order by id desc", []interface{}{rs.id}
+}
+
+func NewReviewSpecificationByTransactionID(id int) ReviewSpecification {
+	return &reviewSpecificationByTransactionID{id: id}
+}
+
+type reviewSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (rs *reviewSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id desc limit $1 offset $2", []interface{}{rs.limit, rs.offset}
+}
+
+func NewReviewSpecificationWithLimitAndOffset(limit int, offset int) ReviewSpecification {
+	return &reviewSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type reviewSpecificationByStateWithLimitAndOffset struct {
+	state  string
+	limit  int
+	offset int
+}
+
+func (rs *reviewSpecificationByStateWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	// @note: pending reviews are listed by urgency
+	return "where state=$1 order by deadline, id limit $2 offset $3", []interface{}{rs.state, rs.limit, rs.offset}
+}
+
+func NewReviewSpecificationByStateWithLimitAndOffset(state string, limit int, offset int) ReviewSpecification {
+	return &reviewSpecificationByStateWithLimitAndOffset{state: state, limit: limit, offset: offset}
+}
+
+type reviewSpecificationOverdue struct {
+	at time.Time
+}
+
+func (rs *reviewSpecificationOverdue) ToSqlClauses() (string, []interface{}) {
+	return "where state=$1 and deadline<$2 order by deadline", []interface{}{PENDING, rs.at}
+}
+
+// NewReviewSpecificationOverdue will select pending reviews with deadline
+// before the time
+func NewReviewSpecificationOverdue(at time.Time) ReviewSpecification {
+	return &reviewSpecificationOverdue{at: at}
+}
+
+type ReviewRepository interface {
+	Add(ctx interface{}, review *Review) error
+	Query(ctx interface{}, specification ReviewSpecification) (error, int, []*Review)
+	// Close will move pending review to its new state. It returns notfound
+	// if the review has already been decided
+	Close(ctx interface{}, review *Review) (error, bool)
+	// Result will store the transaction made by the decision
+	Result(ctx interface{}, review *Review) error
+}
+
+type PGPoolReviewStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (rs *PGPoolReviewStore) Add(ctx interface{}, review *Review) error {
+	return rs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into reviews (deadline, transaction_id, assessment_id, operation, state)
+		values ($1, $2, $3, $4, $5) returning id, created`,
+		review.Deadline,
+		review.TransactionId,
+		review.AssessmentId,
+		review.Operation,
+		review.State,
+	).Scan(&review.Id, &review.Created)
+}
+
+func (rs *PGPoolReviewStore) Query(ctx interface{}, specification ReviewSpecification) (error, int, []*Review) {
+	var list []*Review
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			id,
+			created,
+			deadline,
+			transaction_id,
+			assessment_id,
+			operation,
+			state,
+			operator,
+			comment,
+			decided,
+			result_id,
+			count(*) over()
+		from reviews %s`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		review := &Review{}
+		if err := rows.Scan(
+			&review.Id,
+			&review.Created,
+			&review.Deadline,
+			&review.TransactionId,
+			&review.AssessmentId,
+			&review.Operation,
+			&review.State,
+			&review.Operator,
+			&review.Comment,
+			&review.Decided,
+			&review.ResultId,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		list = append(list, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, list
+}
+
+func (rs *PGPoolReviewStore) Close(ctx interface{}, review *Review) (error, bool) {
+	ct, err := rs.pool.Exec(
+		tracing.ContextOf(ctx),
+		`update reviews set state=$2, operator=$3, comment=$4, decided=localtimestamp
+		where id=$1 and state=$5`,
+		review.Id,
+		review.State,
+		review.Operator,
+		review.Comment,
+		PENDING,
+	)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("pending review with id=%d not found", *review.Id), true
+	}
+
+	return nil, false
+}
+
+func (rs *PGPoolReviewStore) Result(ctx interface{}, review *Review) error {
+	_, err := rs.pool.Exec(
+		tracing.ContextOf(ctx),
+		"update reviews set result_id=$2 where id=$1",
+		review.Id,
+		review.ResultId,
+	)
+
+	return err
+}
+
+// Pending will return pending review of the transaction or nil
+func Pending(ctx interface{}, store ReviewRepository, transactionId int) (error, *Review) {
+	err, _, list := store.Query(ctx, NewReviewSpecificationByTransactionID(transactionId))
+	if err != nil {
+		return err, nil
+	}
+
+	for _, review := range list {
+		if review.IsPending() {
+			return nil, review
+		}
+	}
+
+	return nil, nil
+}
+
+func NewPGPoolReviewStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) ReviewRepository {
+	return &PGPoolReviewStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
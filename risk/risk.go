@@ -0,0 +1,210 @@
+package risk
+
+import (
+	"fmt"
+	"time"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/repository"
+)
+
+// Actions of rules and decisions of assessments in order of severity
+const (
+	ALLOW    = "allow"
+	FORCE3DS = "force3ds"
+	REVIEW   = "review"
+	DENY     = "deny"
+)
+
+var severity = map[string]int{
+	ALLOW:    0,
+	FORCE3DS: 1,
+	REVIEW:   2,
+	DENY:     3,
+}
+
+// Input is the payment being screened. Country is the BIN country and it
+// is nil when unknown
+type Input struct {
+	Profile     *repository.Profile
+	OrderId     *string
+	Customer    *string
+	Amount      uint
+	PANHash     string
+	BIN         string
+	Country     *string
+	Card        *bins.Bin
+	BrowserInfo *repository.BrowserInfo
+}
+
+// RuleFunc evaluates the rule with its settings and tells whether the rule
+// has been triggered and why
+type RuleFunc func(
+	ctx interface{},
+	store RiskRepository,
+	settings map[string]interface{},
+	input *Input,
+) (error, bool, string)
+
+// Rules are registered rule types by key
+var Rules = make(map[string]RuleFunc)
+
+func RegisterRule(key string, ruleFunc RuleFunc) error {
+	if _, ok := Rules[key]; ok {
+		return fmt.Errorf("risk rule <%s> has already been registered", key)
+	}
+
+	Rules[key] = ruleFunc
+	return nil
+}
+
+// Rule is the configured rule. Rules without profile apply to all profiles
+type Rule struct {
+	Id        *int                    `json:"id"`
+	ProfileId *int                    `json:"profile_id"`
+	Key       *string                 `json:"key"`
+	Action    *string                 `json:"action"`
+	Score     *int                    `json:"score"`
+	Settings  *map[string]interface{} `json:"settings"`
+	Enabled   *bool                   `json:"enabled"`
+}
+
+// Triggered is the rule triggered by the payment
+type Triggered struct {
+	RuleId int    `json:"rule_id"`
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+// Assessment is the result of the payment screening. Transaction is nil for
+// denied payments
+type Assessment struct {
+	Id            *int         `json:"id"`
+	Created       *time.Time   `json:"created"`
+	ProfileId     *int         `json:"profile_id"`
+	TransactionId *int         `json:"transaction_id"`
+	OrderId       *string      `json:"order_id"`
+	Customer      *string      `json:"customer"`
+	Score         int          `json:"score"`
+	Decision      string       `json:"decision"`
+	Rules         []*Triggered `json:"rules"`
+}
+
+// Thresholds escalate decision by the total score. Zero threshold is off
+type Thresholds struct {
+	Review int
+	Deny   int
+}
+
+// HashPAN will return hex encoded SHA-256 of the PAN
+func HashPAN(pan string) string {
+	sum := sha256.Sum256([]byte(pan))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewInput will return input of the card payment with its BIN metadata
+func NewInput(
+	profile *repository.Profile,
+	card *repository.Card,
+	bin *bins.Bin,
+	orderId *string,
+	customer *string,
+	amount uint,
+	browserInfo *repository.BrowserInfo,
+) *Input {
+	pan := string(*card.PAN)
+	prefix := pan
+	if len(prefix) > 6 {
+		prefix = prefix[:6]
+	}
+
+	input := &Input{
+		Profile:     profile,
+		OrderId:     orderId,
+		Customer:    customer,
+		Amount:      amount,
+		PANHash:     HashPAN(pan),
+		BIN:         prefix,
+		Card:        bin,
+		BrowserInfo: browserInfo,
+	}
+
+	if bin != nil {
+		input.Country = bin.Country
+	}
+
+	return input
+}
+
+func escalate(decision string, action string) string {
+	if severity[action] > severity[decision] {
+		return action
+	}
+
+	return decision
+}
+
+// Evaluate will run enabled rules of the profile and store the assessment.
+// Decision is the most severe action of triggered rules escalated by score
+func Evaluate(ctx interface{}, store RiskRepository, thresholds Thresholds, input *Input) (error, *Assessment) {
+	err, _, rules := store.QueryRules(ctx, NewRuleSpecificationEnabledForProfile(*input.Profile.Id))
+	if err != nil {
+		return fmt.Errorf("failed to query risk rules: %v", err), nil
+	}
+
+	assessment := &Assessment{
+		ProfileId: input.Profile.Id,
+		OrderId:   input.OrderId,
+		Customer:  input.Customer,
+		Decision:  ALLOW,
+		Rules:     []*Triggered{},
+	}
+
+	for _, rule := range rules {
+		ruleFunc, ok := Rules[*rule.Key]
+		if !ok {
+			return fmt.Errorf("risk rule <%s> not registered", *rule.Key), nil
+		}
+
+		settings := map[string]interface{}{}
+		if rule.Settings != nil {
+			settings = *rule.Settings
+		}
+
+		err, triggered, reason := ruleFunc(ctx, store, settings, input)
+		if err != nil {
+			return fmt.Errorf("risk rule <%d> failed: %v", *rule.Id, err), nil
+		}
+
+		if !triggered {
+			continue
+		}
+
+		assessment.Score += *rule.Score
+		assessment.Decision = escalate(assessment.Decision, *rule.Action)
+		assessment.Rules = append(assessment.Rules, &Triggered{
+			RuleId: *rule.Id,
+			Key:    *rule.Key,
+			Action: *rule.Action,
+			Score:  *rule.Score,
+			Reason: reason,
+		})
+	}
+
+	if thresholds.Review > 0 && assessment.Score >= thresholds.Review {
+		assessment.Decision = escalate(assessment.Decision, REVIEW)
+	}
+
+	if thresholds.Deny > 0 && assessment.Score >= thresholds.Deny {
+		assessment.Decision = escalate(assessment.Decision, DENY)
+	}
+
+	if err := store.AddAssessment(ctx, assessment); err != nil {
+		return fmt.Errorf("failed to add risk assessment: %v", err), nil
+	}
+
+	return nil, assessment
+}
@@ -0,0 +1,391 @@
+package risk
+
+import (
+	"fmt"
+	"time"
+	"testing"
+	"github.com/serg666/gateway/bins"
+	"github.com/serg666/repository"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func integer(n int) *int {
+	return &n
+}
+
+// memoryRiskStore keeps rules, blocklist by kind and value, and the
+// average amount of payments. Assessments added are kept
+type memoryRiskStore struct {
+	rules       []*Rule
+	blocked     map[string]bool
+	count       int
+	average     float64
+	err         error
+	assessments []*Assessment
+}
+
+func (ms *memoryRiskStore) AddRule(ctx interface{}, rule *Rule) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryRiskStore) DeleteRule(ctx interface{}, rule *Rule) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryRiskStore) QueryRules(ctx interface{}, specification RuleSpecification) (error, int, []*Rule) {
+	return nil, len(ms.rules), ms.rules
+}
+
+func (ms *memoryRiskStore) Block(ctx interface{}, entry *BlocklistEntry) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryRiskStore) Unblock(ctx interface{}, entry *BlocklistEntry) (error, bool) {
+	return fmt.Errorf("not implemented"), false
+}
+
+func (ms *memoryRiskStore) QueryBlocklist(ctx interface{}, specification BlocklistSpecification) (error, int, []*BlocklistEntry) {
+	return fmt.Errorf("not implemented"), 0, nil
+}
+
+func (ms *memoryRiskStore) Blocked(ctx interface{}, kind string, value string) (error, bool) {
+	if ms.err != nil {
+		return ms.err, false
+	}
+
+	return nil, ms.blocked[kind+":"+value]
+}
+
+func (ms *memoryRiskStore) AverageAmount(ctx interface{}, profileId int, customer *string, since time.Time) (error, int, float64) {
+	if ms.err != nil {
+		return ms.err, 0, 0
+	}
+
+	return nil, ms.count, ms.average
+}
+
+func (ms *memoryRiskStore) AddAssessment(ctx interface{}, assessment *Assessment) error {
+	ms.assessments = append(ms.assessments, assessment)
+	return nil
+}
+
+func (ms *memoryRiskStore) Link(ctx interface{}, assessment *Assessment, transactionId int) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (ms *memoryRiskStore) QueryAssessments(ctx interface{}, specification AssessmentSpecification) (error, int, []*Assessment) {
+	return fmt.Errorf("not implemented"), 0, nil
+}
+
+func browserInfo() *repository.BrowserInfo {
+	return &repository.BrowserInfo{
+		IP:           "192.0.2.1",
+		UserAgent:    "Mozilla/5.0",
+		AcceptHeader: "text/html",
+		ScreenWidth:  integer(1920),
+		ScreenHeight: integer(1080),
+		ColorDepth:   integer(24),
+	}
+}
+
+func input() *Input {
+	return &Input{
+		Profile:     &repository.Profile{Id: integer(10)},
+		Customer:    str("Customer"),
+		Amount:      1000,
+		PANHash:     HashPAN("4111111111111111"),
+		BIN:         "411111",
+		Country:     str("RU"),
+		Card:        &bins.Bin{Brand: str(bins.VISA), Type: str(bins.PREPAID), Level: str("gold")},
+		BrowserInfo: browserInfo(),
+	}
+}
+
+func TestRules(t *testing.T) {
+	if Registered != nil {
+		t.Fatalf("rules are not registered: %v", Registered)
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		settings  map[string]interface{}
+		store     *memoryRiskStore
+		input     func(*Input)
+		triggered bool
+		reason    string
+		err       bool
+	}{
+		{
+			name:      "pan blocklisted",
+			key:       "blocklist_pan",
+			store:     &memoryRiskStore{blocked: map[string]bool{PANHASH + ":" + HashPAN("4111111111111111"): true}},
+			triggered: true,
+			reason:    "pan_hash is blocklisted",
+		},
+		{
+			name:  "pan not blocklisted",
+			key:   "blocklist_pan",
+			store: &memoryRiskStore{},
+		},
+		{
+			name:      "customer blocklisted in lower case",
+			key:       "blocklist_customer",
+			store:     &memoryRiskStore{blocked: map[string]bool{CUSTOMER + ":customer": true}},
+			triggered: true,
+			reason:    "customer is blocklisted",
+		},
+		{
+			name:  "ip unknown",
+			key:   "blocklist_ip",
+			store: &memoryRiskStore{blocked: map[string]bool{IP + ":": true}},
+			input: func(i *Input) { i.BrowserInfo = nil },
+		},
+		{
+			name:      "bin country blocklisted",
+			key:       "blocklist_bin_country",
+			store:     &memoryRiskStore{blocked: map[string]bool{BINCOUNTRY + ":ru": true}},
+			triggered: true,
+			reason:    "bin_country is blocklisted",
+		},
+		{
+			name:  "blocklist failed",
+			key:   "blocklist_pan",
+			store: &memoryRiskStore{err: fmt.Errorf("db is down")},
+			err:   true,
+		},
+		{
+			name:      "amount anomaly",
+			key:       "amount_anomaly",
+			store:     &memoryRiskStore{count: 10, average: 100},
+			triggered: true,
+			reason:    "amount 1000 exceeds average 100 by more than 5 times",
+		},
+		{
+			name:     "amount within factor",
+			key:      "amount_anomaly",
+			settings: map[string]interface{}{"factor": 10.0},
+			store:    &memoryRiskStore{count: 10, average: 100},
+		},
+		{
+			name:  "too few payments to compare",
+			key:   "amount_anomaly",
+			store: &memoryRiskStore{count: 4, average: 100},
+		},
+		{
+			name:     "wrong setting type",
+			key:      "amount_anomaly",
+			settings: map[string]interface{}{"factor": "5"},
+			store:    &memoryRiskStore{count: 10, average: 100},
+			err:      true,
+		},
+		{
+			name:  "browser info consistent",
+			key:   "browser_mismatch",
+			store: &memoryRiskStore{},
+		},
+		{
+			name:      "browser info missing",
+			key:       "browser_mismatch",
+			store:     &memoryRiskStore{},
+			input:     func(i *Input) { i.BrowserInfo = nil },
+			triggered: true,
+			reason:    "browser info is missing",
+		},
+		{
+			name:  "browser info inconsistent",
+			key:   "browser_mismatch",
+			store: &memoryRiskStore{},
+			input: func(i *Input) {
+				i.BrowserInfo.IP = "localhost"
+				i.BrowserInfo.UserAgent = " "
+				i.BrowserInfo.ScreenWidth = integer(0)
+			},
+			triggered: true,
+			reason:    "invalid ip, empty user agent, invalid screen size",
+		},
+		{
+			name:      "card type listed",
+			key:       "card_type",
+			settings:  map[string]interface{}{"types": []interface{}{"Prepaid"}},
+			store:     &memoryRiskStore{},
+			triggered: true,
+			reason:    "card type is prepaid",
+		},
+		{
+			name:      "card level listed",
+			key:       "card_type",
+			settings:  map[string]interface{}{"types": []interface{}{"credit"}, "levels": []interface{}{"gold"}},
+			store:     &memoryRiskStore{},
+			triggered: true,
+			reason:    "card level is gold",
+		},
+		{
+			name:     "card unknown",
+			key:      "card_type",
+			settings: map[string]interface{}{"types": []interface{}{"prepaid"}},
+			store:    &memoryRiskStore{},
+			input:    func(i *Input) { i.Card = nil },
+		},
+		{
+			name:     "card types of wrong type",
+			key:      "card_type",
+			settings: map[string]interface{}{"types": "prepaid"},
+			store:    &memoryRiskStore{},
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleFunc, ok := Rules[tt.key]
+			if !ok {
+				t.Fatalf("rule %s is not registered", tt.key)
+			}
+
+			in := input()
+			if tt.input != nil {
+				tt.input(in)
+			}
+
+			settings := tt.settings
+			if settings == nil {
+				settings = map[string]interface{}{}
+			}
+
+			err, triggered, reason := ruleFunc(nil, tt.store, settings, in)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("error is not returned")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("rule failed: %v", err)
+			}
+
+			if triggered != tt.triggered || reason != tt.reason {
+				t.Errorf("rule = %v %q, want %v %q", triggered, reason, tt.triggered, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rule := func(id int, key string, action string, score int) *Rule {
+		enabled := true
+		return &Rule{Id: &id, Key: &key, Action: &action, Score: &score, Enabled: &enabled}
+	}
+
+	// @note: browser info is missing, so browser_mismatch is triggered
+	// and blocklist_pan is not
+	tests := []struct {
+		name       string
+		rules      []*Rule
+		thresholds Thresholds
+		decision   string
+		score      int
+		triggered  int
+		err        bool
+	}{
+		{
+			name:     "no rules",
+			decision: ALLOW,
+		},
+		{
+			name:     "nothing triggered",
+			rules:    []*Rule{rule(1, "blocklist_pan", DENY, 100)},
+			decision: ALLOW,
+		},
+		{
+			name:      "action of triggered rule",
+			rules:     []*Rule{rule(1, "blocklist_pan", DENY, 100), rule(2, "browser_mismatch", FORCE3DS, 10)},
+			decision:  FORCE3DS,
+			score:     10,
+			triggered: 1,
+		},
+		{
+			name:       "escalated to review by score",
+			rules:      []*Rule{rule(2, "browser_mismatch", FORCE3DS, 60)},
+			thresholds: Thresholds{Review: 50, Deny: 100},
+			decision:   REVIEW,
+			score:      60,
+			triggered:  1,
+		},
+		{
+			name:       "escalated to deny by score",
+			rules:      []*Rule{rule(2, "browser_mismatch", ALLOW, 100), rule(3, "card_type", ALLOW, 20)},
+			thresholds: Thresholds{Review: 50, Deny: 100},
+			decision:   DENY,
+			score:      120,
+			triggered:  2,
+		},
+		{
+			name:       "threshold off",
+			rules:      []*Rule{rule(2, "browser_mismatch", ALLOW, 1000)},
+			thresholds: Thresholds{},
+			decision:   ALLOW,
+			score:      1000,
+			triggered:  1,
+		},
+		{
+			name:       "most severe action kept",
+			rules:      []*Rule{rule(2, "browser_mismatch", DENY, 0)},
+			thresholds: Thresholds{Review: 50},
+			decision:   DENY,
+			triggered:  1,
+		},
+		{
+			name:  "rule not registered",
+			rules: []*Rule{rule(4, "unknown", DENY, 100)},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryRiskStore{rules: tt.rules}
+
+			for _, r := range tt.rules {
+				if *r.Key == "card_type" {
+					r.Settings = &map[string]interface{}{"types": []interface{}{bins.PREPAID}}
+				}
+			}
+
+			in := input()
+			in.BrowserInfo = nil
+
+			err, assessment := Evaluate(nil, store, tt.thresholds, in)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("error is not returned")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+
+			if assessment.Decision != tt.decision || assessment.Score != tt.score || len(assessment.Rules) != tt.triggered {
+				t.Errorf(
+					"assessment = %s/%d/%d, want %s/%d/%d",
+					assessment.Decision,
+					assessment.Score,
+					len(assessment.Rules),
+					tt.decision,
+					tt.score,
+					tt.triggered,
+				)
+			}
+
+			if len(store.assessments) != 1 || store.assessments[0] != assessment {
+				t.Errorf("assessment is not stored")
+			}
+		})
+	}
+}
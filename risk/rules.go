@@ -0,0 +1,193 @@
+package risk
+
+import (
+	"fmt"
+	"net"
+	"time"
+	"strings"
+)
+
+// Blocklist kinds
+const (
+	PANHASH    = "pan_hash"
+	IP         = "ip"
+	CUSTOMER   = "customer"
+	BINCOUNTRY = "bin_country"
+)
+
+// Registered is the result of builtin rules registration
+var Registered = registerRules(map[string]RuleFunc{
+	"blocklist_pan":         blocklistRule(PANHASH, func(input *Input) *string { return &input.PANHash }),
+	"blocklist_ip":          blocklistRule(IP, ip),
+	"blocklist_customer":    blocklistRule(CUSTOMER, func(input *Input) *string { return input.Customer }),
+	"blocklist_bin_country": blocklistRule(BINCOUNTRY, func(input *Input) *string { return input.Country }),
+	"amount_anomaly":        amountAnomaly,
+	"browser_mismatch":      browserMismatch,
+	"card_type":             cardType,
+})
+
+func registerRules(rules map[string]RuleFunc) error {
+	for key, ruleFunc := range rules {
+		if err := RegisterRule(key, ruleFunc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ip(input *Input) *string {
+	if input.BrowserInfo == nil || input.BrowserInfo.IP == "" {
+		return nil
+	}
+
+	return &input.BrowserInfo.IP
+}
+
+// number will return numeric setting or default value
+func number(settings map[string]interface{}, key string, value float64) (error, float64) {
+	raw, ok := settings[key]
+	if !ok || raw == nil {
+		return nil, value
+	}
+
+	number, ok := raw.(float64)
+	if !ok {
+		return fmt.Errorf("setting %s has wrong type", key), 0
+	}
+
+	return nil, number
+}
+
+// blocklistRule is triggered when the value of the payment is blocklisted
+func blocklistRule(kind string, value func(*Input) *string) RuleFunc {
+	return func(ctx interface{}, store RiskRepository, settings map[string]interface{}, input *Input) (error, bool, string) {
+		v := value(input)
+		if v == nil || *v == "" {
+			return nil, false, ""
+		}
+
+		err, blocked := store.Blocked(ctx, kind, strings.ToLower(*v))
+		if err != nil {
+			return err, false, ""
+		}
+
+		if !blocked {
+			return nil, false, ""
+		}
+
+		return nil, true, fmt.Sprintf("%s is blocklisted", kind)
+	}
+}
+
+// amountAnomaly is triggered when the amount exceeds average successful
+// amount of the profile (or customer when "per_customer" is set) within
+// "days" by "factor" times. It is skipped until "min_count" payments are made
+func amountAnomaly(ctx interface{}, store RiskRepository, settings map[string]interface{}, input *Input) (error, bool, string) {
+	err, factor := number(settings, "factor", 5)
+	if err != nil {
+		return err, false, ""
+	}
+
+	err, days := number(settings, "days", 30)
+	if err != nil {
+		return err, false, ""
+	}
+
+	err, minCount := number(settings, "min_count", 5)
+	if err != nil {
+		return err, false, ""
+	}
+
+	var customer *string
+	if perCustomer, ok := settings["per_customer"].(bool); ok && perCustomer {
+		customer = input.Customer
+	}
+
+	since := time.Now().AddDate(0, 0, -int(days))
+	err, count, average := store.AverageAmount(ctx, *input.Profile.Id, customer, since)
+	if err != nil {
+		return err, false, ""
+	}
+
+	if float64(count) < minCount || average <= 0 {
+		return nil, false, ""
+	}
+
+	if float64(input.Amount) <= average*factor {
+		return nil, false, ""
+	}
+
+	return nil, true, fmt.Sprintf("amount %d exceeds average %.0f by more than %v times", input.Amount, average, factor)
+}
+
+// browserMismatch is triggered when browser data is missing or inconsistent
+func browserMismatch(ctx interface{}, store RiskRepository, settings map[string]interface{}, input *Input) (error, bool, string) {
+	var reasons []string
+	info := input.BrowserInfo
+
+	if info == nil {
+		return nil, true, "browser info is missing"
+	}
+
+	if net.ParseIP(info.IP) == nil {
+		reasons = append(reasons, "invalid ip")
+	}
+
+	if strings.TrimSpace(info.UserAgent) == "" {
+		reasons = append(reasons, "empty user agent")
+	}
+
+	if strings.TrimSpace(info.AcceptHeader) == "" {
+		reasons = append(reasons, "empty accept header")
+	}
+
+	if (info.ScreenWidth != nil && *info.ScreenWidth <= 0) || (info.ScreenHeight != nil && *info.ScreenHeight <= 0) {
+		reasons = append(reasons, "invalid screen size")
+	}
+
+	if info.ColorDepth != nil && *info.ColorDepth <= 0 {
+		reasons = append(reasons, "invalid color depth")
+	}
+
+	if len(reasons) == 0 {
+		return nil, false, ""
+	}
+
+	return nil, true, strings.Join(reasons, ", ")
+}
+
+// cardType is triggered when card type, brand or level of the BIN is listed
+// in "types", "brands" or "levels" settings
+func cardType(ctx interface{}, store RiskRepository, settings map[string]interface{}, input *Input) (error, bool, string) {
+	if input.Card == nil {
+		return nil, false, ""
+	}
+
+	for _, check := range []struct {
+		key   string
+		value *string
+	}{
+		{"types", input.Card.Type},
+		{"brands", input.Card.Brand},
+		{"levels", input.Card.Level},
+	} {
+		raw, ok := settings[check.key]
+		if !ok || raw == nil || check.value == nil {
+			continue
+		}
+
+		list, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("setting %s has wrong type", check.key), false, ""
+		}
+
+		for _, item := range list {
+			if value, ok := item.(string); ok && strings.EqualFold(value, *check.value) {
+				return nil, true, fmt.Sprintf("card %s is %s", strings.TrimSuffix(check.key, "s"), *check.value)
+			}
+		}
+	}
+
+	return nil, false, ""
+}
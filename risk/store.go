@@ -0,0 +1,422 @@
+package risk
+
+import (
+	"fmt"
+	"time"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+// BlocklistEntry is the blocked value of the kind. Values are kept in lower
+// case, PANs are kept as hashes
+type BlocklistEntry struct {
+	Id      *int       `json:"id"`
+	Kind    *string    `json:"kind"`
+	Value   *string    `json:"value"`
+	Comment *string    `json:"comment"`
+	Created *time.Time `json:"created"`
+}
+
+type RuleSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type ruleSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (rs *ruleSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id limit $1 offset $2", []interface{}{rs.limit, rs.offset}
+}
+
+func NewRuleSpecificationWithLimitAndOffset(limit int, offset int) RuleSpecification {
+	return &ruleSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type ruleSpecificationByID struct {
+	id int
+}
+
+func (rs *ruleSpecificationByID) ToSqlClauses() (string, []interface{}) {
+	return "where id=$1", []interface{}{rs.id}
+}
+
+func NewRuleSpecificationByID(id int) RuleSpecification {
+	return &ruleSpecificationByID{id: id}
+}
+
+type ruleSpecificationEnabledForProfile struct {
+	profileId int
+}
+
+func (rs *ruleSpecificationEnabledForProfile) ToSqlClauses() (string, []interface{}) {
+	return "where enabled and (profile_id is null or profile_id=$1) order by id", []interface{}{rs.profileId}
+}
+
+// NewRuleSpecificationEnabledForProfile will select enabled rules applied to
+// the profile
+func NewRuleSpecificationEnabledForProfile(profileId int) RuleSpecification {
+	return &ruleSpecificationEnabledForProfile{profileId: profileId}
+}
+
+type BlocklistSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type blocklistSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (bs *blocklistSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id desc limit $1 offset $2", []interface{}{bs.limit, bs.offset}
+}
+
+func NewBlocklistSpecificationWithLimitAndOffset(limit int, offset int) BlocklistSpecification {
+	return &blocklistSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type blocklistSpecificationByKindWithLimitAndOffset struct {
+	kind   string
+	limit  int
+	offset int
+}
+
+func (bs *blocklistSpecificationByKindWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "where kind=$1 order by id desc limit $2 offset $3", []interface{}{bs.kind, bs.limit, bs.offset}
+}
+
+func NewBlocklistSpecificationByKindWithLimitAndOffset(kind string, limit int, offset int) BlocklistSpecification {
+	return &blocklistSpecificationByKindWithLimitAndOffset{kind: kind, limit: limit, offset: offset}
+}
+
+type AssessmentSpecification interface {
+	ToSqlClauses() (string, []interface{})
+}
+
+type assessmentSpecificationByTransactionID struct {
+	id int
+}
+
+func (as *assessmentSpecificationByTransactionID) ToSqlClauses() (string, []interface{}) {
+	return "where transaction_id=$1", []interface{}{as.id}
+}
+
+func NewAssessmentSpecificationByTransactionID(id int) AssessmentSpecification {
+	return &assessmentSpecificationByTransactionID{id: id}
+}
+
+type assessmentSpecificationWithLimitAndOffset struct {
+	limit  int
+	offset int
+}
+
+func (as *assessmentSpecificationWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "order by id desc limit $1 offset $2", []interface{}{as.limit, as.offset}
+}
+
+func NewAssessmentSpecificationWithLimitAndOffset(limit int, offset int) AssessmentSpecification {
+	return &assessmentSpecificationWithLimitAndOffset{limit: limit, offset: offset}
+}
+
+type assessmentSpecificationByDecisionWithLimitAndOffset struct {
+	decision string
+	limit    int
+	offset   int
+}
+
+func (as *assessmentSpecificationByDecisionWithLimitAndOffset) ToSqlClauses() (string, []interface{}) {
+	return "where decision=$1 order by id desc limit $2 offset $3", []interface{}{as.decision, as.limit, as.offset}
+}
+
+func NewAssessmentSpecificationByDecisionWithLimitAndOffset(decision string, limit int, offset int) AssessmentSpecification {
+	return &assessmentSpecificationByDecisionWithLimitAndOffset{decision: decision, limit: limit, offset: offset}
+}
+
+type RiskRepository interface {
+	AddRule(ctx interface{}, rule *Rule) error
+	DeleteRule(ctx interface{}, rule *Rule) (error, bool)
+	QueryRules(ctx interface{}, specification RuleSpecification) (error, int, []*Rule)
+
+	Block(ctx interface{}, entry *BlocklistEntry) error
+	Unblock(ctx interface{}, entry *BlocklistEntry) (error, bool)
+	QueryBlocklist(ctx interface{}, specification BlocklistSpecification) (error, int, []*BlocklistEntry)
+	Blocked(ctx interface{}, kind string, value string) (error, bool)
+
+	// AverageAmount will return count and average amount of successful
+	// payments of the profile and optionally customer since the time
+	AverageAmount(ctx interface{}, profileId int, customer *string, since time.Time) (error, int, float64)
+
+	AddAssessment(ctx interface{}, assessment *Assessment) error
+	// Link will link the assessment to the transaction created for it
+	Link(ctx interface{}, assessment *Assessment, transactionId int) error
+	QueryAssessments(ctx interface{}, specification AssessmentSpecification) (error, int, []*Assessment)
+}
+
+type PGPoolRiskStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (rs *PGPoolRiskStore) AddRule(ctx interface{}, rule *Rule) error {
+	return rs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into risk_rules (profile_id, key, action, score, settings, enabled)
+		values ($1, $2, $3, $4, $5, $6) returning id`,
+		rule.ProfileId,
+		rule.Key,
+		rule.Action,
+		rule.Score,
+		rule.Settings,
+		rule.Enabled,
+	).Scan(&rule.Id)
+}
+
+func (rs *PGPoolRiskStore) DeleteRule(ctx interface{}, rule *Rule) (error, bool) {
+	ct, err := rs.pool.Exec(tracing.ContextOf(ctx), "delete from risk_rules where id=$1", rule.Id)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("risk rule with id=%d not found", *rule.Id), true
+	}
+
+	return nil, false
+}
+
+func (rs *PGPoolRiskStore) QueryRules(ctx interface{}, specification RuleSpecification) (error, int, []*Rule) {
+	var rules []*Rule
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select id, profile_id, key, action, score, settings, enabled, count(*) over()
+		from risk_rules %s`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := &Rule{}
+		if err := rows.Scan(
+			&rule.Id,
+			&rule.ProfileId,
+			&rule.Key,
+			&rule.Action,
+			&rule.Score,
+			&rule.Settings,
+			&rule.Enabled,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, rules
+}
+
+func (rs *PGPoolRiskStore) Block(ctx interface{}, entry *BlocklistEntry) error {
+	return rs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into risk_blocklist (kind, value, comment) values ($1, $2, $3)
+		on conflict (kind, value) do update set comment=excluded.comment
+		returning id, created`,
+		entry.Kind,
+		entry.Value,
+		entry.Comment,
+	).Scan(&entry.Id, &entry.Created)
+}
+
+func (rs *PGPoolRiskStore) Unblock(ctx interface{}, entry *BlocklistEntry) (error, bool) {
+	ct, err := rs.pool.Exec(tracing.ContextOf(ctx), "delete from risk_blocklist where id=$1", entry.Id)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("blocklist entry with id=%d not found", *entry.Id), true
+	}
+
+	return nil, false
+}
+
+func (rs *PGPoolRiskStore) QueryBlocklist(ctx interface{}, specification BlocklistSpecification) (error, int, []*BlocklistEntry) {
+	var entries []*BlocklistEntry
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf("select id, kind, value, comment, created, count(*) over() from risk_blocklist %s", where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := &BlocklistEntry{}
+		if err := rows.Scan(
+			&entry.Id,
+			&entry.Kind,
+			&entry.Value,
+			&entry.Comment,
+			&entry.Created,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, entries
+}
+
+func (rs *PGPoolRiskStore) Blocked(ctx interface{}, kind string, value string) (error, bool) {
+	var blocked bool
+
+	err := rs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		"select exists(select 1 from risk_blocklist where kind=$1 and value=$2)",
+		kind,
+		value,
+	).Scan(&blocked)
+
+	return err, blocked
+}
+
+func (rs *PGPoolRiskStore) AverageAmount(ctx interface{}, profileId int, customer *string, since time.Time) (error, int, float64) {
+	var count int
+	var average float64
+
+	err := rs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`select count(*), coalesce(avg(amount), 0)::float8 from transactions
+		where profile_id=$1 and ($2::text is null or customer=$2) and type=any($3) and status=$4 and created>=$5`,
+		profileId,
+		customer,
+		[]string{repository.AUTH, repository.PREAUTH, repository.REBILL},
+		repository.SUCCESS,
+		since,
+	).Scan(&count, &average)
+
+	return err, count, average
+}
+
+func (rs *PGPoolRiskStore) AddAssessment(ctx interface{}, assessment *Assessment) error {
+	return rs.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		`insert into risk_assessments (profile_id, order_id, customer, score, decision, rules)
+		values ($1, $2, $3, $4, $5, $6) returning id, created`,
+		assessment.ProfileId,
+		assessment.OrderId,
+		assessment.Customer,
+		assessment.Score,
+		assessment.Decision,
+		assessment.Rules,
+	).Scan(&assessment.Id, &assessment.Created)
+}
+
+func (rs *PGPoolRiskStore) Link(ctx interface{}, assessment *Assessment, transactionId int) error {
+	if _, err := rs.pool.Exec(
+		tracing.ContextOf(ctx),
+		"update risk_assessments set transaction_id=$2 where id=$1",
+		assessment.Id,
+		transactionId,
+	); err != nil {
+		return err
+	}
+
+	assessment.TransactionId = &transactionId
+	return nil
+}
+
+func (rs *PGPoolRiskStore) QueryAssessments(ctx interface{}, specification AssessmentSpecification) (error, int, []*Assessment) {
+	var assessments []*Assessment
+	var overall int
+
+	where, args := specification.ToSqlClauses()
+	rows, err := rs.pool.Query(
+		tracing.ContextOf(ctx),
+		fmt.Sprintf(`select
+			id,
+			created,
+			profile_id,
+			transaction_id,
+			order_id,
+			customer,
+			score,
+			decision,
+			rules,
+			count(*) over()
+		from risk_assessments %s`, where),
+		args...,
+	)
+	if err != nil {
+		return err, 0, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		assessment := &Assessment{}
+		if err := rows.Scan(
+			&assessment.Id,
+			&assessment.Created,
+			&assessment.ProfileId,
+			&assessment.TransactionId,
+			&assessment.OrderId,
+			&assessment.Customer,
+			&assessment.Score,
+			&assessment.Decision,
+			&assessment.Rules,
+			&overall,
+		); err != nil {
+			return err, 0, nil
+		}
+		assessments = append(assessments, assessment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err, 0, nil
+	}
+
+	return nil, overall, assessments
+}
+
+// QueryAssessment will return assessment of the transaction or nil
+func QueryAssessment(ctx interface{}, store RiskRepository, transactionId int) (error, *Assessment) {
+	err, _, assessments := store.QueryAssessments(ctx, NewAssessmentSpecificationByTransactionID(transactionId))
+	if err != nil {
+		return err, nil
+	}
+
+	if len(assessments) == 0 {
+		return nil, nil
+	}
+
+	return nil, assessments[0]
+}
+
+func NewPGPoolRiskStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) RiskRepository {
+	return &PGPoolRiskStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport will make the client span of outbound HTTP requests made in
+// a trace and propagate the trace context to the server
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// @note: requests made without context, like ones of the card store
+	// library, are not a part of any trace
+	if !trace.SpanContextFromContext(r.Context()).IsValid() {
+		return base.RoundTrip(r)
+	}
+
+	ctx, span := Tracer.Start(
+		r.Context(),
+		fmt.Sprintf("HTTP %s", r.Method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(r)...),
+	)
+	defer span.End()
+
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	res, err := base.RoundTrip(r)
+	if err != nil {
+		End(span, err)
+		return res, err
+	}
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(res.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(res.StatusCode, trace.SpanKindClient))
+
+	return res, nil
+}
+
+func (t *Transport) CloseIdleConnections() {
+	if ci, ok := t.Base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// Instrument will return the client tracing its requests. Clients already
+// traced are returned as is
+func Instrument(client *http.Client) *http.Client {
+	if _, ok := client.Transport.(*Transport); ok {
+		return client
+	}
+
+	return &http.Client{
+		Timeout:       client.Timeout,
+		Jar:           client.Jar,
+		CheckRedirect: client.CheckRedirect,
+		Transport:     &Transport{Base: client.Transport},
+	}
+}
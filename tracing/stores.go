@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/repository"
+)
+
+func request(ctx interface{}) *gin.Context {
+	c, _ := ctx.(*gin.Context)
+	return c
+}
+
+// cardStore makes spans of calls to the card store service
+type cardStore struct {
+	repository.CardRepository
+}
+
+func (cs *cardStore) Add(ctx interface{}, card *repository.Card) error {
+	end := Start(request(ctx), "cardstore.cards.add")
+	err := cs.CardRepository.Add(ctx, card)
+	end(err)
+	return err
+}
+
+func (cs *cardStore) Update(ctx interface{}, card *repository.Card) (error, bool) {
+	end := Start(request(ctx), "cardstore.cards.update")
+	err, notfound := cs.CardRepository.Update(ctx, card)
+	end(err)
+	return err, notfound
+}
+
+func (cs *cardStore) Delete(ctx interface{}, card *repository.Card) (error, bool) {
+	end := Start(request(ctx), "cardstore.cards.delete")
+	err, notfound := cs.CardRepository.Delete(ctx, card)
+	end(err)
+	return err, notfound
+}
+
+func (cs *cardStore) Query(ctx interface{}, specification repository.Specification) (error, int, []*repository.Card) {
+	end := Start(request(ctx), "cardstore.cards.query")
+	err, overall, cards := cs.CardRepository.Query(ctx, specification)
+	end(err)
+	return err, overall, cards
+}
+
+// CardStore will return the card store making spans of its calls
+func CardStore(store repository.CardRepository) repository.CardRepository {
+	return &cardStore{CardRepository: store}
+}
+
+// sessionStore makes spans of calls to the card store service sessions
+type sessionStore struct {
+	repository.SessionRepository
+}
+
+func (ss *sessionStore) Add(ctx interface{}, session *repository.Session) error {
+	end := Start(request(ctx), "cardstore.sessions.add")
+	err := ss.SessionRepository.Add(ctx, session)
+	end(err)
+	return err
+}
+
+func (ss *sessionStore) Update(ctx interface{}, session *repository.Session) (error, bool) {
+	end := Start(request(ctx), "cardstore.sessions.update")
+	err, notfound := ss.SessionRepository.Update(ctx, session)
+	end(err)
+	return err, notfound
+}
+
+func (ss *sessionStore) Delete(ctx interface{}, session *repository.Session) (error, bool) {
+	end := Start(request(ctx), "cardstore.sessions.delete")
+	err, notfound := ss.SessionRepository.Delete(ctx, session)
+	end(err)
+	return err, notfound
+}
+
+func (ss *sessionStore) Query(ctx interface{}, specification repository.Specification) (error, int, []*repository.Session) {
+	end := Start(request(ctx), "cardstore.sessions.query")
+	err, overall, sessions := ss.SessionRepository.Query(ctx, specification)
+	end(err)
+	return err, overall, sessions
+}
+
+// SessionStore will return the session store making spans of its calls
+func SessionStore(store repository.SessionRepository) repository.SessionRepository {
+	return &sessionStore{SessionRepository: store}
+}
@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"os"
+	"fmt"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/serg666/gateway/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters
+const (
+	OTLP = "otlp"
+	FILE = "file"
+)
+
+// Tracer starts spans of the gateway. It follows the provider installed
+// by Setup
+var Tracer = otel.Tracer("github.com/serg666/gateway")
+
+// Setup will install the tracer provider exporting spans as configured and
+// return the func flushing and stopping it. Trace context is propagated
+// even if tracing is off, so traces of callers are not broken
+func Setup(cfg *config.Config) (error, func(context.Context) error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var closer func() error
+
+	switch cfg.Tracing.Exporter {
+	case "":
+		return nil, func(context.Context) error { return nil }
+	case OTLP:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Tracing.Endpoint)}
+		if cfg.Tracing.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+
+		e, err := otlptracehttp.New(context.Background(), options...)
+		if err != nil {
+			return fmt.Errorf("can not make otlp exporter: %v", err), nil
+		}
+		exporter = e
+	case FILE:
+		// @note: spans are appended as JSON, one batch after another, so
+		// the file is readable in air-gapped setups without a collector
+		file, err := os.OpenFile(cfg.Tracing.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("can not open traces file: %v", err), nil
+		}
+
+		e, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("can not make file exporter: %v", err), nil
+		}
+		exporter = e
+		closer = file.Close
+	default:
+		return fmt.Errorf("unknown tracing exporter %s", cfg.Tracing.Exporter), nil
+	}
+
+	service := cfg.Tracing.Service
+	if service == "" {
+		service = "gateway"
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.Ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(service),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return nil, func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}
+}
+
+// Context will return the context spans of the request are started in.
+// Background contexts have no request
+func Context(c *gin.Context) context.Context {
+	if c == nil || c.Request == nil {
+		return context.Background()
+	}
+
+	return c.Request.Context()
+}
+
+// End will record the error of the span if any and end it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Start will start the span in the request. It is the parent of spans
+// started in the request until the returned func ends it
+func Start(c *gin.Context, name string, attributes ...attribute.KeyValue) func(error) {
+	parent := Context(c)
+	ctx, span := Tracer.Start(parent, name, trace.WithAttributes(attributes...))
+
+	if c == nil || c.Request == nil {
+		return func(err error) {
+			End(span, err)
+		}
+	}
+
+	c.Request = c.Request.WithContext(ctx)
+
+	return func(err error) {
+		End(span, err)
+		c.Request = c.Request.WithContext(parent)
+	}
+}
+
+// ContextOf will return the context of ctx given to stores: the request
+// context of gin context, the context itself or background context if
+// there is none
+func ContextOf(ctx interface{}) context.Context {
+	switch ctx := ctx.(type) {
+	case *gin.Context:
+		return Context(ctx)
+	case context.Context:
+		return ctx
+	}
+
+	return context.Background()
+}
@@ -0,0 +1,179 @@
+package webhooks
+
+import (
+	"fmt"
+	"time"
+	"bytes"
+	"net/http"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/serg666/gateway/client"
+	"github.com/serg666/gateway/tracing"
+	"github.com/serg666/repository"
+)
+
+// Attempts is the number of deliveries of the event before it is dropped
+const Attempts = 3
+
+// SignatureHeader carries hex encoded HMAC-SHA256 of the body keyed with
+// the webhook secret
+const SignatureHeader = "X-Gateway-Signature"
+
+// Webhook is the merchant endpoint events of the profile are delivered to
+type Webhook struct {
+	ProfileId *int    `json:"profile_id"`
+	Url       *string `json:"url"`
+	Secret    *string `json:"-"`
+}
+
+// Event is the body of the webhook request
+type Event struct {
+	Event   string      `json:"event"`
+	Created time.Time   `json:"created"`
+	Data    interface{} `json:"data"`
+}
+
+type WebhookRepository interface {
+	// Set will set the webhook of the profile replacing previous one
+	Set(ctx interface{}, webhook *Webhook) error
+	Delete(ctx interface{}, profileId int) (error, bool)
+	// Get will return webhook of the profile or nil
+	Get(ctx interface{}, profileId int) (error, *Webhook)
+}
+
+type PGPoolWebhookStore struct {
+	pool       *pgxpool.Pool
+	loggerFunc repository.LoggerFunc
+}
+
+func (ws *PGPoolWebhookStore) Set(ctx interface{}, webhook *Webhook) error {
+	_, err := ws.pool.Exec(
+		tracing.ContextOf(ctx),
+		`insert into webhooks (profile_id, url, secret) values ($1, $2, $3)
+		on conflict (profile_id) do update set url=excluded.url, secret=excluded.secret`,
+		webhook.ProfileId,
+		webhook.Url,
+		webhook.Secret,
+	)
+
+	return err
+}
+
+func (ws *PGPoolWebhookStore) Delete(ctx interface{}, profileId int) (error, bool) {
+	ct, err := ws.pool.Exec(tracing.ContextOf(ctx), "delete from webhooks where profile_id=$1", profileId)
+	if err != nil {
+		return err, false
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("profile <%d> has got no webhook", profileId), true
+	}
+
+	return nil, false
+}
+
+func (ws *PGPoolWebhookStore) Get(ctx interface{}, profileId int) (error, *Webhook) {
+	webhook := &Webhook{}
+
+	err := ws.pool.QueryRow(
+		tracing.ContextOf(ctx),
+		"select profile_id, url, secret from webhooks where profile_id=$1",
+		profileId,
+	).Scan(&webhook.ProfileId, &webhook.Url, &webhook.Secret)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return err, nil
+	}
+
+	return nil, webhook
+}
+
+func NewPGPoolWebhookStore(pool *pgxpool.Pool, loggerFunc repository.LoggerFunc) WebhookRepository {
+	return &PGPoolWebhookStore{
+		pool:       pool,
+		loggerFunc: loggerFunc,
+	}
+}
+
+// Sign will return signature of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver will post the event to the webhook once
+func Deliver(webhook *Webhook, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, *webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != nil {
+		req.Header.Set(SignatureHeader, Sign(*webhook.Secret, body))
+	}
+
+	res, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %d", *webhook.Url, res.StatusCode)
+	}
+
+	return nil
+}
+
+// Notify will deliver the event to the webhook of the profile in background.
+// Failed deliveries are retried with growing delay. Profiles without webhook
+// are not notified
+func Notify(store WebhookRepository, loggerFunc repository.LoggerFunc, profileId int, event string, data interface{}) {
+	go func() {
+		log := loggerFunc(nil)
+
+		err, webhook := store.Get(nil, profileId)
+		if err != nil {
+			log.Errorf("can not get webhook of profile <%d>: %v", profileId, err)
+			return
+		}
+
+		if webhook == nil {
+			return
+		}
+
+		body, err := json.Marshal(&Event{
+			Event:   event,
+			Created: time.Now(),
+			Data:    data,
+		})
+		if err != nil {
+			log.Errorf("can not marshal %s event: %v", event, err)
+			return
+		}
+
+		for attempt := 1; attempt <= Attempts; attempt++ {
+			if err = Deliver(webhook, body); err == nil {
+				log.Printf("%s event delivered to profile <%d>", event, profileId)
+				return
+			}
+
+			log.Warningf("%s event delivery to profile <%d> failed (attempt %d): %v", event, profileId, attempt, err)
+			if attempt < Attempts {
+				time.Sleep(time.Duration(attempt*attempt) * 10 * time.Second)
+			}
+		}
+
+		log.Errorf("%s event to profile <%d> dropped after %d attempts", event, profileId, Attempts)
+	}()
+}